@@ -0,0 +1,135 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"slices"
+	"strconv"
+)
+
+// AccountSummary holds the headline figures of an account. Amounts are in
+// BaseCurrency, except for Cash.
+type AccountSummary struct {
+	Account        string
+	BaseCurrency   string
+	NetLiquidation float64
+	TotalCashValue float64
+	// Cash is the settled cash balance per currency.
+	Cash            map[string]float64
+	BuyingPower     float64
+	AvailableFunds  float64
+	ExcessLiquidity float64
+	InitMarginReq   float64
+	MaintMarginReq  float64
+}
+
+var summaryTags = []string{
+	"NetLiquidation",
+	"TotalCashValue",
+	"BuyingPower",
+	"AvailableFunds",
+	"ExcessLiquidity",
+	"InitMarginReq",
+	"MaintMarginReq",
+	"$LEDGER:ALL",
+}
+
+// GetAccountSummary reads the summary of the login's default account through
+// the TWS API.
+func (dock *Dock) GetAccountSummary(ctx context.Context) (_ AccountSummary, err error) {
+	defer dock.redactError(&err)
+	return dock.GetAccountSummaryFor(ctx, "")
+}
+
+// GetAccountSummaryFor is GetAccountSummary of one of the accounts the login
+// manages, see ListAccounts. An empty account is the login's default one.
+func (dock *Dock) GetAccountSummaryFor(ctx context.Context, account string) (_ AccountSummary, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, account)
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return AccountSummary{}, err
+	}
+	defer client.Close()
+	account, err = selectAccount(client.Accounts, account)
+	if err != nil {
+		return AccountSummary{}, err
+	}
+	values, err := client.AccountSummary(ctx, "All", summaryTags...)
+	if err != nil {
+		return AccountSummary{}, classifyPacing(err)
+	}
+	return parseAccountSummary(account, values), nil
+}
+
+// ListAccounts lists the accounts the login manages, the default one first.
+// A Financial Advisor login manages its sub-accounts, other logins one
+// account.
+func (dock *Dock) ListAccounts(ctx context.Context) (_ []string, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if len(client.Accounts) == 0 {
+		return nil, errors.New("gateway reports no accounts")
+	}
+	return client.Accounts, nil
+}
+
+// selectAccount checks that account is one of managed, or picks the default
+// one if it is empty.
+func selectAccount(managed []string, account string) (string, error) {
+	switch {
+	case len(managed) == 0:
+		return "", errors.New("gateway reports no accounts")
+	case account == "":
+		return managed[0], nil
+	case !slices.Contains(managed, account):
+		return "", fmt.Errorf("account %s is not managed by this login, which manages %v", account, managed)
+	}
+	return account, nil
+}
+
+func parseAccountSummary(account string, values []twsapi.SummaryValue) AccountSummary {
+	summary := AccountSummary{Account: account, Cash: make(map[string]float64)}
+	fields := map[string]*float64{
+		"NetLiquidation":  &summary.NetLiquidation,
+		"TotalCashValue":  &summary.TotalCashValue,
+		"BuyingPower":     &summary.BuyingPower,
+		"AvailableFunds":  &summary.AvailableFunds,
+		"ExcessLiquidity": &summary.ExcessLiquidity,
+		"InitMarginReq":   &summary.InitMarginReq,
+		"MaintMarginReq":  &summary.MaintMarginReq,
+	}
+	for _, value := range values {
+		if value.Account != account {
+			continue
+		}
+		amount, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			continue
+		}
+		if value.Tag == "CashBalance" {
+			// $LEDGER:ALL also reports the total converted to the base
+			// currency, under the pseudo-currency BASE.
+			if value.Currency != "BASE" {
+				summary.Cash[value.Currency] = amount
+			}
+			continue
+		}
+		if field, ok := fields[value.Tag]; ok {
+			*field = amount
+			if value.Tag == "NetLiquidation" {
+				summary.BaseCurrency = value.Currency
+			}
+		}
+	}
+	return summary
+}
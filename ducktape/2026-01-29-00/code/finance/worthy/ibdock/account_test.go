@@ -0,0 +1,39 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"testing"
+)
+
+func TestParseAccountSummary(t *testing.T) {
+	summary := parseAccountSummary("U1111111", []twsapi.SummaryValue{
+		{Account: "U1111111", Tag: "NetLiquidation", Value: "104250.12", Currency: "EUR"},
+		{Account: "U1111111", Tag: "BuyingPower", Value: "50000", Currency: "EUR"},
+		{Account: "U1111111", Tag: "CashBalance", Value: "1200.5", Currency: "USD"},
+		{Account: "U1111111", Tag: "CashBalance", Value: "-300", Currency: "CZK"},
+		{Account: "U1111111", Tag: "CashBalance", Value: "1100", Currency: "BASE"},
+		{Account: "U2222222", Tag: "NetLiquidation", Value: "1", Currency: "USD"},
+	})
+	if summary.NetLiquidation != 104250.12 || summary.BaseCurrency != "EUR" || summary.BuyingPower != 50000 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if len(summary.Cash) != 2 || summary.Cash["USD"] != 1200.5 || summary.Cash["CZK"] != -300 {
+		t.Errorf("Cash = %v", summary.Cash)
+	}
+}
+
+func TestSelectAccount(t *testing.T) {
+	managed := []string{"F1111111", "U2222222", "U3333333"}
+	if got, err := selectAccount(managed, ""); err != nil || got != "F1111111" {
+		t.Errorf("selectAccount(default) = %q, %v", got, err)
+	}
+	if got, err := selectAccount(managed, "U3333333"); err != nil || got != "U3333333" {
+		t.Errorf("selectAccount(U3333333) = %q, %v", got, err)
+	}
+	if _, err := selectAccount(managed, "U4444444"); err == nil {
+		t.Errorf("selectAccount of an unmanaged account succeeded")
+	}
+	if _, err := selectAccount(nil, ""); err == nil {
+		t.Errorf("selectAccount with no accounts succeeded")
+	}
+}
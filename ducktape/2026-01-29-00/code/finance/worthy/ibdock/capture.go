@@ -0,0 +1,144 @@
+package ibdock
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+)
+
+// defaultMaxCaptureBytes is how much of each stream an exec collects unless
+// ExecOptions.MaxCaptureBytes says otherwise.
+const defaultMaxCaptureBytes = 16 << 20
+
+// captureLimit is the cap of opts, 0 for none.
+func captureLimit(opts ExecOptions) int64 {
+	switch {
+	case opts.MaxCaptureBytes < 0:
+		return 0
+	case opts.MaxCaptureBytes == 0:
+		return defaultMaxCaptureBytes
+	}
+	return opts.MaxCaptureBytes
+}
+
+// captureBuffer collects output up to limit bytes: past that, it keeps the
+// first and the last half of the limit, dropping what is in between. Writes
+// always succeed, so the command is never held up by it.
+type captureBuffer struct {
+	limit int64
+	head  bytes.Buffer
+	// tail is a ring of the last limit/2 bytes once the head is full, next
+	// the position to write at.
+	tail    []byte
+	next    int
+	wrapped bool
+	total   int64
+}
+
+func (c *captureBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	c.total += int64(n)
+	if c.limit <= 0 {
+		c.head.Write(p)
+		return n, nil
+	}
+	headLimit := int(c.limit - c.limit/2)
+	if room := headLimit - c.head.Len(); room > 0 {
+		take := min(room, len(p))
+		c.head.Write(p[:take])
+		p = p[take:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	if c.tail == nil {
+		c.tail = make([]byte, c.limit/2)
+	}
+	if len(c.tail) == 0 {
+		return n, nil
+	}
+	if len(p) >= len(c.tail) {
+		copy(c.tail, p[len(p)-len(c.tail):])
+		c.next, c.wrapped = 0, true
+		return n, nil
+	}
+	copied := copy(c.tail[c.next:], p)
+	if copied < len(p) {
+		copy(c.tail, p[copied:])
+		c.wrapped = true
+	}
+	c.next = (c.next + len(p)) % len(c.tail)
+	if c.next == 0 {
+		c.wrapped = true
+	}
+	return n, nil
+}
+
+// dropped is how many bytes were left out.
+func (c *captureBuffer) dropped() int64 {
+	kept := int64(c.head.Len())
+	if c.wrapped {
+		kept += int64(len(c.tail))
+	} else {
+		kept += int64(c.next)
+	}
+	return c.total - kept
+}
+
+// Bytes returns what was collected, with a line marking how much was left
+// out between the head and the tail.
+func (c *captureBuffer) Bytes() []byte {
+	if c.tail == nil {
+		return c.head.Bytes()
+	}
+	var b bytes.Buffer
+	b.Write(c.head.Bytes())
+	if dropped := c.dropped(); dropped > 0 {
+		fmt.Fprintf(&b, "\n[... %d bytes truncated ...]\n", dropped)
+	}
+	if c.wrapped {
+		b.Write(c.tail[c.next:])
+	}
+	b.Write(c.tail[:c.next])
+	return b.Bytes()
+}
+
+// record adds a chunk to the recorder, keeping the first chunks up to half
+// its limit and the last ones up to the other half, splitting chunks at the
+// edges; the recorder's mutex must be held.
+func (r *outputRecorder) record(stream Stream, p []byte) {
+	now := clock.OrReal(r.clock).Now()
+	if r.limit <= 0 {
+		r.output = append(r.output, OutputChunk{Time: now, Stream: stream, Data: bytes.Clone(p)})
+		return
+	}
+	if room := r.limit - r.limit/2 - r.headBytes; room > 0 {
+		take := min(room, int64(len(p)))
+		r.output = append(r.output, OutputChunk{Time: now, Stream: stream, Data: bytes.Clone(p[:take])})
+		r.headBytes += take
+		p = p[take:]
+	}
+	if len(p) == 0 {
+		return
+	}
+	tailLimit := r.limit / 2
+	if int64(len(p)) > tailLimit {
+		r.dropped += int64(len(p)) - tailLimit
+		p = p[int64(len(p))-tailLimit:]
+		if len(p) == 0 {
+			return
+		}
+	}
+	r.tail = append(r.tail, OutputChunk{Time: now, Stream: stream, Data: bytes.Clone(p)})
+	r.tailBytes += int64(len(p))
+	for r.tailBytes > tailLimit {
+		first := &r.tail[0]
+		excess := min(r.tailBytes-tailLimit, int64(len(first.Data)))
+		first.Data = first.Data[excess:]
+		r.dropped += excess
+		r.tailBytes -= excess
+		if len(first.Data) == 0 {
+			r.tail = r.tail[1:]
+		}
+	}
+}
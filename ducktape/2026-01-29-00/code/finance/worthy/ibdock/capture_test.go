@@ -0,0 +1,71 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCaptureBuffer(t *testing.T) {
+	for _, writes := range [][]string{
+		{"0123456789abcdefghij"},
+		{"0123", "456789abcd", "efg", "hij"},
+		{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j"},
+	} {
+		c := captureBuffer{limit: 8}
+		for _, w := range writes {
+			if n, err := c.Write([]byte(w)); n != len(w) || err != nil {
+				t.Errorf("Write(%q) = %d, %v", w, n, err)
+			}
+		}
+		if got, want := string(c.Bytes()), "0123\n[... 12 bytes truncated ...]\nghij"; got != want || c.dropped() != 12 {
+			t.Errorf("writes %q: captured %q, dropped %d, want %q", writes, got, c.dropped(), want)
+		}
+	}
+	c := captureBuffer{limit: 8}
+	c.Write([]byte("01234567"))
+	if got := string(c.Bytes()); got != "01234567" || c.dropped() != 0 {
+		t.Errorf("output at the limit captured as %q", got)
+	}
+	var recorder outputRecorder
+	recorder.limit = 4
+	for _, data := range []string{"a", "b", "c", "d", "e", "f"} {
+		recorder.record(StreamStdout, []byte(data))
+	}
+	output, dropped := recorder.recorded()
+	var joined bytes.Buffer
+	for _, chunk := range output {
+		joined.Write(chunk.Data)
+	}
+	if joined.String() != "abef" || dropped != 2 {
+		t.Errorf("recorded %q, dropped %d", joined.String(), dropped)
+	}
+}
+
+func TestMaxCaptureBytes(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	runaway := strings.Repeat("x", 1000) + "end"
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stdout: []byte(runaway)}
+	})
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := dock.Exec(context.Background(), []string{"yes"}, ExecOptions{MaxCaptureBytes: 100, Interleaved: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(result.Stdout, []byte("end")) || !bytes.Contains(result.Stdout, []byte("bytes truncated")) ||
+		result.StdoutTruncated != 903 || result.OutputTruncated == 0 || !result.Report.Truncated || result.Report.StdoutBytes != 1003 {
+		t.Errorf("result %q, truncated %d, %d, report %+v", result.Stdout, result.StdoutTruncated, result.OutputTruncated, result.Report)
+	}
+	if result, err = dock.Exec(context.Background(), []string{"yes"}, ExecOptions{MaxCaptureBytes: -1}); string(result.Stdout) != runaway || err != nil {
+		t.Errorf("uncapped exec captured %d bytes, %v", len(result.Stdout), err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package clock is the time ibdock polls, waits out timeouts and backs off
+// by, so that tests can swap in a Fake and skip through minutes of it:
+//
+//	fake := clock.NewFake(time.Now())
+//	dock, err := ibdock.StartNew(user, password, logger, ibdock.WithClock(fake))
+//	go dock.GetSnapshot(ctx)
+//	fake.BlockUntil(2) // the exec's poll and its timeout
+//	fake.Advance(5 * time.Minute)
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits for it.
+type Clock interface {
+	Now() time.Time
+	// After is time.After. It is also how ibdock sleeps, in a select with
+	// the context's Done.
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is a time.Ticker of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the system clock.
+var Real Clock = realClock{}
+
+// OrReal returns c, or Real if c is nil, for configuration where a nil
+// Clock means the default.
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+// Fake is a Clock that only moves when told to. Its methods are safe for
+// concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+	// changed is closed and replaced whenever a waiter is added.
+	changed chan struct{}
+}
+
+// waiter is a pending After, or a Ticker if period is set.
+type waiter struct {
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.add(&waiter{at: f.now.Add(d), c: c})
+	return c
+}
+
+// NewTicker panics if d is not positive, as time.NewTicker does.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &waiter{at: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.add(w)
+	return fakeTicker{f, w}
+}
+
+func (f *Fake) add(w *waiter) {
+	f.waiters = append(f.waiters, w)
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// remove drops w from the pending waiters; f.mu must be held.
+func (f *Fake) remove(w *waiter) {
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Advance moves the clock d ahead, firing what falls due in order. Like
+// those of time.Ticker, ticks nobody took in time are dropped.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for {
+		var next *waiter
+		for _, w := range f.waiters {
+			if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+		f.now = next.at
+		select {
+		case next.c <- f.now:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			f.remove(next)
+		}
+	}
+	f.now = end
+}
+
+// BlockUntil waits until at least n Afters and Tickers are pending, i.e. the
+// code under test got to waiting, so that Advance is sure to wake it. Afters
+// whose channel was abandoned unread count until they fire.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		pending, changed := len(f.waiters), f.changed
+		f.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *waiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.waiter.c }
+
+func (t fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.waiter)
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake := NewFake(start)
+	after := fake.After(time.Minute)
+	ticker := fake.NewTicker(20 * time.Second)
+	fake.Advance(30 * time.Second)
+	if got := <-ticker.C(); !got.Equal(start.Add(20 * time.Second)) {
+		t.Errorf("first tick at %v", got)
+	}
+	select {
+	case <-after:
+		t.Errorf("After(1m) fired 30s in")
+	default:
+	}
+	fake.Advance(5 * time.Minute)
+	if got := <-after; !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("After(1m) fired at %v", got)
+	}
+	// The ticks of the 5 minutes went unread but the last.
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Errorf("dropped ticks were kept")
+	default:
+	}
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Errorf("stopped ticker ticked")
+	default:
+	}
+	if now := fake.Now(); !now.Equal(start.Add(30*time.Second + 5*time.Minute + time.Hour)) {
+		t.Errorf("Now = %v", now)
+	}
+	if got := <-fake.After(0); !got.Equal(fake.Now()) {
+		t.Errorf("After(0) = %v", got)
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	fake := NewFake(time.Now())
+	done := make(chan struct{})
+	go func() {
+		<-fake.After(time.Hour)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(time.Hour)
+	<-done
+}
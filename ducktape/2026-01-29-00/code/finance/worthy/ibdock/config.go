@@ -0,0 +1,334 @@
+package ibdock
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config describes the sessions to run and how, as read by LoadConfig:
+//
+//	image: agentydragon/ibcontroller:tws1030-ibc3.20.0-local
+//	variant: gateway
+//	platform: linux/amd64
+//	mode: paper
+//	snapshot_timeout: 10m
+//	auto_restart: 3
+//	session_conflict: take_over
+//	docker:
+//	  endpoint: tcp://docker-host:2376
+//	resources:
+//	  memory_mb: 3072
+//	  restart_policy: on-failure:2
+//	network:
+//	  name: brokers
+//	  bind_address: 127.0.0.1
+//	rate_limit:
+//	  rate: 0.5
+//	  burst: 5
+//	accounts:
+//	  - name: main
+//	    username: jdoe
+//	    password: ...
+//	    api_port: 7496
+//	    settings_volume: ib-main
+//	stress:
+//	  base: EUR
+//	  fx_rates: {USD: 0.92}
+//	  scenarios:
+//	    - name: equities -20%
+//	      shocks: [{sec_type: STK, change: -0.2}]
+//	reports:
+//	  summary: "{{.Account}}: {{money .Total}} {{.Base}}"
+type Config struct {
+	// Image, Variant, Platform, Mode and SnapshotTimeout default to the Dock
+	// defaults if empty, see WithImage, WithVariant, WithPlatform,
+	// WithTradingMode and WithSnapshotTimeout.
+	Image           string          `yaml:"image" toml:"image"`
+	Variant         Variant         `yaml:"variant" toml:"variant"`
+	Platform        string          `yaml:"platform" toml:"platform"`
+	Mode            string          `yaml:"mode" toml:"mode"`
+	SnapshotTimeout time.Duration   `yaml:"snapshot_timeout" toml:"snapshot_timeout"`
+	Docker          DockerConfig    `yaml:"docker" toml:"docker"`
+	Resources       ResourceConfig  `yaml:"resources" toml:"resources"`
+	Network         NetworkConfig   `yaml:"network" toml:"network"`
+	RateLimit       RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Accounts        []AccountConfig `yaml:"accounts" toml:"accounts"`
+	Stress          StressConfig    `yaml:"stress" toml:"stress"`
+	// AutoRestart is the restart budget of each session, see
+	// WithAutoRestart.
+	AutoRestart int `yaml:"auto_restart" toml:"auto_restart"`
+	// SessionConflict is "fail" (the default) or "take_over", see
+	// WithSessionConflict.
+	SessionConflict SessionConflict `yaml:"session_conflict" toml:"session_conflict"`
+	// AccountLock keeps two sessions of one IB login from running at once,
+	// see WithAccountLock.
+	AccountLock bool `yaml:"account_lock" toml:"account_lock"`
+	// Reports are text/template layouts by name, see the report package.
+	Reports map[string]string `yaml:"reports" toml:"reports"`
+}
+
+type DockerConfig struct {
+	// Endpoint defaults to DOCKER_HOST, see WithDockerEndpoint.
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	// TLS file paths, see WithDockerTLS.
+	TLSCert string `yaml:"tls_cert" toml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key" toml:"tls_key"`
+	TLSCA   string `yaml:"tls_ca" toml:"tls_ca"`
+	// SSHIdentity is the key for ssh:// endpoints, see WithDockerSSHIdentity.
+	SSHIdentity string `yaml:"ssh_identity" toml:"ssh_identity"`
+	// Legacy selects the go-dockerclient backend, see
+	// WithLegacyDockerClient.
+	Legacy bool `yaml:"legacy" toml:"legacy"`
+}
+
+// ResourceConfig limits the session containers. Zero values mean the Dock
+// defaults and negative ones no limit, see WithMemoryLimit, WithCPUShares,
+// WithPidsLimit and WithRestartPolicy.
+type ResourceConfig struct {
+	MemoryMB      int64  `yaml:"memory_mb" toml:"memory_mb"`
+	CPUShares     int64  `yaml:"cpu_shares" toml:"cpu_shares"`
+	PidsLimit     int64  `yaml:"pids_limit" toml:"pids_limit"`
+	RestartPolicy string `yaml:"restart_policy" toml:"restart_policy"`
+}
+
+// NetworkConfig places the session containers on the network, see
+// WithNetwork and WithBindAddress. The host port of each account's session
+// is AccountConfig.APIPort.
+type NetworkConfig struct {
+	Name        string `yaml:"name" toml:"name"`
+	BindAddress string `yaml:"bind_address" toml:"bind_address"`
+	// PublishedHost is where to reach the published ports, see
+	// WithPublishedHost.
+	PublishedHost string `yaml:"published_host" toml:"published_host"`
+}
+
+// RateLimitConfig paces each session's calls, see WithRateLimit. Zero values
+// mean the Dock defaults and a negative Rate no limit.
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+// StressConfig defines the scenarios snapshots are stress tested under, see
+// snapshot.Stress. Stress tests make no gateway calls, so FXRates gives the
+// value in Base of each other currency held.
+type StressConfig struct {
+	Base      string              `yaml:"base" toml:"base"`
+	FXRates   map[string]float64  `yaml:"fx_rates" toml:"fx_rates"`
+	Scenarios []snapshot.Scenario `yaml:"scenarios" toml:"scenarios"`
+}
+
+// Rates returns FXRates with Base added.
+func (config StressConfig) Rates() snapshot.FXRates {
+	rates := snapshot.FXRates{config.Base: 1}
+	for currency, rate := range config.FXRates {
+		rates[currency] = rate
+	}
+	return rates
+}
+
+type AccountConfig struct {
+	// Name identifies the account's session, see WithSessionName.
+	Name     string `yaml:"name" toml:"name"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	// APIPort, if set, is the host port of the session's TWS API, see
+	// WithAPIPort.
+	APIPort int `yaml:"api_port" toml:"api_port"`
+	// SettingsVolume, if set, keeps the session's gateway settings, see
+	// WithSettingsVolume.
+	SettingsVolume string `yaml:"settings_volume" toml:"settings_volume"`
+}
+
+// LoadConfig reads a YAML (.yaml, .yml) or TOML (.toml) config file and
+// applies environment overrides: IBDOCK_IMAGE, IBDOCK_MODE,
+// IBDOCK_SNAPSHOT_TIMEOUT, IBDOCK_DOCKER_ENDPOINT, IBDOCK_DOCKER_TLS_CERT,
+// IBDOCK_DOCKER_TLS_KEY, IBDOCK_DOCKER_TLS_CA and IBDOCK_DOCKER_SSH_IDENTITY
+// for the settings above, IBDOCK_<NAME>_USERNAME and IBDOCK_<NAME>_PASSWORD
+// for each account, with NAME upper-cased and dashes turned into
+// underscores. Keeping passwords in the environment keeps them out of the
+// file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, config)
+	case ".toml":
+		err = toml.Unmarshal(data, config)
+	default:
+		return nil, fmt.Errorf("config %s: unknown format, want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (config *Config) applyEnv(lookup func(string) (string, bool)) error {
+	override := func(key string, field *string) {
+		if v, ok := lookup(key); ok {
+			*field = v
+		}
+	}
+	override("IBDOCK_IMAGE", &config.Image)
+	if v, ok := lookup("IBDOCK_VARIANT"); ok {
+		config.Variant = Variant(v)
+	}
+	override("IBDOCK_PLATFORM", &config.Platform)
+	override("IBDOCK_MODE", &config.Mode)
+	override("IBDOCK_DOCKER_ENDPOINT", &config.Docker.Endpoint)
+	override("IBDOCK_DOCKER_TLS_CERT", &config.Docker.TLSCert)
+	override("IBDOCK_DOCKER_TLS_KEY", &config.Docker.TLSKey)
+	override("IBDOCK_DOCKER_TLS_CA", &config.Docker.TLSCA)
+	override("IBDOCK_DOCKER_SSH_IDENTITY", &config.Docker.SSHIdentity)
+	if v, ok := lookup("IBDOCK_SNAPSHOT_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("IBDOCK_SNAPSHOT_TIMEOUT: %w", err)
+		}
+		config.SnapshotTimeout = timeout
+	}
+	for i := range config.Accounts {
+		account := &config.Accounts[i]
+		prefix := "IBDOCK_" + strings.ToUpper(strings.ReplaceAll(account.Name, "-", "_")) + "_"
+		override(prefix+"USERNAME", &account.Username)
+		override(prefix+"PASSWORD", &account.Password)
+	}
+	if err := config.Variant.check(); err != nil {
+		return err
+	}
+	if config.Platform != "" {
+		if err := checkPlatform(config.Platform); err != nil {
+			return err
+		}
+	}
+	switch config.Mode {
+	case "", "live", "paper":
+	default:
+		return fmt.Errorf("mode %q, want live or paper", config.Mode)
+	}
+	if _, err := config.SessionConflict.ibcAction(); err != nil {
+		return err
+	}
+	if policy := config.Resources.RestartPolicy; policy != "" {
+		if _, err := parseRestartPolicy(policy); err != nil {
+			return err
+		}
+	}
+	for _, account := range config.Accounts {
+		if err := checkBinding(account.APIPort, config.Network.BindAddress); err != nil {
+			return fmt.Errorf("account %s: %w", account.Name, err)
+		}
+	}
+	return nil
+}
+
+// Account returns the named account.
+func (config *Config) Account(name string) (AccountConfig, error) {
+	for _, account := range config.Accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+	return AccountConfig{}, fmt.Errorf("no account %q in config", name)
+}
+
+// Options returns the options for starting or attaching to the session of
+// account.
+func (config *Config) Options(account AccountConfig) []Option {
+	opts := []Option{WithSessionName(account.Name)}
+	if config.Image != "" {
+		opts = append(opts, WithImage(config.Image))
+	}
+	if config.Variant != "" {
+		opts = append(opts, WithVariant(config.Variant))
+	}
+	if config.Platform != "" {
+		opts = append(opts, WithPlatform(config.Platform))
+	}
+	if config.Mode != "" {
+		opts = append(opts, WithTradingMode(config.Mode))
+	}
+	if config.SnapshotTimeout > 0 {
+		opts = append(opts, WithSnapshotTimeout(config.SnapshotTimeout))
+	}
+	if config.Docker.Endpoint != "" {
+		opts = append(opts, WithDockerEndpoint(config.Docker.Endpoint))
+	}
+	if tls := config.Docker; tls.TLSCert != "" || tls.TLSKey != "" || tls.TLSCA != "" {
+		opts = append(opts, WithDockerTLS(tls.TLSCert, tls.TLSKey, tls.TLSCA))
+	}
+	if config.Docker.SSHIdentity != "" {
+		opts = append(opts, WithDockerSSHIdentity(config.Docker.SSHIdentity))
+	}
+	if config.Docker.Legacy {
+		opts = append(opts, WithLegacyDockerClient())
+	}
+	if r := config.Resources; r.MemoryMB != 0 {
+		opts = append(opts, WithMemoryLimit(r.MemoryMB<<20))
+	}
+	if r := config.Resources; r.CPUShares != 0 {
+		opts = append(opts, WithCPUShares(r.CPUShares))
+	}
+	if r := config.Resources; r.PidsLimit != 0 {
+		opts = append(opts, WithPidsLimit(r.PidsLimit))
+	}
+	if r := config.Resources; r.RestartPolicy != "" {
+		opts = append(opts, WithRestartPolicy(r.RestartPolicy))
+	}
+	if config.Network.Name != "" {
+		opts = append(opts, WithNetwork(config.Network.Name))
+	}
+	if config.Network.BindAddress != "" {
+		opts = append(opts, WithBindAddress(config.Network.BindAddress))
+	}
+	if config.Network.PublishedHost != "" {
+		opts = append(opts, WithPublishedHost(config.Network.PublishedHost))
+	}
+	if r := config.RateLimit; r.Rate != 0 || r.Burst != 0 {
+		opts = append(opts, WithRateLimit(r.Rate, r.Burst))
+	}
+	if account.APIPort != 0 {
+		opts = append(opts, WithAPIPort(account.APIPort))
+	}
+	if account.SettingsVolume != "" {
+		opts = append(opts, WithSettingsVolume(account.SettingsVolume))
+	}
+	if config.AutoRestart > 0 {
+		opts = append(opts, WithAutoRestart(config.AutoRestart))
+	}
+	if config.SessionConflict != "" {
+		opts = append(opts, WithSessionConflict(config.SessionConflict))
+	}
+	if config.AccountLock {
+		opts = append(opts, WithAccountLock())
+	}
+	return opts
+}
+
+// Sessions returns a SessionSpec for each account, for a Reconciler.
+func (config *Config) Sessions() []SessionSpec {
+	var specs []SessionSpec
+	for _, account := range config.Accounts {
+		specs = append(specs, SessionSpec{
+			Name:     account.Name,
+			Username: account.Username,
+			Password: account.Password,
+			Options:  config.Options(account),
+		})
+	}
+	return specs
+}
@@ -0,0 +1,135 @@
+package ibdock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ibdock.yaml": `
+image: agentydragon/ibcontroller:test
+variant: tws
+mode: paper
+snapshot_timeout: 10m
+auto_restart: 2
+session_conflict: take_over
+account_lock: true
+resources:
+  memory_mb: 3072
+  restart_policy: on-failure:2
+network:
+  name: brokers
+  bind_address: 127.0.0.1
+rate_limit:
+  rate: 0.5
+  burst: 5
+accounts:
+  - name: main
+    username: jdoe
+  - name: kids-ira
+    username: jdoe2
+    api_port: 7497
+    settings_volume: ib-kids
+stress:
+  base: EUR
+  fx_rates: {USD: 0.92}
+  scenarios:
+    - name: crash
+      shocks: [{sec_type: STK, change: -0.2}]
+reports:
+  total: "{{money .Total}}"
+`,
+		"ibdock.toml": `
+image = "agentydragon/ibcontroller:test"
+variant = "tws"
+mode = "paper"
+snapshot_timeout = "10m"
+auto_restart = 2
+session_conflict = "take_over"
+account_lock = true
+
+[resources]
+memory_mb = 3072
+restart_policy = "on-failure:2"
+
+[network]
+name = "brokers"
+bind_address = "127.0.0.1"
+
+[rate_limit]
+rate = 0.5
+burst = 5
+
+[[accounts]]
+name = "main"
+username = "jdoe"
+
+[[accounts]]
+name = "kids-ira"
+username = "jdoe2"
+api_port = 7497
+settings_volume = "ib-kids"
+
+[stress]
+base = "EUR"
+fx_rates = {USD = 0.92}
+
+[[stress.scenarios]]
+name = "crash"
+shocks = [{sec_type = "STK", change = -0.2}]
+
+[reports]
+total = "{{money .Total}}"
+`,
+	}
+	t.Setenv("IBDOCK_KIDS_IRA_PASSWORD", "secret")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if config.Image != "agentydragon/ibcontroller:test" || config.Variant != TWS || config.Mode != "paper" || config.SnapshotTimeout != 10*time.Minute {
+			t.Errorf("%s: unexpected config %+v", name, config)
+		}
+		account, err := config.Account("kids-ira")
+		if err != nil || account.Username != "jdoe2" || account.Password != "secret" {
+			t.Errorf("%s: Account = %+v, %v", name, account, err)
+		}
+		if rates := config.Stress.Rates(); rates["EUR"] != 1 || rates["USD"] != 0.92 || len(config.Stress.Scenarios) != 1 || config.Stress.Scenarios[0].Shocks[0].SecType != "STK" {
+			t.Errorf("%s: stress config %+v", name, config.Stress)
+		}
+		if config.Reports["total"] != "{{money .Total}}" {
+			t.Errorf("%s: reports %v", name, config.Reports)
+		}
+		dock := new(Dock)
+		for _, opt := range config.Options(account) {
+			opt(dock)
+		}
+		if dock.session != "kids-ira" || dock.imageRef() != "agentydragon/ibcontroller:test" || dock.variant != TWS || dock.tradingMode != "paper" || dock.snapshotTimeout() != 10*time.Minute || dock.rateLimit != 0.5 || dock.rateBurst != 5 || dock.sessionConflict != ConflictTakeOver || !dock.accountLock {
+			t.Errorf("%s: options gave %+v", name, dock)
+		}
+		if spec, err := dock.containerSpec("jdoe2", "secret"); err != nil || spec.Memory != 3<<30 || spec.PidsLimit != defaultPidsLimit || spec.Restart != (restartPolicy{"on-failure", 2}) {
+			t.Errorf("%s: containerSpec = %+v, %v", name, spec, err)
+		} else if spec.SettingsVolume != "ib-kids" || dock.autoRestarts != 2 || spec.Network != "brokers" || spec.APIBinding == nil || *spec.APIBinding != (portBinding{"127.0.0.1", "7497"}) {
+			t.Errorf("%s: containerSpec network %q, binding %+v", name, spec.Network, spec.APIBinding)
+		}
+	}
+
+	t.Setenv("IBDOCK_MODE", "simulated")
+	if _, err := LoadConfig(filepath.Join(dir, "ibdock.yaml")); err == nil {
+		t.Errorf("bad IBDOCK_MODE should fail")
+	}
+	t.Setenv("IBDOCK_MODE", "")
+	t.Setenv("IBDOCK_VARIANT", "client-portal")
+	if _, err := LoadConfig(filepath.Join(dir, "ibdock.yaml")); err == nil {
+		t.Errorf("bad IBDOCK_VARIANT should fail")
+	}
+}
@@ -0,0 +1,72 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+)
+
+// ContractCache keeps contract details across sessions, e.g. store.SQLStore.
+// Contract returns an error for contracts it does not have.
+type ContractCache interface {
+	Contract(ctx context.Context, conID int) (twsapi.ContractDetails, error)
+	SaveContract(ctx context.Context, details twsapi.ContractDetails) error
+}
+
+// ContractDetails returns the details of each of conIDs, asking the gateway
+// only for those not in the contract cache.
+func (dock *Dock) ContractDetails(ctx context.Context, conIDs []int) (_ map[int]twsapi.ContractDetails, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	var client *twsapi.Client
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+	return dock.cachedContractDetails(ctx, conIDs, func(ctx context.Context, contract twsapi.Contract) ([]twsapi.ContractDetails, error) {
+		if client == nil {
+			var err error
+			if client, err = dock.dialAPI(ctx); err != nil {
+				return nil, err
+			}
+		}
+		details, err := client.ContractDetails(ctx, contract)
+		return details, classifyPacing(err)
+	})
+}
+
+func (dock *Dock) cachedContractDetails(ctx context.Context, conIDs []int, lookup func(context.Context, twsapi.Contract) ([]twsapi.ContractDetails, error)) (map[int]twsapi.ContractDetails, error) {
+	details := make(map[int]twsapi.ContractDetails)
+	misses := 0
+	for _, conID := range conIDs {
+		if _, ok := details[conID]; ok {
+			continue
+		}
+		if dock.contracts != nil {
+			if cached, err := dock.contracts.Contract(ctx, conID); err == nil {
+				details[conID] = cached
+				continue
+			}
+		}
+		found, err := lookup(ctx, twsapi.Contract{ConID: conID})
+		if err != nil {
+			return nil, fmt.Errorf("contract %d: %w", conID, err)
+		}
+		if len(found) != 1 {
+			return nil, fmt.Errorf("contract %d: gateway returned %d contracts", conID, len(found))
+		}
+		details[conID] = found[0]
+		misses++
+		if dock.contracts != nil {
+			if err := dock.contracts.SaveContract(ctx, found[0]); err != nil {
+				dock.logger.Println("Failed to cache contract", conID, err)
+			}
+		}
+	}
+	if misses > 0 {
+		dock.logger.Printf("Looked up %d of %d contracts on the gateway", misses, len(details))
+	}
+	return details, nil
+}
@@ -0,0 +1,47 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"io"
+	"log"
+	"testing"
+)
+
+type mapCache map[int]twsapi.ContractDetails
+
+func (m mapCache) Contract(ctx context.Context, conID int) (twsapi.ContractDetails, error) {
+	details, ok := m[conID]
+	if !ok {
+		return details, errors.New("not cached")
+	}
+	return details, nil
+}
+
+func (m mapCache) SaveContract(ctx context.Context, details twsapi.ContractDetails) error {
+	m[details.Contract.ConID] = details
+	return nil
+}
+
+func TestCachedContractDetails(t *testing.T) {
+	cache := mapCache{756733: {Contract: twsapi.Contract{ConID: 756733, Symbol: "SPY"}}}
+	dock := &Dock{logger: log.New(io.Discard, "", 0), contracts: cache}
+	var looked []int
+	lookup := func(ctx context.Context, contract twsapi.Contract) ([]twsapi.ContractDetails, error) {
+		looked = append(looked, contract.ConID)
+		return []twsapi.ContractDetails{{Contract: twsapi.Contract{ConID: contract.ConID, Symbol: "VWCE"}}}, nil
+	}
+	for range 2 {
+		details, err := dock.cachedContractDetails(context.Background(), []int{756733, 290651477}, lookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details[756733].Contract.Symbol != "SPY" || details[290651477].Contract.Symbol != "VWCE" {
+			t.Errorf("unexpected details %+v", details)
+		}
+	}
+	if len(looked) != 1 || looked[0] != 290651477 {
+		t.Errorf("looked up %v on the gateway, want only the first miss", looked)
+	}
+}
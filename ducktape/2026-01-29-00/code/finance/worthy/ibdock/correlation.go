@@ -0,0 +1,178 @@
+package ibdock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+)
+
+// shortIDLength is how much of container and exec IDs Call keeps, as the
+// docker CLI shows them.
+const shortIDLength = 12
+
+// Call identifies one call into a Dock, such as an Exec or GetSnapshot, in
+// its log lines, errors and ExecReport, to tell apart what several Docks or
+// concurrent calls log.
+type Call struct {
+	// ID is generated per call unless set with ContextWithCallID.
+	ID string
+	// Account is the IB account the call is about, if it is about one.
+	Account string `json:",omitempty"`
+	// Container and Exec are short IDs, Exec only once the call started one.
+	Container string `json:",omitempty"`
+	Exec      string `json:",omitempty"`
+}
+
+// String is e.g. "call=3f2a1b9c account=U1234567 container=0123456789ab".
+func (c Call) String() string {
+	fields := []string{"call=" + c.ID}
+	if c.Account != "" {
+		fields = append(fields, "account="+c.Account)
+	}
+	if c.Container != "" {
+		fields = append(fields, "container="+c.Container)
+	}
+	if c.Exec != "" {
+		fields = append(fields, "exec="+c.Exec)
+	}
+	return strings.Join(fields, " ")
+}
+
+// CallError is the error of a call, with the Call it failed in. Its message
+// is masked like the Dock's logs, account included.
+type CallError struct {
+	Call Call
+	Err  error
+	// redactor is the Dock's, nil WithoutRedaction.
+	redactor *Redactor
+}
+
+func (e *CallError) Error() string {
+	return e.redactor.Redact(e.Err.Error() + " [" + e.Call.String() + "]")
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+type (
+	loggerKey struct{}
+	callIDKey struct{}
+	callKey   struct{}
+)
+
+// ContextWithLogger makes calls into Docks made with the returned context
+// log to logger instead of their Dock's logger.
+func ContextWithLogger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// ContextWithCallID makes calls into Docks made with the returned context
+// use id as their Call.ID, e.g. that of a request they serve.
+func ContextWithCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, callIDKey{}, id)
+}
+
+// callState is the Call in progress, which calls made on behalf of it, like
+// the Exec of GetSnapshot, join.
+type callState struct {
+	mu       sync.Mutex
+	call     Call
+	logger   *log.Logger
+	redactor *Redactor
+}
+
+// beginCall returns ctx carrying the call it is part of, which is a new one
+// unless ctx already carries a call.
+func (dock *Dock) beginCall(ctx context.Context, account string) (context.Context, *callState) {
+	if c, ok := ctx.Value(callKey{}).(*callState); ok {
+		c.mu.Lock()
+		if c.call.Account == "" {
+			c.call.Account = account
+		}
+		c.mu.Unlock()
+		return ctx, c
+	}
+	id, _ := ctx.Value(callIDKey{}).(string)
+	if id == "" {
+		var b [4]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	logger := dock.logger
+	if l, ok := ctx.Value(loggerKey{}).(*log.Logger); ok && l != nil {
+		logger = dock.redactor.Logger(l)
+	}
+	c := &callState{call: Call{ID: id, Account: account, Container: shortID(dock.current().ID)}, logger: logger, redactor: dock.redactor}
+	return context.WithValue(ctx, callKey{}, c), c
+}
+
+// callFrom returns the call ctx carries, or one logging to the Dock's logger
+// without an ID for code running outside of calls.
+func (dock *Dock) callFrom(ctx context.Context) *callState {
+	if c, ok := ctx.Value(callKey{}).(*callState); ok {
+		return c
+	}
+	return &callState{logger: dock.logger}
+}
+
+func shortID(id string) string {
+	return id[:min(len(id), shortIDLength)]
+}
+
+// setExec records the exec the call started, in container, which is another
+// than at the start of the call if the Dock restarted it since.
+func (c *callState) setExec(container, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.call.Container, c.call.Exec = shortID(container), shortID(id)
+}
+
+func (c *callState) current() Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.call
+}
+
+// tagged prepends the call to v, for logging.
+func (c *callState) tagged(v []any) []any {
+	call := c.current()
+	if call.ID == "" {
+		return v
+	}
+	return append([]any{"[" + call.String() + "]"}, v...)
+}
+
+func (c *callState) Println(v ...any) {
+	c.logger.Println(c.tagged(v)...)
+}
+
+func (c *callState) Print(v ...any) {
+	if call := c.current(); call.ID != "" {
+		// Print only spaces operands that are not strings.
+		v = append([]any{"[" + call.String() + "] "}, v...)
+	}
+	c.logger.Print(v...)
+}
+
+// wrap returns err as a *CallError of the call, unless it is one already.
+func (c *callState) wrap(err error) error {
+	var callErr *CallError
+	if err == nil || errors.As(err, &callErr) {
+		return err
+	}
+	call := c.current()
+	if call.ID == "" {
+		return err
+	}
+	return &CallError{Call: call, Err: err, redactor: c.redactor}
+}
+
+// wrapError is wrap of *err, deferred by the methods that begin a call.
+func (c *callState) wrapError(err *error) {
+	*err = c.wrap(*err)
+}
@@ -0,0 +1,56 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCallCorrelation(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stdout: []byte("not a snapshot"), Stderr: []byte("error 502"), ExitCode: 1}
+	})
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithoutRedaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	ctx := ContextWithCallID(ContextWithLogger(context.Background(), log.New(&logs, "", 0)), "req-1")
+	result, err := dock.Exec(ctx, []string{"true"}, ExecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := result.Report.Call
+	if call.ID != "req-1" || call.Container != shortID(dock.ContainerID()) || call.Exec == "" {
+		t.Errorf("report call %+v", call)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		// The exec ID is only known once it started.
+		if prefix := "[call=req-1 container=" + call.Container; !strings.HasPrefix(line, prefix) {
+			t.Errorf("log line %q does not start with %q", line, prefix)
+		}
+	}
+
+	_, err = dock.Exec(ctx, []string{"true"}, ExecOptions{MaxOutputBytes: 1})
+	var callErr *CallError
+	if !errors.As(err, &callErr) || !errors.Is(err, ErrOutputTooLarge) || callErr.Call.ID != "req-1" || !strings.Contains(err.Error(), "call=req-1") {
+		t.Errorf("Exec over MaxOutputBytes = %v", err)
+	}
+
+	_, err = dock.GetSnapshotFor(context.Background(), "U1234567")
+	if !errors.As(err, &callErr) || callErr.Call.Account != "U1234567" || callErr.Call.Exec == "" {
+		t.Errorf("failed GetSnapshotFor = %v", err)
+	}
+	first := callErr.Call.ID
+	_, err = dock.GetSnapshotFor(context.Background(), "U1234567")
+	if !errors.As(err, &callErr) || callErr.Call.ID == first || len(first) != 8 {
+		t.Errorf("generated call IDs %q and %q", first, callErr.Call.ID)
+	}
+}
@@ -0,0 +1,27 @@
+package ibdock
+
+import "fmt"
+
+// vncPort is where the image's VNC server shows the gateway's screen, when
+// WithDebugVNC turns it on.
+const vncPort = "5900/tcp"
+
+// vncEnv asks the entrypoint for the VNC server set up with WithDebugVNC.
+func (dock *Dock) vncEnv() []string {
+	if !dock.debugVNC {
+		return nil
+	}
+	return []string{"IBDOCK_VNC=1", "VNC_PASSWORD=" + dock.vncPassword}
+}
+
+// DebugEndpoint returns the host:port at which the container's VNC server is
+// published, to see and click through a dialog the login is stuck on. The
+// server only runs in containers started with WithDebugVNC.
+func (dock *Dock) DebugEndpoint() (_ string, err error) {
+	defer dock.redactError(&err)
+	endpoint, err := dock.publishedEndpoint(vncPort)
+	if err != nil {
+		return "", fmt.Errorf("no VNC server, see WithDebugVNC: %w", err)
+	}
+	return endpoint, nil
+}
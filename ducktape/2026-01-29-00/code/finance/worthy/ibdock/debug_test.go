@@ -0,0 +1,56 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpoint(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		for _, test := range []struct {
+			name string
+			opts []Option
+			want string
+		}{
+			{"published", []Option{WithDebugVNC("hunter2")}, "127.0.0.1:"},
+			{"bound", []Option{WithDebugVNC(""), WithBindAddress("10.0.0.5")}, "10.0.0.5:"},
+		} {
+			dock, err := StartNew("jdoe", "secret", logger, append(append(backend.opts, WithDockerEndpoint(server.URL()), WithSessionName(test.name)), test.opts...)...)
+			if err != nil {
+				t.Fatalf("%s %s: %v", backend.name, test.name, err)
+			}
+			endpoint, err := dock.DebugEndpoint()
+			if err != nil || !strings.HasPrefix(endpoint, test.want) {
+				t.Errorf("%s %s: DebugEndpoint = %q, %v, want %s…", backend.name, test.name, endpoint, err, test.want)
+			}
+			if api, _ := dock.APIEndpoint(); api == endpoint {
+				t.Errorf("%s %s: VNC published on the API endpoint %s", backend.name, test.name, api)
+			}
+			for _, c := range server.Containers() {
+				if c.ID == dock.ContainerID() && !slices.Contains(c.Config.Env, "IBDOCK_VNC=1") {
+					t.Errorf("%s %s: entrypoint not asked for VNC: %q", backend.name, test.name, c.Config.Env)
+				}
+			}
+		}
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()), WithSessionName("plain"))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if endpoint, err := dock.DebugEndpoint(); err == nil {
+			t.Errorf("%s: DebugEndpoint without WithDebugVNC = %s", backend.name, endpoint)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ibdock
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// desktopHostName is how containers on Docker Desktop reach the machine
+// Desktop runs on.
+const desktopHostName = "host.docker.internal"
+
+// inContainer reports whether this process runs in a container, where
+// loopback addresses are the container's own. Docker creates /.dockerenv
+// in its containers.
+var inContainer = func() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+// isDesktop reports whether the daemon is Docker Desktop's, on macOS or
+// Windows, asking it once. Desktop runs the daemon in a VM, whose addresses
+// this machine cannot reach, and forwards published ports to this machine's
+// localhost instead.
+func (dock *Dock) isDesktop() bool {
+	dock.desktopOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		info, err := dock.client.info(ctx)
+		if err != nil {
+			dock.logger.Println("Cannot tell whether Docker is Docker Desktop, assuming not:", err)
+			return
+		}
+		dock.desktop = strings.Contains(info.OperatingSystem, "Docker Desktop")
+	})
+	return dock.desktop
+}
+
+// desktopHost maps a loopback host of a port published by Docker Desktop to
+// one this process reaches it at: localhost, on both IPv4 and IPv6 as
+// Desktop may forward either, or host.docker.internal from inside another
+// Desktop container. Other hosts are left alone.
+func desktopHost(host string, inContainer bool) string {
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return host
+	}
+	if inContainer {
+		return desktopHostName
+	}
+	return "localhost"
+}
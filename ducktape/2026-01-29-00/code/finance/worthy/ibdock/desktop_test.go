@@ -0,0 +1,83 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDesktopHost(t *testing.T) {
+	for _, test := range []struct {
+		host        string
+		inContainer bool
+		want        string
+	}{
+		{"127.0.0.1", false, "localhost"},
+		{"::1", false, "localhost"},
+		{"localhost", true, "host.docker.internal"},
+		{"127.0.0.1", true, "host.docker.internal"},
+		{"192.168.1.5", false, "192.168.1.5"},
+		{"docker.example.com", true, "docker.example.com"},
+	} {
+		if got := desktopHost(test.host, test.inContainer); got != test.want {
+			t.Errorf("desktopHost(%q, %v) = %q, want %q", test.host, test.inContainer, got, test.want)
+		}
+	}
+}
+
+func TestDesktopEndpoint(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	defer func(in func() bool) { inContainer = in }(inContainer)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		gateway, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gateway.Close()
+		port := strconv.Itoa(gateway.Addr().(*net.TCPAddr).Port)
+		for i, test := range []struct {
+			operatingSystem string
+			inContainer     bool
+			opts            []Option
+			want            string
+		}{
+			{"Ubuntu 24.04 LTS", false, nil, "127.0.0.1"},
+			{"Ubuntu 24.04 LTS", true, nil, "127.0.0.1"},
+			{"Docker Desktop", false, nil, "localhost"},
+			{"Docker Desktop", true, nil, "host.docker.internal"},
+			{"Docker Desktop", true, []Option{WithPublishedHost("gateway.lan")}, "gateway.lan"},
+			{"Ubuntu 24.04 LTS", true, []Option{WithPublishedHost("172.17.0.1")}, "172.17.0.1"},
+		} {
+			server := ibdocktest.NewServer()
+			defer server.Close()
+			server.SetOperatingSystem(test.operatingSystem)
+			server.PublishAPI(port)
+			inContainer = func() bool { return test.inContainer }
+			opts := append(append([]Option{}, backend.opts...), WithDockerEndpoint(server.URL()), WithSessionName(strconv.Itoa(i)))
+			dock, err := StartNew("jdoe", "secret", logger, append(opts, test.opts...)...)
+			if err != nil {
+				t.Fatalf("%s: %v", backend.name, err)
+			}
+			if endpoint, err := dock.APIEndpoint(); err != nil || endpoint != net.JoinHostPort(test.want, port) {
+				t.Errorf("%s: %s, in container %v: APIEndpoint = %q, %v, want host %s", backend.name, test.operatingSystem, test.inContainer, endpoint, err, test.want)
+			}
+			// Readiness goes through the same host.
+			if test.want == "localhost" {
+				if health, err := dock.checkHealth(context.Background(), time.Second); health != Healthy {
+					t.Errorf("%s: Desktop health %v (%v), want healthy", backend.name, health, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity is how bad a Finding is.
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	// SeverityWarning is for what may stop sessions from working.
+	SeverityWarning
+	// SeverityError is for what stops sessions from working.
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityOK:      "ok",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding is the outcome of one check of Diagnose.
+type Finding struct {
+	// Check is e.g. "docker" or "clock".
+	Check    string
+	Severity Severity
+	Message  string
+}
+
+// DiagnosticsReport is what Diagnose found. Checks that need the Docker
+// daemon are left out if it cannot be reached.
+type DiagnosticsReport struct {
+	Findings []Finding
+}
+
+func (r *DiagnosticsReport) add(check string, severity Severity, format string, args ...any) {
+	r.Findings = append(r.Findings, Finding{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Worst is the highest Severity of the findings.
+func (r DiagnosticsReport) Worst() Severity {
+	worst := SeverityOK
+	for _, f := range r.Findings {
+		worst = max(worst, f.Severity)
+	}
+	return worst
+}
+
+var findingLabels = map[Severity]string{SeverityOK: "ok", SeverityWarning: "WARN", SeverityError: "FAIL"}
+
+// String lists the findings one a line, e.g. "WARN  clock: the Docker
+// host's clock is 8s ahead of this one's".
+func (r DiagnosticsReport) String() string {
+	var b strings.Builder
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "%-5s %s: %s\n", findingLabels[f.Severity], f.Check, f.Message)
+	}
+	return b.String()
+}
+
+const (
+	// minDiskFree is room for pulling the image and a session's logs.
+	minDiskFree = 2 << 30
+	// clockSkewWarning and clockSkewError are how far the Docker host's
+	// clock may be off before Diagnose warns and fails: gateways take
+	// their time from it.
+	clockSkewWarning = 5 * time.Second
+	clockSkewError   = time.Minute
+)
+
+// ibPorts are the ports IB software serves the TWS API on by default: IB
+// Gateway live and paper, then TWS live and paper.
+var ibPorts = []int{4001, 4002, 7496, 7497}
+
+// Diagnose checks the environment sessions would run in, configured by opts
+// as for StartNew: that the Docker daemon is reachable, has disk space left
+// and a clock close to this host's, whether the image is there, and that no
+// container holds the IB ports or the one of WithAPIPort. Unlike a dry run,
+// see WithDryRun, it needs no credentials and also looks at the Docker host.
+func Diagnose(ctx context.Context, opts ...Option) DiagnosticsReport {
+	dock := new(Dock)
+	for _, opt := range opts {
+		opt(dock)
+	}
+	var report DiagnosticsReport
+	if err := dock.connect(); err != nil {
+		report.add("docker", SeverityError, "%v", err)
+		return report
+	}
+	before := time.Now()
+	info, err := dock.client.info(ctx)
+	after := time.Now()
+	if err != nil {
+		report.add("docker", SeverityError, "cannot reach the daemon at %s: %v", dock.client.endpoint(), err)
+		return report
+	}
+	report.add("docker", SeverityOK, "Docker %s (API %s) at %s", info.Version, info.APIVersion, dock.client.endpoint())
+	dock.diagnoseClock(&report, info, before.Add(after.Sub(before)/2))
+	dock.diagnoseDisk(&report, info)
+	dock.diagnoseImage(ctx, &report)
+	dock.diagnosePorts(ctx, &report)
+	return report
+}
+
+// diagnoseClock compares the daemon's clock with this host's at now, the
+// middle of the request asking for it.
+func (dock *Dock) diagnoseClock(report *DiagnosticsReport, info daemonInfo, now time.Time) {
+	if info.Time.IsZero() {
+		report.add("clock", SeverityWarning, "the daemon did not report its time")
+		return
+	}
+	skew := info.Time.Sub(now)
+	direction := "ahead of"
+	if skew < 0 {
+		skew, direction = -skew, "behind"
+	}
+	skew = skew.Round(time.Second)
+	severity := SeverityOK
+	switch {
+	case skew > clockSkewError:
+		severity = SeverityError
+	case skew > clockSkewWarning:
+		severity = SeverityWarning
+	}
+	if severity == SeverityOK {
+		report.add("clock", severity, "the Docker host's clock is within %v of this one's", clockSkewWarning)
+		return
+	}
+	report.add("clock", severity, "the Docker host's clock is %v %s this one's; sync it with NTP", skew, direction)
+}
+
+// diagnoseDisk checks the space left where the daemon keeps images, which it
+// can only do for daemons on this host.
+func (dock *Dock) diagnoseDisk(report *DiagnosticsReport, info daemonInfo) {
+	endpoint, err := url.Parse(dock.client.endpoint())
+	if err != nil || endpoint.Scheme != "unix" || info.RootDir == "" {
+		report.add("disk", SeverityOK, "not checked on a remote Docker host")
+		return
+	}
+	free, err := diskFree(info.RootDir)
+	switch {
+	case err != nil:
+		report.add("disk", SeverityOK, "not checked: %v", err)
+	case free < minDiskFree:
+		report.add("disk", SeverityError, "only %.1f GB free in %s", float64(free)/(1<<30), info.RootDir)
+	default:
+		report.add("disk", SeverityOK, "%.1f GB free in %s", float64(free)/(1<<30), info.RootDir)
+	}
+}
+
+func (dock *Dock) diagnoseImage(ctx context.Context, report *DiagnosticsReport) {
+	ref := dock.imageRef()
+	digest, found, err := dock.client.imageDigest(ctx, ref)
+	switch {
+	case err != nil:
+		report.add("image", SeverityError, "cannot inspect %s: %v", ref, err)
+	case !found:
+		report.add("image", SeverityWarning, "%s is not on the Docker host, starting a session will pull it", ref)
+	default:
+		report.add("image", SeverityOK, "%s at %s", ref, digest)
+	}
+}
+
+// diagnosePorts finds running containers publishing the IB ports on the
+// Docker host. Holding the WithAPIPort one keeps sessions from starting;
+// the others only clash with gateways run outside of ibdock.
+func (dock *Dock) diagnosePorts(ctx context.Context, report *DiagnosticsReport) {
+	ports := ibPorts
+	if dock.apiHostPort != 0 && !slices.Contains(ports, dock.apiHostPort) {
+		ports = append(slices.Clip(ports), dock.apiHostPort)
+	}
+	containers, err := dock.client.list(ctx, false, nil)
+	if err != nil {
+		report.add("ports", SeverityError, "cannot list containers: %v", err)
+		return
+	}
+	held := false
+	for _, c := range containers {
+		for _, binding := range c.Ports {
+			port, err := strconv.Atoi(binding.HostPort)
+			if err != nil || !slices.Contains(ports, port) {
+				continue
+			}
+			held = true
+			severity := SeverityWarning
+			if port == dock.apiHostPort {
+				severity = SeverityError
+			}
+			what := "container " + c.Name
+			if _, ok := c.Labels[sessionLabel]; ok || strings.HasPrefix(c.Name, "ibcontroller_") {
+				what = "ibdock session " + c.Name
+			}
+			report.add("ports", severity, "%s (%s) holds host port %d", what, shortID(c.ID), port)
+		}
+	}
+	if !held {
+		report.add("ports", SeverityOK, "no container holds host ports %v", ports)
+	}
+}
@@ -0,0 +1,64 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnose(t *testing.T) {
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()))
+		findings := func(opts ...Option) map[string]Finding {
+			report := Diagnose(context.Background(), opts...)
+			found := make(map[string]Finding)
+			for _, f := range report.Findings {
+				found[f.Check] = f
+			}
+			return found
+		}
+		got := findings(opts...)
+		for _, check := range []string{"docker", "clock", "disk", "image", "ports"} {
+			if f, ok := got[check]; !ok || f.Severity != SeverityOK {
+				t.Errorf("%s: fresh daemon: %s: %+v", backend.name, check, f)
+			}
+		}
+		if !strings.Contains(got["docker"].Message, "Docker ibdocktest") || !strings.Contains(got["image"].Message, "sha256:") {
+			t.Errorf("%s: findings %+v", backend.name, got)
+		}
+
+		server.SetImage(image, "")
+		server.SetClockSkew(-2 * time.Minute)
+		if got := findings(opts...); got["image"].Severity != SeverityWarning || got["clock"].Severity != SeverityError || !strings.Contains(got["clock"].Message, "2m0s behind") {
+			t.Errorf("%s: missing image and slow clock: %+v", backend.name, got)
+		}
+		server.SetImage(image, "linux/amd64")
+		server.SetClockSkew(0)
+
+		// The fake daemon publishes the API port on 7496.
+		if _, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), append(opts, WithSessionName("ports"))...); err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if f := findings(opts...)["ports"]; f.Severity != SeverityWarning || !strings.Contains(f.Message, "ibdock session ibcontroller_ports") || !strings.Contains(f.Message, "7496") {
+			t.Errorf("%s: ports: %+v", backend.name, f)
+		}
+		if f := findings(append(opts, WithAPIPort(7496))...)["ports"]; f.Severity != SeverityError {
+			t.Errorf("%s: ports WithAPIPort(7496): %+v", backend.name, f)
+		}
+	}
+	if report := Diagnose(context.Background(), WithDockerEndpoint("tcp://127.0.0.1:1")); report.Worst() != SeverityError || len(report.Findings) != 1 {
+		t.Errorf("unreachable daemon: %v", report)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package ibdock
+
+import "syscall"
+
+// diskFree returns how many bytes unprivileged users can still write to the
+// filesystem holding path.
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,186 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// sshCommand is the ssh client, and any leading arguments, used for ssh://
+// endpoints.
+var sshCommand = []string{"ssh"}
+
+// runtime connects to the daemon at c.Endpoint, or to the one DOCKER_HOST and
+// friends configure if it is empty, through the official SDK unless c.Legacy
+// is set. Besides what DOCKER_HOST takes, the endpoint can be
+// "ssh://[user@]host[:port]", which runs "docker system dial-stdio" on host
+// through the local ssh client like the docker CLI does, so the remote daemon
+// need not listen on the network.
+func (c DockerConfig) runtime() (containerRuntime, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("DOCKER_HOST")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("docker endpoint %q: %w", endpoint, err)
+	}
+	hasTLS := c.TLSCert != "" || c.TLSKey != "" || c.TLSCA != ""
+	if u.Scheme == "ssh" {
+		if hasTLS {
+			return nil, errors.New("docker TLS settings do not apply to ssh:// endpoints")
+		}
+		dialer, err := c.dialer(u)
+		if err != nil {
+			return nil, err
+		}
+		if c.Legacy {
+			return newLegacySSHRuntime(dialer)
+		}
+		return newMobySSHRuntime(dialer)
+	}
+	if c.SSHIdentity != "" {
+		return nil, fmt.Errorf("docker SSH identity given for non-ssh endpoint %q", endpoint)
+	}
+	if hasTLS {
+		if u.Scheme == "unix" || u.Scheme == "npipe" {
+			return nil, fmt.Errorf("docker TLS settings do not apply to %s:// endpoints", u.Scheme)
+		}
+		if endpoint == "" {
+			return nil, errors.New("docker TLS settings need an endpoint")
+		}
+		if (c.TLSCert == "") != (c.TLSKey == "") {
+			return nil, errors.New("docker TLS client certificate and key must be given together")
+		}
+		for _, path := range []string{c.TLSCert, c.TLSKey, c.TLSCA} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("docker TLS: %w", err)
+			}
+		}
+	}
+	if c.Legacy {
+		return newLegacyRuntime(c, endpoint)
+	}
+	return newMobyRuntime(c)
+}
+
+func (c DockerConfig) dialer(u *url.URL) (*sshDialer, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("docker endpoint %q has no host", u)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("docker endpoint %q: ssh:// endpoints take no path", u)
+	}
+	if c.SSHIdentity != "" {
+		if _, err := os.Stat(c.SSHIdentity); err != nil {
+			return nil, fmt.Errorf("docker SSH identity: %w", err)
+		}
+	}
+	if _, err := exec.LookPath(sshCommand[0]); err != nil {
+		return nil, fmt.Errorf("docker over SSH: %w", err)
+	}
+	return &sshDialer{args: c.sshArgs(u), endpoint: u.Redacted()}, nil
+}
+
+// sshHost is the daemon address clients of an sshDialer are given. It is never
+// resolved: every connection goes through ssh, and the runtimes' endpoint is
+// the ssh:// one.
+const sshHost = "docker.ssh:2375"
+
+func (c DockerConfig) sshArgs(u *url.URL) []string {
+	var args []string
+	if c.SSHIdentity != "" {
+		args = append(args, "-i", c.SSHIdentity)
+	}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+}
+
+// sshDialer connects to a remote Docker daemon through ssh's standard input
+// and output.
+type sshDialer struct {
+	args []string
+	// endpoint is the ssh:// URL dialed.
+	endpoint string
+}
+
+// Dial is used for go-dockerclient's hijacked connections, e.g. exec streams.
+func (d *sshDialer) Dial(network, address string) (net.Conn, error) {
+	return d.dial(context.Background())
+}
+
+func (d *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.dial(ctx)
+}
+
+func (d *sshDialer) dial(ctx context.Context) (net.Conn, error) {
+	// The connection outlives ctx, which only bounds dialing.
+	cmd := exec.Command(sshCommand[0], append(sshCommand[1:], d.args...)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("docker over SSH: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// commandConn is a net.Conn over a command's standard input and output.
+// Deadlines are not supported.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// CloseWrite ends the command's input, which the Docker clients do once an
+// exec's input is copied.
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return sshAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr               { return sshAddr{} }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type sshAddr struct{}
+
+func (sshAddr) Network() string { return "ssh" }
+func (sshAddr) String() string  { return "ssh" }
@@ -0,0 +1,101 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(cert, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+	for _, test := range []struct {
+		config DockerConfig
+		want   string
+	}{
+		{DockerConfig{Endpoint: "ssh://docker-host", TLSCA: cert}, "do not apply to ssh://"},
+		{DockerConfig{Endpoint: "tcp://docker-host:2376", SSHIdentity: cert}, "non-ssh endpoint"},
+		{DockerConfig{Endpoint: "unix:///var/run/docker.sock", TLSCA: cert}, "do not apply to unix://"},
+		{DockerConfig{Endpoint: "tcp://docker-host:2376", TLSCert: cert}, "must be given together"},
+		{DockerConfig{Endpoint: "tcp://docker-host:2376", TLSCA: missing}, "missing.pem"},
+		{DockerConfig{Endpoint: "tcp://docker-host:2376", TLSCert: cert, TLSKey: cert}, "docker TLS"},
+		{DockerConfig{Endpoint: "ssh:///path"}, "has no host"},
+		{DockerConfig{Endpoint: "ssh://docker-host/path"}, "take no path"},
+		{DockerConfig{Endpoint: "ssh://docker-host", SSHIdentity: missing}, "missing.pem"},
+	} {
+		if _, err := test.config.runtime(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%+v: got error %v, want one mentioning %q", test.config, err, test.want)
+		}
+	}
+}
+
+// TestSSHHelper stands in for ssh in TestSSHEndpoint, connecting its standard
+// input and output to the fake daemon instead of running
+// "docker system dial-stdio".
+func TestSSHHelper(t *testing.T) {
+	addr := os.Getenv("IBDOCK_TEST_SSH_DAEMON")
+	if addr == "" {
+		return
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		os.Exit(1)
+	}
+	go io.Copy(conn, os.Stdin)
+	io.Copy(os.Stdout, conn)
+	os.Exit(0)
+}
+
+func TestSSHEndpoint(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111"})
+	u, err := url.Parse(server.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IBDOCK_TEST_SSH_DAEMON", u.Host)
+	defer func(command []string) { sshCommand = command }(sshCommand)
+	sshCommand = []string{os.Args[0], "-test.run=^TestSSHHelper$", "--"}
+
+	config := DockerConfig{Endpoint: "ssh://me@docker-host:2222"}
+	if args := strings.Join(config.sshArgs(&url.URL{Scheme: "ssh", User: url.User("me"), Host: "docker-host:2222"}), " "); args != "-p 2222 -l me -- docker-host docker system dial-stdio" {
+		t.Errorf("ssh args %q", args)
+	}
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), append(backend.opts, WithDockerEndpoint(config.Endpoint), WithSessionName(backend.name))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		s, err := dock.GetSnapshot(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if s.Account != "U1111111" {
+			t.Errorf("%s: got snapshot of %q", backend.name, s.Account)
+		}
+		// The ports are published on the ssh host, not on the placeholder
+		// the client dials.
+		if endpoint, err := dock.APIEndpoint(); err != nil || endpoint != "docker-host:7496" {
+			t.Errorf("%s: APIEndpoint = %q, %v, want docker-host:7496", backend.name, endpoint, err)
+		}
+	}
+}
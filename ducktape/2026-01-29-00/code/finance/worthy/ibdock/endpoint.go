@@ -0,0 +1,162 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"net"
+	"net/url"
+)
+
+// Port the gateway inside the container serves the TWS API on.
+const apiPort = "7496/tcp"
+
+// APIEndpoint returns the host:port at which the container's TWS API port is
+// published, for connecting to the gateway directly (e.g. with twsapi).
+func (dock *Dock) APIEndpoint() (_ string, err error) {
+	defer dock.redactError(&err)
+	return dock.publishedEndpoint(apiPort)
+}
+
+// dialAPI opens a TWS API connection to the gateway, with a client ID not used
+// by any other connection from this Dock, restarting the container first if
+// it died and WithAutoRestart allows.
+func (dock *Dock) dialAPI(ctx context.Context) (*twsapi.Client, error) {
+	if err := dock.pace(ctx); err != nil {
+		return nil, err
+	}
+	if err := dock.ensureRunning(ctx); err != nil {
+		return nil, err
+	}
+	return dock.dialGateway(ctx)
+}
+
+// dialGateway is dialAPI without the restart, for WaitReady.
+func (dock *Dock) dialGateway(ctx context.Context) (*twsapi.Client, error) {
+	endpoint, err := dock.APIEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	client, err := twsapi.DialJournal(ctx, endpoint, int(dock.clientID.Add(1)), dock.journal)
+	if err != nil {
+		return nil, err
+	}
+	client.Clock = dock.clock
+	return client, nil
+}
+
+// WaitReady blocks until the gateway accepts TWS API connections, which it
+// only does once logged in, or ctx is done. It gives up early with a
+// *LoginError if the container logs that the login failed, and if the
+// container stops. Errors come as a *ScreenshotError with the last screen
+// seen while waiting, if any could be taken.
+func (dock *Dock) WaitReady(ctx context.Context) (err error) {
+	defer dock.redactError(&err)
+	pollInterval := dock.variant.readyPollInterval()
+	// The screen is taken after every failed attempt, since a container that
+	// stopped has none left to take.
+	var screen []byte
+	for {
+		attempt, cancel := context.WithTimeout(ctx, pollInterval)
+		client, err := dock.dialGateway(attempt)
+		cancel()
+		if err == nil {
+			client.Close()
+			dock.emit(Event{Kind: EventReady})
+			return nil
+		}
+		if loginErr := dock.loginFailure(ctx); loginErr != nil {
+			return withScreenshot(loginErr, screen)
+		}
+		container, inspectErr := dock.client.inspect(ctx, dock.current().ID)
+		if inspectErr != nil {
+			return withScreenshot(inspectErr, screen)
+		}
+		if !container.Running {
+			return withScreenshot(fmt.Errorf("container %s stopped before the gateway was ready: %s", container.ID, container.Status), screen)
+		}
+		dock.logger.Println("Gateway not ready yet:", err)
+		if png, err := dock.Screenshot(ctx); err == nil {
+			screen = png
+		}
+		select {
+		case <-clock.OrReal(dock.clock).After(pollInterval):
+		case <-ctx.Done():
+			return withScreenshot(ctx.Err(), screen)
+		}
+	}
+}
+
+func (dock *Dock) publishedEndpoint(port string) (string, error) {
+	container := dock.current()
+	binding, ok := findBinding(container, port)
+	if !ok {
+		var err error
+		if binding, err = dock.publishedBinding(container.ID, port); err != nil {
+			return "", err
+		}
+	}
+	return net.JoinHostPort(dock.publishedHost(binding.HostIP), binding.HostPort), nil
+}
+
+// publishedBinding looks port up in the published ports of container id,
+// inspecting it unless they are cached: ports are only assigned once the
+// container runs, so the container we got back from create has none.
+func (dock *Dock) publishedBinding(id, port string) (portBinding, error) {
+	dock.portsMu.Lock()
+	defer dock.portsMu.Unlock()
+	if dock.ports.ID == id {
+		if binding, ok := findBinding(dock.ports, port); ok {
+			return binding, nil
+		}
+	}
+	container, err := dock.client.inspect(context.Background(), id)
+	if err != nil {
+		return portBinding{}, err
+	}
+	dock.ports = containerInfo{ID: id, Ports: container.Ports}
+	binding, ok := findBinding(dock.ports, port)
+	if !ok {
+		return portBinding{}, fmt.Errorf("port %s of container %s is not published", port, id)
+	}
+	return binding, nil
+}
+
+func findBinding(container containerInfo, port string) (portBinding, bool) {
+	for _, binding := range container.Ports[port] {
+		if binding.HostPort != "" {
+			return binding, true
+		}
+	}
+	return portBinding{}, false
+}
+
+// publishedHost turns the host IP of a port binding into an address callers
+// can reach, unless WithPublishedHost says where. Wildcard bindings are
+// reachable on the Docker host, which is either this machine or the one a
+// tcp:// or ssh:// endpoint points to; with Docker Desktop, see isDesktop, that is
+// this machine's localhost.
+func (dock *Dock) publishedHost(hostIP string) string {
+	if dock.publishHost != "" {
+		return dock.publishHost
+	}
+	host := "127.0.0.1"
+	if hostIP != "" && hostIP != "0.0.0.0" && hostIP != "::" {
+		host = hostIP
+	} else if endpoint, err := url.Parse(dock.client.endpoint()); err == nil {
+		switch endpoint.Scheme {
+		case "tcp", "http", "https", "ssh":
+			host = endpoint.Hostname()
+		}
+	}
+	if dock.isDesktop() {
+		return desktopHost(host, inContainer())
+	}
+	return host
+}
+
+// Journal returns the journal set with WithJournal, or nil.
+func (dock *Dock) Journal() *twsapi.Journal {
+	return dock.journal
+}
@@ -0,0 +1,103 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"time"
+)
+
+// EventKind is what happened to a Dock, see Event.
+type EventKind string
+
+const (
+	// EventCreated and EventStarted follow creating and starting the
+	// container, by StartNew or a restart.
+	EventCreated EventKind = "created"
+	EventStarted EventKind = "started"
+	// EventPullProgress reports the progress of pulling a missing image,
+	// every few seconds and when a layer is done.
+	EventPullProgress EventKind = "pull_progress"
+	// EventReady is sent when WaitReady finds the gateway logged in.
+	EventReady EventKind = "ready"
+	// EventExecStarted and EventExecFinished bracket each Exec and
+	// ExecStream.
+	EventExecStarted  EventKind = "exec_started"
+	EventExecFinished EventKind = "exec_finished"
+	// EventUnhealthy is sent when Monitor finds the session unhealthy or
+	// dead.
+	EventUnhealthy EventKind = "unhealthy"
+	// EventRestarted is sent when a dead container was recreated, see
+	// WithAutoRestart.
+	EventRestarted EventKind = "restarted"
+	// EventStopped is sent when Stop or Kill removed the container.
+	EventStopped EventKind = "stopped"
+)
+
+// Event is a structured record of a Dock's lifecycle, for dashboards and
+// alerting, see Subscribe.
+type Event struct {
+	Kind      EventKind
+	Time      time.Time
+	Container string
+	// Cmd is the command of exec events.
+	Cmd []string
+	// ExitCode and Duration describe finished execs.
+	ExitCode int
+	Duration time.Duration
+	// Report is the provenance of finished execs.
+	Report *ExecReport
+	// Pull is the progress of pull events.
+	Pull *PullProgress
+	// Err is why an exec failed, the session is unhealthy or the container
+	// was restarted.
+	Err error
+}
+
+// subscriberBuffer is the capacity of the channels Subscribe returns.
+const subscriberBuffer = 64
+
+// WithEvents sends the Dock's events to events from the start, so they
+// include the container's creation; see Subscribe.
+func WithEvents(events chan<- Event) Option {
+	return func(dock *Dock) {
+		dock.subscribers = append(dock.subscribers, events)
+	}
+}
+
+// Subscribe returns a channel receiving the Dock's events from now on, and a
+// function ending the subscription, which closes the channel. Events are
+// dropped rather than wait for subscribers that fall behind.
+func (dock *Dock) Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+	dock.eventsMu.Lock()
+	dock.subscribers = append(dock.subscribers, events)
+	dock.eventsMu.Unlock()
+	cancel := func() {
+		dock.eventsMu.Lock()
+		defer dock.eventsMu.Unlock()
+		for i, subscriber := range dock.subscribers {
+			if subscriber == events {
+				dock.subscribers = append(dock.subscribers[:i], dock.subscribers[i+1:]...)
+				close(events)
+				return
+			}
+		}
+	}
+	return events, cancel
+}
+
+// emit sends event to the subscribers that have room for it.
+func (dock *Dock) emit(event Event) {
+	event.Time = clock.OrReal(dock.clock).Now()
+	event.Err = dock.redactor.Error(event.Err)
+	if event.Container == "" {
+		event.Container = dock.current().ID
+	}
+	dock.eventsMu.Lock()
+	defer dock.eventsMu.Unlock()
+	for _, subscriber := range dock.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result { return ibdocktest.Result{ExitCode: 3} })
+	early := make(chan Event, 10)
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithEvents(early))
+	if err != nil {
+		t.Fatal(err)
+	}
+	late, cancel := dock.Subscribe()
+	if _, err := dock.Exec(context.Background(), []string{"false"}, ExecOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dock.Kill()
+	cancel()
+
+	var kinds []EventKind
+	for len(early) > 0 {
+		event := <-early
+		if event.Container != dock.ContainerID() || event.Time.IsZero() {
+			t.Errorf("event %+v", event)
+		}
+		if event.Kind == EventExecFinished && (event.ExitCode != 3 || !slices.Equal(event.Cmd, []string{"false"})) {
+			t.Errorf("exec finished event %+v", event)
+		}
+		kinds = append(kinds, event.Kind)
+	}
+	if want := []EventKind{EventCreated, EventStarted, EventExecStarted, EventExecFinished, EventStopped}; !slices.Equal(kinds, want) {
+		t.Errorf("WithEvents got %v, want %v", kinds, want)
+	}
+	kinds = nil
+	for event := range late {
+		kinds = append(kinds, event.Kind)
+	}
+	if want := []EventKind{EventExecStarted, EventExecFinished, EventStopped}; !slices.Equal(kinds, want) {
+		t.Errorf("Subscribe got %v, want %v", kinds, want)
+	}
+}
+
+func TestEventsTimedByClock(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	fake := clock.NewFake(time.Date(2026, time.January, 29, 16, 30, 0, 0, time.UTC))
+	events := make(chan Event, 10)
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithClock(fake), WithEvents(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Hour)
+	dock.Kill()
+
+	want := []time.Time{fake.Now().Add(-time.Hour), fake.Now().Add(-time.Hour), fake.Now()}
+	var got []time.Time
+	for len(events) > 0 {
+		got = append(got, (<-events).Time)
+	}
+	if !slices.EqualFunc(got, want, time.Time.Equal) {
+		t.Errorf("event times %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,449 @@
+package ibdock
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ExecOptions configures a command run inside the container by Dock.Exec.
+type ExecOptions struct {
+	// Env holds extra "KEY=value" entries added to the container's
+	// environment for this command only.
+	Env        []string
+	WorkingDir string
+	// Stdin, if set, is copied to the command's standard input.
+	Stdin io.Reader
+	// Stdout and Stderr receive the command's output as it is produced.
+	// When nil, the output is collected into ExecResult instead.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Interleaved, if set, also records both streams in the order the
+	// command wrote them, in ExecResult.Output, to tell which output led up
+	// to an error.
+	Interleaved bool
+	// MaxOutputBytes, if positive, caps how much a command may write to
+	// each stream. Going over fails the call with ErrOutputTooLarge.
+	MaxOutputBytes int64
+	// Timeout, if positive, bounds how long the command may run, apart from
+	// how long ctx lets the caller wait. Going over kills the command and
+	// fails the call with ErrExecTimeout.
+	Timeout time.Duration
+	// MaxCaptureBytes caps how much of each stream ExecResult collects, and
+	// of both in Output, 16 MiB if zero and no cap if negative. Past it,
+	// the command runs on but only the first and last halves of the cap
+	// are kept, with a line marking what was left out of Stdout and Stderr
+	// between them, so a runaway command cannot exhaust memory.
+	MaxCaptureBytes int64
+}
+
+// ExecResult describes a finished command. Stdout and Stderr are only filled
+// in for streams that had no writer in ExecOptions.
+type ExecResult struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	// Output is what the command wrote if ExecOptions.Interleaved is set,
+	// including when it timed out or was killed.
+	Output Output
+	// StdoutTruncated, StderrTruncated and OutputTruncated count the bytes
+	// left out of Stdout, Stderr and Output for ExecOptions.MaxCaptureBytes.
+	StdoutTruncated int64
+	StderrTruncated int64
+	OutputTruncated int64
+	// Report is filled in even if the call fails.
+	Report ExecReport
+}
+
+// ExecReport is the provenance of one exec, for logging alongside what it
+// produced. It marshals to JSON as is.
+type ExecReport struct {
+	ContainerID string
+	Cmd         []string
+	Start       time.Time
+	End         time.Time
+	Duration    time.Duration
+	ExitCode    int
+	// StdoutBytes and StderrBytes count what the command wrote, including
+	// to writers of ExecOptions.
+	StdoutBytes int64
+	StderrBytes int64
+	// Retries is how many attempts failed before this one, when the exec
+	// is made under RetryPolicy.Do or a WithRetry session.
+	Retries int
+	// Truncated is set if output was left out of the result, see
+	// ExecOptions.MaxCaptureBytes.
+	Truncated bool `json:",omitempty"`
+	// Err is why the call failed, if it did.
+	Err string `json:",omitempty"`
+	// Call is the call the exec was made in, whose ID its log lines carry.
+	Call Call
+}
+
+func (dock *Dock) startReport(ctx context.Context, cmd []string) ExecReport {
+	return ExecReport{ContainerID: dock.current().ID, Cmd: cmd, Start: clock.OrReal(dock.clock).Now(), Retries: retries(ctx)}
+}
+
+func (r *ExecReport) finish(call *callState, end time.Time, exitCode int, stdout, stderr *countingWriter, err error) {
+	r.Call = call.current()
+	r.End = end
+	r.Duration = r.End.Sub(r.Start)
+	r.ExitCode = exitCode
+	r.StdoutBytes = stdout.n.Load()
+	r.StderrBytes = stderr.n.Load()
+	if err != nil {
+		r.Err = err.Error()
+	}
+}
+
+// ExitError reports a command that exited with a non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.Code)
+}
+
+// ErrOutputTooLarge is returned when a command writes more than
+// ExecOptions.MaxOutputBytes to one of its streams.
+var ErrOutputTooLarge = errors.New("exec output exceeds MaxOutputBytes")
+
+// ErrOutputTruncated is returned when output a call needs whole was cut
+// down to ExecOptions.MaxCaptureBytes.
+var ErrOutputTruncated = errors.New("exec output exceeds MaxCaptureBytes")
+
+// ErrExecTimeout is returned when a command runs longer than
+// ExecOptions.Timeout.
+var ErrExecTimeout = errors.New("exec exceeded its timeout")
+
+// ErrSnapshotTimeout is returned when the snapshot script does not finish
+// within the snapshot timeout.
+var ErrSnapshotTimeout = errors.New("Timed out waiting to get stocks")
+
+// Exec runs cmd inside the container and waits until it exits or ctx is done.
+// A non-zero exit code is reported in the result, not as an error. If ctx is
+// done or the timeout passes first, the command is killed. Execs wait for the
+// Dock's rate limit, see WithRateLimit. Errors are *CallErrors, see Call.
+func (dock *Dock) Exec(ctx context.Context, cmd []string, opts ExecOptions) (_ ExecResult, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	if err := dock.pace(ctx); err != nil {
+		return ExecResult{}, call.wrap(err)
+	}
+	if err := dock.ensureRunning(ctx); err != nil {
+		return ExecResult{}, call.wrap(err)
+	}
+	dock.emit(Event{Kind: EventExecStarted, Cmd: cmd})
+	result, err := dock.execCurrent(ctx, cmd, opts)
+	report := result.Report
+	dock.emit(Event{Kind: EventExecFinished, Cmd: cmd, ExitCode: result.ExitCode, Duration: report.Duration, Err: err, Report: &report})
+	return result, err
+}
+
+// execCurrent is Exec without the restart, for Screenshot, which WaitReady
+// calls while restarting.
+func (dock *Dock) execCurrent(ctx context.Context, cmd []string, opts ExecOptions) (ExecResult, error) {
+	ctx, call := dock.beginCall(ctx, "")
+	report := dock.startReport(ctx, cmd)
+	var stdout, stderr countingWriter
+	result, err := dock.execCounted(ctx, cmd, opts, &stdout, &stderr)
+	err = call.wrap(err)
+	report.finish(call, clock.OrReal(dock.clock).Now(), result.ExitCode, &stdout, &stderr, err)
+	report.Truncated = result.StdoutTruncated+result.StderrTruncated+result.OutputTruncated > 0
+	result.Report = report
+	return result, err
+}
+
+// execCounted is execCurrent counting the output in stdoutCount and
+// stderrCount.
+func (dock *Dock) execCounted(ctx context.Context, cmd []string, opts ExecOptions, stdoutCount, stderrCount *countingWriter) (ExecResult, error) {
+	var result ExecResult
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var overflow atomic.Bool
+	limit := func(w io.Writer) io.Writer {
+		if opts.MaxOutputBytes <= 0 {
+			return w
+		}
+		return &limitedWriter{w: w, remaining: opts.MaxOutputBytes, exceeded: func() {
+			overflow.Store(true)
+			cancel()
+		}}
+	}
+	capture := captureLimit(opts)
+	stdout, stderr := captureBuffer{limit: capture}, captureBuffer{limit: capture}
+	stdoutWriter, stderrWriter := opts.Stdout, opts.Stderr
+	if stdoutWriter == nil {
+		stdoutWriter = &stdout
+	}
+	if stderrWriter == nil {
+		stderrWriter = &stderr
+	}
+	stdoutWriter = stdoutCount.wrap(stdoutWriter)
+	stderrWriter = stderrCount.wrap(stderrWriter)
+	recorder := outputRecorder{limit: capture, clock: dock.clock}
+	if opts.Interleaved {
+		stdoutWriter = recorder.tee(StreamStdout, stdoutWriter)
+		stderrWriter = recorder.tee(StreamStderr, stderrWriter)
+	}
+	exec, err := dock.startExec(ctx, cmd, opts, limit(stdoutWriter), limit(stderrWriter))
+	if err != nil {
+		return result, err
+	}
+	result.ExitCode, err = dock.waitExec(ctx, exec, opts.Timeout)
+	if opts.Interleaved {
+		result.Output, result.OutputTruncated = recorder.recorded()
+	}
+	if overflow.Load() {
+		return result, ErrOutputTooLarge
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Stdout, result.StdoutTruncated = stdout.Bytes(), stdout.dropped()
+	result.Stderr, result.StderrTruncated = stderr.Bytes(), stderr.dropped()
+	return result, nil
+}
+
+// ExecStream starts cmd inside the container and returns its standard output
+// as a stream, without buffering it in memory. Reading returns an *ExitError
+// instead of io.EOF if the command fails. Closing the reader early kills the
+// command.
+func (dock *Dock) ExecStream(ctx context.Context, cmd []string, opts ExecOptions) (_ io.ReadCloser, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	if err := dock.pace(ctx); err != nil {
+		return nil, call.wrap(err)
+	}
+	if err := dock.ensureRunning(ctx); err != nil {
+		return nil, call.wrap(err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	reader, writer := io.Pipe()
+	if opts.Stderr == nil {
+		opts.Stderr = io.Discard
+	}
+	var stdoutCount, stderrCount countingWriter
+	var overflow atomic.Bool
+	var stdout io.Writer = stdoutCount.wrap(writer)
+	if opts.MaxOutputBytes > 0 {
+		stdout = &limitedWriter{w: stdout, remaining: opts.MaxOutputBytes, exceeded: func() {
+			overflow.Store(true)
+			cancel()
+		}}
+	}
+	dock.emit(Event{Kind: EventExecStarted, Cmd: cmd})
+	report := dock.startReport(ctx, cmd)
+	exec, err := dock.startExec(ctx, cmd, opts, stdout, stderrCount.wrap(opts.Stderr))
+	if err != nil {
+		cancel()
+		err = call.wrap(err)
+		report.finish(call, clock.OrReal(dock.clock).Now(), 0, &stdoutCount, &stderrCount, err)
+		dock.emit(Event{Kind: EventExecFinished, Cmd: cmd, Duration: report.Duration, Err: err, Report: &report})
+		return nil, err
+	}
+	go func() {
+		defer cancel()
+		exitCode, err := dock.waitExec(ctx, exec, opts.Timeout)
+		err = call.wrap(err)
+		report.finish(call, clock.OrReal(dock.clock).Now(), exitCode, &stdoutCount, &stderrCount, err)
+		dock.emit(Event{Kind: EventExecFinished, Cmd: cmd, ExitCode: exitCode, Duration: report.Duration, Err: err, Report: &report})
+		if overflow.Load() {
+			err = call.wrap(ErrOutputTooLarge)
+		} else if err == nil && exitCode != 0 {
+			err = call.wrap(&ExitError{Code: exitCode})
+		}
+		writer.CloseWithError(dock.redactor.Error(err))
+	}()
+	return &execReader{PipeReader: reader, cancel: cancel}, nil
+}
+
+type execReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *execReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// execEnv marks the processes of an exec, so killExec can find them: Docker
+// has no call to kill an exec, and the PID it reports is the host's.
+const execEnv = "IBDOCK_EXEC"
+
+// killScript sends $1 to every process whose environment has the entry $0.
+const killScript = `k() {
+	for p in /proc/[0-9]*; do
+		tr '\0' '\n' <"$p/environ" 2>/dev/null | grep -qx "$1" && kill -"$2" "${p#/proc/}" 2>/dev/null
+	done
+}
+k "$0" TERM
+sleep 1
+k "$0" KILL
+true`
+
+// killTimeout bounds killing an exec after the caller stopped waiting.
+const killTimeout = 10 * time.Second
+
+// runningExec is an exec started by startExec.
+type runningExec struct {
+	id string
+	// call is the call that started it, to log to.
+	call *callState
+	// marker is the execEnv entry its processes carry.
+	marker string
+	// wait returns once its output has been copied out.
+	wait func() error
+}
+
+func (dock *Dock) startExec(ctx context.Context, cmd []string, opts ExecOptions, stdout, stderr io.Writer) (runningExec, error) {
+	exec := runningExec{call: dock.callFrom(ctx), marker: execEnv + "=" + rand.Text()}
+	opts.Env = append(slices.Clip(opts.Env), exec.marker)
+	exec.call.Println("Starting exec")
+	id := dock.current().ID
+	var err error
+	exec.id, exec.wait, err = dock.client.startExec(ctx, id, cmd, opts, stdout, stderr)
+	if err != nil {
+		return runningExec{}, err
+	}
+	exec.call.setExec(id, exec.id)
+	exec.call.Println("Execution started")
+	return exec, nil
+}
+
+// waitExec waits until the exec exits and its output has been copied out,
+// and returns its exit code. The output stream ending usually means the
+// process is gone, so that prompts an early look; otherwise it polls. If ctx
+// is done or the timeout passes first, it kills the exec.
+func (dock *Dock) waitExec(ctx context.Context, exec runningExec, timeout time.Duration) (int, error) {
+	pollInterval := 5 * time.Second
+	copied := make(chan error, 1)
+	go func() { copied <- exec.wait() }()
+	clk := clock.OrReal(dock.clock)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = clk.After(timeout)
+	}
+	var copyErr error
+	copyDone := false
+	for {
+		select {
+		case copyErr = <-copied:
+			copyDone = true
+			copied = nil
+		case <-clk.After(pollInterval):
+		case <-expired:
+			dock.killExec(exec)
+			return 0, ErrExecTimeout
+		case <-ctx.Done():
+			dock.killExec(exec)
+			return 0, ctx.Err()
+		}
+		running, exitCode, err := dock.client.inspectExec(ctx, exec.id)
+		if err != nil {
+			return 0, err
+		}
+		if !running {
+			exec.call.Println("finished with exit code", exitCode)
+			if !copyDone {
+				// The process is gone, but its output may still be in
+				// flight.
+				copyErr = <-copied
+			}
+			return exitCode, copyErr
+		}
+		exec.call.Println("not finished yet")
+	}
+}
+
+// killExec kills the processes of an exec with a follow-up exec, first
+// asking them to terminate.
+func (dock *Dock) killExec(exec runningExec) {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+	exec.call.Println("Killing exec", exec.id)
+	_, wait, err := dock.client.startExec(ctx, dock.current().ID, []string{"sh", "-c", killScript, exec.marker}, ExecOptions{}, io.Discard, io.Discard)
+	if err == nil {
+		err = wait()
+	}
+	if err != nil {
+		exec.call.Println("Cannot kill exec", exec.id+":", err)
+	}
+}
+
+// limitedWriter fails writes once more than remaining bytes went through it.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+	exceeded  func()
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		l.exceeded()
+		return 0, ErrOutputTooLarge
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
+
+// countingWriter counts the bytes written through it to w.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) wrap(w io.Writer) io.Writer {
+	c.w = w
+	return c
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// RunExec runs the snapshot script and returns its JSON output. Its
+// ExecReport comes with the EventExecFinished event, see Subscribe.
+func (dock *Dock) RunExec() (_ []byte, err error) {
+	defer dock.redactError(&err)
+	return dock.readSnapshot(context.Background(), SnapshotRequest{})
+}
+
+func (dock *Dock) readSnapshot(ctx context.Context, request SnapshotRequest) ([]byte, error) {
+	cmd, err := request.cmdline()
+	if err != nil {
+		return nil, err
+	}
+	result, err := dock.Exec(ctx, cmd, ExecOptions{Env: request.env(), Timeout: dock.snapshotTimeout(), Interleaved: true})
+	output := result.Output
+	if dock.redactor != nil {
+		// The snapshot on standard output is balances and positions.
+		output = output.only(StreamStderr)
+	}
+	if len(output) > 0 {
+		dock.callFrom(ctx).Print("read_snapshot.py output:\n", output)
+	}
+	if errors.Is(err, ErrExecTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrSnapshotTimeout
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, scriptPacing(&ExitError{Code: result.ExitCode}, result.Stderr)
+	}
+	if result.StdoutTruncated > 0 {
+		return nil, fmt.Errorf("%w: the snapshot is %d bytes", ErrOutputTruncated, len(result.Stdout)+int(result.StdoutTruncated))
+	}
+	return result.Stdout, nil
+}
@@ -0,0 +1,291 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		var mu sync.Mutex
+		var execs []ibdocktest.Exec
+		server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			execs = append(execs, exec)
+			return ibdocktest.Result{Stdout: []byte("out\n"), Stderr: []byte("err\n"), ExitCode: 3}
+		})
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+
+		// A failing command is a result, not an error.
+		result, err := dock.Exec(context.Background(), []string{"cat"}, ExecOptions{Env: []string{"MODE=test"}, WorkingDir: "/tmp", Stdin: strings.NewReader("input")})
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if result.ExitCode != 3 || string(result.Stdout) != "out\n" || string(result.Stderr) != "err\n" {
+			t.Errorf("%s: exit code %d, stdout %q, stderr %q", backend.name, result.ExitCode, result.Stdout, result.Stderr)
+		}
+		mu.Lock()
+		exec := execs[len(execs)-1]
+		mu.Unlock()
+		if !slices.Equal(exec.Cmd, []string{"cat"}) || !slices.Contains(exec.Env, "MODE=test") || exec.WorkingDir != "/tmp" || string(exec.Stdin) != "input" {
+			t.Errorf("%s: ran %+v", backend.name, exec)
+		}
+
+		// Streams with a writer go there instead of into the result.
+		var stdout strings.Builder
+		result, err = dock.Exec(context.Background(), []string{"cat"}, ExecOptions{Stdout: &stdout})
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if stdout.String() != "out\n" || result.Stdout != nil || string(result.Stderr) != "err\n" {
+			t.Errorf("%s: wrote %q, stdout %q, stderr %q", backend.name, stdout.String(), result.Stdout, result.Stderr)
+		}
+		mu.Lock()
+		exec = execs[len(execs)-1]
+		mu.Unlock()
+		if exec.Stdin != nil {
+			t.Errorf("%s: stdin %q attached without ExecOptions.Stdin", backend.name, exec.Stdin)
+		}
+	}
+}
+
+func TestExecKilled(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		var mu sync.Mutex
+		var marker string
+		var kills [][]string
+		server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			if exec.Cmd[0] == "sleep" {
+				for _, env := range exec.Env {
+					if strings.HasPrefix(env, execEnv+"=") {
+						marker = env
+					}
+				}
+				return ibdocktest.Result{Delay: time.Minute}
+			}
+			kills = append(kills, exec.Cmd)
+			return ibdocktest.Result{}
+		})
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		killed := func(what string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if marker == "" || len(kills) != 1 || !slices.Equal(kills[0], []string{"sh", "-c", killScript, marker}) {
+				t.Errorf("%s: %s: marker %q, kill execs %q", backend.name, what, marker, kills)
+			}
+			marker, kills = "", nil
+		}
+
+		start := time.Now()
+		if _, err := dock.Exec(context.Background(), []string{"sleep", "60"}, ExecOptions{Timeout: 100 * time.Millisecond}); !errors.Is(err, ErrExecTimeout) {
+			t.Errorf("%s: Exec past its timeout = %v, want ErrExecTimeout", backend.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > killTimeout {
+			t.Errorf("%s: Exec took %v", backend.name, elapsed)
+		}
+		killed("timeout")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, err := dock.Exec(ctx, []string{"sleep", "60"}, ExecOptions{Timeout: time.Hour}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: Exec past the deadline = %v, want DeadlineExceeded", backend.name, err)
+		}
+		killed("deadline")
+	}
+}
+
+func TestExecStream(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		killed := make(chan struct{}, 1)
+		server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+			switch exec.Cmd[0] {
+			case "print":
+				return ibdocktest.Result{Stdout: []byte("line 1\nline 2\n"), Stderr: []byte("warning\n")}
+			case "fail":
+				return ibdocktest.Result{Stdout: []byte("partial\n"), ExitCode: 2}
+			case "sleep":
+				return ibdocktest.Result{Delay: time.Minute}
+			}
+			select {
+			case killed <- struct{}{}:
+			default:
+			}
+			return ibdocktest.Result{}
+		})
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		ctx := context.Background()
+		read := func(cmd string, opts ExecOptions) (string, error) {
+			stream, err := dock.ExecStream(ctx, []string{cmd}, opts)
+			if err != nil {
+				return "", err
+			}
+			defer stream.Close()
+			data, err := io.ReadAll(stream)
+			return string(data), err
+		}
+
+		if out, err := read("print", ExecOptions{}); out != "line 1\nline 2\n" || err != nil {
+			t.Errorf("%s: streamed %q, %v", backend.name, out, err)
+		}
+		var exitErr *ExitError
+		if out, err := read("fail", ExecOptions{}); out != "partial\n" || !errors.As(err, &exitErr) || exitErr.Code != 2 {
+			t.Errorf("%s: streamed %q, %v, want exit code 2 instead of EOF", backend.name, out, err)
+		}
+		if _, err := read("print", ExecOptions{MaxOutputBytes: 4}); !errors.Is(err, ErrOutputTooLarge) {
+			t.Errorf("%s: stream over MaxOutputBytes = %v", backend.name, err)
+		}
+
+		// Exec caps each stream the same way.
+		if _, err := dock.Exec(ctx, []string{"print"}, ExecOptions{MaxOutputBytes: 14}); err != nil {
+			t.Errorf("%s: Exec within MaxOutputBytes = %v", backend.name, err)
+		}
+		if _, err := dock.Exec(ctx, []string{"print"}, ExecOptions{MaxOutputBytes: 13}); !errors.Is(err, ErrOutputTooLarge) {
+			t.Errorf("%s: Exec over MaxOutputBytes = %v", backend.name, err)
+		}
+
+		// Closing early gives up on the command and kills it.
+		stream, err := dock.ExecStream(ctx, []string{"sleep"}, ExecOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		stream.Close()
+		select {
+		case <-killed:
+		case <-time.After(killTimeout):
+			t.Errorf("%s: closing the stream did not kill the command", backend.name)
+		}
+	}
+}
+
+func TestExecInterleaved(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+			return ibdocktest.Result{ExitCode: 1, Frames: []ibdocktest.Frame{
+				{Data: []byte("connecting\n")},
+				{Stderr: true, Data: []byte("error 502\nretrying")},
+				{Data: []byte("connected\n")},
+			}}
+		})
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		result, err := dock.Exec(context.Background(), []string{"read_snapshot.py"}, ExecOptions{Interleaved: true})
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if string(result.Stdout) != "connecting\nconnected\n" || string(result.Stderr) != "error 502\nretrying" {
+			t.Errorf("%s: stdout %q, stderr %q", backend.name, result.Stdout, result.Stderr)
+		}
+		var lines []string
+		for line := range strings.Lines(result.Output.String()) {
+			// Drop the time.
+			_, line, _ = strings.Cut(line, " ")
+			lines = append(lines, line)
+		}
+		want := []string{"stdout: connecting\n", "stderr: error 502\n", "stderr: retrying\n", "stdout: connected\n"}
+		if !slices.Equal(lines, want) {
+			t.Errorf("%s: Output = %q, want %q", backend.name, lines, want)
+		}
+		if result, _ := dock.Exec(context.Background(), []string{"read_snapshot.py"}, ExecOptions{}); result.Output != nil {
+			t.Errorf("%s: Output recorded without Interleaved", backend.name)
+		}
+	}
+}
+
+func TestExecReport(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stdout: []byte("{}\n"), Stderr: []byte("error 502"), ExitCode: 1}
+	})
+	events := make(chan Event, 10)
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithEvents(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	var result ExecResult
+	err = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}.Do(context.Background(), func(ctx context.Context) error {
+		attempts++
+		result, err = dock.Exec(ctx, []string{"read_snapshot.py"}, ExecOptions{Stderr: io.Discard})
+		if attempts == 1 {
+			return &PacingError{Err: &ExitError{Code: result.ExitCode}}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := result.Report
+	if report.ContainerID != dock.ContainerID() || !slices.Equal(report.Cmd, []string{"read_snapshot.py"}) ||
+		report.ExitCode != 1 || report.StdoutBytes != 3 || report.StderrBytes != 9 || report.Retries != 1 || report.Err != "" ||
+		report.Start.IsZero() || report.End.Sub(report.Start) != report.Duration {
+		t.Errorf("report %+v", report)
+	}
+	var finished []*ExecReport
+	for len(events) > 0 {
+		if event := <-events; event.Kind == EventExecFinished {
+			finished = append(finished, event.Report)
+		}
+	}
+	if len(finished) != 2 || finished[0].Retries != 0 || finished[1].Start != report.Start {
+		t.Errorf("exec_finished reports %+v", finished)
+	}
+}
@@ -0,0 +1,197 @@
+// Package export packages an account's stored history into a zip to hand to
+// an accountant or advisor: a statement of holdings for each month, the
+// monthly performance report and the current allocation, each as CSV and PDF.
+//
+//	snapshots, err := s.Range(ctx, "U1234567", from, to)
+//	report, err := performance.Monthly(snapshots, transactions, rates)
+//	bundle := export.Bundle{Base: "USD", Rates: rates, Snapshots: snapshots, Performance: report}
+//	err = bundle.WriteZip(f)
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/performance"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Bundle is what goes into the zip.
+type Bundle struct {
+	// Base is the currency of Rates, for headings.
+	Base  string
+	Rates snapshot.FXRates
+	// Snapshots are of one account, oldest first. The last one of each
+	// month is that month's statement, the last overall the allocation.
+	Snapshots []*snapshot.Snapshot
+	// Performance, if set, is included as the performance report.
+	Performance *performance.Report
+	// Rounding, if set, rounds amounts and percentages instead of
+	// rounding.Default.
+	Rounding *rounding.Policy
+	// Locale, if set, is the language of the reports instead of English. It
+	// also decides how the CSV files write numbers and dates, for
+	// spreadsheets set to the same locale.
+	Locale *locale.Locale
+}
+
+// table is one report, written both as CSV and as an aligned PDF.
+type table struct {
+	// name is the file name without extension.
+	name   string
+	title  []string
+	header []string
+	rows   [][]string
+}
+
+func (t table) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.header)
+	cw.WriteAll(t.rows)
+	return cw.Error()
+}
+
+func (t table) lines() []string {
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, strings.Join(t.header, "\t")+"\t")
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t")+"\t")
+	}
+	tw.Flush()
+	lines := append(t.title, "")
+	return append(lines, strings.Split(strings.TrimRight(b.String(), "\n"), "\n")...)
+}
+
+// WriteZip writes the bundle as a zip archive to w.
+func (b Bundle) WriteZip(w io.Writer) error {
+	if len(b.Snapshots) == 0 {
+		return errors.New("export: no snapshots")
+	}
+	var tables []table
+	for i, s := range b.Snapshots {
+		if i+1 < len(b.Snapshots) && month(b.Snapshots[i+1].Timestamp) == month(s.Timestamp) {
+			continue
+		}
+		statement, err := b.statement(s)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, statement)
+	}
+	if b.Performance != nil {
+		tables = append(tables, b.performance())
+	}
+	allocation, err := b.allocation(b.Snapshots[len(b.Snapshots)-1])
+	if err != nil {
+		return err
+	}
+	tables = append(tables, allocation)
+
+	modified := b.Snapshots[len(b.Snapshots)-1].Timestamp
+	zw := zip.NewWriter(w)
+	for _, t := range tables {
+		for _, format := range []struct {
+			ext   string
+			write func(io.Writer) error
+		}{
+			{".csv", t.writeCSV},
+			{".pdf", func(w io.Writer) error { return writePDF(w, t.lines()) }},
+		} {
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: t.name + format.ext, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				return err
+			}
+			if err := format.write(fw); err != nil {
+				return fmt.Errorf("export: %s%s: %w", t.name, format.ext, err)
+			}
+		}
+	}
+	return zw.Close()
+}
+
+func month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func account(s *snapshot.Snapshot) string {
+	if s.AccountName != "" {
+		return fmt.Sprintf("%s (%s)", s.AccountName, s.Account)
+	}
+	return s.Account
+}
+
+func (b Bundle) statement(s *snapshot.Snapshot) (table, error) {
+	p := b.printer()
+	t := table{
+		name:   "statements/" + month(s.Timestamp),
+		title:  []string{p.Sprintf("Statement of %s at %s", account(s), p.DateTime(s.Timestamp.UTC()))},
+		header: []string{p.T("Symbol"), p.T("Type"), p.T("Currency"), p.T("Quantity"), p.T("Avg cost"), p.T("Price"), p.T("Market value"), p.Sprintf("Value (%s)", b.Base)},
+	}
+	converted, err := s.ConvertTo(b.Base, b.Rates)
+	if err != nil {
+		return table{}, err
+	}
+	for _, position := range converted.Positions {
+		t.rows = append(t.rows, []string{
+			position.Symbol, position.SecType, position.Currency,
+			p.Number(position.Quantity.Float64()), p.MoneyDecimal(position.AvgCost), p.MoneyDecimal(position.MarketPrice), p.MoneyDecimal(position.MarketValue), p.MoneyDecimal(position.Value),
+		})
+	}
+	t.rows = append(t.rows, []string{p.T("Total"), "", "", "", "", "", "", p.MoneyDecimal(converted.Total)})
+	return t, nil
+}
+
+func (b Bundle) performance() table {
+	p := b.printer()
+	r := b.Performance
+	t := table{
+		name: "performance",
+		title: []string{
+			p.Sprintf("Performance of %s in %s", account(b.Snapshots[0]), b.Base),
+			p.Sprintf("Savings rate: %s/month", p.Money(r.SavingsRate)),
+		},
+		header: []string{p.T("Month"), p.T("Start"), p.T("End"), p.T("Contributions"), p.T("Growth")},
+	}
+	for _, m := range r.Months {
+		t.rows = append(t.rows, []string{p.Month(m.Start), p.MoneyDecimal(m.StartValue), p.MoneyDecimal(m.EndValue), p.MoneyDecimal(m.Contributions), p.MoneyDecimal(m.Growth)})
+	}
+	t.rows = append(t.rows, []string{p.T("Total"), "", "", p.MoneyDecimal(r.Contributions), p.MoneyDecimal(r.Growth)})
+	return t
+}
+
+func (b Bundle) allocation(s *snapshot.Snapshot) (table, error) {
+	share, err := s.Share(b.Rates)
+	if err != nil {
+		return table{}, err
+	}
+	p := b.printer()
+	t := table{
+		name: "allocation",
+		title: []string{
+			p.Sprintf("Allocation of %s at %s", account(s), p.DateTime(s.Timestamp.UTC())),
+			p.Sprintf("Unrealized return on cost: %s", p.Percent(share.Return)),
+		},
+		header: []string{p.T("Symbol"), p.T("Type"), p.T("Weight"), p.T("Return")},
+	}
+	for _, a := range share.Allocations {
+		t.rows = append(t.rows, []string{a.Symbol, a.SecType, p.Percent(a.Weight), p.Percent(a.Return)})
+	}
+	return t, nil
+}
+
+func (b Bundle) printer() locale.Printer {
+	p := locale.Printer{Policy: rounding.Default, Locale: b.Locale}
+	if b.Rounding != nil {
+		p.Policy = *b.Rounding
+	}
+	return p
+}
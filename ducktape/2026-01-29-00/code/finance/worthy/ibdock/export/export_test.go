@@ -0,0 +1,145 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/performance"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func holding(when time.Time, marketValue float64) *snapshot.Snapshot {
+	return &snapshot.Snapshot{Account: "U1111111", AccountName: "main", Timestamp: when, Positions: []snapshot.Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.DecimalFromFloat(10), AvgCost: snapshot.DecimalFromFloat(100), MarketPrice: snapshot.DecimalFromFloat(marketValue / 10), MarketValue: snapshot.DecimalFromFloat(marketValue)},
+		{Symbol: "EUR", SecType: "CASH", Quantity: snapshot.DecimalFromFloat(500)},
+	}}
+}
+
+func TestWriteZip(t *testing.T) {
+	rates := snapshot.FXRates{"USD": 1, "EUR": 1.2}
+	snapshots := []*snapshot.Snapshot{
+		holding(time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), 1000),
+		holding(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), 1100),
+		holding(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), 1400),
+	}
+	report, err := performance.Monthly(snapshots, nil, rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (Bundle{Base: "USD", Rates: rates, Snapshots: snapshots, Performance: report}).WriteZip(&out); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	var names []string
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+	}
+	wantNames := []string{
+		"statements/2026-01.csv", "statements/2026-01.pdf",
+		"statements/2026-02.csv", "statements/2026-02.pdf",
+		"performance.csv", "performance.pdf",
+		"allocation.csv", "allocation.pdf",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("files = %v, want %v", names, wantNames)
+	}
+
+	statement, err := csv.NewReader(bytes.NewReader(files["statements/2026-01.csv"])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The month's last snapshot, with EUR 500 at 1.2.
+	if last := statement[len(statement)-1]; last[0] != "Total" || last[7] != "1700.00" {
+		t.Errorf("statement total row = %v", last)
+	}
+	if got := string(files["performance.csv"]); !strings.Contains(got, "2026-02,1700.00,2000.00,0.00,300.00") {
+		t.Errorf("performance.csv = %s", got)
+	}
+	if got := string(files["allocation.csv"]); !strings.Contains(got, "VT,STK,70.00%,40.00%") {
+		t.Errorf("allocation.csv = %s", got)
+	}
+	for name, data := range files {
+		if strings.HasSuffix(name, ".pdf") && (!bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n"))) {
+			t.Errorf("%s is not a PDF: %.40q", name, data)
+		}
+	}
+	if !bytes.Contains(files["statements/2026-02.pdf"], []byte("(Statement of main \\(U1111111\\) at 2026-02-28 00:00 UTC) Tj")) {
+		t.Errorf("statement PDF lacks its escaped title")
+	}
+}
+
+func TestBundleRounding(t *testing.T) {
+	p := Bundle{Rounding: &rounding.Policy{Places: 1, Mode: rounding.HalfEven}}.printer()
+	if got := p.Money(0.25); got != "0.2" {
+		t.Errorf("Money(0.25) = %s, want 0.2", got)
+	}
+	if got := p.Percent(0.12345); got != "12.3%" {
+		t.Errorf("Percent(0.12345) = %s, want 12.3%%", got)
+	}
+	if got := (Bundle{}).printer().Money(0.125); got != "0.13" {
+		t.Errorf("default Money(0.125) = %s, want 0.13", got)
+	}
+}
+
+func TestBundleLocale(t *testing.T) {
+	b := Bundle{Base: "CZK", Rates: snapshot.FXRates{"USD": 20, "EUR": 25}, Locale: locale.Czech}
+	statement, err := b.statement(holding(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statement.header[2]; got != "Měna" {
+		t.Errorf("header %v", statement.header)
+	}
+	if total := statement.rows[len(statement.rows)-1][7]; total != "32\u00a0500,00" {
+		t.Errorf("total %q", total)
+	}
+	if got := pdfEscape("Výpis účtu"); got != "Vypis uctu" {
+		t.Errorf("pdfEscape = %q", got)
+	}
+}
+
+func TestWritePDFPages(t *testing.T) {
+	var lines []string
+	for i := range 2*linesPerPage + 1 {
+		lines = append(lines, fmt.Sprint("line ", i))
+	}
+	var out bytes.Buffer
+	if err := writePDF(&out, lines); err != nil {
+		t.Fatal(err)
+	}
+	pdf := out.String()
+	if got := strings.Count(pdf, "/Type /Page /Parent"); got != 3 {
+		t.Errorf("%d pages, want 3", got)
+	}
+	// The cross-reference table must point at the objects.
+	start := strings.Index(pdf, "xref\n")
+	for i, entry := range strings.Split(pdf[start:], "\n")[3:10] {
+		var offset int
+		fmt.Sscanf(entry, "%d", &offset)
+		if want := fmt.Sprintf("%d 0 obj", i+1); !strings.HasPrefix(pdf[offset:], want) {
+			t.Errorf("xref entry %d points at %.10q, want %q", i+1, pdf[offset:], want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package flex runs Flex queries through the IB Flex Web Service and decodes
+// the transaction history in their results. It follows the same protocol as
+// worthy's ibflex.rs: SendRequest returns a reference code, which is then
+// polled for the statement.
+//
+// The query must be an Activity Flex Query with the Trades and Cash
+// Transactions sections enabled.
+package flex
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const apiVersion = "3"
+const sendRequestEndpoint = "https://gdcdyn.interactivebrokers.com/Universal/servlet/FlexStatementService.SendRequest"
+
+// How many times, and how often, to poll for a statement that is not ready
+// yet.
+const maxRetries = 5
+
+const retryDelay = time.Second
+
+// Client runs one Flex query.
+type Client struct {
+	Token   string
+	QueryID string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Endpoint overrides the SendRequest URL.
+	Endpoint string
+	// Clock times the waits between retries, default clock.Real.
+	Clock clock.Clock
+}
+
+// Error is an error reported by the Flex Web Service.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Flex error: %d %s", e.Code, e.Message)
+}
+
+func (e *Error) retryable() bool {
+	switch e.Code {
+	case 1004, 1009, 1019:
+		return strings.Contains(e.Message, "Please try again shortly")
+	}
+	return false
+}
+
+type statementResponse struct {
+	Status        string `xml:"Status"`
+	ReferenceCode string `xml:"ReferenceCode"`
+	URL           string `xml:"Url"`
+	ErrorCode     int    `xml:"ErrorCode"`
+	ErrorMessage  string `xml:"ErrorMessage"`
+}
+
+// Run executes the query over the given date range and returns the raw
+// FlexQueryResponse XML. A zero from or to keeps the period configured in the
+// query.
+func (c *Client) Run(ctx context.Context, from, to time.Time) ([]byte, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = sendRequestEndpoint
+	}
+	params := url.Values{"t": {c.Token}, "q": {c.QueryID}, "v": {apiVersion}}
+	if !from.IsZero() {
+		params.Set("fd", from.Format("20060102"))
+	}
+	if !to.IsZero() {
+		params.Set("td", to.Format("20060102"))
+	}
+	body, err := c.get(ctx, endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+	var response statementResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != "Success" {
+		return nil, &Error{Code: response.ErrorCode, Message: response.ErrorMessage}
+	}
+	params = url.Values{"t": {c.Token}, "q": {response.ReferenceCode}, "v": {apiVersion}}
+	for retries := 0; ; retries++ {
+		body, err := c.get(ctx, response.URL, params)
+		if err != nil {
+			return nil, err
+		}
+		var status struct {
+			ErrorCode    int    `xml:"ErrorCode"`
+			ErrorMessage string `xml:"ErrorMessage"`
+		}
+		if err := xml.Unmarshal(body, &status); err != nil {
+			return nil, err
+		}
+		if status.ErrorCode == 0 {
+			return body, nil
+		}
+		flexErr := &Error{Code: status.ErrorCode, Message: status.ErrorMessage}
+		if !flexErr.retryable() || retries >= maxRetries {
+			return nil, flexErr
+		}
+		select {
+		case <-clock.OrReal(c.Clock).After(retryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, params url.Values) ([]byte, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = params.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Flex Web Service returned %s", response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
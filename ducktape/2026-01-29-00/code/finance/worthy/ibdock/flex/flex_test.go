@@ -0,0 +1,104 @@
+package flex
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const transactionsXML = `<FlexQueryResponse queryName="Worthy" type="AF">
+<FlexStatements count="1">
+<FlexStatement accountId="U99999" fromDate="20260101" toDate="20260131" period="Custom" whenGenerated="20260201;101500">
+<Trades>
+<Trade accountId="U99999" currency="USD" assetCategory="STK" symbol="ABCD" dateTime="20260105;153012" quantity="10" tradePrice="11.5" ibCommission="-1" ibCommissionCurrency="USD" tradeID="111" levelOfDetail="EXECUTION" />
+<Trade accountId="U99999" currency="USD" assetCategory="STK" symbol="ABCD" dateTime="20260105;153012" quantity="10" tradePrice="11.5" ibCommission="-1" ibCommissionCurrency="USD" tradeID="" levelOfDetail="ORDER" />
+<Trade accountId="U99999" currency="EUR" assetCategory="STK" symbol="EFGH" dateTime="20260220;100000" quantity="-2" tradePrice="50" ibCommission="-1.25" ibCommissionCurrency="EUR" tradeID="112" levelOfDetail="EXECUTION" />
+</Trades>
+<CashTransactions>
+<CashTransaction accountId="U99999" currency="USD" symbol="ABCD" dateTime="20260115" amount="3.2" type="Dividends" description="ABCD CASH DIVIDEND" transactionID="221" levelOfDetail="DETAIL" />
+<CashTransaction accountId="U99999" currency="USD" symbol="ABCD" dateTime="20260115" amount="-0.48" type="Withholding Tax" description="ABCD US TAX" transactionID="222" levelOfDetail="DETAIL" />
+<CashTransaction accountId="U99999" currency="USD" symbol="" dateTime="20260115" amount="2.72" type="Dividends" description="" transactionID="" levelOfDetail="SUMMARY" />
+</CashTransactions>
+</FlexStatement>
+</FlexStatements>
+</FlexQueryResponse>`
+
+func TestGetTransactions(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, time.February, 1, 10, 15, 0, 0, time.UTC))
+	go func() {
+		// The statement is not ready at the first poll.
+		clk.BlockUntil(1)
+		clk.Advance(retryDelay)
+	}()
+	polls := 0
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/SendRequest", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("t") != "token" || r.FormValue("q") != "123" || r.FormValue("fd") != "20260101" || r.FormValue("td") != "20260131" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		w.Write([]byte(`<FlexStatementResponse timestamp='01 February, 2026 10:15 AM EST'>
+<Status>Success</Status>
+<ReferenceCode>4672968268</ReferenceCode>
+<Url>` + server.URL + `/GetStatement</Url>
+</FlexStatementResponse>`))
+	})
+	mux.HandleFunc("/GetStatement", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("q") != "4672968268" {
+			t.Errorf("unexpected reference code %v", r.FormValue("q"))
+		}
+		if polls++; polls == 1 {
+			w.Write([]byte(`<FlexStatementResponse timestamp='01 February, 2026 10:15 AM EST'>
+<Status>Warn</Status>
+<ErrorCode>1019</ErrorCode>
+<ErrorMessage>Statement generation in progress. Please try again shortly.</ErrorMessage>
+</FlexStatementResponse>`))
+			return
+		}
+		w.Write([]byte(transactionsXML))
+	})
+
+	client := &Client{Token: "token", QueryID: "123", Endpoint: server.URL + "/SendRequest", Clock: clk}
+	from := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	transactions, err := client.GetTransactions(context.Background(), from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 {
+		t.Errorf("polled %d times, want 2", polls)
+	}
+	if len(transactions.Trades) != 1 {
+		t.Fatalf("got trades %+v, want only the January execution", transactions.Trades)
+	}
+	trade := transactions.Trades[0]
+	if trade.Symbol != "ABCD" || trade.Quantity != 10 || trade.Commission != -1 || trade.Time.Hour() != 15 {
+		t.Errorf("unexpected trade %+v", trade)
+	}
+	if len(transactions.CashTransactions) != 2 {
+		t.Fatalf("got cash transactions %+v, want 2", transactions.CashTransactions)
+	}
+	if dividends := transactions.OfType(Dividends); len(dividends) != 1 || dividends[0].Amount != 3.2 {
+		t.Errorf("unexpected dividends %+v", dividends)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<FlexStatementResponse timestamp='16 February, 2021 05:16 PM EST'>
+<Status>Fail</Status>
+<ErrorCode>1020</ErrorCode>
+<ErrorMessage>Invalid request or unable to validate request.</ErrorMessage>
+</FlexStatementResponse>`))
+	}))
+	defer server.Close()
+	client := &Client{Token: "token", QueryID: "123", Endpoint: server.URL}
+	_, err := client.Run(context.Background(), time.Time{}, time.Time{})
+	if flexErr, ok := err.(*Error); !ok || flexErr.Code != 1020 {
+		t.Errorf("Run error = %v, want Flex error 1020", err)
+	}
+}
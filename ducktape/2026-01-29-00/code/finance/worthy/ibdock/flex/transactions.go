@@ -0,0 +1,217 @@
+package flex
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Cash transaction types as reported by IB.
+const (
+	Dividends                = "Dividends"
+	PaymentInLieuOfDividends = "Payment In Lieu Of Dividends"
+	WithholdingTax           = "Withholding Tax"
+	OtherFees                = "Other Fees"
+	BrokerInterest           = "Broker Interest Received"
+	DepositsWithdrawals      = "Deposits/Withdrawals"
+)
+
+// Trade is one execution.
+type Trade struct {
+	Account       string
+	Symbol        string
+	AssetCategory string
+	Currency      string
+	Time          time.Time
+	// Quantity is negative for sells.
+	Quantity float64
+	Price    float64
+	// Commission is negative, in CommissionCurrency.
+	Commission         float64
+	CommissionCurrency string
+	TradeID            string
+}
+
+// CashTransaction is a dividend, fee, tax, interest payment, deposit or
+// withdrawal.
+type CashTransaction struct {
+	Account string
+	// Symbol is the instrument a dividend or tax relates to, if any.
+	Symbol   string
+	Currency string
+	Time     time.Time
+	// Amount is negative for money leaving the account.
+	Amount        float64
+	Type          string
+	Description   string
+	TransactionID string
+}
+
+// Transactions is the transaction history of a date range.
+type Transactions struct {
+	Trades           []Trade
+	CashTransactions []CashTransaction
+}
+
+// OfType returns the cash transactions of the given type, e.g. Dividends.
+func (t *Transactions) OfType(transactionType string) []CashTransaction {
+	var matching []CashTransaction
+	for _, transaction := range t.CashTransactions {
+		if transaction.Type == transactionType {
+			matching = append(matching, transaction)
+		}
+	}
+	return matching
+}
+
+// GetTransactions runs the query for [from, to] and returns the executions and
+// cash transactions in that range.
+func (c *Client) GetTransactions(ctx context.Context, from, to time.Time) (*Transactions, error) {
+	body, err := c.Run(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := ParseTransactions(body)
+	if err != nil {
+		return nil, err
+	}
+	return transactions.between(from, to), nil
+}
+
+type queryResponseXML struct {
+	Statements []struct {
+		Trades []struct {
+			AccountID            string `xml:"accountId,attr"`
+			Currency             string `xml:"currency,attr"`
+			AssetCategory        string `xml:"assetCategory,attr"`
+			Symbol               string `xml:"symbol,attr"`
+			DateTime             string `xml:"dateTime,attr"`
+			Quantity             string `xml:"quantity,attr"`
+			TradePrice           string `xml:"tradePrice,attr"`
+			IBCommission         string `xml:"ibCommission,attr"`
+			IBCommissionCurrency string `xml:"ibCommissionCurrency,attr"`
+			TradeID              string `xml:"tradeID,attr"`
+			LevelOfDetail        string `xml:"levelOfDetail,attr"`
+		} `xml:"Trades>Trade"`
+		CashTransactions []struct {
+			AccountID     string `xml:"accountId,attr"`
+			Currency      string `xml:"currency,attr"`
+			Symbol        string `xml:"symbol,attr"`
+			DateTime      string `xml:"dateTime,attr"`
+			Amount        string `xml:"amount,attr"`
+			Type          string `xml:"type,attr"`
+			Description   string `xml:"description,attr"`
+			TransactionID string `xml:"transactionID,attr"`
+			LevelOfDetail string `xml:"levelOfDetail,attr"`
+		} `xml:"CashTransactions>CashTransaction"`
+	} `xml:"FlexStatements>FlexStatement"`
+}
+
+// ParseTransactions decodes the Trades and CashTransactions sections of a
+// FlexQueryResponse document.
+func ParseTransactions(data []byte) (*Transactions, error) {
+	var response queryResponseXML
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	transactions := new(Transactions)
+	var errs []error
+	number := func(s string) float64 {
+		if s == "" {
+			return 0
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		errs = append(errs, err)
+		return v
+	}
+	when := func(s string) time.Time {
+		t, err := parseDateTime(s)
+		errs = append(errs, err)
+		return t
+	}
+	for _, statement := range response.Statements {
+		for _, trade := range statement.Trades {
+			// Order and closed-lot rows summarize executions listed
+			// separately.
+			if trade.LevelOfDetail != "" && trade.LevelOfDetail != "EXECUTION" {
+				continue
+			}
+			transactions.Trades = append(transactions.Trades, Trade{
+				Account:            trade.AccountID,
+				Symbol:             trade.Symbol,
+				AssetCategory:      trade.AssetCategory,
+				Currency:           trade.Currency,
+				Time:               when(trade.DateTime),
+				Quantity:           number(trade.Quantity),
+				Price:              number(trade.TradePrice),
+				Commission:         number(trade.IBCommission),
+				CommissionCurrency: trade.IBCommissionCurrency,
+				TradeID:            trade.TradeID,
+			})
+		}
+		for _, cash := range statement.CashTransactions {
+			if cash.LevelOfDetail == "SUMMARY" {
+				continue
+			}
+			transactions.CashTransactions = append(transactions.CashTransactions, CashTransaction{
+				Account:       cash.AccountID,
+				Symbol:        cash.Symbol,
+				Currency:      cash.Currency,
+				Time:          when(cash.DateTime),
+				Amount:        number(cash.Amount),
+				Type:          cash.Type,
+				Description:   cash.Description,
+				TransactionID: cash.TransactionID,
+			})
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Flex reports times in whatever format the query is configured with; these
+// are the ones IB offers, with and without the time part.
+var dateTimeLayouts = []string{
+	"20060102;150405",
+	"20060102",
+	"2006-01-02;15:04:05",
+	"2006-01-02, 15:04:05",
+	"2006-01-02",
+	"01/02/2006;15:04:05",
+	"01/02/2006",
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("flex: unknown date format " + strconv.Quote(s))
+}
+
+// between keeps transactions from the days from through to, inclusive. A zero
+// bound is open.
+func (t *Transactions) between(from, to time.Time) *Transactions {
+	inRange := func(when time.Time) bool {
+		day := when.Format("20060102")
+		return (from.IsZero() || day >= from.Format("20060102")) &&
+			(to.IsZero() || day <= to.Format("20060102"))
+	}
+	filtered := new(Transactions)
+	for _, trade := range t.Trades {
+		if inRange(trade.Time) {
+			filtered.Trades = append(filtered.Trades, trade)
+		}
+	}
+	for _, cash := range t.CashTransactions {
+		if inRange(cash.Time) {
+			filtered.CashTransactions = append(filtered.CashTransactions, cash)
+		}
+	}
+	return filtered
+}
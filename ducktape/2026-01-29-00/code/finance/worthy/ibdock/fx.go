@@ -0,0 +1,110 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"sort"
+)
+
+// IB error code for contracts it does not know.
+const errNoSecurityDefinition = 200
+
+// GetFXRates reads the current value of each of currencies in base from IB's
+// IDEALPRO quotes, so positions can be normalized with the same marks IB uses.
+func (dock *Dock) GetFXRates(ctx context.Context, base string, currencies []string) (_ snapshot.FXRates, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	rates, err := fxRates(ctx, client, base, currencies)
+	return rates, classifyPacing(err)
+}
+
+func fxRates(ctx context.Context, client *twsapi.Client, base string, currencies []string) (snapshot.FXRates, error) {
+	rates := snapshot.FXRates{base: 1}
+	var pairs []twsapi.Contract
+	for _, currency := range currencies {
+		if _, ok := rates[currency]; !ok {
+			rates[currency] = 0
+			pairs = append(pairs, fxPair(currency, base))
+		}
+	}
+	quotes, err := client.MarketSnapshots(ctx, pairs)
+	if err != nil {
+		return nil, err
+	}
+	// IDEALPRO only lists each pair one way round (EUR.USD, not USD.EUR),
+	// so retry the pairs it did not know inverted.
+	var inverted []twsapi.Contract
+	failed := make(map[string]error)
+	for _, quote := range quotes {
+		currency := quote.Contract.Symbol
+		var apiErr *twsapi.Error
+		switch {
+		case errors.As(quote.Err, &apiErr) && apiErr.Code == errNoSecurityDefinition:
+			inverted = append(inverted, fxPair(base, currency))
+		case quote.Err != nil:
+			failed[currency] = fmt.Errorf("%s.%s: %w", currency, base, quote.Err)
+		default:
+			rates[currency] = quote.Mid()
+		}
+	}
+	if len(inverted) > 0 {
+		quotes, err := client.MarketSnapshots(ctx, inverted)
+		if err != nil {
+			return nil, err
+		}
+		for _, quote := range quotes {
+			currency := quote.Contract.Currency
+			if quote.Err != nil {
+				failed[currency] = fmt.Errorf("%s.%s: %w", base, currency, quote.Err)
+			} else if mid := quote.Mid(); mid > 0 {
+				rates[currency] = 1 / mid
+			}
+		}
+	}
+	var missing []string
+	for currency, rate := range rates {
+		if rate == 0 {
+			missing = append(missing, currency)
+		}
+	}
+	sort.Strings(missing)
+	var errs []error
+	for _, currency := range missing {
+		if err, ok := failed[currency]; ok {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, fmt.Errorf("no quote for %s.%s", currency, base))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// fxPair is the IDEALPRO contract quoting symbol in currency.
+func fxPair(symbol, currency string) twsapi.Contract {
+	return twsapi.Contract{Symbol: symbol, SecType: "CASH", Exchange: "IDEALPRO", Currency: currency}
+}
+
+// ConvertSnapshot values s in base at the current rates of GetFXRates, see
+// snapshot.Snapshot.ConvertTo.
+func (dock *Dock) ConvertSnapshot(ctx context.Context, s *snapshot.Snapshot, base string) (_ *snapshot.Converted, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, s.Account)
+	defer call.wrapError(&err)
+	rates, err := dock.GetFXRates(ctx, base, s.Currencies())
+	if err != nil {
+		return nil, err
+	}
+	return s.ConvertTo(base, rates)
+}
@@ -0,0 +1,151 @@
+package ibdock
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// quoteGateway serves the TWS API on a new address, answering market data
+// snapshot requests with the bid and ask of their pair ("EUR.USD") in
+// quotes, and pairs missing from quotes with IB's error 200. It returns the
+// address and the pairs requested so far.
+func quoteGateway(t *testing.T, quotes map[string][2]float64) (string, func() []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	var mu sync.Mutex
+	var requested []string
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		write := func(fields ...string) {
+			payload := strings.Join(fields, "\x00") + "\x00"
+			conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...))
+		}
+		r := bufio.NewReader(conn)
+		read := func() ([]string, error) {
+			var size [4]byte
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				return nil, err
+			}
+			payload := make([]byte, binary.BigEndian.Uint32(size[:]))
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return nil, err
+			}
+			return strings.Split(strings.TrimSuffix(string(payload), "\x00"), "\x00"), nil
+		}
+		if _, err := r.Discard(4); err != nil {
+			return
+		}
+		if _, err := read(); err != nil {
+			return
+		}
+		write("151", "20260129 12:00:00 CET")
+		if _, err := read(); err != nil {
+			return
+		}
+		write("15", "1", "U1111111")
+		write("9", "1", "1")
+		for {
+			request, err := read()
+			if err != nil {
+				return
+			}
+			if request[0] != "1" {
+				continue
+			}
+			// msgReqMktData: version, reqId, conId, symbol, secType, ...,
+			// exchange, primary exchange, currency.
+			id, pair := request[2], request[4]+"."+request[12]
+			mu.Lock()
+			requested = append(requested, pair)
+			mu.Unlock()
+			quote, ok := quotes[pair]
+			if !ok {
+				write("4", "2", id, "200", "No security definition has been found for the request")
+				continue
+			}
+			write("1", "6", id, "1", strconv.FormatFloat(quote[0], 'f', -1, 64), "100", "0")
+			write("1", "6", id, "2", strconv.FormatFloat(quote[1], 'f', -1, 64), "100", "0")
+			write("57", "1", id)
+		}
+	}()
+	return listener.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+func dialQuotes(t *testing.T, quotes map[string][2]float64) (*twsapi.Client, func() []string) {
+	addr, requested := quoteGateway(t, quotes)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := twsapi.Dial(ctx, addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, requested
+}
+
+func TestFXRates(t *testing.T) {
+	client, requested := dialQuotes(t, map[string][2]float64{
+		"EUR.USD": {1.0999, 1.1001},
+		"USD.JPY": {149.9, 150.1},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rates, err := fxRates(ctx, client, "USD", []string{"EUR", "JPY", "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JPY.USD is not listed, so its rate is the inverse of USD.JPY's mid.
+	if want := (snapshot.FXRates{"USD": 1, "EUR": (1.0999 + 1.1001) / 2, "JPY": 1 / ((149.9 + 150.1) / 2)}); !reflect.DeepEqual(rates, want) {
+		t.Errorf("rates %v, want %v", rates, want)
+	}
+	if want := []string{"EUR.USD", "JPY.USD", "USD.JPY"}; !reflect.DeepEqual(requested(), want) {
+		t.Errorf("requested %v, want %v", requested(), want)
+	}
+}
+
+func TestFXRatesMissing(t *testing.T) {
+	client, _ := dialQuotes(t, map[string][2]float64{
+		"EUR.USD": {1.0999, 1.1001},
+		// A pair quoted without prices, e.g. outside market hours
+		// without delayed data.
+		"USD.CZK": {0, 0},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := fxRates(ctx, client, "USD", []string{"HUF", "EUR", "CZK", "AUD"})
+	if err == nil {
+		t.Fatal("no error for currencies without rates")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	want := []string{"USD.AUD: tws error 200", "no quote for CZK.USD", "USD.HUF: tws error 200"}
+	if len(lines) != len(want) {
+		t.Fatalf("error %q, want one line for each of AUD, CZK and HUF", err)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("error line %d = %q, want %q...", i, line, want[i])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ibdock
+
+import (
+	"time"
+)
+
+// HealthCheck configures the Docker health check on the containers StartNew
+// creates, see WithHealthCheck. Zero fields mean the defaults.
+type HealthCheck struct {
+	// Interval is the time between probes, default 30s.
+	Interval time.Duration
+	// Timeout is how long a probe may take, default 10s.
+	Timeout time.Duration
+	// StartPeriod is how long after the start failing probes do not count,
+	// covering the login; default 5m.
+	StartPeriod time.Duration
+	// Retries is how many probes in a row have to fail for Docker to mark
+	// the container unhealthy, default 3.
+	Retries int
+}
+
+// healthProbe connects to the TWS API port, apiPort, from inside the
+// container, which the gateway only accepts once logged in. The images have
+// python3 for the snapshot script; a plain connect takes no client ID.
+var healthProbe = []string{"CMD", "python3", "-c", "import socket; socket.create_connection(('127.0.0.1', 7496)).close()"}
+
+// disabledHealthProbe turns off a HEALTHCHECK of the image.
+var disabledHealthProbe = []string{"NONE"}
+
+// healthConfig is the health check of a containerSpec, with the defaults
+// filled in.
+type healthConfig struct {
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+func (dock *Dock) healthConfig() *healthConfig {
+	if dock.noHealthCheck {
+		return &healthConfig{Test: disabledHealthProbe}
+	}
+	check := dock.healthCheck
+	config := &healthConfig{Test: healthProbe, Interval: 30 * time.Second, Timeout: 10 * time.Second, StartPeriod: 5 * time.Minute, Retries: 3}
+	if check.Interval > 0 {
+		config.Interval = check.Interval
+	}
+	if check.Timeout > 0 {
+		config.Timeout = check.Timeout
+	}
+	if check.StartPeriod > 0 {
+		config.StartPeriod = check.StartPeriod
+	}
+	if check.Retries > 0 {
+		config.Retries = check.Retries
+	}
+	return config
+}
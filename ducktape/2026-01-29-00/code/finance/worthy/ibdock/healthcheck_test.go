@@ -0,0 +1,73 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		gateway, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gateway.Close()
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		server.PublishAPI(strconv.Itoa(gateway.Addr().(*net.TCPAddr).Port))
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()))
+
+		dock, err := StartNew("jdoe", "secret", logger, append(opts, WithSessionName("checked"), WithHealthCheck(HealthCheck{Interval: time.Minute}))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		unchecked, err := StartNew("jdoe", "secret", logger, append(opts, WithSessionName("unchecked"), WithoutHealthCheck())...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		for _, c := range server.Containers() {
+			check := c.Config.Healthcheck
+			switch {
+			case check == nil:
+				t.Errorf("%s: %s has no health check", backend.name, c.Name)
+			case c.ID == unchecked.ContainerID():
+				if !slices.Equal(check.Test, []string{"NONE"}) {
+					t.Errorf("%s: WithoutHealthCheck test %q, want NONE", backend.name, check.Test)
+				}
+			case !slices.Equal(check.Test, healthProbe) || check.Interval != time.Minute || check.Timeout != 10*time.Second || check.StartPeriod != 5*time.Minute || check.Retries != 3:
+				t.Errorf("%s: health check %+v", backend.name, check)
+			}
+		}
+
+		ctx := context.Background()
+		if health, err := dock.checkHealth(ctx, time.Second); health != Healthy {
+			t.Errorf("%s: health before probes failed %v (%v), want healthy", backend.name, health, err)
+		}
+		server.SetHealth(dock.ContainerID(), "unhealthy", 3, "ConnectionRefusedError: [Errno 111] Connection refused\n")
+		info, err := dock.Inspect(ctx)
+		if err != nil || info.Health != "unhealthy" || info.HealthFailures != 3 || !strings.Contains(info.HealthOutput, "Connection refused") {
+			t.Errorf("%s: Inspect = %+v, %v", backend.name, info, err)
+		}
+		// The API port is still open from the outside, but Docker's view
+		// wins.
+		health, reason := dock.checkHealth(ctx, time.Second)
+		if health != Unhealthy || reason == nil || !strings.Contains(reason.Error(), "3 health checks") {
+			t.Errorf("%s: health after failed probes %v (%v), want unhealthy", backend.name, health, reason)
+		}
+	}
+}
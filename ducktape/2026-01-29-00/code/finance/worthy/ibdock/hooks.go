@@ -0,0 +1,32 @@
+package ibdock
+
+import "github.com/agentydragon/worthy/ibdock/snapshot"
+
+// Hooks are told about the events of an unattended session, e.g. to page
+// someone when the login breaks. Unset hooks are skipped; see the notify
+// package for hooks that send webhooks.
+type Hooks struct {
+	OnSnapshot func(*snapshot.Snapshot)
+	OnError    func(error)
+	// OnRestart is called after the session was replaced, with the failure
+	// that made it necessary.
+	OnRestart func(reason error)
+}
+
+func (h Hooks) snapshot(s *snapshot.Snapshot) {
+	if h.OnSnapshot != nil {
+		h.OnSnapshot(s)
+	}
+}
+
+func (h Hooks) error(err error) {
+	if h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+func (h Hooks) restart(reason error) {
+	if h.OnRestart != nil {
+		h.OnRestart(reason)
+	}
+}
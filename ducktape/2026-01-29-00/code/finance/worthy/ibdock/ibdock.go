@@ -1,125 +1,335 @@
 package ibdock
 
 import (
-	"bytes"
-	"errors"
+	"context"
 	"fmt"
-	"github.com/fsouza/go-dockerclient"
-	"io/ioutil"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Dock struct {
-	client    *docker.Client
-	container *docker.Container
+	client containerRuntime
+	// container is the container the Dock runs, which ensureRunning
+	// replaces when it dies; see current.
+	container atomic.Pointer[containerInfo]
 	port      int
 	logger    *log.Logger
+	// Last TWS API client ID handed out by dialAPI.
+	clientID  atomic.Int32
+	contracts ContractCache
+	journal   *twsapi.Journal
+	strict    bool
+	// validation is set by WithValidation.
+	validation *snapshot.ValidationRules
+	// session is the name set with WithSessionName, if any.
+	session string
+	// Set by WithImage, WithVariant, WithPlatform, WithTradingMode,
+	// WithSnapshotTimeout, the WithDocker options, the resource options and
+	// the network options; zero values mean the defaults.
+	image         string
+	variant       Variant
+	platform      string
+	tradingMode   string
+	timeout       time.Duration
+	dockerConfig  DockerConfig
+	memoryLimit   int64
+	cpuShares     int64
+	pidsLimit     int64
+	restartPolicy string
+	network       string
+	apiHostPort   int
+	bindAddress   string
+	publishHost   string
+	// Set by WithHealthCheck and WithoutHealthCheck.
+	healthCheck   HealthCheck
+	noHealthCheck bool
+	// Set by WithFile, WithStartExec and WithPreStopExec.
+	files        []containerFile
+	startExecs   [][]string
+	preStopExecs [][]string
+	// desktop caches isDesktop.
+	desktopOnce sync.Once
+	desktop     bool
+	// ports caches the published ports of the container with its ID, see
+	// publishedBinding.
+	portsMu sync.Mutex
+	ports   containerInfo
+	// Set by WithPullStallTimeout.
+	pullStallTimeout time.Duration
+	// Set by WithMonitorInterval.
+	monitorInterval time.Duration
+	// Set by WithWatchInterval.
+	watchInterval time.Duration
+	// Set by WithClock; nil is clock.Real.
+	clock clock.Clock
+	// Set by WithSettingsVolume and WithAutoRestart.
+	settingsVolume string
+	autoRestarts   int
+	// Set by WithSessionConflict.
+	sessionConflict SessionConflict
+	// Set by WithAllowTrading and WithLiveTrading.
+	allowTrading bool
+	liveTrading  bool
+	// redactor masks the logs and snapshot errors; nil WithoutRedaction.
+	redactor    *Redactor
+	noRedaction bool
+	// Set by WithAccountLock.
+	accountLock bool
+	// Set by WithDryRun.
+	dryRun *PreflightReport
+	// Set by WithDebugVNC.
+	debugVNC    bool
+	vncPassword string
+	// Set by WithRateLimit; pacer is nil without a limit.
+	rateLimit float64
+	rateBurst int
+	pacerOnce sync.Once
+	pacer     *tokenBucket
+	// Event subscribers, see WithEvents and Subscribe.
+	eventsMu    sync.Mutex
+	subscribers []chan<- Event
+	// spec is what StartNew created the container from, to recreate it.
+	spec      *containerSpec
+	restartMu sync.Mutex
+	restarts  atomic.Int32
 }
 
 const image = "agentydragon/ibcontroller"
-const deadline = 5 * 60 * time.Second
+const defaultSnapshotTimeout = 5 * 60 * time.Second
 
-var readSnapshotCmdline = []string{"python3", "/root/read_snapshot.py", "--port=7496"}
+func buildEnv(tradingMode string) []string {
+	var env []string
+	if tradingMode != "" {
+		env = append(env, "TRADING_MODE="+tradingMode)
+	}
+	return env
+}
 
-func buildEnv(username, password string) []string {
-	return []string{"IB_LOGIN_ID=" + username, "IB_PASSWORD=" + password}
+// credentialEnv is the environment the gateway logs in with. The Engine API
+// takes it as plain strings, which Go cannot wipe, so the credentials stay
+// in memory while the Dock keeps its spec to recreate the container from;
+// secretEnv at least keeps them out of whatever formats the spec.
+func credentialEnv(username, password string) secretEnv {
+	return secretEnv{"IB_LOGIN_ID=" + username, "IB_PASSWORD=" + password}
+}
+
+func (dock *Dock) imageRef() string {
+	if dock.image != "" {
+		return dock.image
+	}
+	return dock.variant.image()
 }
 
+func (dock *Dock) snapshotTimeout() time.Duration {
+	if dock.timeout > 0 {
+		return dock.timeout
+	}
+	return defaultSnapshotTimeout
+}
+
+func (dock *Dock) connect() error {
+	var err error
+	dock.client, err = dock.dockerConfig.runtime()
+	return err
+}
+
+// sessionLabel marks containers started for a named session, with the name as
+// its value.
+const sessionLabel = "worthy.ibdock.session"
+
 func makeContainerName() string {
 	return fmt.Sprintf("ibcontroller_%d", time.Now().Unix()%1000)
 }
 
-func StartNew(username, password string, logger *log.Logger) (*Dock, error) {
+func (dock *Dock) containerName() string {
+	if dock.session != "" {
+		return "ibcontroller_" + dock.session
+	}
+	return makeContainerName()
+}
+
+func (dock *Dock) labels() map[string]string {
+	if dock.session == "" {
+		return nil
+	}
+	return map[string]string{sessionLabel: dock.session}
+}
+
+func StartNew(username, password string, logger *log.Logger, opts ...Option) (*Dock, error) {
+	return StartNewContext(context.Background(), username, password, logger, opts...)
+}
+
+// StartNewContext is StartNew giving up when ctx is done, e.g. while it
+// pulls the image.
+func StartNewContext(ctx context.Context, username, password string, logger *log.Logger, opts ...Option) (_ *Dock, err error) {
 	dock := new(Dock)
+	defer dock.redactError(&err)
 	dock.logger = logger
-	var err error
-	dock.client, err = docker.NewClientFromEnv()
+	for _, opt := range opts {
+		opt(dock)
+	}
+	dock.redact(username, password)
+	if dock.dryRun != nil {
+		*dock.dryRun = *dock.preflight(ctx, username, password)
+		if err := dock.dryRun.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrDryRun
+	}
+	spec, err := dock.containerSpec(username, password)
 	if err != nil {
 		return nil, err
 	}
-	options := docker.CreateContainerOptions{
-		Name: makeContainerName(),
-		Config: &docker.Config{
-			Env:   buildEnv(username, password),
-			Image: image,
-		},
-		HostConfig: &docker.HostConfig{
-			PublishAllPorts: true,
-		},
-	}
-	dock.container, err = dock.client.CreateContainer(options)
+	if err := dock.checkFiles(); err != nil {
+		return nil, err
+	}
+	if err := dock.connect(); err != nil {
+		return nil, err
+	}
+	login := spec.Labels[loginLabel]
+	if dock.accountLock {
+		if err := dock.checkAccountLock(ctx, login, ""); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Platform, err = dock.preparePlatform(ctx, spec.Image); err != nil {
+		return nil, err
+	}
+	id, err := dock.client.create(ctx, spec)
 	if err != nil {
 		return nil, err
 	}
-	err = dock.client.StartContainer(dock.container.ID, nil)
+	if dock.accountLock {
+		if err := dock.acquireAccountLock(ctx, login, id); err != nil {
+			return nil, err
+		}
+	}
+	dock.setContainer(containerInfo{ID: id})
+	dock.spec = &spec
+	dock.emit(Event{Kind: EventCreated})
+	err = dock.startContainer(ctx, id)
 	if err != nil {
+		// Nothing can use a container that never started, e.g. because ctx
+		// was done, or whose start hooks failed.
+		dock.client.remove(context.WithoutCancel(ctx), id, true)
 		return nil, err
 	}
-	// TODO: from this point on, the container should be killed if anything
-	// fails
 	return dock, nil
 }
 
-func (dock *Dock) RunExec() ([]byte, error) {
-	dock.logger.Println("Calling CreateExec")
-	exec, err := dock.client.CreateExec(docker.CreateExecOptions{
-		AttachStdout: true,
-		AttachStderr: true,
-		Cmd:          readSnapshotCmdline,
-		Container:    dock.container.ID,
-	})
-	if err != nil {
-		return nil, err
+// Attach returns a Dock for an already running ibcontroller container, so that
+// a restarted process can keep using a logged-in session instead of burning
+// another IB login on a fresh container.
+func Attach(containerNameOrID string, logger *log.Logger, opts ...Option) (_ *Dock, err error) {
+	dock := new(Dock)
+	defer dock.redactError(&err)
+	dock.logger = logger
+	for _, opt := range opts {
+		opt(dock)
 	}
-	var stdout, stderr bytes.Buffer
-	dock.logger.Println("Calling StartExec")
-	// NOTE: This will not work with 'detach'.
-	if _, err := dock.client.StartExecNonBlocking(exec.ID, docker.StartExecOptions{
-		OutputStream: &stdout,
-		ErrorStream:  &stderr,
-	}); err != nil {
+	dock.redact()
+	if err := dock.variant.check(); err != nil {
 		return nil, err
 	}
-	dock.logger.Println("Execution started")
-	pollInterval := 5 * time.Second
-	timeout := time.After(deadline)
-loop:
-	for {
-		select {
-		case <-time.After(pollInterval):
-			info, err := dock.client.InspectExec(exec.ID)
-			if err != nil {
-				return nil, err
-			}
-			if !info.Running {
-				if info.ExitCode != 0 {
-					return nil, fmt.Errorf("exit code %d", info.ExitCode)
-				}
-				dock.logger.Println("finished OK")
-				break loop
-			}
-			dock.logger.Println("not finished yet")
-		case <-timeout:
-			return nil, errors.New("Timed out waiting to get stocks")
-		}
+	if err := dock.connect(); err != nil {
+		return nil, err
 	}
-	stdoutBytes, err := ioutil.ReadAll(&stdout)
+	container, err := dock.client.inspect(context.Background(), containerNameOrID)
 	if err != nil {
 		return nil, err
 	}
-	dock.logger.Println("stdout:", string(stdoutBytes))
-	stderrBytes, err := ioutil.ReadAll(&stderr)
-	if err != nil {
+	if err := checkAttachable(container, imageRepository(dock.imageRef())); err != nil {
 		return nil, err
 	}
-	dock.logger.Println("stderr:", string(stderrBytes))
-	return stdoutBytes, nil
+	dock.setContainer(container)
+	dock.logger.Println("Attached to container", container.ID)
+	return dock, nil
+}
+
+func checkAttachable(container containerInfo, repository string) error {
+	if imageRepository(container.Image) != repository {
+		return fmt.Errorf("container %s does not run %s", container.Name, repository)
+	}
+	if !container.Running || container.Paused || container.Restarting {
+		return fmt.Errorf("container %s is not running: %s", container.Name, container.Status)
+	}
+	if container.Health == "unhealthy" {
+		return fmt.Errorf("container %s is unhealthy", container.Name)
+	}
+	return nil
+}
+
+// imageRepository strips the tag and digest from an image reference.
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// ContainerID is the ID of the Dock's container.
+func (dock *Dock) ContainerID() string {
+	return dock.current().ID
+}
+
+// current returns a copy of the container the Dock runs. Callers needing
+// the same container twice should keep the copy rather than call it again.
+func (dock *Dock) current() containerInfo {
+	if container := dock.container.Load(); container != nil {
+		return *container
+	}
+	return containerInfo{}
+}
+
+func (dock *Dock) setContainer(container containerInfo) {
+	dock.container.Store(&container)
+}
+
+// TradingMode is "live" or "paper", see WithTradingMode.
+func (dock *Dock) TradingMode() string {
+	if dock.tradingMode == "" {
+		return "live"
+	}
+	return dock.tradingMode
+}
+
+// Stop gives the gateway a chance to log out before removing the container,
+// killing it if it does not exit within ctx's deadline, or 10 seconds without
+// one.
+func (dock *Dock) Stop(ctx context.Context) (err error) {
+	defer dock.redactError(&err)
+	// A failed cleanup should not keep the session running.
+	if err := dock.runHooks(ctx, "pre-stop", dock.preStopExecs); err != nil {
+		dock.logger.Println("Stopping anyway:", err)
+	}
+	grace := 10 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		grace = max(deadline.Sub(clock.OrReal(dock.clock).Now())-time.Second, 0)
+	}
+	id := dock.current().ID
+	if err := dock.client.stop(ctx, id, grace); err != nil {
+		return err
+	}
+	if err := dock.client.remove(ctx, id, false); err != nil {
+		return err
+	}
+	dock.client.closeIdle()
+	dock.emit(Event{Kind: EventStopped})
+	return nil
 }
 
 func (dock *Dock) Kill() {
-	dock.client.RemoveContainer(docker.RemoveContainerOptions{
-		ID:    dock.container.ID,
-		Force: true,
-	})
+	if dock.client.remove(context.Background(), dock.current().ID, true) == nil {
+		dock.emit(Event{Kind: EventStopped})
+	}
+	dock.client.closeIdle()
 }
@@ -0,0 +1,74 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttach(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111", Timestamp: time.Now()})
+	logger := log.New(io.Discard, "", 0)
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := strings.TrimPrefix(server.Containers()[0].Name, "/")
+
+	// A restarted process picks up the session by name without starting
+	// another container.
+	attached, err := Attach(name, logger, WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attached.ContainerID() != dock.ContainerID() {
+		t.Errorf("attached to %s, want %s", attached.ContainerID(), dock.ContainerID())
+	}
+	if s, err := attached.GetSnapshot(context.Background()); err != nil || s.Account != "U1111111" {
+		t.Errorf("GetSnapshot of the attached session = %v, %v", s, err)
+	}
+	if n := len(server.Containers()); n != 1 {
+		t.Errorf("%d containers after Attach, want 1", n)
+	}
+
+	for _, test := range []struct {
+		name    string
+		prepare func()
+		opts    []Option
+		want    string
+	}{
+		{"other image", func() {}, []Option{WithImage("example.com/other:1.0")}, "does not run example.com/other"},
+		{"unhealthy", func() { server.SetHealth(dock.ContainerID(), "unhealthy", 3, "no API port") }, nil, "is unhealthy"},
+		{"exited", func() { server.Exit(dock.ContainerID(), 1) }, nil, "is not running"},
+	} {
+		test.prepare()
+		if _, err := Attach(dock.ContainerID(), logger, append(test.opts, WithDockerEndpoint(server.URL()))...); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: Attach = %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+	if _, err := Attach("no-such-container", logger, WithDockerEndpoint(server.URL())); err == nil {
+		t.Errorf("Attach to a missing container succeeded")
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	for ref, want := range map[string]string{
+		"ghcr.io/gnzsnz/ib-gateway":                   "ghcr.io/gnzsnz/ib-gateway",
+		"ghcr.io/gnzsnz/ib-gateway:10.30":             "ghcr.io/gnzsnz/ib-gateway",
+		"ghcr.io/gnzsnz/ib-gateway:10.30@sha256:abcd": "ghcr.io/gnzsnz/ib-gateway",
+		"localhost:5000/ib-gateway":                   "localhost:5000/ib-gateway",
+		"localhost:5000/ib-gateway:stable":            "localhost:5000/ib-gateway",
+		"localhost:5000/ib-gateway@sha256:abcd":       "localhost:5000/ib-gateway",
+	} {
+		if got := imageRepository(ref); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,744 @@
+// gRPC interface to ibdock sessions, served by the rpcserver package. The
+// server holds the IB credentials; clients refer to accounts by name.
+//
+// Regenerate with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative ibdock.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: ibdock.proto
+
+package ibdockpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartSessionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Account is a name from the server's credentials configuration.
+	Account       string `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartSessionRequest) Reset() {
+	*x = StartSessionRequest{}
+	mi := &file_ibdock_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartSessionRequest) ProtoMessage() {}
+
+func (x *StartSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartSessionRequest.ProtoReflect.Descriptor instead.
+func (*StartSessionRequest) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartSessionRequest) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+type StartSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartSessionResponse) Reset() {
+	*x = StartSessionResponse{}
+	mi := &file_ibdock_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartSessionResponse) ProtoMessage() {}
+
+func (x *StartSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartSessionResponse.ProtoReflect.Descriptor instead.
+func (*StartSessionResponse) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StartSessionResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type GetSnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSnapshotRequest) Reset() {
+	*x = GetSnapshotRequest{}
+	mi := &file_ibdock_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSnapshotRequest) ProtoMessage() {}
+
+func (x *GetSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*GetSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSnapshotRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type GetAccountSummaryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAccountSummaryRequest) Reset() {
+	*x = GetAccountSummaryRequest{}
+	mi := &file_ibdock_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAccountSummaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAccountSummaryRequest) ProtoMessage() {}
+
+func (x *GetAccountSummaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAccountSummaryRequest.ProtoReflect.Descriptor instead.
+func (*GetAccountSummaryRequest) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetAccountSummaryRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type StopSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopSessionRequest) Reset() {
+	*x = StopSessionRequest{}
+	mi := &file_ibdock_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopSessionRequest) ProtoMessage() {}
+
+func (x *StopSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopSessionRequest.ProtoReflect.Descriptor instead.
+func (*StopSessionRequest) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StopSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type StopSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopSessionResponse) Reset() {
+	*x = StopSessionResponse{}
+	mi := &file_ibdock_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopSessionResponse) ProtoMessage() {}
+
+func (x *StopSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopSessionResponse.ProtoReflect.Descriptor instead.
+func (*StopSessionResponse) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{5}
+}
+
+type Snapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Account       string                 `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Positions     []*Position            `protobuf:"bytes,3,rep,name=positions,proto3" json:"positions,omitempty"`
+	AccountName   string                 `protobuf:"bytes,4,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_ibdock_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Snapshot) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+func (x *Snapshot) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Snapshot) GetPositions() []*Position {
+	if x != nil {
+		return x.Positions
+	}
+	return nil
+}
+
+func (x *Snapshot) GetAccountName() string {
+	if x != nil {
+		return x.AccountName
+	}
+	return ""
+}
+
+type Position struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Symbol   string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	SecType  string                 `protobuf:"bytes,2,opt,name=sec_type,json=secType,proto3" json:"sec_type,omitempty"`
+	Currency string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	// The amounts as doubles, for clients predating the exact fields below.
+	//
+	// Deprecated: Marked as deprecated in ibdock.proto.
+	Quantity float64 `protobuf:"fixed64,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	// Deprecated: Marked as deprecated in ibdock.proto.
+	AvgCost float64 `protobuf:"fixed64,5,opt,name=avg_cost,json=avgCost,proto3" json:"avg_cost,omitempty"`
+	// Deprecated: Marked as deprecated in ibdock.proto.
+	MarketPrice float64 `protobuf:"fixed64,6,opt,name=market_price,json=marketPrice,proto3" json:"market_price,omitempty"`
+	// Deprecated: Marked as deprecated in ibdock.proto.
+	MarketValue float64 `protobuf:"fixed64,7,opt,name=market_value,json=marketValue,proto3" json:"market_value,omitempty"`
+	InTransfer  bool    `protobuf:"varint,8,opt,name=in_transfer,json=inTransfer,proto3" json:"in_transfer,omitempty"`
+	// The amounts as exact decimal strings like "-123.45".
+	QuantityDecimal    string `protobuf:"bytes,9,opt,name=quantity_decimal,json=quantityDecimal,proto3" json:"quantity_decimal,omitempty"`
+	AvgCostDecimal     string `protobuf:"bytes,10,opt,name=avg_cost_decimal,json=avgCostDecimal,proto3" json:"avg_cost_decimal,omitempty"`
+	MarketPriceDecimal string `protobuf:"bytes,11,opt,name=market_price_decimal,json=marketPriceDecimal,proto3" json:"market_price_decimal,omitempty"`
+	MarketValueDecimal string `protobuf:"bytes,12,opt,name=market_value_decimal,json=marketValueDecimal,proto3" json:"market_value_decimal,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Position) Reset() {
+	*x = Position{}
+	mi := &file_ibdock_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Position) ProtoMessage() {}
+
+func (x *Position) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Position.ProtoReflect.Descriptor instead.
+func (*Position) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Position) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Position) GetSecType() string {
+	if x != nil {
+		return x.SecType
+	}
+	return ""
+}
+
+func (x *Position) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// Deprecated: Marked as deprecated in ibdock.proto.
+func (x *Position) GetQuantity() float64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in ibdock.proto.
+func (x *Position) GetAvgCost() float64 {
+	if x != nil {
+		return x.AvgCost
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in ibdock.proto.
+func (x *Position) GetMarketPrice() float64 {
+	if x != nil {
+		return x.MarketPrice
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in ibdock.proto.
+func (x *Position) GetMarketValue() float64 {
+	if x != nil {
+		return x.MarketValue
+	}
+	return 0
+}
+
+func (x *Position) GetInTransfer() bool {
+	if x != nil {
+		return x.InTransfer
+	}
+	return false
+}
+
+func (x *Position) GetQuantityDecimal() string {
+	if x != nil {
+		return x.QuantityDecimal
+	}
+	return ""
+}
+
+func (x *Position) GetAvgCostDecimal() string {
+	if x != nil {
+		return x.AvgCostDecimal
+	}
+	return ""
+}
+
+func (x *Position) GetMarketPriceDecimal() string {
+	if x != nil {
+		return x.MarketPriceDecimal
+	}
+	return ""
+}
+
+func (x *Position) GetMarketValueDecimal() string {
+	if x != nil {
+		return x.MarketValueDecimal
+	}
+	return ""
+}
+
+type AccountSummary struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Account         string                 `protobuf:"bytes,1,opt,name=account,proto3" json:"account,omitempty"`
+	BaseCurrency    string                 `protobuf:"bytes,2,opt,name=base_currency,json=baseCurrency,proto3" json:"base_currency,omitempty"`
+	NetLiquidation  float64                `protobuf:"fixed64,3,opt,name=net_liquidation,json=netLiquidation,proto3" json:"net_liquidation,omitempty"`
+	TotalCashValue  float64                `protobuf:"fixed64,4,opt,name=total_cash_value,json=totalCashValue,proto3" json:"total_cash_value,omitempty"`
+	Cash            map[string]float64     `protobuf:"bytes,5,rep,name=cash,proto3" json:"cash,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	BuyingPower     float64                `protobuf:"fixed64,6,opt,name=buying_power,json=buyingPower,proto3" json:"buying_power,omitempty"`
+	AvailableFunds  float64                `protobuf:"fixed64,7,opt,name=available_funds,json=availableFunds,proto3" json:"available_funds,omitempty"`
+	ExcessLiquidity float64                `protobuf:"fixed64,8,opt,name=excess_liquidity,json=excessLiquidity,proto3" json:"excess_liquidity,omitempty"`
+	InitMarginReq   float64                `protobuf:"fixed64,9,opt,name=init_margin_req,json=initMarginReq,proto3" json:"init_margin_req,omitempty"`
+	MaintMarginReq  float64                `protobuf:"fixed64,10,opt,name=maint_margin_req,json=maintMarginReq,proto3" json:"maint_margin_req,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AccountSummary) Reset() {
+	*x = AccountSummary{}
+	mi := &file_ibdock_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountSummary) ProtoMessage() {}
+
+func (x *AccountSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_ibdock_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountSummary.ProtoReflect.Descriptor instead.
+func (*AccountSummary) Descriptor() ([]byte, []int) {
+	return file_ibdock_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AccountSummary) GetAccount() string {
+	if x != nil {
+		return x.Account
+	}
+	return ""
+}
+
+func (x *AccountSummary) GetBaseCurrency() string {
+	if x != nil {
+		return x.BaseCurrency
+	}
+	return ""
+}
+
+func (x *AccountSummary) GetNetLiquidation() float64 {
+	if x != nil {
+		return x.NetLiquidation
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetTotalCashValue() float64 {
+	if x != nil {
+		return x.TotalCashValue
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetCash() map[string]float64 {
+	if x != nil {
+		return x.Cash
+	}
+	return nil
+}
+
+func (x *AccountSummary) GetBuyingPower() float64 {
+	if x != nil {
+		return x.BuyingPower
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetAvailableFunds() float64 {
+	if x != nil {
+		return x.AvailableFunds
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetExcessLiquidity() float64 {
+	if x != nil {
+		return x.ExcessLiquidity
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetInitMarginReq() float64 {
+	if x != nil {
+		return x.InitMarginReq
+	}
+	return 0
+}
+
+func (x *AccountSummary) GetMaintMarginReq() float64 {
+	if x != nil {
+		return x.MaintMarginReq
+	}
+	return 0
+}
+
+var File_ibdock_proto protoreflect.FileDescriptor
+
+const file_ibdock_proto_rawDesc = "" +
+	"\n" +
+	"\fibdock.proto\x12\x11worthy.ibdock.rpc\x1a\x1fgoogle/protobuf/timestamp.proto\"/\n" +
+	"\x13StartSessionRequest\x12\x18\n" +
+	"\aaccount\x18\x01 \x01(\tR\aaccount\"5\n" +
+	"\x14StartSessionResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"3\n" +
+	"\x12GetSnapshotRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"9\n" +
+	"\x18GetAccountSummaryRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"3\n" +
+	"\x12StopSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x15\n" +
+	"\x13StopSessionResponse\"\xbc\x01\n" +
+	"\bSnapshot\x12\x18\n" +
+	"\aaccount\x18\x01 \x01(\tR\aaccount\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x129\n" +
+	"\tpositions\x18\x03 \x03(\v2\x1b.worthy.ibdock.rpc.PositionR\tpositions\x12!\n" +
+	"\faccount_name\x18\x04 \x01(\tR\vaccountName\"\xc0\x03\n" +
+	"\bPosition\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x19\n" +
+	"\bsec_type\x18\x02 \x01(\tR\asecType\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x1e\n" +
+	"\bquantity\x18\x04 \x01(\x01B\x02\x18\x01R\bquantity\x12\x1d\n" +
+	"\bavg_cost\x18\x05 \x01(\x01B\x02\x18\x01R\aavgCost\x12%\n" +
+	"\fmarket_price\x18\x06 \x01(\x01B\x02\x18\x01R\vmarketPrice\x12%\n" +
+	"\fmarket_value\x18\a \x01(\x01B\x02\x18\x01R\vmarketValue\x12\x1f\n" +
+	"\vin_transfer\x18\b \x01(\bR\n" +
+	"inTransfer\x12)\n" +
+	"\x10quantity_decimal\x18\t \x01(\tR\x0fquantityDecimal\x12(\n" +
+	"\x10avg_cost_decimal\x18\n" +
+	" \x01(\tR\x0eavgCostDecimal\x120\n" +
+	"\x14market_price_decimal\x18\v \x01(\tR\x12marketPriceDecimal\x120\n" +
+	"\x14market_value_decimal\x18\f \x01(\tR\x12marketValueDecimal\"\xe5\x03\n" +
+	"\x0eAccountSummary\x12\x18\n" +
+	"\aaccount\x18\x01 \x01(\tR\aaccount\x12#\n" +
+	"\rbase_currency\x18\x02 \x01(\tR\fbaseCurrency\x12'\n" +
+	"\x0fnet_liquidation\x18\x03 \x01(\x01R\x0enetLiquidation\x12(\n" +
+	"\x10total_cash_value\x18\x04 \x01(\x01R\x0etotalCashValue\x12?\n" +
+	"\x04cash\x18\x05 \x03(\v2+.worthy.ibdock.rpc.AccountSummary.CashEntryR\x04cash\x12!\n" +
+	"\fbuying_power\x18\x06 \x01(\x01R\vbuyingPower\x12'\n" +
+	"\x0favailable_funds\x18\a \x01(\x01R\x0eavailableFunds\x12)\n" +
+	"\x10excess_liquidity\x18\b \x01(\x01R\x0fexcessLiquidity\x12&\n" +
+	"\x0finit_margin_req\x18\t \x01(\x01R\rinitMarginReq\x12(\n" +
+	"\x10maint_margin_req\x18\n" +
+	" \x01(\x01R\x0emaintMarginReq\x1a7\n" +
+	"\tCashEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x012\xff\x02\n" +
+	"\x06Ibdock\x12_\n" +
+	"\fStartSession\x12&.worthy.ibdock.rpc.StartSessionRequest\x1a'.worthy.ibdock.rpc.StartSessionResponse\x12Q\n" +
+	"\vGetSnapshot\x12%.worthy.ibdock.rpc.GetSnapshotRequest\x1a\x1b.worthy.ibdock.rpc.Snapshot\x12c\n" +
+	"\x11GetAccountSummary\x12+.worthy.ibdock.rpc.GetAccountSummaryRequest\x1a!.worthy.ibdock.rpc.AccountSummary\x12\\\n" +
+	"\vStopSession\x12%.worthy.ibdock.rpc.StopSessionRequest\x1a&.worthy.ibdock.rpc.StopSessionResponseB0Z.github.com/agentydragon/worthy/ibdock/ibdockpbb\x06proto3"
+
+var (
+	file_ibdock_proto_rawDescOnce sync.Once
+	file_ibdock_proto_rawDescData []byte
+)
+
+func file_ibdock_proto_rawDescGZIP() []byte {
+	file_ibdock_proto_rawDescOnce.Do(func() {
+		file_ibdock_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ibdock_proto_rawDesc), len(file_ibdock_proto_rawDesc)))
+	})
+	return file_ibdock_proto_rawDescData
+}
+
+var file_ibdock_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_ibdock_proto_goTypes = []any{
+	(*StartSessionRequest)(nil),      // 0: worthy.ibdock.rpc.StartSessionRequest
+	(*StartSessionResponse)(nil),     // 1: worthy.ibdock.rpc.StartSessionResponse
+	(*GetSnapshotRequest)(nil),       // 2: worthy.ibdock.rpc.GetSnapshotRequest
+	(*GetAccountSummaryRequest)(nil), // 3: worthy.ibdock.rpc.GetAccountSummaryRequest
+	(*StopSessionRequest)(nil),       // 4: worthy.ibdock.rpc.StopSessionRequest
+	(*StopSessionResponse)(nil),      // 5: worthy.ibdock.rpc.StopSessionResponse
+	(*Snapshot)(nil),                 // 6: worthy.ibdock.rpc.Snapshot
+	(*Position)(nil),                 // 7: worthy.ibdock.rpc.Position
+	(*AccountSummary)(nil),           // 8: worthy.ibdock.rpc.AccountSummary
+	nil,                              // 9: worthy.ibdock.rpc.AccountSummary.CashEntry
+	(*timestamppb.Timestamp)(nil),    // 10: google.protobuf.Timestamp
+}
+var file_ibdock_proto_depIdxs = []int32{
+	10, // 0: worthy.ibdock.rpc.Snapshot.timestamp:type_name -> google.protobuf.Timestamp
+	7,  // 1: worthy.ibdock.rpc.Snapshot.positions:type_name -> worthy.ibdock.rpc.Position
+	9,  // 2: worthy.ibdock.rpc.AccountSummary.cash:type_name -> worthy.ibdock.rpc.AccountSummary.CashEntry
+	0,  // 3: worthy.ibdock.rpc.Ibdock.StartSession:input_type -> worthy.ibdock.rpc.StartSessionRequest
+	2,  // 4: worthy.ibdock.rpc.Ibdock.GetSnapshot:input_type -> worthy.ibdock.rpc.GetSnapshotRequest
+	3,  // 5: worthy.ibdock.rpc.Ibdock.GetAccountSummary:input_type -> worthy.ibdock.rpc.GetAccountSummaryRequest
+	4,  // 6: worthy.ibdock.rpc.Ibdock.StopSession:input_type -> worthy.ibdock.rpc.StopSessionRequest
+	1,  // 7: worthy.ibdock.rpc.Ibdock.StartSession:output_type -> worthy.ibdock.rpc.StartSessionResponse
+	6,  // 8: worthy.ibdock.rpc.Ibdock.GetSnapshot:output_type -> worthy.ibdock.rpc.Snapshot
+	8,  // 9: worthy.ibdock.rpc.Ibdock.GetAccountSummary:output_type -> worthy.ibdock.rpc.AccountSummary
+	5,  // 10: worthy.ibdock.rpc.Ibdock.StopSession:output_type -> worthy.ibdock.rpc.StopSessionResponse
+	7,  // [7:11] is the sub-list for method output_type
+	3,  // [3:7] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_ibdock_proto_init() }
+func file_ibdock_proto_init() {
+	if File_ibdock_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ibdock_proto_rawDesc), len(file_ibdock_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ibdock_proto_goTypes,
+		DependencyIndexes: file_ibdock_proto_depIdxs,
+		MessageInfos:      file_ibdock_proto_msgTypes,
+	}.Build()
+	File_ibdock_proto = out.File
+	file_ibdock_proto_goTypes = nil
+	file_ibdock_proto_depIdxs = nil
+}
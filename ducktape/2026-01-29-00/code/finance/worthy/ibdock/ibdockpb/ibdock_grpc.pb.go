@@ -0,0 +1,246 @@
+// gRPC interface to ibdock sessions, served by the rpcserver package. The
+// server holds the IB credentials; clients refer to accounts by name.
+//
+// Regenerate with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative ibdock.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: ibdock.proto
+
+package ibdockpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Ibdock_StartSession_FullMethodName      = "/worthy.ibdock.rpc.Ibdock/StartSession"
+	Ibdock_GetSnapshot_FullMethodName       = "/worthy.ibdock.rpc.Ibdock/GetSnapshot"
+	Ibdock_GetAccountSummary_FullMethodName = "/worthy.ibdock.rpc.Ibdock/GetAccountSummary"
+	Ibdock_StopSession_FullMethodName       = "/worthy.ibdock.rpc.Ibdock/StopSession"
+)
+
+// IbdockClient is the client API for Ibdock service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type IbdockClient interface {
+	// StartSession starts a gateway logged in to account and returns once its
+	// container runs; the gateway may still be logging in.
+	StartSession(ctx context.Context, in *StartSessionRequest, opts ...grpc.CallOption) (*StartSessionResponse, error)
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	GetAccountSummary(ctx context.Context, in *GetAccountSummaryRequest, opts ...grpc.CallOption) (*AccountSummary, error)
+	StopSession(ctx context.Context, in *StopSessionRequest, opts ...grpc.CallOption) (*StopSessionResponse, error)
+}
+
+type ibdockClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIbdockClient(cc grpc.ClientConnInterface) IbdockClient {
+	return &ibdockClient{cc}
+}
+
+func (c *ibdockClient) StartSession(ctx context.Context, in *StartSessionRequest, opts ...grpc.CallOption) (*StartSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartSessionResponse)
+	err := c.cc.Invoke(ctx, Ibdock_StartSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ibdockClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, Ibdock_GetSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ibdockClient) GetAccountSummary(ctx context.Context, in *GetAccountSummaryRequest, opts ...grpc.CallOption) (*AccountSummary, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AccountSummary)
+	err := c.cc.Invoke(ctx, Ibdock_GetAccountSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ibdockClient) StopSession(ctx context.Context, in *StopSessionRequest, opts ...grpc.CallOption) (*StopSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StopSessionResponse)
+	err := c.cc.Invoke(ctx, Ibdock_StopSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IbdockServer is the server API for Ibdock service.
+// All implementations must embed UnimplementedIbdockServer
+// for forward compatibility.
+type IbdockServer interface {
+	// StartSession starts a gateway logged in to account and returns once its
+	// container runs; the gateway may still be logging in.
+	StartSession(context.Context, *StartSessionRequest) (*StartSessionResponse, error)
+	GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error)
+	GetAccountSummary(context.Context, *GetAccountSummaryRequest) (*AccountSummary, error)
+	StopSession(context.Context, *StopSessionRequest) (*StopSessionResponse, error)
+	mustEmbedUnimplementedIbdockServer()
+}
+
+// UnimplementedIbdockServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIbdockServer struct{}
+
+func (UnimplementedIbdockServer) StartSession(context.Context, *StartSessionRequest) (*StartSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartSession not implemented")
+}
+func (UnimplementedIbdockServer) GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedIbdockServer) GetAccountSummary(context.Context, *GetAccountSummaryRequest) (*AccountSummary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccountSummary not implemented")
+}
+func (UnimplementedIbdockServer) StopSession(context.Context, *StopSessionRequest) (*StopSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopSession not implemented")
+}
+func (UnimplementedIbdockServer) mustEmbedUnimplementedIbdockServer() {}
+func (UnimplementedIbdockServer) testEmbeddedByValue()                {}
+
+// UnsafeIbdockServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IbdockServer will
+// result in compilation errors.
+type UnsafeIbdockServer interface {
+	mustEmbedUnimplementedIbdockServer()
+}
+
+func RegisterIbdockServer(s grpc.ServiceRegistrar, srv IbdockServer) {
+	// If the following call pancis, it indicates UnimplementedIbdockServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Ibdock_ServiceDesc, srv)
+}
+
+func _Ibdock_StartSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbdockServer).StartSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibdock_StartSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbdockServer).StartSession(ctx, req.(*StartSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ibdock_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbdockServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibdock_GetSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbdockServer).GetSnapshot(ctx, req.(*GetSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ibdock_GetAccountSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbdockServer).GetAccountSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibdock_GetAccountSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbdockServer).GetAccountSummary(ctx, req.(*GetAccountSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ibdock_StopSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbdockServer).StopSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibdock_StopSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbdockServer).StopSession(ctx, req.(*StopSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Ibdock_ServiceDesc is the grpc.ServiceDesc for Ibdock service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Ibdock_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "worthy.ibdock.rpc.Ibdock",
+	HandlerType: (*IbdockServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartSession",
+			Handler:    _Ibdock_StartSession_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _Ibdock_GetSnapshot_Handler,
+		},
+		{
+			MethodName: "GetAccountSummary",
+			Handler:    _Ibdock_GetAccountSummary_Handler,
+		},
+		{
+			MethodName: "StopSession",
+			Handler:    _Ibdock_StopSession_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "ibdock.proto",
+}
@@ -0,0 +1,968 @@
+// Package ibdocktest runs a fake Docker daemon, so code built on ibdock can be
+// tested without Docker or IB credentials:
+//
+//	server := ibdocktest.NewServer()
+//	defer server.Close()
+//	server.Snapshot(&snapshot.Snapshot{Account: "U1234567"})
+//	dock, err := ibdock.StartNew("user", "password", logger, ibdock.WithDockerEndpoint(server.URL()))
+//	...
+//	s, err := dock.GetSnapshot(ctx)
+//
+// It serves just the Engine API calls ibdock makes and keeps containers in
+// memory. Execs answer from the script set with HandleExec or Snapshot;
+// FailNext and SetLatency inject failures and slowness; ServeGateway lets
+// WaitReady see a logged-in gateway. Code that takes the daemon from the
+// environment, like ibdock.NewReconciler, can be pointed at it by setting
+// DOCKER_HOST to URL.
+package ibdocktest
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	_ "github.com/agentydragon/worthy/ibdock/snapshot/snapshotpb"
+	"github.com/fsouza/go-dockerclient"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is a kind of call to the daemon, for FailNext and SetLatency.
+type Op string
+
+const (
+	OpCreate  Op = "create"
+	OpStart   Op = "start"
+	OpInspect Op = "inspect"
+	OpStop    Op = "stop"
+	OpRemove  Op = "remove"
+	OpList    Op = "list"
+	OpLogs    Op = "logs"
+	OpPull    Op = "pull"
+	OpCopy    Op = "copy"
+	// OpExec covers creating, starting and inspecting execs.
+	OpExec Op = "exec"
+)
+
+// Exec is a command run in a container.
+type Exec struct {
+	// Container is the ID of the container it runs in.
+	Container  string
+	Cmd        []string
+	Env        []string
+	WorkingDir string
+	// Stdin is what the client wrote to the command's input, for execs
+	// attaching it. It is read in full before the handler runs.
+	Stdin []byte
+}
+
+// Result is what an Exec does.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	// Frames are written after Stdout and Stderr, for commands switching
+	// between the streams.
+	Frames []Frame
+	// Delay is how long the command runs before it exits.
+	Delay time.Duration
+}
+
+// File is a file copied into a container.
+type File struct {
+	Content []byte
+	Mode    int64
+	// Status is that of the container when the file was copied, e.g.
+	// "created".
+	Status string
+}
+
+// Frame is output written to one stream at once.
+type Frame struct {
+	Stderr bool
+	Data   []byte
+}
+
+// Server is a fake Docker daemon. Its methods are safe for concurrent use.
+type Server struct {
+	http *httptest.Server
+
+	mu         sync.Mutex
+	nextID     int
+	containers map[string]*docker.Container
+	logs       map[string][]byte
+	files      map[string]map[string]File
+	execs      map[string]*execState
+	handler    func(Exec) Result
+	failures   map[Op][]int
+	latencies  map[Op]time.Duration
+	apiPort    string
+	arch       string
+	// images maps normalized references to their platforms, "" for images
+	// that are not there; other images are built for arch.
+	images   map[string]string
+	pulls    []string
+	nextPort int
+	// clockSkew is how far the daemon's clock is off.
+	clockSkew time.Duration
+	// operatingSystem is what the daemon says it runs on.
+	operatingSystem string
+	// gateways are the listeners of ServeGateway.
+	gateways []net.Listener
+}
+
+type execState struct {
+	exec        Exec
+	attachStdin bool
+	running     bool
+	exitCode    int
+}
+
+// Port the gateway inside an ibdock container serves the TWS API on.
+const apiPort docker.Port = "7496/tcp"
+
+// NewServer starts a fake daemon. Until HandleExec or Snapshot is called,
+// every exec exits with code 127, as if the command did not exist.
+func NewServer() *Server {
+	s := &Server{
+		containers:      make(map[string]*docker.Container),
+		logs:            make(map[string][]byte),
+		files:           make(map[string]map[string]File),
+		execs:           make(map[string]*execState),
+		failures:        make(map[Op][]int),
+		latencies:       make(map[Op]time.Duration),
+		apiPort:         "7496",
+		arch:            "amd64",
+		images:          make(map[string]string),
+		operatingSystem: "ibdocktest",
+		handler: func(Exec) Result {
+			return Result{Stderr: []byte("command not found\n"), ExitCode: 127}
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /version", s.version)
+	mux.HandleFunc("GET /info", s.info)
+	mux.HandleFunc("GET /_ping", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /images/{ref...}", s.inspectImage)
+	mux.HandleFunc("POST /images/create", s.op(OpPull, s.pullImage))
+	mux.HandleFunc("POST /containers/create", s.op(OpCreate, s.createContainer))
+	mux.HandleFunc("GET /containers/json", s.op(OpList, s.listContainers))
+	mux.HandleFunc("GET /containers/{id}/json", s.op(OpInspect, s.inspectContainer))
+	mux.HandleFunc("POST /containers/{id}/start", s.op(OpStart, s.startContainer))
+	mux.HandleFunc("POST /containers/{id}/stop", s.op(OpStop, s.stopContainer))
+	mux.HandleFunc("DELETE /containers/{id}", s.op(OpRemove, s.removeContainer))
+	mux.HandleFunc("PUT /containers/{id}/archive", s.op(OpCopy, s.copyToContainer))
+	mux.HandleFunc("GET /containers/{id}/logs", s.op(OpLogs, s.containerLogs))
+	mux.HandleFunc("POST /containers/{id}/exec", s.op(OpExec, s.createExec))
+	mux.HandleFunc("POST /exec/{id}/start", s.op(OpExec, s.startExec))
+	mux.HandleFunc("GET /exec/{id}/json", s.op(OpExec, s.inspectExec))
+	s.http = httptest.NewServer(stripVersion(mux))
+	return s
+}
+
+func (s *Server) Close() {
+	s.http.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.gateways {
+		l.Close()
+	}
+}
+
+// URL is the daemon's endpoint, for ibdock.WithDockerEndpoint or DOCKER_HOST,
+// e.g. "tcp://127.0.0.1:41234".
+func (s *Server) URL() string {
+	return "tcp://" + s.http.Listener.Addr().String()
+}
+
+// HandleExec makes f decide what execs do.
+func (s *Server) HandleExec(f func(Exec) Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = f
+}
+
+// Snapshot makes execs of the snapshot script print snaps[0] in the format
+// they ask for, as the script in a logged-in container would, or the one of
+// the account they ask for with --account, as for a login managing several.
+// --currency filters the positions.
+func (s *Server) Snapshot(snaps ...*snapshot.Snapshot) {
+	formats := map[string]string{"json": "json", "csv": "csv", "proto": "protobuf"}
+	s.HandleExec(func(e Exec) Result {
+		format, currency := "json", ""
+		snap := snaps[0]
+		for _, arg := range e.Cmd {
+			if flag, ok := strings.CutPrefix(arg, "--format="); ok {
+				format = formats[flag]
+			}
+			if account, ok := strings.CutPrefix(arg, "--account="); ok {
+				i := slices.IndexFunc(snaps, func(snap *snapshot.Snapshot) bool { return snap.Account == account })
+				if i < 0 {
+					return Result{Stderr: []byte("no such account: " + account), ExitCode: 2}
+				}
+				snap = snaps[i]
+			}
+			if c, ok := strings.CutPrefix(arg, "--currency="); ok {
+				currency = c
+			}
+		}
+		if currency != "" {
+			filtered := *snap
+			filtered.Positions = nil
+			for _, p := range snap.Positions {
+				if p.Currency == currency {
+					filtered.Positions = append(filtered.Positions, p)
+				}
+			}
+			snap = &filtered
+		}
+		data, err := snapshot.Marshal(format, snap)
+		if err != nil {
+			return Result{Stderr: []byte(err.Error()), ExitCode: 2}
+		}
+		return Result{Stdout: data}
+	})
+}
+
+// FailNext makes the next call of kind op fail with the given HTTP status.
+// Calls to FailNext queue up: each failure is used once, in order.
+func (s *Server) FailNext(op Op, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[op] = append(s.failures[op], status)
+}
+
+// SetLatency delays every call of kind op by d.
+func (s *Server) SetLatency(op Op, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[op] = d
+}
+
+// PublishAPI sets the host port containers started from now on publish the
+// TWS API port on, e.g. that of a fake gateway. The default is 7496.
+func (s *Server) PublishAPI(hostPort string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiPort = hostPort
+}
+
+// ServeGateway makes containers started from now on publish a TWS API that
+// completes the handshake, for a login managing accounts, and hangs up, so
+// that ibdock's readiness checks pass.
+func (s *Server) ServeGateway(accounts ...string) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	frame := func(fields ...string) []byte {
+		payload := strings.Join(fields, "\x00") + "\x00"
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+	}
+	skipFrame := func(r *bufio.Reader) error {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return err
+		}
+		_, err := r.Discard(int(binary.BigEndian.Uint32(size[:])))
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			if _, err := r.Discard(4); err == nil && skipFrame(r) == nil {
+				conn.Write(frame("151", "20260129 12:00:00 CET"))
+				if skipFrame(r) == nil {
+					conn.Write(append(frame("15", "1", strings.Join(accounts, ",")), frame("9", "1", "1")...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gateways = append(s.gateways, listener)
+	s.apiPort = strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	return nil
+}
+
+// SetArch sets the architecture the daemon runs on, amd64 by default.
+func (s *Server) SetArch(arch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.arch = arch
+}
+
+// SetClockSkew makes the daemon's clock run d ahead of the real one, or
+// behind it if d is negative.
+func (s *Server) SetClockSkew(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clockSkew = d
+}
+
+// SetOperatingSystem sets the operating system the daemon reports, e.g.
+// "Docker Desktop"; the default is "ibdocktest".
+func (s *Server) SetOperatingSystem(os string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operatingSystem = os
+}
+
+// SetImage makes the image ref one built for platform, e.g. "linux/arm64",
+// or with an empty platform, one that has to be pulled. Other images are
+// there, built for the daemon's architecture.
+func (s *Server) SetImage(ref, platform string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[normalizeRef(ref)] = platform
+}
+
+// Pulls returns the images pulled so far, as "ref platform".
+func (s *Server) Pulls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.pulls...)
+}
+
+// AppendLog adds output to what the container with the given ID or name
+// logs.
+func (s *Server) AppendLog(idOrName string, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(idOrName); c != nil {
+		s.logs[c.ID] = append(s.logs[c.ID], output...)
+	}
+}
+
+// Exit marks the container with the given ID or name as having exited, e.g.
+// because the gateway crashed.
+func (s *Server) Exit(idOrName string, exitCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(idOrName); c != nil {
+		c.State = docker.State{Status: "exited", ExitCode: exitCode, StartedAt: c.State.StartedAt, FinishedAt: time.Now()}
+	}
+}
+
+// SetHealth sets the health check status of the container with the given
+// ID or name, e.g. "unhealthy", with failing probes in a row, the last of
+// which printed output.
+func (s *Server) SetHealth(idOrName, status string, failing int, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(idOrName); c != nil {
+		c.State.Health = docker.Health{Status: status, FailingStreak: failing, Log: []docker.HealthCheck{{End: time.Now(), ExitCode: min(failing, 1), Output: output}}}
+	}
+}
+
+// Files returns the files copied into the container with the given ID or
+// name, by absolute path.
+func (s *Server) Files(idOrName string) map[string]File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(idOrName)
+	if c == nil {
+		return nil
+	}
+	files := make(map[string]File)
+	for name, f := range s.files[c.ID] {
+		files[name] = f
+	}
+	return files
+}
+
+// Containers returns copies of all containers, running or not.
+func (s *Server) Containers() []docker.Container {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var containers []docker.Container
+	for _, c := range s.containers {
+		containers = append(containers, *c)
+	}
+	return containers
+}
+
+var versionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+
+// stripVersion serves versioned API paths like unversioned ones.
+func stripVersion(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = versionPrefix.ReplaceAllString(r.URL.Path, "/")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// op applies the failures and latency set for op before calling h.
+func (s *Server) op(op Op, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		latency := s.latencies[op]
+		status := 0
+		if queued := s.failures[op]; len(queued) > 0 {
+			status, s.failures[op] = queued[0], queued[1:]
+		}
+		s.mu.Unlock()
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+		if status != 0 {
+			fail(w, status, "injected failure of "+string(op))
+			return
+		}
+		h(w, r)
+	}
+}
+
+func fail(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"ApiVersion": "1.41", "Version": "ibdocktest", "Os": "linux", "Arch": s.arch})
+}
+
+func (s *Server) info(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"ServerVersion":   "ibdocktest",
+		"DockerRootDir":   "/var/lib/docker",
+		"SystemTime":      time.Now().Add(s.clockSkew).Format(time.RFC3339Nano),
+		"OperatingSystem": s.operatingSystem,
+	})
+}
+
+// normalizeRef spells image references the way Docker resolves them, less
+// the default registry: "ibcontroller" is "library/ibcontroller:latest".
+func normalizeRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	if !strings.Contains(ref, "/") {
+		ref = "library/" + ref
+	}
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") && !strings.Contains(ref, "@") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+func (s *Server) inspectImage(w http.ResponseWriter, r *http.Request) {
+	ref, ok := strings.CutSuffix(r.PathValue("ref"), "/json")
+	if !ok {
+		fail(w, http.StatusNotFound, "page not found")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	platform, ok := s.images[normalizeRef(ref)]
+	if !ok {
+		platform = "linux/" + s.arch
+	} else if platform == "" {
+		fail(w, http.StatusNotFound, "No such image: "+ref)
+		return
+	}
+	parts := strings.SplitN(platform, "/", 3)
+	image := map[string]string{"Id": imageID(ref), "Os": parts[0], "Architecture": parts[1]}
+	if len(parts) == 3 {
+		image["Variant"] = parts[2]
+	}
+	writeJSON(w, http.StatusOK, image)
+}
+
+// pullImage makes the image there for the platform asked for, or the
+// daemon's.
+func (s *Server) pullImage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ref := query.Get("fromImage")
+	if tag := query.Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	platform := query.Get("platform")
+	s.mu.Lock()
+	if platform == "" {
+		platform = "linux/" + s.arch
+	}
+	s.images[normalizeRef(ref)] = platform
+	s.pulls = append(s.pulls, ref+" "+platform)
+	s.mu.Unlock()
+	// The progress of a pull of two layers, as the daemon streams it.
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	for _, msg := range []map[string]any{
+		{"status": "Pulling from " + repository(ref), "id": "latest"},
+		{"status": "Pulling fs layer", "id": "layer1"},
+		{"status": "Already exists", "id": "layer2"},
+		{"status": "Downloading", "id": "layer1", "progressDetail": map[string]int{"current": 1000, "total": 4000}},
+		{"status": "Downloading", "id": "layer1", "progressDetail": map[string]int{"current": 3000, "total": 4000}},
+		{"status": "Download complete", "id": "layer1"},
+		{"status": "Pull complete", "id": "layer1"},
+		{"status": "Downloaded newer image for " + ref},
+	} {
+		encoder.Encode(msg)
+	}
+}
+
+// find looks a container up by ID or name; s.mu must be held.
+func (s *Server) find(idOrName string) *docker.Container {
+	if c, ok := s.containers[idOrName]; ok {
+		return c
+	}
+	for _, c := range s.containers {
+		if c.Name == "/"+strings.TrimPrefix(idOrName, "/") {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%064x", s.nextID)
+}
+
+func (s *Server) createContainer(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		docker.Config
+		HostConfig *docker.HostConfig
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := r.URL.Query().Get("name")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name != "" && s.find(name) != nil {
+		fail(w, http.StatusConflict, "container name "+name+" is already in use")
+		return
+	}
+	id := s.newID()
+	if name == "" {
+		name = id[len(id)-12:]
+	}
+	config := body.Config
+	s.containers[id] = &docker.Container{
+		ID:         id,
+		Name:       "/" + name,
+		Created:    time.Now(),
+		Config:     &config,
+		HostConfig: body.HostConfig,
+		Image:      imageID(config.Image),
+		Platform:   r.URL.Query().Get("platform"),
+		State:      docker.State{Status: "created"},
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": id})
+}
+
+// imageID makes up a digest for an image reference, standing in for the ID
+// Docker would resolve it to.
+func imageID(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (s *Server) inspectContainer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) startContainer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	if c.State.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	c.State = docker.State{Status: "running", Running: true, Pid: 1, StartedAt: time.Now()}
+	c.NetworkSettings = &docker.NetworkSettings{Ports: map[docker.Port][]docker.PortBinding{}}
+	if c.HostConfig != nil && c.HostConfig.PublishAllPorts {
+		c.NetworkSettings.Ports[apiPort] = []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: s.apiPort}}
+		for port := range c.Config.ExposedPorts {
+			if port != apiPort {
+				c.NetworkSettings.Ports[port] = []docker.PortBinding{{HostIP: "0.0.0.0", HostPort: s.freePort()}}
+			}
+		}
+	}
+	if c.HostConfig != nil {
+		// Bindings without a host port get the API port, or another as
+		// Docker would pick a free one.
+		for port, bindings := range c.HostConfig.PortBindings {
+			for _, binding := range bindings {
+				if binding.HostIP == "" {
+					binding.HostIP = "0.0.0.0"
+				}
+				if binding.HostPort == "" && port == apiPort {
+					binding.HostPort = s.apiPort
+				} else if binding.HostPort == "" {
+					binding.HostPort = s.freePort()
+				}
+				c.NetworkSettings.Ports[port] = append(c.NetworkSettings.Ports[port], binding)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// freePort stands in for the ephemeral host ports Docker publishes ports
+// other than the API's on.
+func (s *Server) freePort() string {
+	s.nextPort++
+	return strconv.Itoa(49152 + s.nextPort)
+}
+
+func (s *Server) stopContainer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	if !c.State.Running {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	c.State = docker.State{Status: "exited", FinishedAt: time.Now()}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeContainer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	if c.State.Running && !isTrue(r.URL.Query().Get("force")) {
+		fail(w, http.StatusConflict, "container is running, stop it or use force")
+		return
+	}
+	delete(s.containers, c.ID)
+	delete(s.logs, c.ID)
+	delete(s.files, c.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// copyToContainer extracts the regular files of the uploaded tar archive.
+func (s *Server) copyToContainer(w http.ResponseWriter, r *http.Request) {
+	dir := r.URL.Query().Get("path")
+	if !path.IsAbs(dir) {
+		fail(w, http.StatusBadRequest, "path must be absolute")
+		return
+	}
+	files := make(map[string]File)
+	archive := tar.NewReader(r.Body)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		files[path.Join(dir, header.Name)] = File{Content: content, Mode: header.Mode}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	if s.files[c.ID] == nil {
+		s.files[c.ID] = make(map[string]File)
+	}
+	for name, f := range files {
+		f.Status = c.State.Status
+		s.files[c.ID][name] = f
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func isTrue(v string) bool {
+	return v == "1" || v == "true" || v == "True"
+}
+
+func (s *Server) listContainers(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"))
+	if err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	all := isTrue(r.URL.Query().Get("all"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	listed := []docker.APIContainers{}
+	for _, c := range s.containers {
+		if (!all && !c.State.Running) || !matches(c, filters) {
+			continue
+		}
+		listed = append(listed, docker.APIContainers{
+			ID:      c.ID,
+			Image:   c.Config.Image,
+			Names:   []string{c.Name},
+			Labels:  c.Config.Labels,
+			State:   c.State.Status,
+			Status:  c.State.String(),
+			Created: c.Created.Unix(),
+			Ports:   publishedPorts(c),
+		})
+	}
+	writeJSON(w, http.StatusOK, listed)
+}
+
+func publishedPorts(c *docker.Container) []docker.APIPort {
+	var ports []docker.APIPort
+	if c.NetworkSettings == nil {
+		return ports
+	}
+	for port, bindings := range c.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			hostPort, _ := strconv.ParseInt(binding.HostPort, 10, 64)
+			private, _ := strconv.ParseInt(port.Port(), 10, 64)
+			ports = append(ports, docker.APIPort{PrivatePort: private, PublicPort: hostPort, Type: port.Proto(), IP: binding.HostIP})
+		}
+	}
+	return ports
+}
+
+// parseFilters reads list filters in either format clients send:
+// {"label": ["a"]} or the daemon's own {"label": {"a": true}}.
+func parseFilters(raw string) (map[string][]string, error) {
+	filters := map[string][]string{}
+	if raw == "" {
+		return filters, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &filters); err == nil {
+		return filters, nil
+	}
+	var sets map[string]map[string]bool
+	if err := json.Unmarshal([]byte(raw), &sets); err != nil {
+		return nil, err
+	}
+	for key, set := range sets {
+		for v, on := range set {
+			if on {
+				filters[key] = append(filters[key], v)
+			}
+		}
+	}
+	return filters, nil
+}
+
+// matches applies the list filters ibdock uses: label, ancestor, status and
+// name. Values of one filter are alternatives; all filters must match.
+func matches(c *docker.Container, filters map[string][]string) bool {
+	for key, values := range filters {
+		ok := false
+		for _, v := range values {
+			switch key {
+			case "label":
+				label, want, hasValue := strings.Cut(v, "=")
+				got, has := c.Config.Labels[label]
+				ok = ok || (has && (!hasValue || got == want))
+			case "ancestor":
+				ok = ok || c.Config.Image == v || repository(c.Config.Image) == v
+			case "status":
+				ok = ok || c.State.Status == v
+			case "name":
+				ok = ok || strings.Contains(c.Name, v)
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func repository(ref string) string {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i]
+	}
+	return ref
+}
+
+// Streams of the multiplexed format Docker sends non-TTY output in.
+const (
+	stdout = 1
+	stderr = 2
+)
+
+func frame(stream byte, data []byte) []byte {
+	header := []byte{stream, 0, 0, 0}
+	header = binary.BigEndian.AppendUint32(header, uint32(len(data)))
+	return append(header, data...)
+}
+
+// containerLogs writes what the container logged so far and ends the stream,
+// even when following.
+func (s *Server) containerLogs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	c := s.find(r.PathValue("id"))
+	var logs []byte
+	if c != nil {
+		logs = s.logs[c.ID]
+	}
+	s.mu.Unlock()
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+	w.WriteHeader(http.StatusOK)
+	if len(logs) > 0 && isTrue(r.URL.Query().Get("stdout")) {
+		w.Write(frame(stdout, logs))
+	}
+}
+
+func (s *Server) createExec(w http.ResponseWriter, r *http.Request) {
+	var options docker.CreateExecOptions
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(options.Cmd) == 0 {
+		fail(w, http.StatusBadRequest, "no command specified")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(r.PathValue("id"))
+	if c == nil {
+		fail(w, http.StatusNotFound, "no such container")
+		return
+	}
+	if !c.State.Running {
+		fail(w, http.StatusConflict, "container "+c.ID+" is not running")
+		return
+	}
+	id := s.newID()
+	s.execs[id] = &execState{exec: Exec{Container: c.ID, Cmd: options.Cmd, Env: options.Env, WorkingDir: options.WorkingDir}, attachStdin: options.AttachStdin}
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": id})
+}
+
+// startExec runs the exec on a hijacked connection, as Docker does for
+// attached execs: the client reads multiplexed output until the connection
+// closes, then inspects the exec for its exit code.
+func (s *Server) startExec(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	state, ok := s.execs[r.PathValue("id")]
+	handler := s.handler
+	if ok {
+		state.running = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		fail(w, http.StatusNotFound, "no such exec")
+		return
+	}
+	// The start options come first; input follows them on the connection.
+	io.Copy(io.Discard, r.Body)
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		fail(w, http.StatusInternalServerError, "cannot hijack connection")
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	buf.Flush()
+	exec := state.exec
+	if state.attachStdin {
+		// Clients close their end for writing once the input is sent.
+		if exec.Stdin, err = io.ReadAll(buf); err != nil {
+			return
+		}
+	}
+	result := handler(exec)
+	time.Sleep(result.Delay)
+	if len(result.Stdout) > 0 {
+		buf.Write(frame(stdout, result.Stdout))
+	}
+	if len(result.Stderr) > 0 {
+		buf.Write(frame(stderr, result.Stderr))
+	}
+	for _, f := range result.Frames {
+		stream := byte(stdout)
+		if f.Stderr {
+			stream = stderr
+		}
+		buf.Write(frame(stream, f.Data))
+	}
+	buf.Flush()
+	s.mu.Lock()
+	state.running, state.exitCode = false, result.ExitCode
+	s.mu.Unlock()
+}
+
+func (s *Server) inspectExec(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.execs[r.PathValue("id")]
+	if !ok {
+		fail(w, http.StatusNotFound, "no such exec")
+		return
+	}
+	writeJSON(w, http.StatusOK, docker.ExecInspect{
+		ID:          r.PathValue("id"),
+		Running:     state.running,
+		ExitCode:    state.exitCode,
+		ContainerID: state.exec.Container,
+		ProcessConfig: docker.ExecProcessConfig{
+			EntryPoint: state.exec.Cmd[0],
+			Arguments:  state.exec.Cmd[1:],
+		},
+	})
+}
@@ -0,0 +1,102 @@
+package ibdocktest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("sdk", func(t *testing.T) { testSnapshot(t) })
+	t.Run("legacy", func(t *testing.T) { testSnapshot(t, ibdock.WithLegacyDockerClient()) })
+}
+
+func testSnapshot(t *testing.T, opts ...ibdock.Option) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	want := &snapshot.Snapshot{Account: "U1111111", Timestamp: time.Unix(1767225600, 0).UTC(),
+		Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(10)}}}
+	server.Snapshot(want)
+	logger := log.New(io.Discard, "", 0)
+	ctx := context.Background()
+
+	opts = append(opts, ibdock.WithDockerEndpoint(server.URL()))
+	dock, err := ibdock.StartNew("jdoe", "secret", logger,
+		append(opts, ibdock.WithSessionName("main"), ibdock.WithTradingMode("paper"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := server.Containers()
+	if len(containers) != 1 || containers[0].Name != "/ibcontroller_main" || !containers[0].State.Running {
+		t.Fatalf("containers = %+v", containers)
+	}
+	if env := strings.Join(containers[0].Config.Env, " "); !strings.Contains(env, "TRADING_MODE=paper") {
+		t.Errorf("env = %s", env)
+	}
+	for _, format := range []string{"json", "csv", "protobuf"} {
+		got, err := dock.GetSnapshotAs(ctx, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got.Account != want.Account || len(got.Positions) != 1 || got.Positions[0].Quantity != snapshot.DecimalFromFloat(10) {
+			t.Errorf("%s: got %+v", format, got)
+		}
+	}
+
+	attached, err := ibdock.Attach("ibcontroller_main", logger, opts...)
+	if err != nil || attached.ContainerID() != dock.ContainerID() {
+		t.Errorf("Attach = %v, %v", attached, err)
+	}
+	if err := dock.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if containers := server.Containers(); len(containers) != 0 {
+		t.Errorf("Stop left %+v", containers)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	ctx := context.Background()
+
+	server.FailNext(ibdocktest.OpCreate, http.StatusInternalServerError)
+	if _, err := ibdock.StartNew("jdoe", "secret", logger, ibdock.WithDockerEndpoint(server.URL())); err == nil {
+		t.Errorf("StartNew succeeded despite a failing create")
+	}
+	dock, err := ibdock.StartNew("jdoe", "secret", logger, ibdock.WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exitErr *ibdock.ExitError
+	if _, err := dock.GetSnapshot(ctx); !errors.As(err, &exitErr) || exitErr.Code != 127 {
+		t.Errorf("GetSnapshot without a script = %v, want exit code 127", err)
+	}
+
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stdout: []byte("{}"), Delay: time.Second}
+	})
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := dock.GetSnapshot(timeout); err == nil {
+		t.Errorf("GetSnapshot outlived its deadline")
+	}
+
+	server.Exit(dock.ContainerID(), 1)
+	if err := dock.WaitReady(ctx); err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Errorf("WaitReady on an exited container = %v", err)
+	}
+	if _, err := ibdock.Attach(dock.ContainerID(), logger, ibdock.WithDockerEndpoint(server.URL())); err == nil {
+		t.Errorf("Attach to an exited container succeeded")
+	}
+}
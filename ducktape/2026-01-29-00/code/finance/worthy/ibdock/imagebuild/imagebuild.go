@@ -0,0 +1,249 @@
+// Package imagebuild assembles the ibcontroller images that ibdock runs: IB
+// Gateway, or TWS for the TWS variant, driven by IBC, plus the snapshot
+// script, built locally instead of pulled from the published images.
+//
+//	client, err := docker.NewClientFromEnv()
+//	if err != nil {
+//	  panic(err)
+//	}
+//	script, err := os.ReadFile("read_snapshot.py")
+//	if err != nil {
+//	  panic(err)
+//	}
+//	tag, err := imagebuild.Build(ctx, client, imagebuild.Options{Script: script}, os.Stdout)
+package imagebuild
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fsouza/go-dockerclient"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Repository is the image repository ibdock starts containers from, and
+// TWSRepository that of its TWS variant.
+const (
+	Repository    = "agentydragon/ibcontroller"
+	TWSRepository = "agentydragon/ibcontroller-tws"
+)
+
+const (
+	DefaultTWSVersion = "1030"
+	DefaultIBCVersion = "3.20.0"
+	// IB only publishes the current stable and latest installers, so the
+	// default TWSVersion has to track the stable channel's major version.
+	defaultInstallerURL    = "https://download2.interactivebrokers.com/installers/ibgateway/stable-standalone/ibgateway-stable-standalone-linux-x64.sh"
+	defaultTWSInstallerURL = "https://download2.interactivebrokers.com/installers/tws/stable-standalone/tws-stable-standalone-linux-x64.sh"
+)
+
+type Options struct {
+	// Variant is "gateway" (the default) for IB Gateway or "tws" for TWS,
+	// see ibdock.Variant.
+	Variant string
+	// TWSVersion is the major IB Gateway or TWS version the installer
+	// provides, as IBC expects it, e.g. "1030" for 10.30.
+	TWSVersion string
+	// InstallerURL is where the installer is downloaded from.
+	InstallerURL string
+	// IBCVersion is the IBC release, see github.com/IbcAlpha/IBC/releases.
+	IBCVersion string
+	// Script is the content of read_snapshot.py, which must take the flags
+	// described at ibdock.SnapshotRequest.
+	Script []byte
+	// ScriptRevision identifies Script in the image tag and labels, e.g. the
+	// git revision it was taken from.
+	ScriptRevision string
+}
+
+func (options *Options) setDefaults() {
+	if options.Variant == "" {
+		options.Variant = "gateway"
+	}
+	if options.TWSVersion == "" {
+		options.TWSVersion = DefaultTWSVersion
+	}
+	if options.InstallerURL == "" {
+		options.InstallerURL = defaultInstallerURL
+		if options.Variant == "tws" {
+			options.InstallerURL = defaultTWSInstallerURL
+		}
+	}
+	if options.IBCVersion == "" {
+		options.IBCVersion = DefaultIBCVersion
+	}
+	if options.ScriptRevision == "" {
+		options.ScriptRevision = "local"
+	}
+}
+
+// ImageRepository is the repository the image for options is built in.
+func ImageRepository(options Options) string {
+	if options.Variant == "tws" {
+		return TWSRepository
+	}
+	return Repository
+}
+
+// Tag is the tag the image for options is built under.
+func Tag(options Options) string {
+	options.setDefaults()
+	return fmt.Sprintf("tws%s-ibc%s-%s", options.TWSVersion, options.IBCVersion, options.ScriptRevision)
+}
+
+var dockerfile = template.Must(template.New("Dockerfile").Parse(`FROM ubuntu:24.04
+
+RUN apt-get update && \
+    apt-get install -y --no-install-recommends ca-certificates curl unzip xvfb x11vnc imagemagick libxtst6 libxrender1 python3 && \
+    rm -rf /var/lib/apt/lists/*
+
+RUN curl -fsSL -o /tmp/installer.sh {{.InstallerURL}} && \
+    sh /tmp/installer.sh -q -dir /root/Jts/{{if eq .Variant "gateway"}}ibgateway/{{end}}{{.TWSVersion}} && \
+    rm /tmp/installer.sh
+
+RUN curl -fsSL -o /tmp/ibc.zip https://github.com/IbcAlpha/IBC/releases/download/{{.IBCVersion}}/IBCLinux-{{.IBCVersion}}.zip && \
+    unzip /tmp/ibc.zip -d /opt/ibc && \
+    chmod +x /opt/ibc/*.sh /opt/ibc/scripts/*.sh && \
+    rm /tmp/ibc.zip
+
+COPY config.ini /root/ibc/config.ini
+COPY entrypoint.sh /root/entrypoint.sh
+COPY read_snapshot.py /root/read_snapshot.py
+
+LABEL org.opencontainers.image.title="ibcontroller" \
+      worthy.variant="{{.Variant}}" \
+      worthy.tws-version="{{.TWSVersion}}" \
+      worthy.ibc-version="{{.IBCVersion}}" \
+      worthy.script-revision="{{.ScriptRevision}}"
+
+ENV TWS_MAJOR_VRSN={{.TWSVersion}} IBC_VARIANT={{.Variant}} DISPLAY=:1
+EXPOSE 7496
+# The API port only accepts connections once the login went through.
+HEALTHCHECK --interval=30s --timeout=10s --start-period=5m --retries=3 \
+    CMD python3 -c "import socket; socket.create_connection(('127.0.0.1', 7496)).close()"
+ENTRYPOINT ["/bin/sh", "/root/entrypoint.sh"]
+`))
+
+// The gateway or TWS listens on ibdock's API port, TWS's default, and accepts
+// its connections without a confirmation dialog.
+const configINI = `IbLoginId=
+IbPassword=
+TradingMode=live
+OverrideTwsApiPort=7496
+AcceptIncomingConnectionAction=accept
+ReadOnlyApi=yes
+ExistingSessionDetectedAction=primary
+`
+
+// Credentials and the trading mode come from the environment ibdock.StartNew
+// sets, as does IBC's ExistingSessionDetectedAction, see
+// ibdock.WithSessionConflict. Settings go to /root/tws_settings if
+// ibdock.WithSettingsVolume mounted a volume there. TWS needs a larger screen than the gateway to lay
+// out its windows. ibdock.WithDebugVNC sets IBDOCK_VNC to get a VNC server on
+// the screen.
+const entrypoint = `#!/bin/sh
+screen=1024x768x16
+gateway=--gateway
+if [ "$IBC_VARIANT" = tws ]; then
+    screen=1920x1080x24
+    gateway=
+fi
+Xvfb :1 -screen 0 "$screen" &
+if [ "$IBDOCK_VNC" = 1 ]; then
+    set -- -nopw
+    [ -n "$VNC_PASSWORD" ] && set -- -passwd "$VNC_PASSWORD"
+    x11vnc -display :1 -forever -shared -loop -rfbport 5900 "$@" -o /tmp/x11vnc.log &
+fi
+if [ -n "$IBDOCK_EXISTING_SESSION" ]; then
+    sed -i "s/^ExistingSessionDetectedAction=.*/ExistingSessionDetectedAction=$IBDOCK_EXISTING_SESSION/" /root/ibc/config.ini
+fi
+settings=/root/Jts
+[ -d /root/tws_settings ] && settings=/root/tws_settings
+exec /opt/ibc/scripts/ibcstart.sh "$TWS_MAJOR_VRSN" $gateway \
+    --tws-path=/root/Jts --tws-settings-path="$settings" \
+    --ibc-path=/opt/ibc --ibc-ini=/root/ibc/config.ini \
+    --user="$IB_LOGIN_ID" --pw="$IB_PASSWORD" --mode="${TRADING_MODE:-live}"
+`
+
+// Dockerfile renders the Dockerfile for options.
+func Dockerfile(options Options) (string, error) {
+	options.setDefaults()
+	if options.Variant != "gateway" && options.Variant != "tws" {
+		return "", fmt.Errorf("imagebuild: unknown variant %q, want gateway or tws", options.Variant)
+	}
+	var b strings.Builder
+	if err := dockerfile.Execute(&b, options); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// BuildContext returns the build context for options as a tar archive.
+func BuildContext(options Options) ([]byte, error) {
+	if len(options.Script) == 0 {
+		return nil, errors.New("imagebuild: Options.Script is empty")
+	}
+	df, err := Dockerfile(options)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	now := time.Now()
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{"Dockerfile", []byte(df)},
+		{"config.ini", []byte(configINI)},
+		{"entrypoint.sh", []byte(entrypoint)},
+		{"read_snapshot.py", options.Script},
+	} {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), ModTime: now}
+		if err := w.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Build builds the image for options, streaming build output to output, and
+// tags it both as ImageRepository(options):Tag(options) and as :latest, which
+// is what ibdock starts. It returns the full versioned image name.
+func Build(ctx context.Context, client *docker.Client, options Options, output io.Writer) (string, error) {
+	buildContext, err := BuildContext(options)
+	if err != nil {
+		return "", err
+	}
+	repository := ImageRepository(options)
+	name := repository + ":" + Tag(options)
+	if err := client.BuildImage(docker.BuildImageOptions{
+		Context:        ctx,
+		Name:           name,
+		InputStream:    bytes.NewReader(buildContext),
+		OutputStream:   output,
+		RmTmpContainer: true,
+	}); err != nil {
+		return "", err
+	}
+	if err := client.TagImage(name, docker.TagImageOptions{
+		Context: ctx,
+		Repo:    repository,
+		Tag:     "latest",
+		Force:   true,
+	}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
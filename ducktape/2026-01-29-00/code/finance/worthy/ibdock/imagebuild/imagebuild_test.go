@@ -0,0 +1,89 @@
+package imagebuild
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBuildContext(t *testing.T) {
+	options := Options{TWSVersion: "1019", IBCVersion: "3.18.0", Script: []byte("print()\n"), ScriptRevision: "abc123"}
+	if tag := Tag(options); tag != "tws1019-ibc3.18.0-abc123" {
+		t.Errorf("Tag = %q", tag)
+	}
+	data, err := BuildContext(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	r := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		files[header.Name] = string(content)
+	}
+	if files["read_snapshot.py"] != "print()\n" {
+		t.Errorf("read_snapshot.py = %q", files["read_snapshot.py"])
+	}
+	for _, want := range []string{"/root/Jts/ibgateway/1019", "IBCLinux-3.18.0.zip", `worthy.script-revision="abc123"`, defaultInstallerURL} {
+		if !strings.Contains(files["Dockerfile"], want) {
+			t.Errorf("Dockerfile does not contain %q:\n%s", want, files["Dockerfile"])
+		}
+	}
+	if _, err := BuildContext(Options{}); err == nil {
+		t.Errorf("BuildContext without a script should fail")
+	}
+
+	tws := Options{Variant: "tws", TWSVersion: "1019", Script: []byte("print()\n")}
+	if repository := ImageRepository(tws); repository != TWSRepository {
+		t.Errorf("ImageRepository(tws) = %q", repository)
+	}
+	df, err := Dockerfile(tws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-dir /root/Jts/1019 ", defaultTWSInstallerURL, "IBC_VARIANT=tws"} {
+		if !strings.Contains(df, want) {
+			t.Errorf("TWS Dockerfile does not contain %q:\n%s", want, df)
+		}
+	}
+	if _, err := Dockerfile(Options{Variant: "portal"}); err == nil {
+		t.Errorf("Dockerfile for an unknown variant should fail")
+	}
+}
+
+func TestStubBuildContext(t *testing.T) {
+	data, err := StubBuildContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	r := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == "read_snapshot.py" {
+			content, _ := io.ReadAll(r)
+			if !strings.Contains(string(content), StubAccount) {
+				t.Errorf("stub read_snapshot.py does not report %s:\n%s", StubAccount, content)
+			}
+		}
+		names = append(names, header.Name)
+	}
+	if strings.Join(names, " ") != "Dockerfile stub_gateway.py read_snapshot.py" {
+		t.Errorf("stub build context has %v", names)
+	}
+}
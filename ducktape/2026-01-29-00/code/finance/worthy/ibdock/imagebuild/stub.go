@@ -0,0 +1,168 @@
+package imagebuild
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"github.com/fsouza/go-dockerclient"
+	"io"
+	"time"
+)
+
+// StubRepository is where BuildStub tags the stub image.
+const StubRepository = "agentydragon/ibcontroller-stub"
+
+// StubAccount is the account the stub gateway manages and its
+// read_snapshot.py reports.
+const StubAccount = "DU0000000"
+
+// The stub stands in for the gateway and IBC: it needs no IB login, starts in
+// a second and is a few dozen MB, for testing ibdock's container lifecycle.
+const stubDockerfile = `FROM python:3.12-alpine
+
+COPY stub_gateway.py /root/stub_gateway.py
+COPY read_snapshot.py /root/read_snapshot.py
+
+LABEL org.opencontainers.image.title="ibcontroller-stub" \
+      worthy.variant="stub"
+
+EXPOSE 7496
+HEALTHCHECK --interval=5s --timeout=5s --retries=3 \
+    CMD python3 -c "import socket; socket.create_connection(('127.0.0.1', 7496)).close()"
+ENTRYPOINT ["python3", "/root/stub_gateway.py"]
+`
+
+// stubGateway answers the TWS API handshake on ibdock's API port the way a
+// logged-in gateway does, which is what ibdock.Dock.WaitReady waits for, then
+// ignores whatever the client sends. It exits on SIGTERM so that stopping the
+// container does not wait out the grace period.
+const stubGateway = `import signal
+import socket
+import struct
+import sys
+import threading
+import time
+
+ACCOUNT = "` + StubAccount + `"
+SERVER_VERSION = 151
+
+
+def read_exact(conn, n):
+    data = b""
+    while len(data) < n:
+        chunk = conn.recv(n - len(data))
+        if not chunk:
+            raise EOFError
+        data += chunk
+    return data
+
+
+def read_frame(conn):
+    (size,) = struct.unpack(">I", read_exact(conn, 4))
+    return read_exact(conn, size)
+
+
+def frame(*fields):
+    payload = b"".join(str(f).encode() + b"\0" for f in fields)
+    return struct.pack(">I", len(payload)) + payload
+
+
+def serve(conn):
+    with conn:
+        try:
+            if read_exact(conn, 4) != b"API\0":
+                return
+            read_frame(conn)
+            conn.sendall(frame(SERVER_VERSION, time.strftime("%Y%m%d %H:%M:%S UTC", time.gmtime())))
+            read_frame(conn)
+            conn.sendall(frame(9, 1, 1) + frame(15, 1, ACCOUNT))
+            while conn.recv(4096):
+                pass
+        except (EOFError, OSError):
+            pass
+
+
+signal.signal(signal.SIGTERM, lambda *_: sys.exit(0))
+listener = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+listener.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
+listener.bind(("0.0.0.0", 7496))
+listener.listen()
+print("stub gateway listening on 7496", flush=True)
+while True:
+    conn, _ = listener.accept()
+    threading.Thread(target=serve, args=(conn,), daemon=True).start()
+`
+
+// stubScript is a read_snapshot.py printing a fixed JSON snapshot of
+// StubAccount, taking the real one's flags.
+const stubScript = `import argparse
+import datetime
+import json
+
+parser = argparse.ArgumentParser()
+parser.add_argument("--port")
+parser.add_argument("--format", default="json")
+parser.add_argument("--account", default="` + StubAccount + `")
+parser.add_argument("--currency")
+args, _ = parser.parse_known_args()
+if args.format != "json":
+    raise SystemExit("the stub only prints --format=json")
+currency = args.currency or "USD"
+print(json.dumps({
+    "SchemaVersion": 2,
+    "Account": args.account,
+    "Timestamp": datetime.datetime.now(datetime.timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ"),
+    "Positions": [{"Symbol": "VT", "SecType": "STK", "Currency": currency, "Quantity": 10,
+                   "AvgCost": 100, "MarketPrice": 110, "MarketValue": 1100}],
+}))
+`
+
+// StubBuildContext returns the build context of the stub image as a tar
+// archive.
+func StubBuildContext() ([]byte, error) {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	now := time.Now()
+	for _, file := range []struct {
+		name string
+		data string
+	}{
+		{"Dockerfile", stubDockerfile},
+		{"stub_gateway.py", stubGateway},
+		{"read_snapshot.py", stubScript},
+	} {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), ModTime: now}
+		if err := w.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// BuildStub builds the stub image, streaming build output to output, and
+// returns its name, StubRepository:latest. Start sessions from it with
+// ibdock.WithImage; the integration package runs ibdock's lifecycle tests
+// against it.
+func BuildStub(ctx context.Context, client *docker.Client, output io.Writer) (string, error) {
+	buildContext, err := StubBuildContext()
+	if err != nil {
+		return "", err
+	}
+	name := StubRepository + ":latest"
+	if err := client.BuildImage(docker.BuildImageOptions{
+		Context:        ctx,
+		Name:           name,
+		InputStream:    bytes.NewReader(buildContext),
+		OutputStream:   output,
+		RmTmpContainer: true,
+	}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,57 @@
+package ibdock
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ContainerInfo is what Docker reports about a Dock's container, for logs and
+// dashboards.
+type ContainerInfo struct {
+	ID string
+	// Name is without Docker's leading slash, e.g. "ibcontroller_default".
+	Name string
+	// Image is the reference the container was created from, ImageID the
+	// digest it resolved to, e.g. "sha256:...".
+	Image   string
+	ImageID string
+	Created time.Time
+	// StartedAt is when the container last started, zero if it never did.
+	StartedAt time.Time
+	Running   bool
+	// State describes the state for humans, e.g. "exited (1)"; the wording
+	// depends on the Docker client.
+	State string
+	// Health is the health check status, "starting", "healthy" or
+	// "unhealthy", empty without a health check; see WithHealthCheck.
+	// HealthFailures counts the probes in a row that failed, HealthOutput
+	// is what the last one printed.
+	Health         string
+	HealthFailures int
+	HealthOutput   string
+	Labels         map[string]string
+}
+
+// Inspect asks Docker about the Dock's container.
+func (dock *Dock) Inspect(ctx context.Context) (_ ContainerInfo, err error) {
+	defer dock.redactError(&err)
+	c, err := dock.client.inspect(ctx, dock.current().ID)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+	return ContainerInfo{
+		ID:             c.ID,
+		Name:           strings.TrimPrefix(c.Name, "/"),
+		Image:          c.Image,
+		ImageID:        c.ImageID,
+		Created:        c.Created,
+		StartedAt:      c.StartedAt,
+		Running:        c.Running,
+		State:          c.Status,
+		Health:         c.Health,
+		HealthFailures: c.HealthFailures,
+		HealthOutput:   c.HealthOutput,
+		Labels:         c.Labels,
+	}, nil
+}
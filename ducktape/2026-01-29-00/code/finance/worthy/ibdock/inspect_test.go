@@ -0,0 +1,47 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		before := time.Now().Add(-time.Second)
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()), WithSessionName("main"))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		info, err := dock.Inspect(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if info.ID != dock.ContainerID() || info.Name != "ibcontroller_main" || info.Image != image || !strings.HasPrefix(info.ImageID, "sha256:") || info.Labels[sessionLabel] != "main" {
+			t.Errorf("%s: Inspect = %+v", backend.name, info)
+		}
+		// The legacy client describes states as docker ps does, e.g. "Up 2
+		// minutes".
+		if !info.Running || info.State == "" || info.Created.Before(before) || info.StartedAt.Before(info.Created) {
+			t.Errorf("%s: state %q, running %v, created %v, started %v", backend.name, info.State, info.Running, info.Created, info.StartedAt)
+		}
+
+		server.Exit(dock.ContainerID(), 1)
+		if info, err := dock.Inspect(context.Background()); err != nil || info.Running || !strings.Contains(strings.ToLower(info.State), "exited (1)") {
+			t.Errorf("%s: Inspect after exit = %+v, %v", backend.name, info, err)
+		}
+	}
+}
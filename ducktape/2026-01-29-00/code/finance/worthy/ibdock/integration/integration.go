@@ -0,0 +1,218 @@
+// Package integration exercises ibdock's session lifecycle against a real
+// Docker daemon: starting a container, waiting until it is ready and Docker
+// reports it healthy, execs that succeed, fail and time out, a snapshot,
+// stopping the session and collecting an orphaned container. It runs against
+// the stub image of imagebuild.BuildStub, which needs no IB account, or any
+// image standing in for the ibcontroller one.
+//
+// Projects built on ibdock run the same suite from a test of their own:
+//
+//	func TestLifecycle(t *testing.T) {
+//	  client, err := docker.NewClientFromEnv()
+//	  if err != nil {
+//	    t.Skip(err)
+//	  }
+//	  ref, err := imagebuild.BuildStub(ctx, client, io.Discard)
+//	  if err != nil {
+//	    t.Fatal(err)
+//	  }
+//	  integration.Run(t, integration.Config{Image: ref})
+//	}
+//
+// ibdock's own run of it needs the integration build tag:
+//
+//	go test -tags=integration ./ibdock/integration/
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+const defaultReadyTimeout = 2 * time.Minute
+
+// Config configures Run.
+type Config struct {
+	// Image is the image sessions start from. Its main process must exit on
+	// SIGTERM, which the orphan test sends it from inside the container.
+	Image string
+	// Options are passed to every ibdock call, e.g. WithDockerEndpoint.
+	Options []ibdock.Option
+	// ReadyTimeout bounds WaitReady, 2 minutes if zero.
+	ReadyTimeout time.Duration
+	// Logger gets ibdock's logs; nil discards them.
+	Logger *log.Logger
+}
+
+// options are those of the suite's sessions. The health check probes often
+// enough for the suite to see it pass, unless c.Options configure another.
+func (c Config) options(session string) []ibdock.Option {
+	opts := []ibdock.Option{ibdock.WithHealthCheck(ibdock.HealthCheck{Interval: time.Second, StartPeriod: time.Second})}
+	return append(append(opts, c.Options...), ibdock.WithImage(c.Image), ibdock.WithSessionName(session))
+}
+
+// Run runs the suite as subtests of t. The sessions it starts are named
+// after the time, so runs sharing a Docker host do not clash; the orphan
+// test removes all stopped ibcontroller containers there, as RemoveStopped
+// does.
+func Run(t *testing.T, config Config) {
+	t.Helper()
+	if config.Image == "" {
+		t.Fatal("integration: Config.Image is empty")
+	}
+	if config.ReadyTimeout <= 0 {
+		config.ReadyTimeout = defaultReadyTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = log.New(io.Discard, "", 0)
+	}
+	ctx := context.Background()
+	session := fmt.Sprintf("integration_%d", time.Now().UnixNano())
+
+	dock, err := ibdock.StartNewContext(ctx, "stub", "stub", config.Logger, config.options(session)...)
+	if err != nil {
+		t.Fatalf("StartNew: %v", err)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			dock.Kill()
+		}
+	}()
+
+	t.Run("Ready", func(t *testing.T) {
+		ready, cancel := context.WithTimeout(ctx, config.ReadyTimeout)
+		defer cancel()
+		if err := dock.WaitReady(ready); err != nil {
+			t.Fatalf("WaitReady: %v", err)
+		}
+		info, err := dock.Inspect(ctx)
+		if err != nil || !info.Running || info.Image != config.Image {
+			t.Errorf("Inspect = %+v, %v, want a running container of %s", info, err, config.Image)
+		}
+	})
+
+	t.Run("HealthCheck", func(t *testing.T) {
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			info, err := dock.Inspect(ctx)
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if info.Health == "healthy" {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Docker reports health %q, last probe printed %q", info.Health, info.HealthOutput)
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+	})
+
+	t.Run("ExecSuccess", func(t *testing.T) {
+		result, err := dock.Exec(ctx, []string{"echo", "hello"}, execOptions())
+		if err != nil || result.ExitCode != 0 || string(result.Stdout) != "hello\n" {
+			t.Errorf("echo hello = %d %q, %v", result.ExitCode, result.Stdout, err)
+		}
+	})
+
+	t.Run("ExecFailure", func(t *testing.T) {
+		result, err := dock.Exec(ctx, []string{"sh", "-c", "echo oops >&2; exit 3"}, execOptions())
+		if err != nil || result.ExitCode != 3 || string(result.Stderr) != "oops\n" {
+			t.Errorf("exit 3 = %d %q, %v", result.ExitCode, result.Stderr, err)
+		}
+		if result.Report.ExitCode != 3 || result.Report.ContainerID != dock.ContainerID() {
+			t.Errorf("report %+v", result.Report)
+		}
+	})
+
+	t.Run("ExecTimeout", func(t *testing.T) {
+		start := time.Now()
+		options := execOptions()
+		options.Timeout = time.Second
+		_, err := dock.Exec(ctx, []string{"sleep", "60"}, options)
+		if !errors.Is(err, ibdock.ErrExecTimeout) {
+			t.Errorf("sleep past the timeout = %v, want ErrExecTimeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > 30*time.Second {
+			t.Errorf("timed out exec took %v", elapsed)
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		s, err := dock.GetSnapshot(ctx)
+		if err != nil {
+			t.Fatalf("GetSnapshot: %v", err)
+		}
+		if s.Account == "" || len(s.Positions) == 0 {
+			t.Errorf("snapshot %+v, want an account with positions", s)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		stop, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := dock.Stop(stop); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		stopped = true
+		ids, err := ibdock.Containers(ctx, config.options(session)...)
+		if err != nil || slices.Contains(ids, dock.ContainerID()) {
+			t.Errorf("Containers after Stop = %v, %v, still has %s", ids, err, dock.ContainerID())
+		}
+	})
+
+	t.Run("OrphanGC", func(t *testing.T) {
+		runOrphanGC(t, config, session+"_orphan")
+	})
+}
+
+// execOptions are those of the suite's execs, interleaved for readable
+// failures.
+func execOptions() ibdock.ExecOptions {
+	return ibdock.ExecOptions{Interleaved: true}
+}
+
+// runOrphanGC leaves a container behind the way a process dying without
+// Stop or Kill does, by making it exit on its own, and checks RemoveStopped
+// collects it.
+func runOrphanGC(t *testing.T, config Config, session string) {
+	ctx := context.Background()
+	orphan, err := ibdock.StartNewContext(ctx, "stub", "stub", config.Logger, config.options(session)...)
+	if err != nil {
+		t.Fatalf("StartNew: %v", err)
+	}
+	defer orphan.Kill()
+	// The exec may fail as the container goes away under it.
+	if _, err := orphan.Exec(ctx, []string{"kill", "1"}, execOptions()); err != nil {
+		t.Logf("kill 1: %v", err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		info, err := orphan.Inspect(ctx)
+		if err != nil {
+			t.Fatalf("Inspect: %v", err)
+		}
+		if !info.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("container still %s after SIGTERM", info.State)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	removed, err := ibdock.RemoveStopped(ctx, config.Logger, config.options(session)...)
+	if err != nil || !slices.Contains(removed, orphan.ContainerID()) {
+		t.Errorf("RemoveStopped = %v, %v, want %s among them", removed, err, orphan.ContainerID())
+	}
+	if _, err := orphan.Inspect(ctx); err == nil {
+		t.Errorf("orphan %s is still there after RemoveStopped", orphan.ContainerID())
+	}
+}
@@ -0,0 +1,26 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/imagebuild"
+	"github.com/fsouza/go-dockerclient"
+	"io"
+	"testing"
+)
+
+func TestStub(t *testing.T) {
+	client, err := docker.NewClientFromEnv()
+	if err == nil {
+		err = client.Ping()
+	}
+	if err != nil {
+		t.Skipf("no Docker daemon: %v", err)
+	}
+	ref, err := imagebuild.BuildStub(context.Background(), client, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run(t, Config{Image: ref})
+}
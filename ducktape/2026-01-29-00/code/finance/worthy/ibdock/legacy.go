@@ -0,0 +1,324 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fsouza/go-dockerclient"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func newLegacyRuntime(c DockerConfig, endpoint string) (*legacyRuntime, error) {
+	if c.TLSCert != "" || c.TLSKey != "" || c.TLSCA != "" {
+		client, err := docker.NewTLSClient(endpoint, c.TLSCert, c.TLSKey, c.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("docker TLS: %w", err)
+		}
+		return &legacyRuntime{client: client}, nil
+	}
+	var client *docker.Client
+	var err error
+	if c.Endpoint == "" {
+		client, err = docker.NewClientFromEnv()
+	} else {
+		client, err = docker.NewClient(c.Endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &legacyRuntime{client: client}, nil
+}
+
+func newLegacySSHRuntime(dialer *sshDialer) (*legacyRuntime, error) {
+	client, err := docker.NewClient("http://" + sshHost)
+	if err != nil {
+		return nil, err
+	}
+	client.Dialer = dialer
+	client.HTTPClient.Transport = &http.Transport{
+		DialContext:     dialer.DialContext,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	return &legacyRuntime{client, dialer.endpoint}, nil
+}
+
+// legacyRuntime is the go-dockerclient containerRuntime.
+type legacyRuntime struct {
+	client *docker.Client
+	// sshEndpoint is the ssh:// URL the client is tunneled to, if it is.
+	sshEndpoint string
+}
+
+func (l *legacyRuntime) create(ctx context.Context, spec containerSpec) (string, error) {
+	host := &docker.HostConfig{
+		PublishAllPorts: spec.APIBinding == nil,
+		NetworkMode:     spec.Network,
+		Memory:          spec.Memory,
+		CPUShares:       spec.CPUShares,
+		PidsLimit:       &spec.PidsLimit,
+		RestartPolicy:   docker.RestartPolicy{Name: spec.Restart.Name, MaximumRetryCount: spec.Restart.MaxRetries},
+	}
+	if spec.SettingsVolume != "" {
+		host.Binds = []string{spec.SettingsVolume + ":" + settingsDir}
+	}
+	if b := spec.APIBinding; b != nil {
+		host.PortBindings = map[docker.Port][]docker.PortBinding{apiPort: {{HostIP: b.HostIP, HostPort: b.HostPort}}}
+		if spec.DebugVNC {
+			host.PortBindings[vncPort] = []docker.PortBinding{{HostIP: b.HostIP}}
+		}
+	}
+	config := &docker.Config{
+		Env:    spec.env(),
+		Image:  spec.Image,
+		Labels: spec.Labels,
+	}
+	if spec.DebugVNC {
+		config.ExposedPorts = map[docker.Port]struct{}{vncPort: {}}
+	}
+	if h := spec.HealthCheck; h != nil {
+		config.Healthcheck = &docker.HealthConfig{Test: h.Test, Interval: h.Interval, Timeout: h.Timeout, StartPeriod: h.StartPeriod, Retries: h.Retries}
+	}
+	container, err := l.client.CreateContainer(docker.CreateContainerOptions{
+		Name:       spec.Name,
+		Platform:   spec.Platform,
+		Config:     config,
+		HostConfig: host,
+		Context:    ctx,
+	})
+	if err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+func (l *legacyRuntime) start(ctx context.Context, id string) error {
+	return l.client.StartContainerWithContext(id, nil, ctx)
+}
+
+func (l *legacyRuntime) copyTo(ctx context.Context, id, dir string, archive io.Reader) error {
+	return l.client.UploadToContainer(id, docker.UploadToContainerOptions{
+		InputStream:          archive,
+		Path:                 dir,
+		NoOverwriteDirNonDir: true,
+		Context:              ctx,
+	})
+}
+
+func (l *legacyRuntime) inspect(ctx context.Context, idOrName string) (containerInfo, error) {
+	container, err := l.client.InspectContainerWithOptions(docker.InspectContainerOptions{
+		ID:      idOrName,
+		Context: ctx,
+	})
+	if err != nil {
+		return containerInfo{}, err
+	}
+	info := containerInfo{
+		ID:             container.ID,
+		Name:           container.Name,
+		ImageID:        container.Image,
+		Created:        container.Created,
+		StartedAt:      container.State.StartedAt,
+		Running:        container.State.Running,
+		Paused:         container.State.Paused,
+		Restarting:     container.State.Restarting,
+		Status:         container.State.String(),
+		Health:         container.State.Health.Status,
+		HealthFailures: container.State.Health.FailingStreak,
+	}
+	if probes := container.State.Health.Log; len(probes) > 0 {
+		// The log is oldest first.
+		info.HealthOutput = probes[len(probes)-1].Output
+	}
+	if container.Config != nil {
+		info.Image = container.Config.Image
+		info.Labels = container.Config.Labels
+	}
+	if container.NetworkSettings != nil {
+		info.Ports = make(map[string][]portBinding)
+		for port, bindings := range container.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				info.Ports[string(port)] = append(info.Ports[string(port)], portBinding{HostIP: binding.HostIP, HostPort: binding.HostPort})
+			}
+		}
+	}
+	return info, nil
+}
+
+func (l *legacyRuntime) stop(ctx context.Context, id string, grace time.Duration) error {
+	err := l.client.StopContainerWithContext(id, uint(grace/time.Second), ctx)
+	var notRunning *docker.ContainerNotRunning
+	if errors.As(err, &notRunning) {
+		return nil
+	}
+	return err
+}
+
+func (l *legacyRuntime) remove(ctx context.Context, id string, force bool) error {
+	return l.client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:      id,
+		Force:   force,
+		Context: ctx,
+	})
+}
+
+func (l *legacyRuntime) list(ctx context.Context, all bool, filters map[string][]string) ([]containerSummary, error) {
+	containers, err := l.client.ListContainers(docker.ListContainersOptions{
+		All:     all,
+		Filters: filters,
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var summaries []containerSummary
+	for _, c := range containers {
+		summary := containerSummary{ID: c.ID, Labels: c.Labels, Running: c.State == "running"}
+		if len(c.Names) > 0 {
+			summary.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort != 0 {
+				summary.Ports = append(summary.Ports, portBinding{HostIP: port.IP, HostPort: strconv.FormatInt(port.PublicPort, 10)})
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func (l *legacyRuntime) logs(ctx context.Context, id string, w io.Writer, follow bool) error {
+	return l.client.Logs(docker.LogsOptions{
+		Context:      ctx,
+		Container:    id,
+		OutputStream: w,
+		ErrorStream:  w,
+		Stdout:       true,
+		Stderr:       true,
+		Follow:       follow,
+	})
+}
+
+func (l *legacyRuntime) startExec(ctx context.Context, id string, cmd []string, opts ExecOptions, stdout, stderr io.Writer) (string, func() error, error) {
+	exec, err := l.client.CreateExec(docker.CreateExecOptions{
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+		Env:          opts.Env,
+		WorkingDir:   opts.WorkingDir,
+		Container:    id,
+		Context:      ctx,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	// NOTE: This will not work with 'detach'.
+	waiter, err := l.client.StartExecNonBlocking(exec.ID, docker.StartExecOptions{
+		InputStream:  opts.Stdin,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Context:      ctx,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return exec.ID, waiter.Wait, nil
+}
+
+func (l *legacyRuntime) inspectExec(ctx context.Context, execID string) (bool, int, error) {
+	info, err := l.client.InspectExec(execID)
+	if err != nil {
+		return false, 0, err
+	}
+	return info.Running, info.ExitCode, nil
+}
+
+func (l *legacyRuntime) endpoint() string {
+	if l.sshEndpoint != "" {
+		return l.sshEndpoint
+	}
+	return l.client.Endpoint()
+}
+
+func (l *legacyRuntime) closeIdle() {
+	l.client.HTTPClient.CloseIdleConnections()
+}
+
+func (l *legacyRuntime) daemonPlatform(ctx context.Context) (string, error) {
+	version, err := l.client.VersionWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return version.Get("Os") + "/" + version.Get("Arch"), nil
+}
+
+func (l *legacyRuntime) imagePlatform(ctx context.Context, ref string) (string, bool, error) {
+	image, err := l.client.InspectImage(ref)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	// go-dockerclient does not decode the variant.
+	return image.OS + "/" + image.Architecture, true, nil
+}
+
+func (l *legacyRuntime) info(ctx context.Context) (daemonInfo, error) {
+	version, err := l.client.VersionWithContext(ctx)
+	if err != nil {
+		return daemonInfo{}, err
+	}
+	// go-dockerclient has no Info taking a context.
+	info, err := l.client.Info()
+	if err != nil {
+		return daemonInfo{}, err
+	}
+	t, _ := time.Parse(time.RFC3339Nano, info.SystemTime)
+	return daemonInfo{Version: version.Get("Version"), APIVersion: version.Get("ApiVersion"), RootDir: info.DockerRootDir, Time: t, OperatingSystem: info.OperatingSystem}, nil
+}
+
+func (l *legacyRuntime) imageDigest(ctx context.Context, ref string) (string, bool, error) {
+	image, err := l.client.InspectImage(ref)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if len(image.RepoDigests) > 0 {
+		return image.RepoDigests[0], true, nil
+	}
+	return image.ID, true, nil
+}
+
+func (l *legacyRuntime) pull(ctx context.Context, ref, platform string, progress func(pullMessage)) error {
+	repository, tag := docker.ParseRepositoryTag(ref)
+	reader, writer := io.Pipe()
+	decoded := make(chan error, 1)
+	go func() {
+		err := decodePull(reader, progress)
+		// Keep the pull from blocking on output after an error message.
+		io.Copy(io.Discard, reader)
+		decoded <- err
+	}()
+	err := l.client.PullImage(docker.PullImageOptions{
+		Repository: repository,
+		Tag:        tag,
+		Platform:   platform,
+		Context:    ctx,
+		// Raw, since go-dockerclient otherwise renders the progress for a
+		// terminal.
+		OutputStream:  writer,
+		RawJSONStream: true,
+	}, docker.AuthConfiguration{})
+	writer.CloseWithError(err)
+	if decodeErr := <-decoded; err == nil {
+		err = decodeErr
+	}
+	return err
+}
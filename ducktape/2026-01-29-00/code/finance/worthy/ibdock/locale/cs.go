@@ -0,0 +1,42 @@
+package locale
+
+// czech translates the strings of ibdockd's reports, the export bundle and
+// notify's messages.
+var czech = map[string]string{
+	// Reports and the export bundle.
+	"%s as of %s":                   "%s k %s",
+	"%s/month":                      "%s/měsíc",
+	"Allocation of %s at %s":        "Rozložení účtu %s k %s",
+	"Avg cost":                      "Prům. cena",
+	"Change":                        "Změna",
+	"Contributions":                 "Vklady",
+	"Currency":                      "Měna",
+	"End":                           "Konec",
+	"Growth":                        "Zhodnocení",
+	"Market value":                  "Tržní hodnota",
+	"Month":                         "Měsíc",
+	"Performance of %s in %s":       "Výkonnost účtu %s v %s",
+	"Price":                         "Cena",
+	"Quantity":                      "Množství",
+	"Return":                        "Výnos",
+	"Savings rate":                  "Míra úspor",
+	"Savings rate: %s/month":        "Míra úspor: %s/měsíc",
+	"Scenario":                      "Scénář",
+	"Share":                         "Podíl",
+	"Start":                         "Začátek",
+	"Start (%s)":                    "Začátek (%s)",
+	"Statement of %s at %s":         "Výpis účtu %s k %s",
+	"Symbol":                        "Symbol",
+	"Total":                         "Celkem",
+	"Type":                          "Typ",
+	"Unrealized return on cost: %s": "Nerealizovaný výnos z pořizovací ceny: %s",
+	"Value (%s)":                    "Hodnota (%s)",
+	"Weight":                        "Váha",
+
+	// Notifications.
+	"IB session failed: %s":              "Relace IB selhala: %s",
+	"IB session restarted after: %s":     "Relace IB restartována po chybě: %s",
+	"IB user is logged in elsewhere: %s": "Uživatel IB je přihlášen jinde: %s",
+	"Risk limits exceeded in %s: %s":     "Překročeny limity rizika účtu %s: %s",
+	"Snapshot of %s taken: %d positions": "Snímek účtu %[1]s pořízen, počet pozic: %[2]d",
+}
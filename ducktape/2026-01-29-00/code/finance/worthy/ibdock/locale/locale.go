@@ -0,0 +1,180 @@
+// Package locale renders the human-facing output of reports, exports and
+// notifications in a language: numbers, dates and the strings around them.
+//
+//	l, err := locale.Parse("cs")
+//	p := locale.Printer{Policy: rounding.Default, Locale: l}
+//	p.Money(-1234567.895) // "-1 234 567,90", grouped by no-break spaces
+//	p.T("Total")          // "Celkem"
+//
+// Strings are translated by their English format string, see Czech for the
+// catalog; strings missing from a catalog are printed in English.
+package locale
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale is how one language writes numbers and dates, and its
+// translations.
+type Locale struct {
+	// Name is the language code, e.g. "cs".
+	Name string
+	// decimal separates the fraction, group the thousands (none if empty).
+	decimal, group string
+	// percent follows percentages.
+	percent string
+	// Time layouts of dates, months and times of day.
+	date, month, dateTime string
+	// messages maps English format strings to translated ones.
+	messages map[string]string
+}
+
+// English keeps the format of reports from before they were localized:
+// ISO dates and ungrouped numbers.
+var English = &Locale{
+	Name:     "en",
+	decimal:  ".",
+	percent:  "%",
+	date:     "2006-01-02",
+	month:    "2006-01",
+	dateTime: "2006-01-02 15:04 MST",
+}
+
+var Czech = &Locale{
+	Name:     "cs",
+	decimal:  ",",
+	group:    "\u00a0",
+	percent:  "\u00a0%",
+	date:     "2. 1. 2006",
+	month:    "01/2006",
+	dateTime: "2. 1. 2006 15:04 MST",
+	messages: czech,
+}
+
+var locales = []*Locale{English, Czech}
+
+// Parse returns the locale of a language code or a POSIX locale name like
+// "cs_CZ.UTF-8".
+func Parse(name string) (*Locale, error) {
+	language, _, _ := strings.Cut(strings.ToLower(name), ".")
+	language, _, _ = strings.Cut(language, "_")
+	language, _, _ = strings.Cut(language, "-")
+	var names []string
+	for _, l := range locales {
+		if l.Name == language {
+			return l, nil
+		}
+		names = append(names, l.Name)
+	}
+	return nil, fmt.Errorf("unknown locale %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// Number localizes a number formatted with a '.' decimal point, e.g. by
+// rounding.Policy.Format. Anything else, e.g. "NaN", is returned as it is.
+func (l *Locale) Number(s string) string {
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(digits, ".")
+	if integer == "" || strings.Trim(integer, "0123456789") != "" {
+		return s
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(digit)
+	}
+	if hasFraction {
+		b.WriteString(l.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(l.date)
+}
+
+func (l *Locale) Month(t time.Time) string {
+	return t.Format(l.month)
+}
+
+func (l *Locale) DateTime(t time.Time) string {
+	return t.Format(l.dateTime)
+}
+
+// T translates an English string.
+func (l *Locale) T(english string) string {
+	if translated, ok := l.messages[english]; ok {
+		return translated
+	}
+	return english
+}
+
+// Sprintf formats args with the translation of an English format string.
+func (l *Locale) Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(l.T(format), args...)
+}
+
+// Printer formats values for people: rounded by Policy, written as in
+// Locale, English if nil.
+type Printer struct {
+	Policy rounding.Policy
+	Locale *Locale
+}
+
+func (p Printer) locale() *Locale {
+	if p.Locale != nil {
+		return p.Locale
+	}
+	return English
+}
+
+func (p Printer) Money(x float64) string {
+	return p.locale().Number(p.Policy.Format(x))
+}
+
+// MoneyDecimal is Money for an exact amount, rounded without going through
+// float64.
+func (p Printer) MoneyDecimal(x snapshot.Decimal) string {
+	return p.locale().Number(p.Policy.FormatDecimal(x))
+}
+
+// Number writes x in full, e.g. a quantity, which is not rounded.
+func (p Printer) Number(x float64) string {
+	return p.locale().Number(strconv.FormatFloat(x, 'f', -1, 64))
+}
+
+// Percent writes the fraction x, e.g. 0.25, as a percentage.
+func (p Printer) Percent(x float64) string {
+	return p.locale().Number(p.Policy.Format(100*x)) + p.locale().percent
+}
+
+func (p Printer) Date(t time.Time) string {
+	return p.locale().Date(t)
+}
+
+func (p Printer) Month(t time.Time) string {
+	return p.locale().Month(t)
+}
+
+func (p Printer) DateTime(t time.Time) string {
+	return p.locale().DateTime(t)
+}
+
+func (p Printer) T(english string) string {
+	return p.locale().T(english)
+}
+
+func (p Printer) Sprintf(format string, args ...any) string {
+	return p.locale().Sprintf(format, args...)
+}
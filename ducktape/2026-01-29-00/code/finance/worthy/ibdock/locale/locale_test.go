@@ -0,0 +1,84 @@
+package locale
+
+import (
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrinter(t *testing.T) {
+	when := time.Date(2026, 3, 7, 9, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		locale                      *Locale
+		money, percent, date, month string
+	}{
+		{English, "-1234567.90", "12.35%", "2026-03-07", "2026-03"},
+		{Czech, "-1\u00a0234\u00a0567,90", "12,35\u00a0%", "7. 3. 2026", "03/2026"},
+	} {
+		p := Printer{Policy: rounding.Default, Locale: test.locale}
+		if got := p.Money(-1234567.895); got != test.money {
+			t.Errorf("%s: Money = %q, want %q", test.locale.Name, got, test.money)
+		}
+		if got := p.Percent(0.12345); got != test.percent {
+			t.Errorf("%s: Percent = %q, want %q", test.locale.Name, got, test.percent)
+		}
+		if got := p.Date(when); got != test.date {
+			t.Errorf("%s: Date = %q, want %q", test.locale.Name, got, test.date)
+		}
+		if got := p.Month(when); got != test.month {
+			t.Errorf("%s: Month = %q, want %q", test.locale.Name, got, test.month)
+		}
+	}
+	if got := (Printer{Policy: rounding.Default}).Money(999.5); got != "999.50" {
+		t.Errorf("English by default: Money = %q", got)
+	}
+	cs := Printer{Locale: Czech}
+	if got := (Printer{Policy: rounding.Default, Locale: Czech}).MoneyDecimal(snapshot.NewDecimal(-1234567895, -3)); got != "-1\u00a0234\u00a0567,90" {
+		t.Errorf("MoneyDecimal = %q", got)
+	}
+	if got := cs.Number(1500); got != "1\u00a0500" {
+		t.Errorf("Number(1500) = %q", got)
+	}
+	if got := cs.Sprintf("Snapshot of %s taken: %d positions", "U1", 3); got != "Snímek účtu U1 pořízen, počet pozic: 3" {
+		t.Errorf("Sprintf = %q", got)
+	}
+	if got := cs.T("Not translated"); got != "Not translated" {
+		t.Errorf("T of a missing string = %q", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for name, want := range map[string]*Locale{"en": English, "cs": Czech, "cs_CZ.UTF-8": Czech, "en-GB": English} {
+		if got, err := Parse(name); err != nil || got != want {
+			t.Errorf("Parse(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := Parse("de"); err == nil {
+		t.Errorf("Parse(de) succeeded")
+	}
+}
+
+// verbs matches the formatting verbs of a format string.
+var verbs = regexp.MustCompile(`%(\[\d+\])?[a-z]`)
+
+// TestCatalogs checks that translations take the arguments of the English
+// strings, so no report prints %!s(MISSING).
+func TestCatalogs(t *testing.T) {
+	letters := func(format string) []string {
+		var letters []string
+		for _, verb := range verbs.FindAllString(format, -1) {
+			letters = append(letters, verb[len(verb)-1:])
+		}
+		return letters
+	}
+	for _, l := range locales {
+		for english, translated := range l.messages {
+			if want, got := letters(english), letters(translated); !slices.Equal(got, want) {
+				t.Errorf("%s: %q translates to %q, with verbs %v", l.Name, english, translated, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ibdock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// loginLabel marks containers started WithAccountLock, with a hash of the IB
+// login as its value, so the login itself is not shown to whoever lists
+// containers.
+const loginLabel = "worthy.ibdock.login"
+
+// ErrAccountLocked is returned by StartNew WithAccountLock when another
+// container runs a session of the same IB login.
+var ErrAccountLocked = errors.New("another ibdock session of this IB login is running")
+
+func loginHash(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return hex.EncodeToString(sum[:8])
+}
+
+// lockHolder returns a live container labelled with login other than self,
+// or "" if there is none. Containers that are only created count, since
+// their StartNew may be about to start them.
+func (dock *Dock) lockHolder(ctx context.Context, login, self string) (string, error) {
+	containers, err := dock.client.list(ctx, true, map[string][]string{
+		"label":  {loginLabel + "=" + login},
+		"status": {"created", "running", "restarting", "paused"},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range containers {
+		if c.ID != self {
+			return c.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// checkAccountLock fails with ErrAccountLocked if another container than
+// self holds the lock of the login with the given hash.
+func (dock *Dock) checkAccountLock(ctx context.Context, login, self string) error {
+	holder, err := dock.lockHolder(ctx, login, self)
+	if err != nil {
+		return err
+	}
+	if holder != "" {
+		return fmt.Errorf("%w: container %s", ErrAccountLocked, holder)
+	}
+	return nil
+}
+
+// acquireAccountLock checks the lock again once the container id holding it
+// is created: of two StartNew racing, the one creating its container last
+// sees the other's, and backs off by removing its own. If both create theirs
+// before either checks, both back off.
+func (dock *Dock) acquireAccountLock(ctx context.Context, login, id string) error {
+	err := dock.checkAccountLock(ctx, login, id)
+	if err == nil {
+		return nil
+	}
+	if removeErr := dock.client.remove(ctx, id, true); removeErr != nil {
+		dock.logger.Println("Cannot remove container", id, "after losing the account lock:", removeErr)
+	}
+	return err
+}
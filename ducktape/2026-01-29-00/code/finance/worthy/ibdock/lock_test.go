@@ -0,0 +1,58 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestAccountLock(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	sessions := 0
+	start := func(username string) (*Dock, error) {
+		sessions++
+		return StartNew(username, "secret", logger, WithDockerEndpoint(server.URL()), WithAccountLock(), WithSessionName(fmt.Sprint(sessions)))
+	}
+	first, err := start("jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := start("jdoe"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("second session of the login: %v, want ErrAccountLocked", err)
+	}
+	if len(server.Containers()) != 1 {
+		t.Errorf("%d containers, want the first session's only", len(server.Containers()))
+	}
+	if label := server.Containers()[0].Config.Labels[loginLabel]; label == "" || label == "jdoe" {
+		t.Errorf("login label %q", label)
+	}
+	other, err := start("asmith")
+	if err != nil {
+		t.Fatalf("session of another login: %v", err)
+	}
+	other.Kill()
+	first.Kill()
+	second, err := start("jdoe")
+	if err != nil {
+		t.Fatalf("session after the first was removed: %v", err)
+	}
+	defer second.Kill()
+
+	// A racing StartNew that created its container after the check.
+	id, err := second.client.create(context.Background(), containerSpec{Image: second.imageRef(), Labels: map[string]string{loginLabel: loginHash("jdoe")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.acquireAccountLock(context.Background(), loginHash("jdoe"), id); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("racing session: %v, want ErrAccountLocked", err)
+	}
+	if len(server.Containers()) != 1 {
+		t.Errorf("the racing session's container was not removed")
+	}
+}
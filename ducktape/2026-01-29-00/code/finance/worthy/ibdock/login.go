@@ -0,0 +1,104 @@
+package ibdock
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLoginFailed is wrapped in the *LoginError WaitReady returns when IB
+// rejected the credentials. Retrying them gets the account locked, so it is
+// not Retryable.
+var ErrLoginFailed = errors.New("IB login failed")
+
+// ErrSessionConflict is wrapped in the *LoginError WaitReady returns when the
+// user is logged in elsewhere, which IB allows only one session of, and the
+// Dock's SessionConflict policy is ConflictFail.
+var ErrSessionConflict = errors.New("IB user already has a session")
+
+// SessionConflict says what a gateway does when its user already has a
+// session elsewhere, e.g. TWS open on a desktop, see WithSessionConflict.
+type SessionConflict string
+
+const (
+	// ConflictFail leaves the other session alone, and WaitReady fails with
+	// ErrSessionConflict. It is the default.
+	ConflictFail SessionConflict = "fail"
+	// ConflictTakeOver logs the other session out.
+	ConflictTakeOver SessionConflict = "take_over"
+)
+
+// ibcAction is the ExistingSessionDetectedAction IBC is configured with for
+// c.
+func (c SessionConflict) ibcAction() (string, error) {
+	switch c {
+	case "", ConflictFail:
+		return "secondary", nil
+	case ConflictTakeOver:
+		return "primaryoverride", nil
+	}
+	return "", fmt.Errorf("unknown session conflict policy %q, want %s or %s", c, ConflictFail, ConflictTakeOver)
+}
+
+// LoginError reports a failed login found in the container's logs. Err is
+// ErrLoginFailed or ErrSessionConflict, for errors.Is.
+type LoginError struct {
+	Err error
+	// Line is the log line reporting the failure.
+	Line string
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Line)
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// loginFailures are what IBC and the gateway log when a login fails, in
+// lower case.
+var loginFailures = []struct {
+	pattern string
+	err     error
+}{
+	{"existing session", ErrSessionConflict},
+	{"login failed", ErrLoginFailed},
+	{"login has failed", ErrLoginFailed},
+	{"invalid credentials", ErrLoginFailed},
+	{"unrecognized username or password", ErrLoginFailed},
+}
+
+// scanLoginFailure returns a *LoginError for the first line of logs that
+// reports a failed login, or nil if none does. Under ConflictTakeOver, IBC
+// resolves session conflicts, so they are no failure.
+func scanLoginFailure(logs []byte, conflict SessionConflict) error {
+	scanner := bufio.NewScanner(bytes.NewReader(logs))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.ToLower(scanner.Text())
+		for _, failure := range loginFailures {
+			if failure.err == ErrSessionConflict && conflict == ConflictTakeOver {
+				continue
+			}
+			if strings.Contains(line, failure.pattern) {
+				return &LoginError{Err: failure.err, Line: strings.TrimSpace(scanner.Text())}
+			}
+		}
+	}
+	return nil
+}
+
+// loginFailure looks for a failed login in the container's logs so far.
+// Logs that cannot be read report none.
+func (dock *Dock) loginFailure(ctx context.Context) error {
+	var logs bytes.Buffer
+	if err := dock.Logs(ctx, &logs, false); err != nil {
+		dock.logger.Println("Cannot read the container's logs:", err)
+		return nil
+	}
+	return scanLoginFailure(logs.Bytes(), dock.sessionConflict)
+}
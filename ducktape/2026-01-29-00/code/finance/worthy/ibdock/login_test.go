@@ -0,0 +1,78 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScanLoginFailure(t *testing.T) {
+	for _, test := range []struct {
+		logs     string
+		want     error
+		wantLine string
+	}{
+		{"2026-01-29 10:00:01:123 IBC: Starting Gateway\n", nil, ""},
+		{"IBC: Click button: OK\n2026-01-29 10:00:31:005 IBC: Login has failed\n", ErrLoginFailed, "2026-01-29 10:00:31:005 IBC: Login has failed"},
+		{"ERROR: Unrecognized Username or Password.\n", ErrLoginFailed, "ERROR: Unrecognized Username or Password."},
+		{"IBC: detected dialog entitled: Existing session detected; event=Opened\n", ErrSessionConflict, "IBC: detected dialog entitled: Existing session detected; event=Opened"},
+	} {
+		err := scanLoginFailure([]byte(test.logs), ConflictFail)
+		var loginErr *LoginError
+		switch {
+		case test.want == nil && err != nil:
+			t.Errorf("scanLoginFailure(%q) = %v", test.logs, err)
+		case test.want != nil && (!errors.Is(err, test.want) || !errors.As(err, &loginErr) || loginErr.Line != test.wantLine):
+			t.Errorf("scanLoginFailure(%q) = %v, want %v with line %q", test.logs, err, test.want, test.wantLine)
+		}
+	}
+}
+
+func TestSessionConflict(t *testing.T) {
+	logs := []byte("IBC: detected dialog entitled: Existing session detected; event=Opened\n")
+	if err := scanLoginFailure(logs, ConflictTakeOver); err != nil {
+		t.Errorf("scanLoginFailure under ConflictTakeOver = %v", err)
+	}
+	for _, test := range []struct {
+		policy SessionConflict
+		want   string
+	}{
+		{"", "IBDOCK_EXISTING_SESSION=secondary"},
+		{ConflictTakeOver, "IBDOCK_EXISTING_SESSION=primaryoverride"},
+	} {
+		dock := new(Dock)
+		WithSessionConflict(test.policy)(dock)
+		spec, err := dock.containerSpec("jdoe", "secret")
+		if err != nil || !slices.Contains(spec.Env, test.want) {
+			t.Errorf("%q: containerSpec env %q, %v, want %s", test.policy, spec.Env, err, test.want)
+		}
+	}
+	if _, err := (&Dock{sessionConflict: "ask"}).containerSpec("jdoe", "secret"); err == nil {
+		t.Errorf("containerSpec with an unknown policy succeeded")
+	}
+}
+
+func TestWaitReadyLoginFailed(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	dock, err := StartNew("jdoe", "wrong", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AppendLog(dock.ContainerID(), "IBC: Login has failed\n")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	err = dock.WaitReady(ctx)
+	if !errors.Is(err, ErrLoginFailed) || Retryable(err) {
+		t.Errorf("WaitReady = %v, want a fatal ErrLoginFailed", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("WaitReady took %v to notice the failed login", elapsed)
+	}
+}
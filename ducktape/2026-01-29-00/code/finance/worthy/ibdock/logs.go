@@ -0,0 +1,79 @@
+package ibdock
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+)
+
+// Logs copies the container's output to w, following it until ctx is done if
+// follow is set.
+func (dock *Dock) Logs(ctx context.Context, w io.Writer, follow bool) (err error) {
+	defer dock.redactError(&err)
+	return dock.client.logs(ctx, dock.current().ID, w, follow)
+}
+
+// RemoveStopped removes ibcontroller containers that are no longer running,
+// which pile up when processes die without calling Kill, and returns their
+// IDs. opts select the Docker host as for StartNew; with WithImage, stopped
+// containers of that image are removed too.
+func RemoveStopped(ctx context.Context, logger *log.Logger, opts ...Option) ([]string, error) {
+	client, images, err := gcTarget(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeIdle()
+	containers, err := client.list(ctx, true, map[string][]string{
+		"ancestor": images,
+		"status":   {"created", "exited", "dead"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, c := range containers {
+		if err := client.remove(ctx, c.ID, false); err != nil {
+			return removed, err
+		}
+		logger.Println("Removed container", c.ID)
+		removed = append(removed, c.ID)
+	}
+	return removed, nil
+}
+
+// Containers returns the IDs of ibcontroller containers of either variant,
+// running or not, with opts as for RemoveStopped.
+func Containers(ctx context.Context, opts ...Option) ([]string, error) {
+	client, images, err := gcTarget(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeIdle()
+	containers, err := client.list(ctx, true, map[string][]string{"ancestor": images})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
+
+// gcTarget connects to the Docker host of opts and returns the images whose
+// containers RemoveStopped and Containers look at.
+func gcTarget(opts []Option) (containerRuntime, []string, error) {
+	dock := new(Dock)
+	for _, opt := range opts {
+		opt(dock)
+	}
+	if err := dock.connect(); err != nil {
+		return nil, nil, err
+	}
+	images := []string{image, twsImage}
+	if dock.image != "" && !slices.Contains(images, dock.image) {
+		images = append(images, dock.image)
+	}
+	return dock.client, images, nil
+}
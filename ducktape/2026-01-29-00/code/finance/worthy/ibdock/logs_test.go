@@ -0,0 +1,49 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRemoveStopped(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	ctx := context.Background()
+	var stopped []string
+	for _, opts := range [][]Option{{WithSessionName("default")}, {WithSessionName("stub"), WithImage("example/stub")}} {
+		dock, err := StartNew("jdoe", "secret", logger, append(opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dock.client.stop(ctx, dock.ContainerID(), time.Second); err != nil {
+			t.Fatal(err)
+		}
+		stopped = append(stopped, dock.ContainerID())
+	}
+	running, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("running"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := Containers(ctx, WithDockerEndpoint(server.URL()))
+	if err != nil || len(ids) != 2 || slices.Contains(ids, stopped[1]) {
+		t.Errorf("Containers = %v, %v, want those of the default image", ids, err)
+	}
+	removed, err := RemoveStopped(ctx, logger, WithDockerEndpoint(server.URL()))
+	if err != nil || !slices.Equal(removed, stopped[:1]) {
+		t.Errorf("RemoveStopped = %v, %v, want %v", removed, err, stopped[:1])
+	}
+	removed, err = RemoveStopped(ctx, logger, WithDockerEndpoint(server.URL()), WithImage("example/stub"))
+	if err != nil || !slices.Equal(removed, stopped[1:]) {
+		t.Errorf("RemoveStopped of example/stub = %v, %v, want %v", removed, err, stopped[1:])
+	}
+	if ids, _ := Containers(ctx, WithDockerEndpoint(server.URL()), WithImage("example/stub")); !slices.Equal(ids, []string{running.ContainerID()}) {
+		t.Errorf("Containers after RemoveStopped = %v, want the running one", ids)
+	}
+}
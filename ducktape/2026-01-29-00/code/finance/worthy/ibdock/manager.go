@@ -0,0 +1,297 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"log"
+	"sync"
+	"time"
+)
+
+// SnapshotFunc takes one snapshot, e.g. Dock.GetSnapshot.
+type SnapshotFunc func(ctx context.Context) (*snapshot.Snapshot, error)
+
+type ManagerOptions struct {
+	// Interval between scheduled snapshots. Zero disables scheduling, leaving
+	// only triggered ones.
+	Interval time.Duration
+	// Handler, if set, is called with the outcome of every snapshot run,
+	// scheduled or triggered, before the run's handle is done.
+	Handler func(*snapshot.Snapshot, error)
+	// OnlyOnChange skips calling Handler with snapshots whose positions are
+	// the same as in the last one it was called with, see
+	// snapshot.Changes.PositionsChanged. Failures are always passed on.
+	OnlyOnChange bool
+	// SkipUnchanged skips calling Hooks.OnSnapshot and Handler with
+	// snapshots that hash the same as the last successful one, see
+	// snapshot.Snapshot.Hash, so sinks hooked up do not store identical
+	// copies. Unlike OnlyOnChange, moves in market value count as changes.
+	SkipUnchanged bool
+	// Restart, if set, is called after a failed run to replace the session
+	// the SnapshotFunc reads from, e.g. with a fresh Dock.
+	Restart func(ctx context.Context) error
+	// RestartAfter is how many runs in a row have to fail before Restart is
+	// called; zero means 1.
+	RestartAfter int
+	Hooks        Hooks
+	// Clock schedules the runs and times their handles, default
+	// clock.Real.
+	Clock clock.Clock
+}
+
+// Manager takes snapshots on a schedule and on demand, never running more
+// than one at a time: a trigger that arrives while a run is in flight joins
+// that run instead of starting another.
+type Manager struct {
+	take     SnapshotFunc
+	options  ManagerOptions
+	logger   *log.Logger
+	triggers chan trigger
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu      sync.Mutex
+	current *SnapshotHandle
+	last    *SnapshotHandle
+	// handled is the last snapshot passed to Handler.
+	handled *snapshot.Snapshot
+	// lastHash is the Hash of the last successful snapshot, for
+	// SkipUnchanged. Only the running run touches it.
+	lastHash string
+	// failures counts failed runs since the last success or restart. Runs
+	// never overlap, so only the running one touches it.
+	failures int
+}
+
+type trigger struct {
+	options SnapshotOptions
+	reply   chan *SnapshotHandle
+}
+
+type SnapshotOptions struct {
+	// MaxAge, if positive, lets a successful snapshot that finished at most
+	// MaxAge ago satisfy the trigger instead of a new run.
+	MaxAge time.Duration
+}
+
+// SnapshotHandle tracks one snapshot run. It can be waited on with Wait or
+// polled with Result.
+type SnapshotHandle struct {
+	Started  time.Time
+	done     chan struct{}
+	finished time.Time
+	snapshot *snapshot.Snapshot
+	err      error
+}
+
+// Done is closed when the run finishes.
+func (h *SnapshotHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the run finishes or ctx is done.
+func (h *SnapshotHandle) Wait(ctx context.Context) (*snapshot.Snapshot, error) {
+	select {
+	case <-h.done:
+		return h.snapshot, h.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Result returns the outcome of the run; finished is false while it is still
+// in progress.
+func (h *SnapshotHandle) Result() (s *snapshot.Snapshot, err error, finished bool) {
+	select {
+	case <-h.done:
+		return h.snapshot, h.err, true
+	default:
+		return nil, nil, false
+	}
+}
+
+func NewManager(take SnapshotFunc, options ManagerOptions, logger *log.Logger) *Manager {
+	return &Manager{
+		take:     take,
+		options:  options,
+		logger:   logger,
+		triggers: make(chan trigger),
+		stop:     make(chan struct{}),
+	}
+}
+
+// ErrStopped is returned by TriggerSnapshot after Shutdown.
+var ErrStopped = errors.New("ibdock: manager stopped")
+
+// Run takes scheduled snapshots and serves TriggerSnapshot until ctx is done
+// or Shutdown is called. Runs in flight when ctx is done are cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	var tick <-chan time.Time
+	if m.options.Interval > 0 {
+		ticker := clock.OrReal(m.options.Clock).NewTicker(m.options.Interval)
+		defer ticker.Stop()
+		tick = ticker.C()
+		m.start(ctx)
+	}
+	for {
+		select {
+		case <-tick:
+			m.start(ctx)
+		case t := <-m.triggers:
+			t.reply <- m.trigger(ctx, t.options)
+		case <-m.stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Shutdown stops Run from starting new runs and waits for the one in flight,
+// including its Handler, to finish. It returns ctx.Err() if ctx is done first;
+// cancelling the context Run was given then cancels the run.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.mu.Lock()
+	current := m.current
+	m.mu.Unlock()
+	if current == nil {
+		return nil
+	}
+	select {
+	case <-current.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) stopped() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// TriggerSnapshot requests a snapshot outside the schedule and returns a
+// handle to await it. It needs Run to be running; ctx only bounds the wait to
+// hand the request over, the run itself is owned by the Manager.
+func (m *Manager) TriggerSnapshot(ctx context.Context, options SnapshotOptions) (*SnapshotHandle, error) {
+	t := trigger{options: options, reply: make(chan *SnapshotHandle, 1)}
+	select {
+	case m.triggers <- t:
+	case <-m.stop:
+		return nil, ErrStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return <-t.reply, nil
+}
+
+func (m *Manager) trigger(ctx context.Context, options SnapshotOptions) *SnapshotHandle {
+	m.mu.Lock()
+	last := m.last
+	m.mu.Unlock()
+	if options.MaxAge > 0 && last != nil && last.err == nil && clock.OrReal(m.options.Clock).Now().Sub(last.finished) <= options.MaxAge {
+		return last
+	}
+	return m.start(ctx)
+}
+
+// start begins a snapshot run, or returns the one in flight.
+func (m *Manager) start(ctx context.Context) *SnapshotHandle {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current != nil {
+		return m.current
+	}
+	if m.stopped() {
+		// Shutdown raced with a tick or trigger; hand out a failed run
+		// instead of one Shutdown does not wait for.
+		handle := &SnapshotHandle{Started: clock.OrReal(m.options.Clock).Now(), done: make(chan struct{}), err: ErrStopped}
+		handle.finished = handle.Started
+		close(handle.done)
+		return handle
+	}
+	handle := &SnapshotHandle{Started: clock.OrReal(m.options.Clock).Now(), done: make(chan struct{})}
+	m.current = handle
+	go func() {
+		m.logger.Println("Taking snapshot")
+		s, err := m.take(ctx)
+		if err != nil {
+			m.logger.Println("Snapshot failed:", err)
+			m.options.Hooks.error(err)
+			m.failures++
+			if m.failures >= max(m.options.RestartAfter, 1) {
+				m.restart(ctx, err)
+			}
+		} else {
+			m.failures = 0
+		}
+		if m.unchanged(s, err) {
+			m.logger.Println("Snapshot unchanged, not calling hooks")
+		} else {
+			if err == nil {
+				m.options.Hooks.snapshot(s)
+			}
+			// The run only counts as finished once the handler is done
+			// with it, so handlers never overlap.
+			m.handle(s, err)
+		}
+		m.mu.Lock()
+		handle.snapshot, handle.err, handle.finished = s, err, clock.OrReal(m.options.Clock).Now()
+		m.current = nil
+		m.last = handle
+		m.mu.Unlock()
+		close(handle.done)
+	}()
+	return handle
+}
+
+// unchanged reports whether SkipUnchanged applies to the outcome of a run.
+// Failures are always passed on.
+func (m *Manager) unchanged(s *snapshot.Snapshot, err error) bool {
+	if !m.options.SkipUnchanged || err != nil {
+		return false
+	}
+	hash := s.Hash()
+	unchanged := hash == m.lastHash
+	m.lastHash = hash
+	return unchanged
+}
+
+func (m *Manager) handle(s *snapshot.Snapshot, err error) {
+	if m.options.Handler == nil {
+		return
+	}
+	if err == nil && m.options.OnlyOnChange {
+		m.mu.Lock()
+		previous := m.handled
+		m.handled = s
+		m.mu.Unlock()
+		if previous != nil && !snapshot.Diff(previous, s).PositionsChanged() {
+			m.logger.Println("Positions unchanged, not calling handler")
+			return
+		}
+	}
+	m.options.Handler(s, err)
+}
+
+func (m *Manager) restart(ctx context.Context, reason error) {
+	// A session started during shutdown would outlive the daemon.
+	if m.options.Restart == nil || ctx.Err() != nil || m.stopped() {
+		return
+	}
+	m.logger.Println("Restarting session")
+	m.failures = 0
+	if err := m.options.Restart(ctx); err != nil {
+		m.logger.Println("Restart failed:", err)
+		m.options.Hooks.error(err)
+		return
+	}
+	m.options.Hooks.restart(reason)
+}
@@ -0,0 +1,200 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTriggerSnapshot(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	take := func(ctx context.Context) (*snapshot.Snapshot, error) {
+		runs.Add(1)
+		<-release
+		return &snapshot.Snapshot{Account: "U1111111"}, nil
+	}
+	m := NewManager(take, ManagerOptions{}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	first, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("trigger during a run started another run")
+	}
+	if _, _, finished := first.Result(); finished {
+		t.Errorf("Result reports finished before the run returned")
+	}
+	close(release)
+	s, err := first.Wait(ctx)
+	if err != nil || s.Account != "U1111111" {
+		t.Errorf("Wait = %v, %v", s, err)
+	}
+
+	reused, err := m.TriggerSnapshot(ctx, SnapshotOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != first {
+		t.Errorf("MaxAge did not reuse the finished snapshot")
+	}
+	fresh, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.Wait(ctx)
+	if fresh == first || runs.Load() != 2 {
+		t.Errorf("got %d runs, want 2", runs.Load())
+	}
+}
+
+func TestOnlyOnChange(t *testing.T) {
+	quantities := []float64{10, 10, 12}
+	var handled []float64
+	take := func(ctx context.Context) (*snapshot.Snapshot, error) {
+		q := quantities[0]
+		quantities = quantities[1:]
+		return &snapshot.Snapshot{Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(q)}}}, nil
+	}
+	m := NewManager(take, ManagerOptions{
+		Handler:      func(s *snapshot.Snapshot, err error) { handled = append(handled, s.Positions[0].Quantity.Float64()) },
+		OnlyOnChange: true,
+	}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	for range 3 {
+		handle, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handle.Wait(ctx)
+	}
+	if len(handled) != 2 || handled[0] != 10 || handled[1] != 12 {
+		t.Errorf("handler called with %v, want [10 12]", handled)
+	}
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	values := []float64{1000, 1000, 1010}
+	var hooked, handled []float64
+	take := func(ctx context.Context) (*snapshot.Snapshot, error) {
+		v := values[0]
+		values = values[1:]
+		return &snapshot.Snapshot{Timestamp: time.Now(), Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.NewDecimal(10, 0), MarketValue: snapshot.DecimalFromFloat(v)}}}, nil
+	}
+	m := NewManager(take, ManagerOptions{
+		Handler:       func(s *snapshot.Snapshot, err error) { handled = append(handled, s.Positions[0].MarketValue.Float64()) },
+		Hooks:         Hooks{OnSnapshot: func(s *snapshot.Snapshot) { hooked = append(hooked, s.Positions[0].MarketValue.Float64()) }},
+		SkipUnchanged: true,
+	}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	for range 3 {
+		handle, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handle.Wait(ctx)
+	}
+	for _, got := range [][]float64{hooked, handled} {
+		if len(got) != 2 || got[0] != 1000 || got[1] != 1010 {
+			t.Errorf("called with %v, want [1000 1010]", got)
+		}
+	}
+}
+
+func TestRestartHooks(t *testing.T) {
+	failing := true
+	take := func(ctx context.Context) (*snapshot.Snapshot, error) {
+		if failing {
+			return nil, errors.New("not logged in")
+		}
+		return &snapshot.Snapshot{Account: "U1111111"}, nil
+	}
+	var events []string
+	m := NewManager(take, ManagerOptions{
+		Restart: func(ctx context.Context) error {
+			failing = false
+			return nil
+		},
+		Hooks: Hooks{
+			OnSnapshot: func(s *snapshot.Snapshot) { events = append(events, "snapshot "+s.Account) },
+			OnError:    func(err error) { events = append(events, "error "+err.Error()) },
+			OnRestart:  func(reason error) { events = append(events, "restart "+reason.Error()) },
+		},
+	}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	for range 2 {
+		handle, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handle.Wait(ctx)
+	}
+	want := []string{"error not logged in", "restart not logged in", "snapshot U1111111"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events %q, want %q", events, want)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	take := func(ctx context.Context) (*snapshot.Snapshot, error) {
+		close(started)
+		select {
+		case <-release:
+			return &snapshot.Snapshot{Account: "U1111111"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	m := NewManager(take, ManagerOptions{}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan error)
+	go func() { ran <- m.Run(ctx) }()
+
+	handle, err := m.TriggerSnapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	timeout, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelTimeout()
+	if err := m.Shutdown(timeout); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown with a run in flight = %v, want deadline exceeded", err)
+	}
+	if err := <-ran; err != nil {
+		t.Errorf("Run = %v after Shutdown", err)
+	}
+	if _, err := m.TriggerSnapshot(ctx, SnapshotOptions{}); err != ErrStopped {
+		t.Errorf("TriggerSnapshot after Shutdown = %v, want ErrStopped", err)
+	}
+
+	close(release)
+	if err := m.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if s, err, finished := handle.Result(); !finished || err != nil || s.Account != "U1111111" {
+		t.Errorf("in-flight run was not drained: %v, %v, %v", s, err, finished)
+	}
+}
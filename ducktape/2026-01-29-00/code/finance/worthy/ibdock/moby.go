@@ -0,0 +1,337 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"io"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mobyRuntime is the containerRuntime on the official Docker SDK.
+type mobyRuntime struct {
+	client *client.Client
+	// sshEndpoint is the ssh:// URL the client is tunneled to, if it is.
+	sshEndpoint string
+}
+
+func newMobyRuntime(c DockerConfig) (*mobyRuntime, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if c.Endpoint != "" {
+		opts = append(opts, client.WithHost(c.Endpoint))
+	}
+	prefix := "docker"
+	if c.TLSCert != "" || c.TLSKey != "" || c.TLSCA != "" {
+		opts = append(opts, client.WithTLSClientConfig(c.TLSCA, c.TLSCert, c.TLSKey))
+		prefix = "docker TLS"
+	}
+	cli, err := client.New(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, err)
+	}
+	return &mobyRuntime{client: cli}, nil
+}
+
+func newMobySSHRuntime(dialer *sshDialer) (*mobyRuntime, error) {
+	// The dialer must come after the host, which resets the transport.
+	cli, err := client.New(
+		client.WithHost("tcp://"+sshHost),
+		client.WithDialContext(dialer.DialContext),
+		client.WithAPIVersionNegotiation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("docker over SSH: %w", err)
+	}
+	return &mobyRuntime{cli, dialer.endpoint}, nil
+}
+
+func (m *mobyRuntime) create(ctx context.Context, spec containerSpec) (string, error) {
+	host := &container.HostConfig{
+		PublishAllPorts: spec.APIBinding == nil,
+		NetworkMode:     container.NetworkMode(spec.Network),
+		Resources: container.Resources{
+			Memory:    spec.Memory,
+			CPUShares: spec.CPUShares,
+			PidsLimit: &spec.PidsLimit,
+		},
+		RestartPolicy: container.RestartPolicy{
+			Name:              container.RestartPolicyMode(spec.Restart.Name),
+			MaximumRetryCount: spec.Restart.MaxRetries,
+		},
+	}
+	if spec.SettingsVolume != "" {
+		host.Binds = []string{spec.SettingsVolume + ":" + settingsDir}
+	}
+	if b := spec.APIBinding; b != nil {
+		binding := network.PortBinding{HostPort: b.HostPort}
+		if b.HostIP != "" {
+			ip, err := netip.ParseAddr(b.HostIP)
+			if err != nil {
+				return "", err
+			}
+			binding.HostIP = ip
+		}
+		host.PortBindings = network.PortMap{network.MustParsePort(apiPort): {binding}}
+		if spec.DebugVNC {
+			host.PortBindings[network.MustParsePort(vncPort)] = []network.PortBinding{{HostIP: binding.HostIP}}
+		}
+	}
+	config := &container.Config{
+		Env:    spec.env(),
+		Image:  spec.Image,
+		Labels: spec.Labels,
+	}
+	if spec.DebugVNC {
+		config.ExposedPorts = network.PortSet{network.MustParsePort(vncPort): {}}
+	}
+	if h := spec.HealthCheck; h != nil {
+		config.Healthcheck = &container.HealthConfig{Test: h.Test, Interval: h.Interval, Timeout: h.Timeout, StartPeriod: h.StartPeriod, Retries: h.Retries}
+	}
+	var platform *ocispec.Platform
+	if spec.Platform != "" {
+		os, arch, variant := splitPlatform(spec.Platform)
+		platform = &ocispec.Platform{OS: os, Architecture: arch, Variant: variant}
+	}
+	created, err := m.client.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Name:       spec.Name,
+		Platform:   platform,
+		Config:     config,
+		HostConfig: host,
+	})
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (m *mobyRuntime) start(ctx context.Context, id string) error {
+	_, err := m.client.ContainerStart(ctx, id, client.ContainerStartOptions{})
+	return err
+}
+
+func (m *mobyRuntime) copyTo(ctx context.Context, id, dir string, archive io.Reader) error {
+	_, err := m.client.CopyToContainer(ctx, id, client.CopyToContainerOptions{DestinationPath: dir, Content: archive})
+	return err
+}
+
+func (m *mobyRuntime) inspect(ctx context.Context, idOrName string) (containerInfo, error) {
+	inspected, err := m.client.ContainerInspect(ctx, idOrName, client.ContainerInspectOptions{})
+	if err != nil {
+		return containerInfo{}, err
+	}
+	c := inspected.Container
+	info := containerInfo{ID: c.ID, Name: c.Name, ImageID: c.Image}
+	info.Created, _ = time.Parse(time.RFC3339Nano, c.Created)
+	if c.Config != nil {
+		info.Image = c.Config.Image
+		info.Labels = c.Config.Labels
+	}
+	if state := c.State; state != nil {
+		info.Running, info.Paused, info.Restarting = state.Running, state.Paused, state.Restarting
+		// A container that never started reports 0001-01-01T00:00:00Z,
+		// which parses as the zero time.
+		info.StartedAt, _ = time.Parse(time.RFC3339Nano, state.StartedAt)
+		info.Status = string(state.Status)
+		if state.Status == container.StateExited || state.Status == container.StateDead {
+			info.Status = fmt.Sprintf("%s (%d)", state.Status, state.ExitCode)
+		}
+		if health := state.Health; health != nil {
+			info.Health, info.HealthFailures = string(health.Status), health.FailingStreak
+			if n := len(health.Log); n > 0 && health.Log[n-1] != nil {
+				info.HealthOutput = health.Log[n-1].Output
+			}
+		}
+	}
+	if c.NetworkSettings != nil {
+		info.Ports = make(map[string][]portBinding)
+		for port, bindings := range c.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				hostIP := ""
+				if binding.HostIP.IsValid() {
+					hostIP = binding.HostIP.String()
+				}
+				info.Ports[port.String()] = append(info.Ports[port.String()], portBinding{HostIP: hostIP, HostPort: binding.HostPort})
+			}
+		}
+	}
+	return info, nil
+}
+
+func (m *mobyRuntime) stop(ctx context.Context, id string, grace time.Duration) error {
+	timeout := int(grace / time.Second)
+	// The daemon answers 304 for containers that are not running, which
+	// the SDK does not treat as an error.
+	_, err := m.client.ContainerStop(ctx, id, client.ContainerStopOptions{Timeout: &timeout})
+	return err
+}
+
+func (m *mobyRuntime) remove(ctx context.Context, id string, force bool) error {
+	_, err := m.client.ContainerRemove(ctx, id, client.ContainerRemoveOptions{Force: force})
+	return err
+}
+
+func (m *mobyRuntime) list(ctx context.Context, all bool, filters map[string][]string) ([]containerSummary, error) {
+	f := make(client.Filters)
+	for key, values := range filters {
+		f.Add(key, values...)
+	}
+	listed, err := m.client.ContainerList(ctx, client.ContainerListOptions{All: all, Filters: f})
+	if err != nil {
+		return nil, err
+	}
+	var summaries []containerSummary
+	for _, c := range listed.Items {
+		summary := containerSummary{ID: c.ID, Labels: c.Labels, Running: c.State == container.StateRunning}
+		if len(c.Names) > 0 {
+			summary.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort != 0 {
+				summary.Ports = append(summary.Ports, portBinding{HostIP: port.IP.String(), HostPort: strconv.Itoa(int(port.PublicPort))})
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func (m *mobyRuntime) logs(ctx context.Context, id string, w io.Writer, follow bool) error {
+	logs, err := m.client.ContainerLogs(ctx, id, client.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	_, err = stdcopy.StdCopy(w, w, logs)
+	return err
+}
+
+func (m *mobyRuntime) startExec(ctx context.Context, id string, cmd []string, opts ExecOptions, stdout, stderr io.Writer) (string, func() error, error) {
+	created, err := m.client.ExecCreate(ctx, id, client.ExecCreateOptions{
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+		Env:          opts.Env,
+		WorkingDir:   opts.WorkingDir,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	attached, err := m.client.ExecAttach(ctx, created.ID, client.ExecAttachOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	// The hijacked connection does not end with ctx by itself.
+	stopClosing := context.AfterFunc(ctx, attached.Close)
+	if opts.Stdin != nil {
+		go func() {
+			io.Copy(attached.Conn, opts.Stdin)
+			attached.CloseWrite()
+		}()
+	}
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, attached.Reader)
+		stopClosing()
+		attached.Close()
+		copied <- err
+	}()
+	return created.ID, func() error { return <-copied }, nil
+}
+
+func (m *mobyRuntime) inspectExec(ctx context.Context, execID string) (bool, int, error) {
+	info, err := m.client.ExecInspect(ctx, execID, client.ExecInspectOptions{})
+	if err != nil {
+		return false, 0, err
+	}
+	return info.Running, info.ExitCode, nil
+}
+
+func (m *mobyRuntime) endpoint() string {
+	if m.sshEndpoint != "" {
+		return m.sshEndpoint
+	}
+	return m.client.DaemonHost()
+}
+
+func (m *mobyRuntime) closeIdle() {
+	m.client.Close()
+}
+
+func (m *mobyRuntime) daemonPlatform(ctx context.Context) (string, error) {
+	version, err := m.client.ServerVersion(ctx, client.ServerVersionOptions{})
+	if err != nil {
+		return "", err
+	}
+	return version.Os + "/" + version.Arch, nil
+}
+
+func (m *mobyRuntime) imagePlatform(ctx context.Context, ref string) (string, bool, error) {
+	image, err := m.client.ImageInspect(ctx, ref)
+	if cerrdefs.IsNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return path.Join(image.Os, image.Architecture, image.Variant), true, nil
+}
+
+func (m *mobyRuntime) info(ctx context.Context) (daemonInfo, error) {
+	version, err := m.client.ServerVersion(ctx, client.ServerVersionOptions{})
+	if err != nil {
+		return daemonInfo{}, err
+	}
+	info, err := m.client.Info(ctx, client.InfoOptions{})
+	if err != nil {
+		return daemonInfo{}, err
+	}
+	t, _ := time.Parse(time.RFC3339Nano, info.Info.SystemTime)
+	return daemonInfo{Version: version.Version, APIVersion: version.APIVersion, RootDir: info.Info.DockerRootDir, Time: t, OperatingSystem: info.Info.OperatingSystem}, nil
+}
+
+func (m *mobyRuntime) imageDigest(ctx context.Context, ref string) (string, bool, error) {
+	image, err := m.client.ImageInspect(ctx, ref)
+	if cerrdefs.IsNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if len(image.RepoDigests) > 0 {
+		return image.RepoDigests[0], true, nil
+	}
+	return image.ID, true, nil
+}
+
+func (m *mobyRuntime) pull(ctx context.Context, ref, platform string, progress func(pullMessage)) error {
+	os, arch, variant := splitPlatform(platform)
+	pulled, err := m.client.ImagePull(ctx, ref, client.ImagePullOptions{
+		Platforms: []ocispec.Platform{{OS: os, Architecture: arch, Variant: variant}},
+	})
+	if err != nil {
+		return err
+	}
+	defer pulled.Close()
+	return decodePull(pulled, progress)
+}
+
+// splitPlatform splits a platform like "linux/arm/v7" into its parts.
+func splitPlatform(platform string) (os, arch, variant string) {
+	parts := strings.SplitN(platform, "/", 3)
+	parts = append(parts, "", "")
+	return parts[0], parts[1], parts[2]
+}
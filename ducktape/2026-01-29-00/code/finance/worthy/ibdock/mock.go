@@ -0,0 +1,155 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"os"
+	"slices"
+	"sync"
+)
+
+// MockDock is a Session that returns canned snapshots instead of reading them
+// from a gateway, for developing and demoing without a live IB account.
+type MockDock struct {
+	// Summary is returned by GetAccountSummary. If its Account is empty,
+	// that of the first snapshot is used.
+	Summary AccountSummary
+	// FXRates holds the rates GetFXRates returns, in whatever base it is
+	// asked for; currencies missing from it other than the base fail.
+	FXRates snapshot.FXRates
+	// ExecHandler, if set, runs the commands passed to Exec.
+	ExecHandler func(cmd []string, opts ExecOptions) (ExecResult, error)
+	// Clock stamps snapshots without a Timestamp, default clock.Real.
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	snapshots []*snapshot.Snapshot
+	next      int
+	stopped   bool
+}
+
+// NewMockDock returns a MockDock handing out snapshots in turn, repeating the
+// last one once it runs out.
+func NewMockDock(snapshots ...*snapshot.Snapshot) *MockDock {
+	return &MockDock{snapshots: snapshots}
+}
+
+// LoadMockDock is NewMockDock with the snapshots read from files in format.
+func LoadMockDock(format string, paths ...string) (*MockDock, error) {
+	var snapshots []*snapshot.Snapshot
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s := new(snapshot.Snapshot)
+		if err := snapshot.Unmarshal(format, data, s); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return NewMockDock(snapshots...), nil
+}
+
+var errMockStopped = errors.New("ibdock: mock session stopped")
+
+// WaitReady returns right away: a MockDock is ready until stopped.
+func (m *MockDock) WaitReady(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return errMockStopped
+	}
+	return nil
+}
+
+// GetSnapshot returns a copy of the next snapshot. Snapshots without a
+// Timestamp get the current time, so they look fresh to callers checking age.
+func (m *MockDock) GetSnapshot(ctx context.Context) (*snapshot.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, errMockStopped
+	}
+	if len(m.snapshots) == 0 {
+		return nil, errors.New("ibdock: MockDock has no snapshots")
+	}
+	s := *m.snapshots[m.next]
+	if m.next < len(m.snapshots)-1 {
+		m.next++
+	}
+	s.Positions = slices.Clone(s.Positions)
+	s.PendingTransfers = slices.Clone(s.PendingTransfers)
+	if s.Timestamp.IsZero() {
+		s.Timestamp = clock.OrReal(m.Clock).Now()
+	}
+	return &s, nil
+}
+
+func (m *MockDock) GetAccountSummary(ctx context.Context) (AccountSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return AccountSummary{}, errMockStopped
+	}
+	summary := m.Summary
+	if summary.Account == "" && len(m.snapshots) > 0 {
+		summary.Account = m.snapshots[0].Account
+	}
+	return summary, nil
+}
+
+func (m *MockDock) GetFXRates(ctx context.Context, base string, currencies []string) (snapshot.FXRates, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, errMockStopped
+	}
+	rates := snapshot.FXRates{base: 1}
+	for _, currency := range currencies {
+		if currency == base {
+			continue
+		}
+		rate, ok := m.FXRates[currency]
+		if !ok {
+			return nil, fmt.Errorf("ibdock: MockDock has no FX rate for %s", currency)
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+// Exec runs cmd with ExecHandler, or fails if there is none.
+func (m *MockDock) Exec(ctx context.Context, cmd []string, opts ExecOptions) (ExecResult, error) {
+	m.mu.Lock()
+	stopped, handler := m.stopped, m.ExecHandler
+	m.mu.Unlock()
+	if stopped {
+		return ExecResult{}, errMockStopped
+	}
+	if handler == nil {
+		return ExecResult{}, fmt.Errorf("ibdock: MockDock cannot run %q", cmd)
+	}
+	return handler(cmd, opts)
+}
+
+func (m *MockDock) Stop(ctx context.Context) error {
+	m.Kill()
+	return nil
+}
+
+func (m *MockDock) Kill() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+}
+
+// Stopped reports whether Stop or Kill was called.
+func (m *MockDock) Stopped() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopped
+}
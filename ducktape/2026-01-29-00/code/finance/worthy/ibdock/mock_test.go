@@ -0,0 +1,63 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockDock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "day2.json")
+	data, err := snapshot.Marshal("json", &snapshot.Snapshot{Account: "U1111111", Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(12)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMockDock("json", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := NewMockDock(&snapshot.Snapshot{Account: "U1111111", Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(10)}}}, loaded.snapshots[0])
+
+	var quantities []float64
+	for range 3 {
+		s, err := mock.GetSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Timestamp.IsZero() {
+			t.Errorf("snapshot without Timestamp was not stamped")
+		}
+		quantities = append(quantities, s.Positions[0].Quantity.Float64())
+		s.Positions[0].Quantity = snapshot.Decimal{}
+	}
+	if quantities[0] != 10 || quantities[1] != 12 || quantities[2] != 12 {
+		t.Errorf("quantities = %v, want 10 12 12", quantities)
+	}
+	if summary, err := mock.GetAccountSummary(ctx); err != nil || summary.Account != "U1111111" {
+		t.Errorf("GetAccountSummary = %+v, %v", summary, err)
+	}
+
+	if err := mock.WaitReady(ctx); err != nil {
+		t.Errorf("WaitReady = %v", err)
+	}
+	if _, err := mock.Exec(ctx, []string{"true"}, ExecOptions{}); err == nil {
+		t.Errorf("Exec without ExecHandler succeeded")
+	}
+	mock.ExecHandler = func(cmd []string, opts ExecOptions) (ExecResult, error) {
+		return ExecResult{Stdout: []byte(cmd[0])}, nil
+	}
+	if result, err := mock.Exec(ctx, []string{"echo"}, ExecOptions{}); err != nil || string(result.Stdout) != "echo" {
+		t.Errorf("Exec = %+v, %v", result, err)
+	}
+
+	mock.Kill()
+	if _, err := mock.GetSnapshot(ctx); err == nil || !mock.Stopped() {
+		t.Errorf("GetSnapshot after Kill = %v", err)
+	}
+}
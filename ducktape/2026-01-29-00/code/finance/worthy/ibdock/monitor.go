@@ -0,0 +1,128 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/fsouza/go-dockerclient"
+	"net"
+	"strings"
+	"time"
+)
+
+// Health is the state of a session as seen by Monitor.
+type Health int
+
+const (
+	// HealthUnknown is the state before the first check.
+	HealthUnknown Health = iota
+	// Healthy means the container runs and its TWS API port accepts
+	// connections.
+	Healthy
+	// Unhealthy means the container runs but the gateway does not accept
+	// connections, e.g. while logging in or when hung, that Docker marked it
+	// unhealthy, see WithHealthCheck, or that Docker could not be asked.
+	Unhealthy
+	// Dead means the container stopped or was removed.
+	Dead
+)
+
+var healthNames = map[Health]string{
+	HealthUnknown: "unknown",
+	Healthy:       "healthy",
+	Unhealthy:     "unhealthy",
+	Dead:          "dead",
+}
+
+func (h Health) String() string {
+	if name, ok := healthNames[h]; ok {
+		return name
+	}
+	return fmt.Sprintf("Health(%d)", int(h))
+}
+
+// HealthChange is a transition reported by Monitor.
+type HealthChange struct {
+	From, To Health
+	// Reason says why the session is not healthy; nil if it is.
+	Reason error
+	Time   time.Time
+}
+
+const defaultMonitorInterval = 15 * time.Second
+
+// Monitor checks the container and its TWS API port every interval, see
+// WithMonitorInterval, and calls onChange on every change of Health,
+// starting with the first check. It returns nil once it reported the
+// container dead, or ctx.Err().
+//
+// Checking only dials the port, so it does not take a TWS API client ID.
+func (dock *Dock) Monitor(ctx context.Context, onChange func(HealthChange)) (err error) {
+	defer dock.redactError(&err)
+	interval := dock.monitorInterval
+	if interval <= 0 {
+		interval = defaultMonitorInterval
+	}
+	clk := clock.OrReal(dock.clock)
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+	last := HealthUnknown
+	for {
+		health, reason := dock.checkHealth(ctx, min(interval, 5*time.Second))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if health != last {
+			if health == Unhealthy || health == Dead {
+				dock.emit(Event{Kind: EventUnhealthy, Err: reason})
+			}
+			onChange(HealthChange{From: last, To: health, Reason: dock.redactor.Error(reason), Time: clk.Now()})
+			last = health
+		}
+		if health == Dead {
+			return nil
+		}
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (dock *Dock) checkHealth(ctx context.Context, timeout time.Duration) (Health, error) {
+	// Monitor runs alongside the other methods, so it inspects the
+	// container itself instead of refreshing dock.container.
+	id := dock.current().ID
+	container, err := dock.client.inspect(ctx, id)
+	if isNoSuchContainer(err) {
+		return Dead, fmt.Errorf("container %s was removed", id)
+	}
+	if err != nil {
+		return Unhealthy, err
+	}
+	if !container.Running {
+		return Dead, fmt.Errorf("container %s stopped: %s", container.ID, container.Status)
+	}
+	if container.Health == "unhealthy" {
+		return Unhealthy, fmt.Errorf("container %s failed %d health checks in a row: %s", container.ID, container.HealthFailures, strings.TrimSpace(container.HealthOutput))
+	}
+	binding, ok := findBinding(container, apiPort)
+	if !ok {
+		return Unhealthy, fmt.Errorf("port %s of container %s is not published", apiPort, container.ID)
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(dock.publishedHost(binding.HostIP), binding.HostPort))
+	if err != nil {
+		return Unhealthy, err
+	}
+	conn.Close()
+	return Healthy, nil
+}
+
+func isNoSuchContainer(err error) bool {
+	var noSuch *docker.NoSuchContainer
+	return errors.As(err, &noSuch) || cerrdefs.IsNotFound(err)
+}
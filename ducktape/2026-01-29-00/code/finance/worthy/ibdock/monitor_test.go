@@ -0,0 +1,69 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		gateway, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		server.PublishAPI(strconv.Itoa(gateway.Addr().(*net.TCPAddr).Port))
+		// Removing the container first, while the gateway still listens.
+		for _, kill := range []bool{true, false} {
+			opts := append(backend.opts, WithDockerEndpoint(server.URL()), WithSessionName(strconv.FormatBool(kill)), WithMonitorInterval(10*time.Millisecond))
+			dock, err := StartNew("jdoe", "secret", logger, opts...)
+			if err != nil {
+				t.Fatalf("%s: %v", backend.name, err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			changes := make(chan HealthChange, 10)
+			done := make(chan error, 1)
+			go func() {
+				done <- dock.Monitor(ctx, func(change HealthChange) { changes <- change })
+			}()
+			expect := func(want Health) {
+				t.Helper()
+				select {
+				case change := <-changes:
+					if change.To != want {
+						t.Errorf("%s: change %v -> %v (%v), want %v", backend.name, change.From, change.To, change.Reason, want)
+					}
+				case <-ctx.Done():
+					t.Fatalf("%s: no change to %v", backend.name, want)
+				}
+			}
+			expect(Healthy)
+			if kill {
+				dock.Kill()
+			} else {
+				gateway.Close()
+				expect(Unhealthy)
+				server.Exit(dock.ContainerID(), 1)
+			}
+			expect(Dead)
+			if err := <-done; err != nil {
+				t.Errorf("%s: Monitor = %v", backend.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package ibdock
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// apiBinding is the host binding of the container's TWS API port set with
+// WithAPIPort and WithBindAddress, or nil to let Docker publish all ports on
+// random host ports.
+func (dock *Dock) apiBinding() (*portBinding, error) {
+	if dock.apiHostPort == 0 && dock.bindAddress == "" {
+		return nil, nil
+	}
+	if err := checkBinding(dock.apiHostPort, dock.bindAddress); err != nil {
+		return nil, err
+	}
+	binding := &portBinding{HostIP: dock.bindAddress}
+	if dock.apiHostPort != 0 {
+		binding.HostPort = strconv.Itoa(dock.apiHostPort)
+	}
+	return binding, nil
+}
+
+// checkBinding validates a host port, zero for any, and a bind address,
+// empty for all interfaces.
+func checkBinding(port int, address string) error {
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("API port %d out of range", port)
+	}
+	if address != "" && net.ParseIP(address) == nil {
+		return fmt.Errorf("bind address %q is not an IP address", address)
+	}
+	return nil
+}
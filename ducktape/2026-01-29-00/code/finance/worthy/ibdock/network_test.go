@@ -0,0 +1,37 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestNetworkOptions(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()), WithNetwork("brokers"), WithAPIPort(7497), WithBindAddress("127.0.0.1"))
+		dock, err := StartNew("jdoe", "secret", logger, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		host := server.Containers()[0].HostConfig
+		if host.PublishAllPorts || host.NetworkMode != "brokers" {
+			t.Errorf("%s: publish all %v, network %q", backend.name, host.PublishAllPorts, host.NetworkMode)
+		}
+		if endpoint, err := dock.APIEndpoint(); err != nil || endpoint != "127.0.0.1:7497" {
+			t.Errorf("%s: APIEndpoint = %q, %v", backend.name, endpoint, err)
+		}
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithSessionName("bad"), WithBindAddress("localhost"))...); err == nil {
+			t.Errorf("%s: StartNew with a host name as bind address succeeded", backend.name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package notify sends the events of an unattended ibdock session to HTTP
+// webhooks and Slack:
+//
+//	slack := &notify.Slack{WebhookURL: "https://hooks.slack.com/services/..."}
+//	manager := ibdock.NewManager(dock.GetSnapshot, ibdock.ManagerOptions{
+//	  Interval: time.Hour,
+//	  Hooks:    notify.Hooks(logger, notify.Failures(slack), notify.RiskAlerts(assess, slack)),
+//	}, logger)
+//
+// where assess is e.g.
+//
+//	func(ctx context.Context, s *snapshot.Snapshot) ([]snapshot.RiskFlag, error) {
+//	  return dock.AssessRisk(ctx, s, snapshot.RiskLimits{MaxWeight: 0.2})
+//	}
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	EventSnapshot = "snapshot"
+	EventError    = "error"
+	EventRestart  = "restart"
+	EventRisk     = "risk"
+	// EventSessionConflict is an error event for ibdock.ErrSessionConflict:
+	// someone has to log the other session out.
+	EventSessionConflict = "session_conflict"
+)
+
+// sendTimeout bounds each delivery, so a hung endpoint cannot stall the
+// session the hooks are called from.
+const sendTimeout = 10 * time.Second
+
+type Event struct {
+	// Kind is EventSnapshot, EventError, EventSessionConflict, EventRestart
+	// or EventRisk.
+	Kind string
+	Time time.Time
+	// Error is the failure, or for restarts what caused it.
+	Error string `json:",omitempty"`
+	// Snapshot is the snapshot taken, or for risk events the one assessed.
+	Snapshot *snapshot.Snapshot  `json:",omitempty"`
+	Risks    []snapshot.RiskFlag `json:",omitempty"`
+}
+
+// Text describes the event in one line.
+func (e Event) Text() string {
+	return e.TextIn(locale.English)
+}
+
+// TextIn is Text in the language of l. Errors and risk flags stay in
+// English.
+func (e Event) TextIn(l *locale.Locale) string {
+	switch e.Kind {
+	case EventSnapshot:
+		return l.Sprintf("Snapshot of %s taken: %d positions", e.Snapshot.Account, len(e.Snapshot.Positions))
+	case EventSessionConflict:
+		return l.Sprintf("IB user is logged in elsewhere: %s", e.Error)
+	case EventRestart:
+		return l.Sprintf("IB session restarted after: %s", e.Error)
+	case EventRisk:
+		var risks []string
+		for _, risk := range e.Risks {
+			risks = append(risks, risk.String())
+		}
+		return l.Sprintf("Risk limits exceeded in %s: %s", e.Snapshot.Account, strings.Join(risks, "; "))
+	default:
+		return l.Sprintf("IB session failed: %s", e.Error)
+	}
+}
+
+type Sender interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Hooks returns hooks that send every event to each of senders. Delivery
+// failures are logged.
+func Hooks(logger *log.Logger, senders ...Sender) ibdock.Hooks {
+	return HooksWithClock(clock.Real, logger, senders...)
+}
+
+// HooksWithClock is Hooks with the events timestamped by clk.
+func HooksWithClock(clk clock.Clock, logger *log.Logger, senders ...Sender) ibdock.Hooks {
+	send := func(event Event) {
+		event.Time = clk.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		for _, sender := range senders {
+			if err := sender.Send(ctx, event); err != nil {
+				logger.Println("Failed to send", event.Kind, "notification:", err)
+			}
+		}
+	}
+	return ibdock.Hooks{
+		OnSnapshot: func(s *snapshot.Snapshot) { send(Event{Kind: EventSnapshot, Snapshot: s}) },
+		OnError: func(err error) {
+			kind := EventError
+			if errors.Is(err, ibdock.ErrSessionConflict) {
+				kind = EventSessionConflict
+			}
+			send(Event{Kind: kind, Error: err.Error()})
+		},
+		OnRestart: func(reason error) { send(Event{Kind: EventRestart, Error: reason.Error()}) },
+	}
+}
+
+type failures struct {
+	Sender
+}
+
+func (f failures) Send(ctx context.Context, event Event) error {
+	if event.Kind == EventSnapshot {
+		return nil
+	}
+	return f.Sender.Send(ctx, event)
+}
+
+// Failures passes only error and restart events on to sender.
+func Failures(sender Sender) Sender {
+	return failures{sender}
+}
+
+// riskTimeout bounds each assessment by RiskAlerts, which may need market
+// data, separately from delivery.
+const riskTimeout = time.Minute
+
+type riskAlerts struct {
+	assess func(context.Context, *snapshot.Snapshot) ([]snapshot.RiskFlag, error)
+	sender Sender
+}
+
+func (r riskAlerts) Send(ctx context.Context, event Event) error {
+	if event.Kind != EventSnapshot {
+		return nil
+	}
+	assessCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), riskTimeout)
+	risks, err := r.assess(assessCtx, event.Snapshot)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("assessing risk: %w", err)
+	}
+	if len(risks) == 0 {
+		return nil
+	}
+	ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	return r.sender.Send(ctx, Event{Kind: EventRisk, Time: event.Time, Snapshot: event.Snapshot, Risks: risks})
+}
+
+// RiskAlerts assesses each snapshot with assess, e.g. a closure over
+// ibdock.Dock.AssessRisk, and sends sender a risk event, at the time of the
+// snapshot event, if anything is flagged. Other events are dropped.
+func RiskAlerts(assess func(context.Context, *snapshot.Snapshot) ([]snapshot.RiskFlag, error), sender Sender) Sender {
+	return riskAlerts{assess, sender}
+}
+
+// Webhook POSTs each event as JSON to URL.
+type Webhook struct {
+	URL string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, w.Client, w.URL, event)
+}
+
+// Slack posts the events' Text to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Locale is the language of the messages, English if nil.
+	Locale *locale.Locale
+}
+
+func (s *Slack) Send(ctx context.Context, event Event) error {
+	l := s.Locale
+	if l == nil {
+		l = locale.English
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, struct {
+		Text string `json:"text"`
+	}{event.TextIn(l)})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("notify: %s returned %s", url, response.Status)
+	}
+	return nil
+}
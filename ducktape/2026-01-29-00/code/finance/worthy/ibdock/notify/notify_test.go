@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad body: %v", err)
+		}
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	fake := clock.NewFake(time.Date(2026, time.January, 29, 16, 30, 0, 0, time.UTC))
+	hooks := HooksWithClock(fake, log.New(io.Discard, "", 0), &Webhook{URL: server.URL}, Failures(&Slack{WebhookURL: server.URL}))
+	hooks.OnSnapshot(&snapshot.Snapshot{Account: "U1111111"})
+	hooks.OnError(errors.New("login failed"))
+	hooks.OnError(&ibdock.LoginError{Err: ibdock.ErrSessionConflict, Line: "Existing session detected"})
+
+	if len(bodies) != 5 {
+		t.Fatalf("got %d requests, want 5: %v", len(bodies), bodies)
+	}
+	if bodies[0]["Kind"] != EventSnapshot || bodies[1]["Kind"] != EventError || bodies[3]["Kind"] != EventSessionConflict || bodies[0]["Time"] != "2026-01-29T16:30:00Z" {
+		t.Errorf("webhook bodies %v", bodies)
+	}
+	if bodies[2]["text"] != "IB session failed: login failed" {
+		t.Errorf("Slack body %v", bodies[2])
+	}
+}
+
+func TestTextIn(t *testing.T) {
+	event := Event{Kind: EventSnapshot, Snapshot: &snapshot.Snapshot{Account: "U1111111", Positions: make([]snapshot.Position, 2)}}
+	if got, want := event.TextIn(locale.Czech), "Snímek účtu U1111111 pořízen, počet pozic: 2"; got != want {
+		t.Errorf("TextIn(Czech) = %q, want %q", got, want)
+	}
+	if got, want := event.Text(), "Snapshot of U1111111 taken: 2 positions"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestRiskAlerts(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad body: %v", err)
+		}
+		texts = append(texts, body["text"])
+	}))
+	defer server.Close()
+
+	flagged := map[string][]snapshot.RiskFlag{
+		"U1111111": {{Kind: snapshot.RiskConcentration, Symbol: "AAPL", Value: 0.5, Limit: 0.2}},
+	}
+	assess := func(ctx context.Context, s *snapshot.Snapshot) ([]snapshot.RiskFlag, error) {
+		return flagged[s.Account], nil
+	}
+	hooks := Hooks(log.New(io.Discard, "", 0), RiskAlerts(assess, &Slack{WebhookURL: server.URL}))
+	hooks.OnSnapshot(&snapshot.Snapshot{Account: "U1111111"})
+	hooks.OnSnapshot(&snapshot.Snapshot{Account: "U2222222"})
+	hooks.OnError(errors.New("login failed"))
+
+	if len(texts) != 1 {
+		t.Fatalf("got %d requests, want 1: %v", len(texts), texts)
+	}
+	want := "Risk limits exceeded in U1111111: " + flagged["U1111111"][0].String()
+	if texts[0] != want {
+		t.Errorf("got %q, want %q", texts[0], want)
+	}
+}
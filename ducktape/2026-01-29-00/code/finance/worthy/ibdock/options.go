@@ -0,0 +1,380 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"os"
+	"time"
+)
+
+// Option configures a Dock created by StartNew or Attach.
+type Option func(*Dock)
+
+// WithContractCache makes the Dock look up contract details in cache before
+// asking the gateway, and remember what the gateway returns.
+func WithContractCache(cache ContractCache) Option {
+	return func(dock *Dock) {
+		dock.contracts = cache
+	}
+}
+
+// WithJournal records the messages of all of the Dock's TWS API connections in
+// journal, see Dock.Journal.
+func WithJournal(journal *twsapi.Journal) Option {
+	return func(dock *Dock) {
+		dock.journal = journal
+	}
+}
+
+// WithStrictDecoding makes GetSnapshot fail on unknown or missing fields in
+// the snapshot script's output instead of ignoring them, see
+// snapshot.UnmarshalStrict. Meant for smoke tests of new images.
+func WithStrictDecoding() Option {
+	return func(dock *Dock) {
+		dock.strict = true
+	}
+}
+
+// WithValidation makes GetSnapshot fail with a *snapshot.ValidationError on
+// snapshots that break rules, see snapshot.Validate, rather than return
+// partial data. Such errors are not Retryable.
+func WithValidation(rules snapshot.ValidationRules) Option {
+	return func(dock *Dock) {
+		dock.validation = &rules
+	}
+}
+
+// WithSessionName names the container after session and labels it, so a
+// Reconciler can find it again.
+func WithSessionName(session string) Option {
+	return func(dock *Dock) {
+		dock.session = session
+	}
+}
+
+// WithImage runs ref, e.g. a tag built by the imagebuild package, instead of
+// the published image. Attach then only accepts containers of ref's
+// repository.
+func WithImage(ref string) Option {
+	return func(dock *Dock) {
+		dock.image = ref
+	}
+}
+
+// WithVariant runs TWS or IB Gateway, the default. Unless WithImage is given
+// too, it picks the image built for the variant.
+func WithVariant(variant Variant) Option {
+	return func(dock *Dock) {
+		dock.variant = variant
+	}
+}
+
+// WithPlatform runs the image as platform, e.g. "linux/amd64" on an ARM host
+// that emulates it, instead of as the Docker host's own platform.
+func WithPlatform(platform string) Option {
+	return func(dock *Dock) {
+		dock.platform = platform
+	}
+}
+
+// WithDebugVNC runs a VNC server on the container's screen, see
+// Dock.DebugEndpoint. An empty password lets anyone who reaches the port in,
+// so publish it on a trusted address, see WithBindAddress.
+func WithDebugVNC(password string) Option {
+	return func(dock *Dock) {
+		dock.debugVNC = true
+		dock.vncPassword = password
+	}
+}
+
+// WithTradingMode logs in to the "live" (the default) or "paper" account.
+func WithTradingMode(mode string) Option {
+	return func(dock *Dock) {
+		dock.tradingMode = mode
+	}
+}
+
+// WithSnapshotTimeout bounds how long the snapshot script may run; the default
+// is 5 minutes. How long callers wait is up to their contexts.
+func WithSnapshotTimeout(timeout time.Duration) Option {
+	return func(dock *Dock) {
+		dock.timeout = timeout
+	}
+}
+
+// WithSessionConflict sets what the gateway does when its user is already
+// logged in elsewhere: ConflictFail, the default, or ConflictTakeOver.
+func WithSessionConflict(policy SessionConflict) Option {
+	return func(dock *Dock) {
+		dock.sessionConflict = policy
+	}
+}
+
+// WithAllowTrading lets the trading package place and cancel orders through
+// the Dock's session, if it is a paper one; see WithLiveTrading.
+func WithAllowTrading() Option {
+	return func(dock *Dock) {
+		dock.allowTrading = true
+	}
+}
+
+// WithLiveTrading is WithAllowTrading for live sessions too.
+func WithLiveTrading() Option {
+	return func(dock *Dock) {
+		dock.allowTrading = true
+		dock.liveTrading = true
+	}
+}
+
+// WithoutRedaction turns off masking account IDs and credentials in the
+// Dock's logs and errors, and logs the snapshot script's standard output,
+// balances and all, to debug it; see Redactor.
+func WithoutRedaction() Option {
+	return func(dock *Dock) {
+		dock.noRedaction = true
+	}
+}
+
+// WithAccountLock makes StartNew fail with ErrAccountLocked while another
+// container started WithAccountLock on the same Docker host runs a session
+// of the same IB login, which it would otherwise log out. The lock is held
+// until the container is removed, e.g. by Stop.
+func WithAccountLock() Option {
+	return func(dock *Dock) {
+		dock.accountLock = true
+	}
+}
+
+// WithDryRun makes StartNew check the options, the credentials, the Docker
+// daemon, the image and the ports into report instead of starting a
+// container, and return ErrDryRun if all checks pass. Images missing on the
+// Docker host are not pulled.
+func WithDryRun(report *PreflightReport) Option {
+	return func(dock *Dock) {
+		dock.dryRun = report
+	}
+}
+
+// WithRateLimit limits the Dock's execs and TWS API connections to rate a
+// second, with bursts of up to burst, so a busy caller does not get the
+// account locked for pacing; the default is one a second with bursts of 10.
+// Calls wait their turn, or fail with a *PacingError if their context would
+// expire first. A negative rate lifts the limit.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(dock *Dock) {
+		dock.rateLimit = rate
+		dock.rateBurst = burst
+	}
+}
+
+// WithPullStallTimeout makes StartNew give up pulling a missing image with
+// ErrPullStalled after the daemon reported no progress for timeout, 5
+// minutes by default.
+func WithPullStallTimeout(timeout time.Duration) Option {
+	return func(dock *Dock) {
+		dock.pullStallTimeout = timeout
+	}
+}
+
+// WithMonitorInterval sets how often Monitor checks the session, 15
+// seconds by default.
+func WithMonitorInterval(interval time.Duration) Option {
+	return func(dock *Dock) {
+		dock.monitorInterval = interval
+	}
+}
+
+// WithWatchInterval sets how often Watch polls the account, every minute by
+// default.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(dock *Dock) {
+		dock.watchInterval = interval
+	}
+}
+
+// WithClock makes the Dock poll, time out execs, pace calls and timestamp
+// events and output by c instead of the system clock, e.g. a clock.Fake in
+// tests.
+func WithClock(c clock.Clock) Option {
+	return func(dock *Dock) {
+		dock.clock = c
+	}
+}
+
+// WithDockerEndpoint talks to the Docker daemon at endpoint, e.g.
+// "tcp://docker-host:2376" or "ssh://me@docker-host", instead of the one
+// configured by DOCKER_HOST.
+func WithDockerEndpoint(endpoint string) Option {
+	return func(dock *Dock) {
+		dock.dockerConfig.Endpoint = endpoint
+	}
+}
+
+// WithDockerTLS talks to a TCP Docker endpoint over TLS, authenticating with
+// the PEM client certificate and key at certPath and keyPath, if given, and
+// verifying the daemon against the CA certificate at caPath, if given.
+func WithDockerTLS(certPath, keyPath, caPath string) Option {
+	return func(dock *Dock) {
+		dock.dockerConfig.TLSCert = certPath
+		dock.dockerConfig.TLSKey = keyPath
+		dock.dockerConfig.TLSCA = caPath
+	}
+}
+
+// WithDockerSSHIdentity makes ssh:// Docker endpoints log in with the private
+// key at path rather than ssh's defaults.
+func WithDockerSSHIdentity(path string) Option {
+	return func(dock *Dock) {
+		dock.dockerConfig.SSHIdentity = path
+	}
+}
+
+// WithMemoryLimit caps the container's memory at bytes, 2 GiB by default;
+// negative lifts the cap. The kernel kills the gateway when it goes over,
+// which Manager then handles like any other failure.
+func WithMemoryLimit(bytes int64) Option {
+	return func(dock *Dock) {
+		dock.memoryLimit = bytes
+	}
+}
+
+// WithCPUShares sets the container's relative CPU weight, 512 by default
+// against Docker's 1024; negative leaves Docker's.
+func WithCPUShares(shares int64) Option {
+	return func(dock *Dock) {
+		dock.cpuShares = shares
+	}
+}
+
+// WithPidsLimit caps the processes and threads in the container, 1024 by
+// default; negative lifts the cap.
+func WithPidsLimit(pids int64) Option {
+	return func(dock *Dock) {
+		dock.pidsLimit = pids
+	}
+}
+
+// WithRestartPolicy sets the Docker restart policy, as for docker run
+// --restart: "no" (the default), "always", "unless-stopped" or
+// "on-failure[:max retries]". Restarting logs in again, so a wrong password
+// is retried too.
+func WithRestartPolicy(policy string) Option {
+	return func(dock *Dock) {
+		dock.restartPolicy = policy
+	}
+}
+
+// WithHealthCheck configures the Docker health check on the container,
+// which probes the TWS API port from inside it, so docker ps and
+// orchestrators see whether the session is up; Monitor and Inspect report
+// its status.
+func WithHealthCheck(check HealthCheck) Option {
+	return func(dock *Dock) {
+		dock.healthCheck = check
+	}
+}
+
+// WithoutHealthCheck creates the container without a health check, also
+// turning off the image's.
+func WithoutHealthCheck() Option {
+	return func(dock *Dock) {
+		dock.noHealthCheck = true
+	}
+}
+
+// WithNetwork joins the container to an existing Docker network, e.g. one
+// shared with the services that connect to the gateway, instead of the
+// default bridge.
+func WithNetwork(name string) Option {
+	return func(dock *Dock) {
+		dock.network = name
+	}
+}
+
+// WithAPIPort publishes the TWS API port on a fixed host port, e.g. 7496 for
+// a live and 7497 for a paper session, so firewall rules and clients can
+// name it. By default all ports are published on random host ports.
+func WithAPIPort(hostPort int) Option {
+	return func(dock *Dock) {
+		dock.apiHostPort = hostPort
+	}
+}
+
+// WithBindAddress publishes the TWS API port on one host IP only, e.g.
+// 127.0.0.1 to keep the gateway off the network, instead of all interfaces.
+func WithBindAddress(ip string) Option {
+	return func(dock *Dock) {
+		dock.bindAddress = ip
+	}
+}
+
+// WithPublishedHost connects to the container's published ports at host,
+// e.g. "host.docker.internal" or the Docker host's name, instead of the
+// address found from the port bindings, the Docker endpoint and whether it
+// is Docker Desktop. Use it when this process runs in a container on a
+// Linux Docker host, where localhost is not the host's.
+func WithPublishedHost(host string) Option {
+	return func(dock *Dock) {
+		dock.publishHost = host
+	}
+}
+
+// WithFile copies a file with the given content and permissions into the
+// container at the absolute path, after it is created and before the
+// gateway starts, e.g. a jts.ini or the API precautions of the gateway's
+// settings. Missing directories are created. It may be given more than once.
+func WithFile(path string, content []byte, mode os.FileMode) Option {
+	return func(dock *Dock) {
+		dock.files = append(dock.files, containerFile{path, content, mode})
+	}
+}
+
+// WithStartExec runs cmd in the container once it started, for each
+// container StartNew or WithAutoRestart starts. The entrypoint launches the
+// gateway meanwhile, so settings it reads at launch belong in WithFile. If
+// cmd fails or exits non-zero, the start fails. It may be given more than
+// once; the commands run in order, each for at most a minute.
+func WithStartExec(cmd ...string) Option {
+	return func(dock *Dock) {
+		dock.startExecs = append(dock.startExecs, cmd)
+	}
+}
+
+// WithPreStopExec runs cmd in the container before Stop stops it, e.g. a
+// cleanup script. A failing cmd is logged and the container stopped anyway.
+// It may be given more than once; the commands run in order, each for at
+// most a minute and within Stop's context.
+func WithPreStopExec(cmd ...string) Option {
+	return func(dock *Dock) {
+		dock.preStopExecs = append(dock.preStopExecs, cmd)
+	}
+}
+
+// WithSettingsVolume keeps the gateway's settings in the named Docker
+// volume, created if missing, so they survive the container. Images built
+// before imagebuild supported it ignore the volume.
+func WithSettingsVolume(name string) Option {
+	return func(dock *Dock) {
+		dock.settingsVolume = name
+	}
+}
+
+// WithAutoRestart recreates the container from the same settings and
+// credentials when it is found dead before an exec, up to restarts times
+// over the Dock's life, and waits for the gateway to log in again. Past the
+// budget calls fail with ErrRestartBudget. Only Docks from StartNew restart.
+func WithAutoRestart(restarts int) Option {
+	return func(dock *Dock) {
+		dock.autoRestarts = restarts
+	}
+}
+
+// WithLegacyDockerClient talks to Docker through go-dockerclient instead of
+// the official SDK. It is a fallback while the migration to the SDK settles
+// and will be removed.
+func WithLegacyDockerClient() Option {
+	return func(dock *Dock) {
+		dock.dockerConfig.Legacy = true
+	}
+}
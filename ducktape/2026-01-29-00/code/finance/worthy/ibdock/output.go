@@ -0,0 +1,107 @@
+package ibdock
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stream is an output stream of a command, numbered as in the headers Docker
+// multiplexes exec output with.
+type Stream int
+
+const (
+	StreamStdout Stream = 1
+	StreamStderr Stream = 2
+)
+
+func (s Stream) String() string {
+	switch s {
+	case StreamStdout:
+		return "stdout"
+	case StreamStderr:
+		return "stderr"
+	}
+	return fmt.Sprintf("stream %d", int(s))
+}
+
+// OutputChunk is output a command wrote to one stream, as Docker framed it.
+type OutputChunk struct {
+	// Time is when the chunk reached the Dock; Docker does not say when the
+	// command wrote it.
+	Time   time.Time
+	Stream Stream
+	Data   []byte
+}
+
+// Output is a command's output on both streams, in the order it was written.
+type Output []OutputChunk
+
+// String prints each line of o after its time and stream, e.g.
+// "12:00:01.250 stderr: Connecting to 127.0.0.1:7496".
+func (o Output) String() string {
+	var b strings.Builder
+	for _, chunk := range o {
+		for line := range strings.Lines(string(chunk.Data)) {
+			fmt.Fprintf(&b, "%s %s: %s", chunk.Time.Format("15:04:05.000"), chunk.Stream, line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return b.String()
+}
+
+// only returns the chunks of o written to stream.
+func (o Output) only(stream Stream) Output {
+	var filtered Output
+	for _, chunk := range o {
+		if chunk.Stream == stream {
+			filtered = append(filtered, chunk)
+		}
+	}
+	return filtered
+}
+
+// outputRecorder collects the Output of both of a command's streams, up to
+// limit bytes if positive, see captureBuffer.
+type outputRecorder struct {
+	limit int64
+	// clock stamps the chunks, default clock.Real.
+	clock clock.Clock
+
+	mu     sync.Mutex
+	output Output
+	// tail holds the last chunks once output has half the limit.
+	tail                 Output
+	headBytes, tailBytes int64
+	dropped              int64
+}
+
+// tee returns a writer recording what it writes to w as output on stream.
+func (r *outputRecorder) tee(stream Stream, w io.Writer) io.Writer {
+	return &recordingWriter{r: r, stream: stream, w: w}
+}
+
+// recorded returns the output recorded and how many bytes were left out.
+func (r *outputRecorder) recorded() (Output, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(r.output[:len(r.output):len(r.output)], r.tail...), r.dropped
+}
+
+type recordingWriter struct {
+	r      *outputRecorder
+	stream Stream
+	w      io.Writer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.r.mu.Lock()
+	w.r.record(w.stream, p)
+	w.r.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,140 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"sync"
+	"time"
+)
+
+// Defaults of WithRateLimit. IB allows 50 API messages a second, but a
+// snapshot sends hundreds, and sessions that keep at it get locked out for
+// minutes; a call a second with bursts of 10 stays well clear.
+const (
+	defaultRate  = 1
+	defaultBurst = 10
+)
+
+// ErrRateLimited is wrapped in the *PacingError returned when a call would
+// wait for the Dock's rate limit past its context's deadline.
+var ErrRateLimited = errors.New("ibdock: rate limit exceeded")
+
+// PacingError reports a call IB refused, or the Dock's rate limit would have
+// delayed, for being made too soon after others. It is Retryable, and
+// RetryPolicy waits at least RetryAfter before trying again.
+type PacingError struct {
+	// Err is the gateway's *twsapi.Error, the snapshot script's *ExitError,
+	// or ErrRateLimited.
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *PacingError) Error() string {
+	return fmt.Sprintf("pacing violation, retry after %v: %v", e.RetryAfter, e.Err)
+}
+
+func (e *PacingError) Unwrap() error {
+	return e.Err
+}
+
+// pacingWaits are how long IB wants callers to back off after its pacing
+// errors, by error code.
+var pacingWaits = map[int]time.Duration{
+	100: time.Second,      // max rate of messages per second exceeded
+	162: 15 * time.Second, // historical data pacing violation
+	420: 10 * time.Second, // invalid real-time query, usually pacing
+}
+
+// classifyPacing turns the gateway's pacing errors into *PacingErrors and
+// returns other errors as they are.
+func classifyPacing(err error) error {
+	var twsErr *twsapi.Error
+	if errors.As(err, &twsErr) {
+		if wait, ok := pacingWaits[twsErr.Code]; ok {
+			return &PacingError{Err: err, RetryAfter: wait}
+		}
+	}
+	return err
+}
+
+// scriptPacing tells a snapshot script failing on pacing from its stderr,
+// where the script logs the gateway's errors.
+func scriptPacing(err error, stderr []byte) error {
+	if bytes.Contains(stderr, []byte("pacing violation")) || bytes.Contains(stderr, []byte("Max rate of messages")) {
+		return &PacingError{Err: err, RetryAfter: pacingWaits[100]}
+	}
+	return err
+}
+
+// tokenBucket hands out tokens at rate a second, up to burst at once.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	clock clock.Clock
+
+	mu sync.Mutex
+	// tokens is negative while callers wait for tokens not yet added.
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, clk clock.Clock) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), clock: clk, tokens: float64(burst), last: clk.Now()}
+}
+
+// take takes a token, waiting until it is added or ctx is done. Callers get
+// tokens in the order they ask.
+func (b *tokenBucket) take(ctx context.Context) error {
+	b.mu.Lock()
+	now := b.clock.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(b.clock.Now()) < wait {
+		b.giveBack()
+		return &PacingError{Err: ErrRateLimited, RetryAfter: wait}
+	}
+	select {
+	case <-b.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		b.giveBack()
+		return ctx.Err()
+	}
+}
+
+func (b *tokenBucket) giveBack() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// pace takes a token from the Dock's rate limit, see WithRateLimit.
+func (dock *Dock) pace(ctx context.Context) error {
+	dock.pacerOnce.Do(func() {
+		rate, burst := dock.rateLimit, dock.rateBurst
+		if rate < 0 {
+			return
+		}
+		if rate == 0 {
+			rate = defaultRate
+		}
+		if burst <= 0 {
+			burst = defaultBurst
+		}
+		dock.pacer = newTokenBucket(rate, burst, clock.OrReal(dock.clock))
+	})
+	if dock.pacer == nil {
+		return nil
+	}
+	return dock.pacer.take(ctx)
+}
@@ -0,0 +1,89 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(20, 2, clock.Real)
+	ctx := context.Background()
+	start := time.Now()
+	for range 4 {
+		if err := bucket.take(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two tokens from the burst, two more at 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("4 tokens took %v, want about 100ms", elapsed)
+	}
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err := bucket.take(short)
+	var pacingErr *PacingError
+	if !errors.As(err, &pacingErr) || !errors.Is(err, ErrRateLimited) || pacingErr.RetryAfter <= 0 {
+		t.Errorf("take past the deadline = %v, want a *PacingError", err)
+	}
+	// The refused token went back.
+	time.Sleep(50 * time.Millisecond)
+	if err := bucket.take(short); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("take after refill = %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result { return ibdocktest.Result{} })
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithRateLimit(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := dock.Exec(ctx, []string{"true"}, ExecOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = dock.Exec(ctx, []string{"true"}, ExecOptions{})
+	var pacingErr *PacingError
+	if !errors.As(err, &pacingErr) || !Retryable(err) {
+		t.Errorf("second Exec = %v, want a retryable *PacingError", err)
+	}
+}
+
+func TestPacingErrors(t *testing.T) {
+	pacing := &twsapi.Error{Code: 162, Message: "Historical Market Data Service error message:API historical data query cancelled"}
+	var pacingErr *PacingError
+	if err := classifyPacing(fmt.Errorf("contract 1: %w", pacing)); !errors.As(err, &pacingErr) || pacingErr.RetryAfter != 15*time.Second {
+		t.Errorf("classifyPacing(162) = %v", err)
+	}
+	notFound := &twsapi.Error{Code: 200, Message: "No security definition has been found"}
+	if err := classifyPacing(notFound); err != notFound {
+		t.Errorf("classifyPacing(200) = %v", err)
+	}
+	exit := &ExitError{Code: 1}
+	if err := scriptPacing(exit, []byte("Error 420: Invalid Real-time Query: pacing violation")); !errors.As(err, &pacingErr) || !errors.Is(err, exit) {
+		t.Errorf("scriptPacing = %v", err)
+	}
+
+	// RetryPolicy waits out RetryAfter rather than its shorter backoff.
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	start := time.Now()
+	calls := 0
+	policy.Do(context.Background(), func(context.Context) error {
+		calls++
+		return &PacingError{Err: pacing, RetryAfter: 50 * time.Millisecond}
+	})
+	if elapsed := time.Since(start); calls != 2 || elapsed < 50*time.Millisecond {
+		t.Errorf("%d calls in %v, want 2 at least 50ms apart", calls, elapsed)
+	}
+}
@@ -0,0 +1,98 @@
+// Package performance splits the change in an account's value into what was
+// paid in and what the market added, month by month, from stored snapshots
+// and the deposits and withdrawals in Flex cash transactions:
+//
+//	snapshots, err := s.Range(ctx, "U1234567", from, to)
+//	transactions, err := flexClient.GetTransactions(ctx, from, to)
+//	report, err := performance.Monthly(snapshots, transactions.CashTransactions, rates)
+package performance
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/flex"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"time"
+)
+
+// Month is one calendar month of an account's history. Values are in the
+// base currency of the rates given to Monthly.
+type Month struct {
+	// Start is midnight UTC on the first day of the month.
+	Start      time.Time
+	StartValue snapshot.Decimal
+	EndValue   snapshot.Decimal
+	// Contributions is deposits less withdrawals, i.e. what was saved into
+	// the account.
+	Contributions snapshot.Decimal
+	// Growth is the rest of the change in value: market moves, dividends,
+	// interest and fees.
+	Growth snapshot.Decimal
+}
+
+// Report is the monthly history of one account.
+type Report struct {
+	Months        []Month
+	Contributions snapshot.Decimal
+	Growth        snapshot.Decimal
+	// SavingsRate is the average monthly contribution, as used by worthy's
+	// model. Being an average it is a float, like the model's inputs.
+	SavingsRate float64
+}
+
+// Monthly builds the report of the account snapshots, oldest first, were
+// taken of. Each month runs from the last snapshot before it, or for the
+// first month from the first snapshot, to its own last snapshot, and counts
+// the deposits and withdrawals in transactions made in between. Months
+// without snapshots are left out, their flows going to the next month.
+//
+// All snapshots are valued with the same rates, so currency moves show up as
+// neither contributions nor growth.
+func Monthly(snapshots []*snapshot.Snapshot, transactions []flex.CashTransaction, rates snapshot.FXRates) (*Report, error) {
+	report := new(Report)
+	if len(snapshots) == 0 {
+		return report, nil
+	}
+	account := snapshots[0].Account
+	var flows []flex.CashTransaction
+	for _, t := range transactions {
+		if t.Type == flex.DepositsWithdrawals && (t.Account == "" || t.Account == account) {
+			if _, ok := rates[t.Currency]; !ok {
+				return nil, fmt.Errorf("performance: no FX rate for %s", t.Currency)
+			}
+			flows = append(flows, t)
+		}
+	}
+	startValue, err := snapshots[0].Value(rates)
+	if err != nil {
+		return nil, err
+	}
+	start := snapshots[0].Timestamp
+	for i, s := range snapshots {
+		if i+1 < len(snapshots) && monthOf(snapshots[i+1].Timestamp).Equal(monthOf(s.Timestamp)) {
+			continue
+		}
+		endValue, err := s.Value(rates)
+		if err != nil {
+			return nil, err
+		}
+		month := Month{Start: monthOf(s.Timestamp), StartValue: startValue, EndValue: endValue}
+		for _, t := range flows {
+			if t.Time.After(start) && !t.Time.After(s.Timestamp) {
+				amount := snapshot.DecimalFromFloat(t.Amount).Mul(snapshot.DecimalFromFloat(rates[t.Currency]))
+				month.Contributions = month.Contributions.Add(amount)
+			}
+		}
+		month.Growth = month.EndValue.Sub(month.StartValue).Sub(month.Contributions)
+		report.Months = append(report.Months, month)
+		report.Contributions = report.Contributions.Add(month.Contributions)
+		report.Growth = report.Growth.Add(month.Growth)
+		startValue, start = endValue, s.Timestamp
+	}
+	report.SavingsRate = report.Contributions.Float64() / float64(len(report.Months))
+	return report, nil
+}
+
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,62 @@
+package performance
+
+import (
+	"github.com/agentydragon/worthy/ibdock/flex"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2026, month, d, 12, 0, 0, 0, time.UTC)
+}
+
+func dec(x float64) snapshot.Decimal {
+	return snapshot.DecimalFromFloat(x)
+}
+
+func cash(when time.Time, usd float64) *snapshot.Snapshot {
+	return &snapshot.Snapshot{
+		Account:   "U1111111",
+		Timestamp: when,
+		Positions: []snapshot.Position{{Symbol: "USD", SecType: "CASH", Currency: "USD", Quantity: snapshot.DecimalFromFloat(usd)}},
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	snapshots := []*snapshot.Snapshot{
+		cash(day(time.January, 1), 1000),
+		cash(day(time.January, 15), 1500),
+		cash(day(time.January, 31), 1600),
+		// Nothing in February.
+		cash(day(time.March, 31), 2200),
+	}
+	transactions := []flex.CashTransaction{
+		{Account: "U1111111", Currency: "EUR", Time: day(time.January, 10), Amount: 400, Type: flex.DepositsWithdrawals},
+		{Account: "U1111111", Currency: "USD", Time: day(time.January, 20), Amount: 100, Type: flex.Dividends},
+		{Account: "U1111111", Currency: "USD", Time: day(time.February, 10), Amount: 700, Type: flex.DepositsWithdrawals},
+		{Account: "U1111111", Currency: "USD", Time: day(time.March, 10), Amount: -200, Type: flex.DepositsWithdrawals},
+		{Account: "U2222222", Currency: "USD", Time: day(time.March, 10), Amount: 5000, Type: flex.DepositsWithdrawals},
+	}
+	report, err := Monthly(snapshots, transactions, snapshot.FXRates{"USD": 1, "EUR": 1.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Report{
+		Months: []Month{
+			{Start: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), StartValue: dec(1000), EndValue: dec(1600), Contributions: dec(500), Growth: dec(100)},
+			{Start: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), StartValue: dec(1600), EndValue: dec(2200), Contributions: dec(500), Growth: dec(100)},
+		},
+		Contributions: dec(1000),
+		Growth:        dec(200),
+		SavingsRate:   500,
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v, want %+v", report, want)
+	}
+
+	if _, err := Monthly(snapshots, transactions, snapshot.FXRates{"USD": 1}); err == nil {
+		t.Error("no error for missing FX rate")
+	}
+}
@@ -0,0 +1,72 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// checkPlatform checks platform is an OS and architecture, optionally with a
+// variant, as Docker takes them, e.g. "linux/arm64" or "linux/arm/v7".
+func checkPlatform(platform string) error {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("platform %q, want os/arch[/variant], e.g. linux/amd64", platform)
+	}
+	return nil
+}
+
+// samePlatform compares platforms, ignoring a variant only one of them has:
+// Docker reports arm64 images as "linux/arm64/v8" but hosts as "linux/arm64".
+func samePlatform(a, b string) bool {
+	aOS, aArch, aVariant := splitPlatform(a)
+	bOS, bArch, bVariant := splitPlatform(b)
+	return aOS == bOS && aArch == bArch && (aVariant == "" || bVariant == "" || aVariant == bVariant)
+}
+
+// preparePlatform returns the platform to run ref as, that of WithPlatform or
+// else the Docker host's own, pulling ref for it if the host does not have
+// it. An image built for another platform would only fail later with an
+// exec format error, so that is an error here.
+func (dock *Dock) preparePlatform(ctx context.Context, ref string) (string, error) {
+	platform, wanted, err := dock.targetPlatform(ctx)
+	if err != nil {
+		return "", err
+	}
+	built, found, err := dock.client.imagePlatform(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		dock.logger.Println("Pulling", ref, "for", platform)
+		if err := dock.pullImage(ctx, ref, platform); err != nil {
+			return "", fmt.Errorf("pulling %s for %s: %w", ref, platform, err)
+		}
+		if built, found, err = dock.client.imagePlatform(ctx, ref); err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("image %s is not on the Docker host after pulling it", ref)
+		}
+	}
+	if !samePlatform(built, platform) {
+		return "", platformMismatch(ref, built, wanted)
+	}
+	return platform, nil
+}
+
+// targetPlatform returns the platform to run the image as, that of
+// WithPlatform or else the Docker host's own, and why, for errors.
+func (dock *Dock) targetPlatform(ctx context.Context) (platform, wanted string, err error) {
+	if dock.platform != "" {
+		return dock.platform, "WithPlatform asks for " + dock.platform, nil
+	}
+	if platform, err = dock.client.daemonPlatform(ctx); err != nil {
+		return "", "", err
+	}
+	return platform, "the Docker host runs " + platform, nil
+}
+
+func platformMismatch(ref, built, wanted string) error {
+	return fmt.Errorf("image %s is built for %s, but %s; build the image for it, e.g. with ibdock_image on the Docker host, or run %s under emulation with WithPlatform if the host has it", ref, built, wanted, built)
+}
@@ -0,0 +1,68 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestPlatform(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		server.SetArch("arm64")
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()))
+
+		server.SetImage(image, "")
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithSessionName("pulled"))...); err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		if pulls := server.Pulls(); len(pulls) != 1 || !strings.HasSuffix(pulls[0], " linux/arm64") {
+			t.Errorf("%s: pulls = %q, want the image pulled for linux/arm64", backend.name, pulls)
+		}
+		if platform := server.Containers()[0].Platform; platform != "linux/arm64" {
+			t.Errorf("%s: created for %q", backend.name, platform)
+		}
+
+		server.SetImage(image, "linux/amd64")
+		if _, err := StartNew("jdoe", "secret", logger, opts...); err == nil || !strings.Contains(err.Error(), "built for linux/amd64, but the Docker host runs linux/arm64") {
+			t.Errorf("%s: StartNew with an amd64 image on arm64 = %v", backend.name, err)
+		}
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithSessionName("emulated"), WithPlatform("linux/amd64"))...); err != nil {
+			t.Errorf("%s: StartNew emulating amd64: %v", backend.name, err)
+		}
+		for _, c := range server.Containers() {
+			if c.Name == "/ibcontroller_emulated" && c.Platform != "linux/amd64" {
+				t.Errorf("%s: emulated session created for %q", backend.name, c.Platform)
+			}
+		}
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithPlatform("amd64"))...); err == nil {
+			t.Errorf("%s: StartNew with a bad platform succeeded", backend.name)
+		}
+	}
+}
+
+func TestSamePlatform(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		{"linux/arm64", "linux/arm64/v8", true},
+		{"linux/arm/v7", "linux/arm/v6", false},
+		{"linux/amd64", "linux/arm64", false},
+		{"windows/amd64", "linux/amd64", false},
+	} {
+		if got := samePlatform(test.a, test.b); got != test.want {
+			t.Errorf("samePlatform(%q, %q) = %v", test.a, test.b, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDryRun is what StartNew returns after a dry run whose checks all
+// passed, see WithDryRun.
+var ErrDryRun = errors.New("dry run: no container started")
+
+// portCheckTimeout bounds the dial that finds out whether the API port is
+// taken on the Docker host.
+const portCheckTimeout = 2 * time.Second
+
+// PreflightCheck is one check of a dry run.
+type PreflightCheck struct {
+	// Name is e.g. "docker" or "image".
+	Name string
+	// Err is why the check failed; nil if it passed.
+	Err error
+	// Note tells more about a passed check, e.g. that the image would be
+	// pulled.
+	Note string
+}
+
+// PreflightReport is what a dry run found, see WithDryRun. Checks that
+// need the Docker host are left out if it cannot be reached.
+type PreflightReport struct {
+	Checks []PreflightCheck
+}
+
+func (r *PreflightReport) add(name string, err error, note string) {
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Err: err, Note: note})
+}
+
+// OK reports whether all checks passed.
+func (r *PreflightReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err returns the failed checks as one error, or nil.
+func (r *PreflightReport) Err() error {
+	var errs []error
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", check.Name, check.Err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("preflight checks failed: %w", errors.Join(errs...))
+}
+
+// String lists the checks one a line, e.g. "ok    image: not on the Docker
+// host, would be pulled for linux/amd64".
+func (r *PreflightReport) String() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		switch {
+		case check.Err != nil:
+			fmt.Fprintf(&b, "FAIL  %s: %v\n", check.Name, check.Err)
+		case check.Note != "":
+			fmt.Fprintf(&b, "ok    %s: %s\n", check.Name, check.Note)
+		default:
+			fmt.Fprintf(&b, "ok    %s\n", check.Name)
+		}
+	}
+	return b.String()
+}
+
+// preflight checks what StartNew needs without creating anything: the
+// options, the credentials, the Docker daemon, the image, and that the
+// session's container name, the API port and the account lock are free.
+func (dock *Dock) preflight(ctx context.Context, username, password string) *PreflightReport {
+	report := new(PreflightReport)
+	_, err := dock.containerSpec(username, password)
+	report.add("config", err, "")
+	report.add("credentials", checkCredentials(username, password), "")
+
+	platform, wanted, err := "", "", dock.connect()
+	if err == nil {
+		platform, wanted, err = dock.targetPlatform(ctx)
+	}
+	if err != nil {
+		report.add("docker", err, "")
+		return report
+	}
+	report.add("docker", nil, "reachable at "+dock.client.endpoint())
+
+	ref := dock.imageRef()
+	built, found, err := dock.client.imagePlatform(ctx, ref)
+	switch {
+	case err != nil:
+		report.add("image", err, "")
+	case !found:
+		report.add("image", nil, fmt.Sprintf("%s is not on the Docker host, would be pulled for %s", ref, platform))
+	case !samePlatform(built, platform):
+		report.add("image", platformMismatch(ref, built, wanted), "")
+	default:
+		report.add("image", nil, ref+" for "+built)
+	}
+
+	if dock.session != "" {
+		name := dock.containerName()
+		err = nil
+		if container, inspectErr := dock.client.inspect(ctx, name); inspectErr == nil {
+			err = fmt.Errorf("container %s already exists with status %s", name, container.Status)
+		}
+		report.add("container name", err, "")
+	}
+	if dock.apiHostPort != 0 {
+		report.add("API port", dock.checkPortFree(ctx), "")
+	}
+	if dock.accountLock && username != "" {
+		report.add("account lock", dock.checkAccountLock(ctx, loginHash(username), ""), "")
+	}
+	return report
+}
+
+func checkCredentials(username, password string) error {
+	switch {
+	case username == "" && password == "":
+		return errors.New("no username and password")
+	case username == "":
+		return errors.New("no username")
+	case password == "":
+		return errors.New("no password")
+	}
+	return nil
+}
+
+// checkPortFree fails if something accepts connections on the API host
+// port, where Docker could not publish the container's.
+func (dock *Dock) checkPortFree(ctx context.Context) error {
+	address := net.JoinHostPort(dock.publishedHost(dock.bindAddress), strconv.Itoa(dock.apiHostPort))
+	dialer := net.Dialer{Timeout: portCheckTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return fmt.Errorf("%s is in use", address)
+}
@@ -0,0 +1,76 @@
+package ibdock
+
+import (
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	var report PreflightReport
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithDryRun(&report))
+	if dock != nil || !errors.Is(err, ErrDryRun) {
+		t.Fatalf("StartNew = %v, %v, want ErrDryRun", dock, err)
+	}
+	if len(server.Containers()) != 0 {
+		t.Errorf("dry run created containers %+v", server.Containers())
+	}
+	var names []string
+	for _, check := range report.Checks {
+		names = append(names, check.Name)
+	}
+	if got, want := strings.Join(names, ","), "config,credentials,docker,image"; got != want {
+		t.Errorf("checks %s, want %s", got, want)
+	}
+
+	existing, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer existing.Kill()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server.SetImage("registry.example.com/ibcontroller:1", "")
+	_, err = StartNew("jdoe", "", logger,
+		WithDockerEndpoint(server.URL()), WithDryRun(&report), WithSessionName("main"),
+		WithImage("registry.example.com/ibcontroller:1"), WithAPIPort(listener.Addr().(*net.TCPAddr).Port))
+	if err == nil || errors.Is(err, ErrDryRun) || report.OK() {
+		t.Fatalf("StartNew = %v, want failed checks", err)
+	}
+	failed := make(map[string]bool)
+	for _, check := range report.Checks {
+		failed[check.Name] = check.Err != nil
+		if check.Name == "image" && !strings.Contains(check.Note, "would be pulled") {
+			t.Errorf("image check %+v", check)
+		}
+	}
+	for name, want := range map[string]bool{"config": false, "credentials": true, "docker": false, "image": false, "container name": true, "API port": true} {
+		if got, ok := failed[name]; !ok || got != want {
+			t.Errorf("%s failed %v, want %v\n%s", name, got, want, &report)
+		}
+	}
+	if len(server.Pulls()) != 0 {
+		t.Errorf("dry run pulled %v", server.Pulls())
+	}
+}
+
+func TestDryRunNoDocker(t *testing.T) {
+	server := ibdocktest.NewServer()
+	endpoint := server.URL()
+	server.Close()
+	var report PreflightReport
+	_, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(endpoint), WithDryRun(&report))
+	if err == nil || len(report.Checks) != 3 || report.Checks[2].Name != "docker" || report.Checks[2].Err == nil {
+		t.Errorf("StartNew = %v with\n%s", err, &report)
+	}
+}
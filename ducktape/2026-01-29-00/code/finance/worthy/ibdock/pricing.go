@@ -0,0 +1,65 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"time"
+)
+
+// IB allows 100 simultaneous market data lines by default; leave headroom for
+// other clients of the same session.
+const defaultBatchSize = 50
+
+type PricingOptions struct {
+	// BatchSize is how many contracts are requested at once; zero means
+	// defaultBatchSize.
+	BatchSize int
+	// BatchDelay is the pause between batches.
+	BatchDelay time.Duration
+	// Progress, if set, is called after each batch with the number of
+	// contracts priced so far.
+	Progress func(done, total int)
+}
+
+// PriceContracts takes market data snapshots of contracts in batches, to stay
+// within IB's limit of simultaneous tickers when pricing large portfolios.
+// Quotes are in the order of contracts.
+func (dock *Dock) PriceContracts(ctx context.Context, contracts []twsapi.Contract, options PricingOptions) (_ []twsapi.Quote, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	quotes, err := priceInBatches(ctx, clock.OrReal(dock.clock), contracts, options, client.MarketSnapshots)
+	return quotes, classifyPacing(err)
+}
+
+func priceInBatches(ctx context.Context, clk clock.Clock, contracts []twsapi.Contract, options PricingOptions, snapshots func(context.Context, []twsapi.Contract) ([]twsapi.Quote, error)) ([]twsapi.Quote, error) {
+	size := options.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	quotes := make([]twsapi.Quote, 0, len(contracts))
+	for start := 0; start < len(contracts); start += size {
+		if start > 0 && options.BatchDelay > 0 {
+			select {
+			case <-clk.After(options.BatchDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		batch, err := snapshots(ctx, contracts[start:min(start+size, len(contracts))])
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, batch...)
+		if options.Progress != nil {
+			options.Progress(len(quotes), len(contracts))
+		}
+	}
+	return quotes, nil
+}
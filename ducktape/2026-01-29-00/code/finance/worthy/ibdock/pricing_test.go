@@ -0,0 +1,43 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"testing"
+)
+
+func TestPriceInBatches(t *testing.T) {
+	contracts := make([]twsapi.Contract, 7)
+	for i := range contracts {
+		contracts[i].ConID = i
+	}
+	var batches []int
+	snapshots := func(ctx context.Context, batch []twsapi.Contract) ([]twsapi.Quote, error) {
+		batches = append(batches, len(batch))
+		quotes := make([]twsapi.Quote, len(batch))
+		for i, contract := range batch {
+			quotes[i] = twsapi.Quote{Contract: contract, Last: float64(contract.ConID)}
+		}
+		return quotes, nil
+	}
+	var progress []int
+	quotes, err := priceInBatches(context.Background(), clock.Real, contracts, PricingOptions{
+		BatchSize: 3,
+		Progress:  func(done, total int) { progress = append(progress, done) },
+	}, snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || batches[2] != 1 {
+		t.Errorf("batch sizes %v, want [3 3 1]", batches)
+	}
+	if len(progress) != 3 || progress[2] != 7 {
+		t.Errorf("progress %v, want [3 6 7]", progress)
+	}
+	for i, quote := range quotes {
+		if quote.Last != float64(i) {
+			t.Errorf("quote %d is for contract %v", i, quote.Contract.ConID)
+		}
+	}
+}
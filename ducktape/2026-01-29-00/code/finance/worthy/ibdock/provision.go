@@ -0,0 +1,97 @@
+package ibdock
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// hookTimeout bounds each WithStartExec and WithPreStopExec command.
+const hookTimeout = time.Minute
+
+// containerFile is a file WithFile copies into the container.
+type containerFile struct {
+	path    string
+	content []byte
+	mode    os.FileMode
+}
+
+func (dock *Dock) checkFiles() error {
+	for _, f := range dock.files {
+		if !path.IsAbs(f.path) || strings.HasSuffix(f.path, "/") {
+			return fmt.Errorf("container file %q is not an absolute file path", f.path)
+		}
+	}
+	return nil
+}
+
+// copyFiles copies the WithFile files into the created container id, before
+// it starts.
+func (dock *Dock) copyFiles(ctx context.Context, id string) error {
+	if len(dock.files) == 0 {
+		return nil
+	}
+	var archive bytes.Buffer
+	w := tar.NewWriter(&archive)
+	now := clock.OrReal(dock.clock).Now()
+	for _, f := range dock.files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     strings.TrimPrefix(path.Clean(f.path), "/"),
+			Mode:     int64(f.mode.Perm()),
+			Size:     int64(len(f.content)),
+			ModTime:  now,
+		}
+		if err := w.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(f.content); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := dock.client.copyTo(ctx, id, "/", &archive); err != nil {
+		return fmt.Errorf("copying files into container %s: %w", id, err)
+	}
+	return nil
+}
+
+// runHooks runs the commands in the container in order, stopping at the
+// first that fails.
+func (dock *Dock) runHooks(ctx context.Context, kind string, cmds [][]string) error {
+	for _, cmd := range cmds {
+		result, err := dock.execCurrent(ctx, cmd, ExecOptions{Timeout: hookTimeout})
+		if err == nil && result.ExitCode != 0 {
+			err = &ExitError{Code: result.ExitCode}
+			// The logger masks secrets the output may have.
+			if stderr := bytes.TrimSpace(result.Stderr); len(stderr) > 0 {
+				dock.logger.Printf("%s hook %q output:\n%s", kind, cmd, stderr)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q: %w", kind, cmd, err)
+		}
+	}
+	return nil
+}
+
+// startContainer starts the created container id, copying the WithFile files
+// in first and running the WithStartExec commands after.
+func (dock *Dock) startContainer(ctx context.Context, id string) error {
+	if err := dock.copyFiles(ctx, id); err != nil {
+		return err
+	}
+	if err := dock.client.start(ctx, id); err != nil {
+		return err
+	}
+	dock.emit(Event{Kind: EventStarted})
+	return dock.runHooks(ctx, "start", dock.startExecs)
+}
@@ -0,0 +1,79 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProvision(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		var mu sync.Mutex
+		var ran []string
+		server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, exec.Cmd[0])
+			if strings.HasPrefix(exec.Cmd[0], "fail") {
+				return ibdocktest.Result{Stderr: []byte("no\n"), ExitCode: 1}
+			}
+			return ibdocktest.Result{}
+		})
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()))
+		ctx := context.Background()
+
+		jts := []byte("[IBGateway]\nApiOnly=true\n")
+		dock, err := StartNew("jdoe", "secret", logger, append(opts,
+			WithFile("/root/Jts/jts.ini", jts, 0o644),
+			WithFile("/opt/ibc/precautions.xml", []byte("<xml/>"), 0o600),
+			WithStartExec("start1"), WithStartExec("start2", "-v"),
+			WithPreStopExec("fail-cleanup"), WithPreStopExec("cleanup"))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		files := server.Files(dock.ContainerID())
+		if f := files["/root/Jts/jts.ini"]; string(f.Content) != string(jts) || f.Mode != 0o644 || f.Status != "created" {
+			t.Errorf("%s: jts.ini copied as %+v, want before the start", backend.name, f)
+		}
+		if f := files["/opt/ibc/precautions.xml"]; f.Mode != 0o600 {
+			t.Errorf("%s: precautions.xml copied as %+v", backend.name, f)
+		}
+		if err := dock.Stop(ctx); err != nil {
+			t.Errorf("%s: Stop despite failing pre-stop hook: %v", backend.name, err)
+		}
+		// The first pre-stop failure ends the pre-stop hooks.
+		if want := []string{"start1", "start2", "fail-cleanup"}; !slices.Equal(ran, want) {
+			t.Errorf("%s: ran %q, want %q", backend.name, ran, want)
+		}
+		if n := len(server.Containers()); n != 0 {
+			t.Errorf("%s: %d containers left after Stop", backend.name, n)
+		}
+
+		_, err = StartNew("jdoe", "secret", logger, append(opts, WithStartExec("fail-start"))...)
+		var exit *ExitError
+		if !errors.As(err, &exit) || exit.Code != 1 || !strings.Contains(err.Error(), "start hook") {
+			t.Errorf("%s: StartNew with failing start hook: %v", backend.name, err)
+		}
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithFile("Jts/jts.ini", jts, 0o644))...); err == nil {
+			t.Errorf("%s: StartNew copied a file to a relative path", backend.name)
+		}
+		if n := len(server.Containers()); n != 0 {
+			t.Errorf("%s: %d containers left after failed starts", backend.name, n)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package ibdock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPullStallTimeout is how long a pull may go without progress before
+// it is given up, see WithPullStallTimeout.
+const defaultPullStallTimeout = 5 * time.Minute
+
+// pullReportInterval is how often pull progress is logged and sent as an
+// EventPullProgress, besides when a layer finishes.
+const pullReportInterval = 5 * time.Second
+
+// ErrPullStalled is returned when pulling the image makes no progress for
+// the stall timeout, as when the registry hangs.
+var ErrPullStalled = errors.New("image pull stalled")
+
+// PullProgress is how far pulling the image got, summed over its layers.
+// Totals grow as the daemon learns of more layers.
+type PullProgress struct {
+	Ref        string
+	Layers     int
+	LayersDone int
+	// Downloaded and Size count the bytes of the layers being downloaded
+	// so far; Size is 0 until the daemon knows any.
+	Downloaded int64
+	Size       int64
+	// Status is the daemon's latest message, e.g. "Extracting".
+	Status string
+}
+
+func (p PullProgress) String() string {
+	s := fmt.Sprintf("%s: %d of %d layers", p.Ref, p.LayersDone, p.Layers)
+	if p.Size > 0 {
+		s += fmt.Sprintf(", %.1f of %.1f MB", float64(p.Downloaded)/1e6, float64(p.Size)/1e6)
+	}
+	return s
+}
+
+// pullMessage is one progress message of the daemon's pull output, decoded
+// by each containerRuntime.
+type pullMessage struct {
+	ID      string
+	Status  string
+	Current int64
+	Total   int64
+}
+
+// decodePull reads the daemon's pull output, a stream of JSON messages,
+// passing on their progress, and fails with the first error message.
+func decodePull(r io.Reader, progress func(pullMessage)) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg struct {
+			ID       string `json:"id"`
+			Status   string `json:"status"`
+			Progress struct {
+				Current int64 `json:"current"`
+				Total   int64 `json:"total"`
+			} `json:"progressDetail"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+		progress(pullMessage{ID: msg.ID, Status: msg.Status, Current: msg.Progress.Current, Total: msg.Progress.Total})
+	}
+}
+
+// pullTracker sums up the pull messages of each layer.
+type pullTracker struct {
+	mu       sync.Mutex
+	progress PullProgress
+	layers   map[string]*layerProgress
+	// seen is told of every message, for the stall timeout.
+	seen chan struct{}
+}
+
+type layerProgress struct {
+	current, total int64
+	done           bool
+}
+
+func newPullTracker(ref string) *pullTracker {
+	return &pullTracker{
+		progress: PullProgress{Ref: ref},
+		layers:   make(map[string]*layerProgress),
+		seen:     make(chan struct{}, 1),
+	}
+}
+
+// add records msg and reports whether a layer finished with it.
+func (t *pullTracker) add(msg pullMessage) (finished bool) {
+	select {
+	case t.seen <- struct{}{}:
+	default:
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Status = msg.Status
+	// Messages without an ID, or about the whole image, as "Pulling from
+	// agentydragon/ibcontroller" with the tag as ID, are not about layers.
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		return false
+	}
+	layer, ok := t.layers[msg.ID]
+	if !ok {
+		layer = new(layerProgress)
+		t.layers[msg.ID] = layer
+		t.progress.Layers++
+	}
+	switch {
+	case msg.Status == "Downloading" && msg.Total > 0:
+		t.progress.Downloaded += msg.Current - layer.current
+		t.progress.Size += msg.Total - layer.total
+		layer.current, layer.total = msg.Current, msg.Total
+	case msg.Status == "Download complete":
+		t.progress.Downloaded += layer.total - layer.current
+		layer.current = layer.total
+	case (msg.Status == "Pull complete" || msg.Status == "Already exists") && !layer.done:
+		layer.done = true
+		t.progress.LayersDone++
+		return true
+	}
+	return false
+}
+
+func (t *pullTracker) snapshot() PullProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
+
+// pullImage pulls ref for platform, logging its progress and sending it as
+// EventPullProgress now and then. It fails with ErrPullStalled if the
+// daemon stops reporting progress for the stall timeout.
+func (dock *Dock) pullImage(ctx context.Context, ref, platform string) error {
+	stallTimeout := dock.pullStallTimeout
+	if stallTimeout <= 0 {
+		stallTimeout = defaultPullStallTimeout
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	tracker := newPullTracker(ref)
+	done := make(chan struct{})
+	defer close(done)
+	clk := clock.OrReal(dock.clock)
+	go func() {
+		stall := clk.After(stallTimeout)
+		for {
+			select {
+			case <-tracker.seen:
+				stall = clk.After(stallTimeout)
+			case <-stall:
+				cancel(fmt.Errorf("%w: no progress in %v", ErrPullStalled, stallTimeout))
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	var lastReport time.Time
+	report := func(progress PullProgress) {
+		dock.logger.Println("Pulling", progress)
+		dock.emit(Event{Kind: EventPullProgress, Pull: &progress})
+	}
+	err := dock.client.pull(ctx, ref, platform, func(msg pullMessage) {
+		finished := tracker.add(msg)
+		if now := clk.Now(); finished || now.Sub(lastReport) >= pullReportInterval {
+			lastReport = now
+			report(tracker.snapshot())
+		}
+	})
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrPullStalled) {
+		return cause
+	}
+	if err == nil {
+		report(tracker.snapshot())
+	}
+	return err
+}
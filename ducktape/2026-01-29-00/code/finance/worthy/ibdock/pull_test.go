@@ -0,0 +1,82 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPullProgress(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		server.SetImage(image, "")
+		events := make(chan Event, 20)
+		dock, err := StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()), WithEvents(events))...)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		dock.Kill()
+		var last *PullProgress
+		for len(events) > 0 {
+			if event := <-events; event.Kind == EventPullProgress {
+				last = event.Pull
+			}
+		}
+		if last == nil {
+			t.Fatalf("%s: no pull progress", backend.name)
+		}
+		// The SDK pulls the normalized reference.
+		if progress := *last; !strings.HasPrefix(progress.Status, "Downloaded newer image for ") {
+			t.Errorf("%s: last status %q", backend.name, progress.Status)
+		} else if progress.Status = ""; progress != (PullProgress{Ref: image, Layers: 2, LayersDone: 2, Downloaded: 4000, Size: 4000}) {
+			t.Errorf("%s: last progress %+v", backend.name, progress)
+		}
+
+		server.SetImage(image, "")
+		server.SetLatency(ibdocktest.OpPull, time.Minute)
+		_, err = StartNew("jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()), WithPullStallTimeout(50*time.Millisecond))...)
+		if !errors.Is(err, ErrPullStalled) {
+			t.Errorf("%s: stalled pull: %v, want ErrPullStalled", backend.name, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = StartNewContext(ctx, "jdoe", "secret", logger, append(backend.opts, WithDockerEndpoint(server.URL()))...)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: pull past the deadline: %v, want context.DeadlineExceeded", backend.name, err)
+		}
+		server.SetLatency(ibdocktest.OpPull, 0)
+	}
+}
+
+func TestPullTracker(t *testing.T) {
+	tracker := newPullTracker("ibcontroller")
+	for _, msg := range []pullMessage{
+		{ID: "a", Status: "Downloading", Current: 10, Total: 100},
+		{ID: "b", Status: "Downloading", Current: 5, Total: 50},
+		{ID: "a", Status: "Downloading", Current: 60, Total: 100},
+		{ID: "a", Status: "Extracting", Current: 30, Total: 100},
+	} {
+		if tracker.add(msg) {
+			t.Errorf("%+v finished a layer", msg)
+		}
+	}
+	if got := tracker.snapshot(); got.Layers != 2 || got.Downloaded != 65 || got.Size != 150 || got.String() != "ibcontroller: 0 of 2 layers, 0.0 of 0.0 MB" {
+		t.Errorf("progress %+v: %s", got, got)
+	}
+	if !tracker.add(pullMessage{ID: "b", Status: "Pull complete"}) || tracker.add(pullMessage{ID: "b", Status: "Pull complete"}) {
+		t.Error("Pull complete should finish a layer once")
+	}
+}
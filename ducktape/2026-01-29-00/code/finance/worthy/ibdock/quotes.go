@@ -0,0 +1,133 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"slices"
+)
+
+// GetQuotes returns current quotes of the held instruments with the given
+// symbols, or of all held instruments if symbols is empty, in the order the
+// gateway lists the positions. A symbol can have several instruments, e.g. a
+// stock and options on it. Instruments the gateway refuses quotes for have
+// Quote.Err set.
+func (dock *Dock) GetQuotes(ctx context.Context, symbols []string) (_ []twsapi.Quote, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	positions, err := client.Positions(ctx)
+	if err != nil {
+		return nil, classifyPacing(err)
+	}
+	quotes, err := priceInBatches(ctx, clock.OrReal(dock.clock), heldContracts(positions, symbols), PricingOptions{}, client.MarketSnapshots)
+	return quotes, classifyPacing(err)
+}
+
+// heldContracts returns the contract of each distinct instrument in positions
+// with one of symbols, or all if symbols is empty. Positions rarely name an
+// exchange, so quotes are asked of SMART routing then.
+func heldContracts(positions []twsapi.Position, symbols []string) []twsapi.Contract {
+	seen := make(map[int]bool)
+	var contracts []twsapi.Contract
+	for _, p := range positions {
+		contract := p.Contract
+		if seen[contract.ConID] || len(symbols) > 0 && !slices.Contains(symbols, contract.Symbol) {
+			continue
+		}
+		seen[contract.ConID] = true
+		if contract.Exchange == "" {
+			contract.Exchange = "SMART"
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts
+}
+
+// MarkToMarket returns a copy of s with its positions' prices and values
+// replaced by current quotes, see GetQuotes, rather than IB's marks, which can
+// be stale outside trading hours of the gateway's data subscriptions.
+func (dock *Dock) MarkToMarket(ctx context.Context, s *snapshot.Snapshot) (_ *snapshot.Snapshot, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, s.Account)
+	defer call.wrapError(&err)
+	var symbols []string
+	for _, p := range s.Positions {
+		if !slices.Contains(symbols, p.Symbol) {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return s, nil
+	}
+	quotes, err := dock.GetQuotes(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	return markToMarket(s, quotes, dock.logger.Println), nil
+}
+
+// markToMarket prices each position of s at the Mid of the quote of its
+// symbol, security type and currency. Cash and bonds, which IB quotes
+// differently from how it values them, positions without a quote, and
+// positions matching several quotes keep IB's marks.
+func markToMarket(s *snapshot.Snapshot, quotes []twsapi.Quote, log func(...any)) *snapshot.Snapshot {
+	type key struct{ symbol, secType, currency string }
+	byKey := make(map[key]twsapi.Quote)
+	ambiguous := make(map[key]bool)
+	for _, quote := range quotes {
+		k := key{quote.Contract.Symbol, quote.Contract.SecType, quote.Contract.Currency}
+		if _, ok := byKey[k]; ok {
+			ambiguous[k] = true
+		}
+		byKey[k] = quote
+	}
+	marked := *s
+	marked.Positions = slices.Clone(s.Positions)
+	for i, p := range marked.Positions {
+		if p.SecType == "CASH" || p.SecType == "BOND" {
+			continue
+		}
+		k := key{p.Symbol, p.SecType, p.Currency}
+		quote, ok := byKey[k]
+		switch {
+		case !ok:
+			log("No quote for", p.Symbol, p.SecType+", keeping IB's mark")
+			continue
+		case ambiguous[k]:
+			log("Several instruments are", p.Symbol, p.SecType+", keeping IB's mark")
+			continue
+		case quote.Err != nil:
+			log("No quote for", p.Symbol, p.SecType+", keeping IB's mark:", quote.Err)
+			continue
+		}
+		price := quotePrice(quote)
+		if price.Sign() <= 0 {
+			continue
+		}
+		multiplier, err := snapshot.ParseDecimal(quote.Contract.Multiplier)
+		if err != nil || multiplier.Sign() <= 0 {
+			multiplier = snapshot.NewDecimal(1, 0)
+		}
+		p.MarketPrice = price
+		p.MarketValue = p.Quantity.Mul(price).Mul(multiplier)
+		marked.Positions[i] = p
+	}
+	return &marked
+}
+
+// quotePrice is twsapi.Quote.Mid in decimals, so that the midpoint of two
+// prices is exact.
+func quotePrice(q twsapi.Quote) snapshot.Decimal {
+	if q.Bid > 0 && q.Ask > 0 {
+		half := snapshot.NewDecimal(5, -1)
+		return snapshot.DecimalFromFloat(q.Bid).Add(snapshot.DecimalFromFloat(q.Ask)).Mul(half)
+	}
+	return snapshot.DecimalFromFloat(q.Mid())
+}
@@ -0,0 +1,52 @@
+package ibdock
+
+import (
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"testing"
+)
+
+func TestHeldContracts(t *testing.T) {
+	positions := []twsapi.Position{
+		{Account: "U1", Contract: twsapi.Contract{ConID: 1, Symbol: "VT", SecType: "STK", Currency: "USD"}},
+		{Account: "U2", Contract: twsapi.Contract{ConID: 1, Symbol: "VT", SecType: "STK", Currency: "USD"}},
+		{Account: "U1", Contract: twsapi.Contract{ConID: 2, Symbol: "ES", SecType: "FUT", Exchange: "CME", Currency: "USD"}},
+		{Account: "U1", Contract: twsapi.Contract{ConID: 3, Symbol: "BND", SecType: "STK", Currency: "USD"}},
+	}
+	contracts := heldContracts(positions, []string{"VT", "ES"})
+	if len(contracts) != 2 || contracts[0].ConID != 1 || contracts[0].Exchange != "SMART" || contracts[1].Exchange != "CME" {
+		t.Errorf("heldContracts = %+v", contracts)
+	}
+	if all := heldContracts(positions, nil); len(all) != 3 {
+		t.Errorf("heldContracts(nil) = %+v, want 3 contracts", all)
+	}
+}
+
+func TestMarkToMarket(t *testing.T) {
+	s := &snapshot.Snapshot{Account: "U1", Positions: []snapshot.Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(10, 0), MarketPrice: snapshot.NewDecimal(100, 0), MarketValue: snapshot.NewDecimal(1000, 0)},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", Quantity: snapshot.NewDecimal(1, 0), MarketPrice: snapshot.NewDecimal(5000, 0), MarketValue: snapshot.NewDecimal(250000, 0)},
+		{Symbol: "USD", SecType: "CASH", Currency: "USD", Quantity: snapshot.NewDecimal(500, 0), MarketValue: snapshot.NewDecimal(500, 0)},
+		{Symbol: "BND", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(1, 0), MarketPrice: snapshot.NewDecimal(70, 0), MarketValue: snapshot.NewDecimal(70, 0)},
+		{Symbol: "PNY", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(3, 0)},
+	}}
+	quotes := []twsapi.Quote{
+		{Contract: twsapi.Contract{Symbol: "VT", SecType: "STK", Currency: "USD"}, Bid: 109, Ask: 111},
+		{Contract: twsapi.Contract{Symbol: "ES", SecType: "FUT", Currency: "USD", Multiplier: "50"}, Last: 5100},
+		{Contract: twsapi.Contract{Symbol: "BND", SecType: "STK", Currency: "USD"}, Err: errors.New("no market data permissions")},
+		// In float64, the midpoint is 0.15000000000000002 and 3 of it
+		// 0.45000000000000007.
+		{Contract: twsapi.Contract{Symbol: "PNY", SecType: "STK", Currency: "USD"}, Bid: 0.1, Ask: 0.2},
+	}
+	marked := markToMarket(s, quotes, func(...any) {})
+	for i, want := range []struct{ price, value string }{{"110", "1100"}, {"5100", "255000"}, {"0", "500"}, {"70", "70"}, {"0.15", "0.45"}} {
+		p := marked.Positions[i]
+		if p.MarketPrice.String() != want.price || p.MarketValue.String() != want.value {
+			t.Errorf("%s marked at %v, worth %v; want %v, %v", p.Symbol, p.MarketPrice, p.MarketValue, want.price, want.value)
+		}
+	}
+	if s.Positions[0].MarketPrice.Float64() != 100 {
+		t.Error("markToMarket changed its input")
+	}
+}
@@ -0,0 +1,217 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionSpec declares a session that should be running.
+type SessionSpec struct {
+	// Name identifies the session across restarts of the process; its
+	// container is labelled with it.
+	Name     string
+	Username string
+	Password string
+	Options  []Option
+	// Manager configures the session's snapshot schedule. A zero Interval
+	// leaves the session unscheduled.
+	Manager ManagerOptions
+}
+
+// Plan is what a reconciliation does to make the running sessions match the
+// declared ones. Any action means the two had drifted apart.
+type Plan struct {
+	// Start are sessions without a container.
+	Start []string
+	// Adopt maps sessions with a running container not managed by this
+	// process yet to that container.
+	Adopt map[string]string
+	// Stop maps containers to remove, because their session is no longer
+	// declared or they are not running, to their session.
+	Stop map[string]string
+}
+
+func (p Plan) Drift() bool {
+	return len(p.Start) > 0 || len(p.Adopt) > 0 || len(p.Stop) > 0
+}
+
+func (p Plan) String() string {
+	return fmt.Sprintf("start %v, adopt %v, stop %v", p.Start, p.Adopt, p.Stop)
+}
+
+// sessionContainer is a labelled container as found on the Docker host.
+type sessionContainer struct {
+	ID      string
+	Session string
+	Running bool
+}
+
+// plan compares the declared sessions with the labelled containers and those
+// this process already manages.
+func plan(desired []SessionSpec, containers []sessionContainer, managed map[string]bool) Plan {
+	p := Plan{Adopt: make(map[string]string), Stop: make(map[string]string)}
+	declared := make(map[string]bool)
+	for _, spec := range desired {
+		declared[spec.Name] = true
+	}
+	found := make(map[string]bool)
+	for _, c := range containers {
+		switch {
+		case !declared[c.Session] || !c.Running || found[c.Session]:
+			p.Stop[c.ID] = c.Session
+		case !managed[c.Session]:
+			p.Adopt[c.Session] = c.ID
+			found[c.Session] = true
+		default:
+			found[c.Session] = true
+		}
+	}
+	for _, spec := range desired {
+		if !found[spec.Name] {
+			p.Start = append(p.Start, spec.Name)
+		}
+	}
+	sort.Strings(p.Start)
+	return p
+}
+
+// Reconciler keeps the sessions on a Docker host matching a declared set,
+// starting, adopting and stopping ibcontroller containers as needed, and runs
+// a Manager for each.
+type Reconciler struct {
+	// Clock times the passes of Run, default clock.Real. Set it before Run.
+	Clock clock.Clock
+
+	client containerRuntime
+	logger *log.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*managedSession
+}
+
+type managedSession struct {
+	dock    *Dock
+	manager *Manager
+	stop    context.CancelFunc
+}
+
+func NewReconciler(logger *log.Logger) (*Reconciler, error) {
+	client, err := DockerConfig{}.runtime()
+	if err != nil {
+		return nil, err
+	}
+	return &Reconciler{client: client, logger: logger, sessions: make(map[string]*managedSession)}, nil
+}
+
+// Reconcile makes one pass over desired and returns what it did. Managers of
+// the sessions it starts or adopts run until ctx is done.
+func (r *Reconciler) Reconcile(ctx context.Context, desired []SessionSpec) (Plan, error) {
+	listed, err := r.client.list(ctx, true, map[string][]string{"label": {sessionLabel}})
+	if err != nil {
+		return Plan{}, err
+	}
+	var containers []sessionContainer
+	for _, c := range listed {
+		containers = append(containers, sessionContainer{ID: c.ID, Session: c.Labels[sessionLabel], Running: c.Running})
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	managed := make(map[string]bool)
+	for name := range r.sessions {
+		managed[name] = true
+	}
+	p := plan(desired, containers, managed)
+	if p.Drift() {
+		r.logger.Println("Reconciling sessions:", p)
+	}
+
+	var errs []error
+	for id, name := range p.Stop {
+		if session, ok := r.sessions[name]; ok && session.dock.current().ID == id {
+			session.stop()
+			delete(r.sessions, name)
+		}
+		if err := r.client.remove(ctx, id, true); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", name, err))
+		}
+	}
+	// Managed sessions whose container went away are in p.Start, or no
+	// longer declared.
+	for name, session := range r.sessions {
+		if !containsContainer(containers, session.dock.current().ID) {
+			session.stop()
+			delete(r.sessions, name)
+		}
+	}
+	for _, spec := range desired {
+		var dock *Dock
+		var err error
+		opts := append([]Option{WithSessionName(spec.Name)}, spec.Options...)
+		if id, ok := p.Adopt[spec.Name]; ok {
+			dock, err = Attach(id, r.logger, opts...)
+		} else if slices.Contains(p.Start, spec.Name) {
+			dock, err = StartNew(spec.Username, spec.Password, r.logger, opts...)
+		} else {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", spec.Name, err))
+			continue
+		}
+		r.sessions[spec.Name] = r.manage(ctx, dock, spec)
+	}
+	if len(errs) > 0 {
+		return p, fmt.Errorf("reconciling: %v", errs)
+	}
+	return p, nil
+}
+
+// Run reconciles every interval until ctx is done, reading the declared
+// sessions afresh each time. Failed passes are logged and retried.
+func (r *Reconciler) Run(ctx context.Context, desired func() []SessionSpec, interval time.Duration) error {
+	ticker := clock.OrReal(r.Clock).NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Reconcile(ctx, desired()); err != nil {
+			r.logger.Println(err)
+		}
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Reconciler) manage(ctx context.Context, dock *Dock, spec SessionSpec) *managedSession {
+	ctx, stop := context.WithCancel(ctx)
+	manager := NewManager(dock.GetSnapshot, spec.Manager, r.logger)
+	go manager.Run(ctx)
+	return &managedSession{dock: dock, manager: manager, stop: stop}
+}
+
+// Manager returns the Manager of the named session, or nil if it is not
+// running.
+func (r *Reconciler) Manager(name string) *Manager {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[name]; ok {
+		return session.manager
+	}
+	return nil
+}
+
+func containsContainer(containers []sessionContainer, id string) bool {
+	for _, c := range containers {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	desired := []SessionSpec{{Name: "main"}, {Name: "ira"}, {Name: "kids"}}
+	containers := []sessionContainer{
+		{ID: "c1", Session: "main", Running: true},
+		{ID: "c2", Session: "ira", Running: true},
+		{ID: "c3", Session: "old", Running: true},
+		{ID: "c4", Session: "kids", Running: false},
+	}
+	p := plan(desired, containers, map[string]bool{"main": true})
+	want := Plan{
+		Start: []string{"kids"},
+		Adopt: map[string]string{"ira": "c2"},
+		Stop:  map[string]string{"c3": "old", "c4": "kids"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("plan = %v, want %v", p, want)
+	}
+	if p := plan(desired[:1], containers[:1], map[string]bool{"main": true}); p.Drift() {
+		t.Errorf("matching state reported drift: %v", p)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111"})
+	t.Setenv("DOCKER_HOST", server.URL())
+	r, err := NewReconciler(log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	desired := []SessionSpec{{Name: "main"}, {Name: "ira"}}
+
+	p, err := r.Reconcile(ctx, desired)
+	if err != nil || len(p.Start) != 2 {
+		t.Fatalf("first Reconcile = %v, %v; want both started", p, err)
+	}
+	server.Exit("ibcontroller_main", 1)
+	p, err = r.Reconcile(ctx, desired[1:])
+	if err != nil || len(p.Start) != 0 || len(p.Stop) != 1 {
+		t.Errorf("Reconcile after main exited and was undeclared = %v, %v", p, err)
+	}
+	if containers := server.Containers(); len(containers) != 1 || containers[0].Name != "/ibcontroller_ira" {
+		t.Errorf("containers = %+v, want only ira", containers)
+	}
+
+	handle, err := r.Manager("ira").TriggerSnapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := handle.Wait(ctx); err != nil || s.Account != "U1111111" {
+		t.Errorf("snapshot of ira = %v, %v", s, err)
+	}
+}
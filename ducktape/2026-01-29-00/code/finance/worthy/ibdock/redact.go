@@ -0,0 +1,137 @@
+package ibdock
+
+import (
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// minSecretLength is the length below which secrets are not masked, lest
+// every occurrence of a common short string be.
+const minSecretLength = 3
+
+// accountIDPattern matches IB account IDs: individual and advisor accounts,
+// and their paper counterparts, e.g. U1234567, F1234567 and DU1234567.
+var accountIDPattern = regexp.MustCompile(`\b(DU|DF|U|F)(\d{3,})(\d{2})\b`)
+
+// Redactor masks what should not end up in logs and error messages: IB
+// account IDs, which keep their prefix and last two digits to tell accounts
+// apart, e.g. U*****67, and registered secrets such as logins and
+// passwords. A nil Redactor masks nothing.
+//
+// Docks redact their logs and the errors of their methods with one, unless
+// created WithoutRedaction.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// NewRedactor returns a Redactor masking secrets, or nil if
+// $IBDOCK_NO_REDACTION is set, to debug with logs in the clear.
+func NewRedactor(secrets ...string) *Redactor {
+	if os.Getenv("IBDOCK_NO_REDACTION") != "" {
+		return nil
+	}
+	r := new(Redactor)
+	r.Add(secrets...)
+	return r
+}
+
+// Add registers more secrets to mask.
+func (r *Redactor) Add(secrets ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+}
+
+// Redact masks s.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	r.mu.RUnlock()
+	return accountIDPattern.ReplaceAllStringFunc(s, func(id string) string {
+		m := accountIDPattern.FindStringSubmatch(id)
+		return m[1] + strings.Repeat("*", len(m[2])) + m[3]
+	})
+}
+
+// Error returns err with a masked message, which still unwraps to err.
+func (r *Redactor) Error(err error) error {
+	if _, masked := err.(*redactedError); r == nil || err == nil || masked {
+		return err
+	}
+	return &redactedError{err: err, r: r}
+}
+
+type redactedError struct {
+	err error
+	r   *Redactor
+}
+
+func (e *redactedError) Error() string {
+	return e.r.Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Writer returns a writer masking what it writes to w. Each write is masked
+// on its own, which suits a log.Logger's one write per line.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return &redactingWriter{r: r, w: w}
+}
+
+// Logger returns a logger like logger, masking what it writes.
+func (r *Redactor) Logger(logger *log.Logger) *log.Logger {
+	if r == nil || logger == nil {
+		return logger
+	}
+	return log.New(r.Writer(logger.Writer()), logger.Prefix(), logger.Flags())
+}
+
+type redactingWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactError masks *err, deferred by the Dock methods callers get errors
+// from, so that none ends up in their logs in the clear.
+func (dock *Dock) redactError(err *error) {
+	*err = dock.redactor.Error(*err)
+}
+
+// redact makes the Dock mask its logs, and secrets in them, unless created
+// WithoutRedaction.
+func (dock *Dock) redact(secrets ...string) {
+	if dock.noRedaction {
+		return
+	}
+	dock.redactor = NewRedactor(secrets...)
+	dock.logger = dock.redactor.Logger(dock.logger)
+}
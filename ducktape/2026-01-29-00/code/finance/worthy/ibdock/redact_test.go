@@ -0,0 +1,128 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Setenv("IBDOCK_NO_REDACTION", "")
+	r := NewRedactor("jdoe", "hunter2", "ab")
+	for in, want := range map[string]string{
+		"IB_LOGIN_ID=jdoe IB_PASSWORD=hunter2":    "IB_LOGIN_ID=[REDACTED] IB_PASSWORD=[REDACTED]",
+		"asked for U1234567, got DU7654321":       "asked for U*****67, got DU*****21",
+		"F12345678 and DF1234567 manage U1234567": "F******78 and DF*****67 manage U*****67",
+		"U123 is no account, nor is XU1234567":    "U123 is no account, nor is XU1234567",
+		"tab":                                     "tab",
+	} {
+		if got := r.Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+	err := r.Error(&ExitError{Code: 2})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("redacted error does not unwrap: %v", err)
+	}
+	var nilRedactor *Redactor
+	if got := nilRedactor.Redact("U1234567"); got != "U1234567" {
+		t.Errorf("nil Redactor masked %q", got)
+	}
+	t.Setenv("IBDOCK_NO_REDACTION", "1")
+	if NewRedactor() != nil {
+		t.Error("NewRedactor not nil with IBDOCK_NO_REDACTION")
+	}
+}
+
+func TestDockRedaction(t *testing.T) {
+	t.Setenv("IBDOCK_NO_REDACTION", "")
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stdout: []byte(`{"Account": "U1234567", "NetLiquidation": 123456}`), Stderr: []byte("Logged in as jdoe to U1234567\n")}
+	})
+	for _, test := range []struct {
+		opts []Option
+		want []string
+	}{
+		{nil, []string{"Logged in as [REDACTED] to U*****67", "asked for a snapshot of U*****89, got one of U*****67"}},
+		{[]Option{WithoutRedaction()}, []string{"Logged in as jdoe to U1234567", "NetLiquidation", "got one of U1234567"}},
+	} {
+		var logs bytes.Buffer
+		dock, err := StartNew("jdoe", "secret", log.New(&logs, "", 0), append(test.opts, WithDockerEndpoint(server.URL()))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = dock.GetSnapshotFor(t.Context(), "U9876589")
+		dock.Kill()
+		got := logs.String() + "\n" + err.Error()
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%d options: %q missing from\n%s", len(test.opts), want, got)
+			}
+		}
+		if len(test.opts) == 0 && (strings.Contains(got, "NetLiquidation") || strings.Contains(got, "U1234567")) {
+			t.Errorf("not redacted:\n%s", got)
+		}
+	}
+}
+
+func TestErrorRedaction(t *testing.T) {
+	t.Setenv("IBDOCK_NO_REDACTION", "")
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	if err := server.ServeGateway("U1111111", "U2222222"); err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(io.Discard, "", 0)
+	check := func(what string, err error, want string, clear ...string) {
+		t.Helper()
+		if err == nil {
+			t.Errorf("%s did not fail", what)
+			return
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: %q missing from %q", what, want, err)
+		}
+		for _, c := range clear {
+			if strings.Contains(err.Error(), c) {
+				t.Errorf("%s: %s not redacted in %q", what, c, err)
+			}
+		}
+	}
+
+	_, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("hook"), WithStartExec("grep", "-q", "U1234567", "/root/Jts/jts.ini"))
+	check("StartNew", err, "U*****67", "U1234567")
+
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dock.GetAccountSummaryFor(context.Background(), "U9876589")
+	check("GetAccountSummaryFor", err, "account U*****89 is not managed by this login, which manages [U*****11 U*****22]", "U9876589", "U1111111")
+	// The call carries the account in the clear, its error does not.
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Call.Account != "U9876589" || !strings.Contains(callErr.Error(), "account=U*****89") {
+		t.Errorf("GetAccountSummaryFor error %v, call %+v", err, callErr)
+	}
+
+	server.FailNext(ibdocktest.OpExec, http.StatusInternalServerError)
+	_, err = dock.Exec(ContextWithCallID(context.Background(), "sync-U1234567"), []string{"true"}, ExecOptions{})
+	check("Exec", err, "call=sync-U*****67", "U1234567")
+	if !errors.As(err, &callErr) {
+		t.Errorf("redacted Exec error %v does not unwrap to a *CallError", err)
+	}
+
+	spec, err := dock.containerSpec("jdoe", "hunter2")
+	if formatted := fmt.Sprintf("%+v %#v", spec, spec); err != nil || strings.Contains(formatted, "hunter2") || !strings.Contains(strings.Join(spec.env(), " "), "IB_PASSWORD=hunter2") {
+		t.Errorf("containerSpec formats as %s, env %q, %v", formatted, spec.env(), err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package report renders snapshots through user-written text/template
+// layouts, e.g. from the reports section of an ibdock config:
+//
+//	reports:
+//	  summary: |
+//	    {{.Account}} on {{date .Timestamp}}: {{money .Total}} {{.Base}}
+//	    {{range .Positions}}{{.Symbol}}	{{percent .Weight}}
+//	    {{end}}
+//
+// Templates see a Data and can format with the functions of a
+// locale.Printer: money, percent, number, date, month, datetime and t
+// (translate). They cannot read files or run commands.
+package report
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"sort"
+	"text/template"
+	"time"
+)
+
+// Data is what a template renders.
+type Data struct {
+	Account     string
+	AccountName string
+	Timestamp   time.Time
+	// Base is the currency of Rates and all values below.
+	Base  string
+	Rates snapshot.FXRates
+	// Positions are largest by absolute value first.
+	Positions []Position
+	// Total is the net value.
+	Total    snapshot.Decimal
+	Exposure []snapshot.Exposure
+	// Share is the redacted view, see snapshot.Share.
+	Share *snapshot.Share
+	// Snapshot is the snapshot as stored, for anything not above.
+	Snapshot *snapshot.Snapshot
+}
+
+// Position is a snapshot position with its value in the base currency.
+type Position struct {
+	snapshot.Position
+	Value snapshot.Decimal
+	// Weight is Value relative to the net value.
+	Weight float64
+}
+
+// NewData values s in base at rates.
+func NewData(s *snapshot.Snapshot, base string, rates snapshot.FXRates) (*Data, error) {
+	converted, err := s.ConvertTo(base, rates)
+	if err != nil {
+		return nil, err
+	}
+	exposure, err := s.CurrencyExposure(rates)
+	if err != nil {
+		return nil, err
+	}
+	share, err := s.Share(rates)
+	if err != nil {
+		return nil, err
+	}
+	data := &Data{
+		Account:     s.Account,
+		AccountName: s.AccountName,
+		Timestamp:   s.Timestamp,
+		Base:        base,
+		Rates:       rates,
+		Total:       converted.Total,
+		Exposure:    exposure,
+		Share:       share,
+		Snapshot:    s,
+	}
+	for _, p := range converted.Positions {
+		position := Position{Position: p.Position, Value: p.Value}
+		if !converted.Total.IsZero() {
+			position.Weight = p.Value.Float64() / converted.Total.Float64()
+		}
+		data.Positions = append(data.Positions, position)
+	}
+	sort.SliceStable(data.Positions, func(i, j int) bool {
+		return data.Positions[i].Value.Abs().Cmp(data.Positions[j].Value.Abs()) > 0
+	})
+	return data, nil
+}
+
+// Template is a parsed report layout.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses a layout, formatting values with p.
+func Parse(name, text string, p locale.Printer) (*Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"money":    decimals(p.Money, p.MoneyDecimal),
+		"percent":  decimals(p.Percent, nil),
+		"number":   decimals(p.Number, nil),
+		"date":     p.Date,
+		"month":    p.Month,
+		"datetime": p.DateTime,
+		"t":        p.T,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("report %s: %w", name, err)
+	}
+	return &Template{tmpl}, nil
+}
+
+// decimals lets a Printer function format snapshot.Decimals, e.g. a
+// position's MarketValue, as well as floats. Decimals go to exact if given,
+// so amounts are rounded as they are rather than as their float64.
+func decimals(format func(float64) string, exact func(snapshot.Decimal) string) func(any) (string, error) {
+	return func(x any) (string, error) {
+		switch x := x.(type) {
+		case float64:
+			return format(x), nil
+		case int:
+			return format(float64(x)), nil
+		case snapshot.Decimal:
+			if exact != nil {
+				return exact(x), nil
+			}
+			return format(x.Float64()), nil
+		}
+		return "", fmt.Errorf("cannot format %T as a number", x)
+	}
+}
+
+// Render writes the report of data to w.
+func (t *Template) Render(w io.Writer, data *Data) error {
+	if err := t.tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("report %s: %w", t.tmpl.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package report
+
+import (
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	s := &snapshot.Snapshot{Account: "U1111111", Timestamp: time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC), Positions: []snapshot.Position{
+		{Symbol: "EUR", SecType: "CASH", Currency: "EUR", Quantity: snapshot.DecimalFromFloat(500)},
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.DecimalFromFloat(10), AvgCost: snapshot.DecimalFromFloat(100), MarketPrice: snapshot.DecimalFromFloat(140), MarketValue: snapshot.DecimalFromFloat(1400)},
+	}}
+	data, err := NewData(s, "USD", snapshot.FXRates{"USD": 1, "EUR": 1.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		locale *locale.Locale
+		want   string
+	}{
+		{locale.English, "U1111111 2026-03-07 2000.00\nVT 70.00% 10\nEUR 30.00% 500\n"},
+		{locale.Czech, "U1111111 7. 3. 2026 2\u00a0000,00\nVT 70,00\u00a0% 10\nEUR 30,00\u00a0% 500\n"},
+	} {
+		tmpl, err := Parse("test", "{{.Account}} {{date .Timestamp}} {{money .Total}}\n{{range .Positions}}{{.Symbol}} {{percent .Weight}} {{number .Quantity}}\n{{end}}", locale.Printer{Policy: rounding.Default, Locale: test.locale})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := tmpl.Render(&out, data); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: rendered %q, want %q", test.locale.Name, out.String(), test.want)
+		}
+	}
+
+	if _, err := Parse("bad", "{{.Account", locale.Printer{}); err == nil {
+		t.Errorf("Parse of an unclosed action succeeded")
+	}
+	tmpl, err := Parse("missing", "{{.Balance}}", locale.Printer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Render(&strings.Builder{}, data); err == nil || !strings.Contains(err.Error(), "report missing") {
+		t.Errorf("Render of an unknown field = %v", err)
+	}
+}
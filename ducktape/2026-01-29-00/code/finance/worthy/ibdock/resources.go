@@ -0,0 +1,123 @@
+package ibdock
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Default container limits, so a misbehaving gateway takes down its own
+// container rather than the host. Negative values passed to the options
+// lift a limit.
+const (
+	// TWS and IB Gateway run in about 1 GiB of JVM heap; the rest is
+	// headroom for Xvfb and the snapshot scripts.
+	defaultMemoryLimit = 2 << 30
+	// Half of Docker's default weight, so the host's own processes win
+	// when the CPU is contended.
+	defaultCPUShares = 512
+	// Far above the few hundred threads a busy gateway uses, low enough to
+	// stop a runaway fork.
+	defaultPidsLimit = 1024
+	// Docker does not restart the container by default: a failed login
+	// exits it, and restarting would retry bad credentials until IB locks
+	// the account. Manager already replaces sessions that keep failing.
+	defaultRestartPolicy = "no"
+)
+
+// restartPolicy is a parsed Docker restart policy.
+type restartPolicy struct {
+	// Name is "no", "always", "unless-stopped" or "on-failure".
+	Name string
+	// MaxRetries bounds "on-failure" restarts; zero means no bound.
+	MaxRetries int
+}
+
+// parseRestartPolicy parses a policy as given to docker run --restart, e.g.
+// "on-failure:3".
+func parseRestartPolicy(policy string) (restartPolicy, error) {
+	name, retries, hasRetries := strings.Cut(policy, ":")
+	switch name {
+	case "no", "always", "unless-stopped":
+		if hasRetries {
+			return restartPolicy{}, fmt.Errorf("restart policy %q: only on-failure takes a retry count", policy)
+		}
+	case "on-failure":
+		if hasRetries {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return restartPolicy{}, fmt.Errorf("restart policy %q: bad retry count", policy)
+			}
+			return restartPolicy{Name: name, MaxRetries: n}, nil
+		}
+	default:
+		return restartPolicy{}, fmt.Errorf("unknown restart policy %q, want no, always, unless-stopped or on-failure[:N]", policy)
+	}
+	return restartPolicy{Name: name}, nil
+}
+
+// resolveLimit resolves a limit set by an option: zero is the default,
+// negative none.
+func resolveLimit(value, def int64) int64 {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// containerSpec is the container StartNew creates.
+func (dock *Dock) containerSpec(username, password string) (containerSpec, error) {
+	policy := dock.restartPolicy
+	if policy == "" {
+		policy = defaultRestartPolicy
+	}
+	restart, err := parseRestartPolicy(policy)
+	if err != nil {
+		return containerSpec{}, err
+	}
+	if err := dock.variant.check(); err != nil {
+		return containerSpec{}, err
+	}
+	if dock.platform != "" {
+		if err := checkPlatform(dock.platform); err != nil {
+			return containerSpec{}, err
+		}
+	}
+	binding, err := dock.apiBinding()
+	if err != nil {
+		return containerSpec{}, err
+	}
+	action, err := dock.sessionConflict.ibcAction()
+	if err != nil {
+		return containerSpec{}, err
+	}
+	env := append(buildEnv(dock.tradingMode), "IBDOCK_EXISTING_SESSION="+action)
+	labels := dock.labels()
+	if dock.accountLock {
+		labels = maps.Clone(labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[loginLabel] = loginHash(username)
+	}
+	return containerSpec{
+		Name:           dock.containerName(),
+		Image:          dock.imageRef(),
+		Env:            append(env, dock.vncEnv()...),
+		Credentials:    credentialEnv(username, password),
+		Labels:         labels,
+		Memory:         resolveLimit(dock.memoryLimit, defaultMemoryLimit),
+		CPUShares:      resolveLimit(dock.cpuShares, defaultCPUShares),
+		PidsLimit:      resolveLimit(dock.pidsLimit, defaultPidsLimit),
+		Restart:        restart,
+		Network:        dock.network,
+		APIBinding:     binding,
+		SettingsVolume: dock.settingsVolume,
+		DebugVNC:       dock.debugVNC,
+		HealthCheck:    dock.healthConfig(),
+	}, nil
+}
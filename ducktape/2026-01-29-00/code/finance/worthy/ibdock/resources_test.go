@@ -0,0 +1,57 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy string
+		want   restartPolicy
+	}{
+		{"no", restartPolicy{Name: "no"}},
+		{"unless-stopped", restartPolicy{Name: "unless-stopped"}},
+		{"on-failure", restartPolicy{Name: "on-failure"}},
+		{"on-failure:3", restartPolicy{Name: "on-failure", MaxRetries: 3}},
+	} {
+		if got, err := parseRestartPolicy(test.policy); err != nil || got != test.want {
+			t.Errorf("parseRestartPolicy(%q) = %+v, %v; want %+v", test.policy, got, err, test.want)
+		}
+	}
+	for _, policy := range []string{"", "sometimes", "always:3", "on-failure:-1", "on-failure:x"} {
+		if _, err := parseRestartPolicy(policy); err == nil {
+			t.Errorf("parseRestartPolicy(%q) succeeded", policy)
+		}
+	}
+}
+
+func TestResourceLimits(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, backend := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sdk", nil},
+		{"legacy", []Option{WithLegacyDockerClient()}},
+	} {
+		server := ibdocktest.NewServer()
+		defer server.Close()
+		opts := append(backend.opts, WithDockerEndpoint(server.URL()), WithMemoryLimit(-1), WithRestartPolicy("on-failure:2"))
+		if _, err := StartNew("jdoe", "secret", logger, opts...); err != nil {
+			t.Fatalf("%s: %v", backend.name, err)
+		}
+		host := server.Containers()[0].HostConfig
+		if host.Memory != 0 || host.CPUShares != defaultCPUShares || host.PidsLimit == nil || *host.PidsLimit != defaultPidsLimit {
+			t.Errorf("%s: limits = memory %d, cpu shares %d, pids %v", backend.name, host.Memory, host.CPUShares, host.PidsLimit)
+		}
+		if policy := host.RestartPolicy; policy.Name != "on-failure" || policy.MaximumRetryCount != 2 {
+			t.Errorf("%s: restart policy = %+v", backend.name, policy)
+		}
+		if _, err := StartNew("jdoe", "secret", logger, append(opts, WithRestartPolicy("sometimes"))...); err == nil {
+			t.Errorf("%s: StartNew with a bad restart policy succeeded", backend.name)
+		}
+	}
+}
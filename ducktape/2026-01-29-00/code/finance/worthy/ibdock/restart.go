@@ -0,0 +1,63 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// settingsDir is where the image's entrypoint keeps the gateway's settings
+// if it exists, see imagebuild; WithSettingsVolume mounts a volume there.
+const settingsDir = "/root/tws_settings"
+
+// ErrRestartBudget is returned once the container died more often than
+// WithAutoRestart allows.
+var ErrRestartBudget = errors.New("container restart budget exhausted")
+
+// Restarts is how often the container was recreated after it died, see
+// WithAutoRestart.
+func (dock *Dock) Restarts() int {
+	return int(dock.restarts.Load())
+}
+
+// ensureRunning recreates the container if it died and WithAutoRestart
+// allows another restart, and waits for the new gateway to log in. Docks
+// from Attach have no credentials to restart with and are left alone.
+func (dock *Dock) ensureRunning(ctx context.Context) error {
+	if dock.autoRestarts <= 0 || dock.spec == nil {
+		return nil
+	}
+	dock.restartMu.Lock()
+	defer dock.restartMu.Unlock()
+	current := dock.current()
+	container, err := dock.client.inspect(ctx, current.ID)
+	switch {
+	case isNoSuchContainer(err):
+		container.Status = "removed"
+	case err != nil:
+		return err
+	case container.Running || container.Restarting:
+		// A restart policy may already be bringing it back.
+		return nil
+	}
+	if dock.Restarts() >= dock.autoRestarts {
+		return fmt.Errorf("container %s %s after %d restarts: %w", current.ID, container.Status, dock.Restarts(), ErrRestartBudget)
+	}
+	dock.logger.Printf("Container %s %s, recreating it", current.ID, container.Status)
+	if err := dock.client.remove(ctx, current.ID, true); err != nil && !isNoSuchContainer(err) {
+		return err
+	}
+	id, err := dock.client.create(ctx, *dock.spec)
+	if err != nil {
+		return err
+	}
+	dead := current.ID
+	dock.setContainer(containerInfo{ID: id})
+	dock.restarts.Add(1)
+	dock.emit(Event{Kind: EventCreated})
+	if err := dock.startContainer(ctx, id); err != nil {
+		return err
+	}
+	dock.emit(Event{Kind: EventRestarted, Err: fmt.Errorf("container %s %s", dead, container.Status)})
+	return dock.WaitReady(ctx)
+}
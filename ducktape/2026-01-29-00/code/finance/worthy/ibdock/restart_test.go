@@ -0,0 +1,78 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAutoRestart(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("main"), WithSettingsVolume("ib-main"), WithAutoRestart(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := dock.ContainerID()
+	server.Exit(first, 1)
+
+	// The new gateway never logs in here, so the exec gives up waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := dock.Exec(ctx, []string{"true"}, ExecOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Exec after the crash = %v, want it to wait for the login", err)
+	}
+	if dock.Restarts() != 1 || dock.ContainerID() == first {
+		t.Fatalf("Restarts = %d, container %s", dock.Restarts(), dock.ContainerID())
+	}
+	containers := server.Containers()
+	if len(containers) != 1 || containers[0].ID != dock.ContainerID() || containers[0].Name != "/ibcontroller_main" || !containers[0].State.Running {
+		t.Fatalf("containers after restart: %+v", containers)
+	}
+	if !slices.Contains(containers[0].Config.Env, "IB_PASSWORD=secret") || !slices.Equal(containers[0].HostConfig.Binds, []string{"ib-main:" + settingsDir}) {
+		t.Errorf("recreated with env %v, binds %v", containers[0].Config.Env, containers[0].HostConfig.Binds)
+	}
+
+	server.Exit(dock.ContainerID(), 1)
+	if _, err := dock.Exec(context.Background(), []string{"true"}, ExecOptions{}); !errors.Is(err, ErrRestartBudget) {
+		t.Errorf("Exec past the budget = %v, want ErrRestartBudget", err)
+	}
+}
+
+// TestAutoRestartConcurrent checks, under the race detector, that replacing
+// the container is safe while other goroutines use the Dock.
+func TestAutoRestartConcurrent(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithAutoRestart(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Exit(dock.ContainerID(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dock.Exec(ctx, []string{"true"}, ExecOptions{})
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			dock.APIEndpoint()
+			dock.ContainerID()
+		}
+	}
+	if dock.Restarts() != 1 {
+		t.Errorf("Restarts = %d, want 1", dock.Restarts())
+	}
+}
@@ -0,0 +1,198 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/moby/moby/client"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy says how often and how patiently to retry failed calls. Zero
+// fields mean the defaults.
+type RetryPolicy struct {
+	// MaxAttempts counts the first try, default 3.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, default 1s. Each
+	// further wait is Multiplier (default 2) times longer, up to MaxBackoff
+	// (default 1m).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction by which each wait is randomly shortened or
+	// lengthened, so sessions failing together do not retry together.
+	// Default 0.2; set it negative for none.
+	Jitter float64
+	// Retryable tells transient errors from fatal ones, default Retryable.
+	Retryable func(error) bool
+	// Clock is what the waits are timed by, default clock.Real.
+	Clock clock.Clock
+}
+
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff, multiplier, maxBackoff, jitter := p.InitialBackoff, p.Multiplier, p.MaxBackoff, p.Jitter
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+	if jitter == 0 {
+		jitter = 0.2
+	}
+	wait := float64(backoff)
+	for i := 0; i < retry && wait < float64(maxBackoff); i++ {
+		wait *= multiplier
+	}
+	wait = min(wait, float64(maxBackoff))
+	if jitter > 0 {
+		wait *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+// attemptKey is the context key under which Do passes op the number of its
+// attempt, for ExecReport.Retries.
+type attemptKey struct{}
+
+// retries returns how many attempts of a Do enclosing ctx failed already.
+func retries(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return max(attempt-1, 0)
+}
+
+// Do calls op until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or ctx is done, and returns op's last error. After a
+// *PacingError it waits at least the error's RetryAfter. Use it to retry
+// StartNew, which there is no Session for yet.
+func (p RetryPolicy) Do(ctx context.Context, op func(context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = Retryable
+	}
+	for attempt := 1; ; attempt++ {
+		err := op(context.WithValue(ctx, attemptKey{}, attempt))
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		wait := p.backoff(attempt - 1)
+		var pacingErr *PacingError
+		if errors.As(err, &pacingErr) {
+			wait = max(wait, pacingErr.RetryAfter)
+		}
+		select {
+		case <-clock.OrReal(p.Clock).After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// Retryable reports whether err is likely transient: Docker daemon
+// unavailability and server errors, network timeouts and refused or reset
+// connections, IB pacing and connectivity errors, and snapshot scripts timing
+// out or killed while the gateway (re)connects. Everything else, e.g. a
+// missing container, a script failing on its own, a snapshot that does not
+// decode or fails validation, or a cancelled or expired context, is fatal:
+// trying again would only spend IB's pacing budget.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pacingErr *PacingError
+	if errors.As(err, &pacingErr) {
+		return true
+	}
+	var twsErr *twsapi.Error
+	if errors.As(err, &twsErr) {
+		switch twsErr.Code {
+		case 100, // max rate of messages per second exceeded
+			162,  // historical data pacing violation
+			420,  // invalid real-time query, usually pacing
+			502,  // could not connect to TWS
+			504,  // not connected
+			1100, // connectivity between IB and TWS lost
+			1300: // socket port reset
+			return true
+		}
+		return false
+	}
+	var dockerErr *docker.Error
+	if errors.As(err, &dockerErr) {
+		return dockerErr.Status >= 500 || dockerErr.Status == 429
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return transientExitCodes[exitErr.Code]
+	}
+	var netErr net.Error
+	return errors.Is(err, docker.ErrConnectionRefused) ||
+		client.IsErrConnectionFailed(err) ||
+		cerrdefs.IsUnavailable(err) ||
+		cerrdefs.IsInternal(err) ||
+		cerrdefs.IsResourceExhausted(err) ||
+		errors.Is(err, ErrSnapshotTimeout) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// transientExitCodes are those of commands killed by SIGKILL or SIGTERM, as
+// when the gateway restarts under them, rather than failing on their own.
+var transientExitCodes = map[int]bool{137: true, 143: true}
+
+type retrySession struct {
+	Session
+	policy RetryPolicy
+}
+
+// WithRetry wraps session so GetSnapshot, GetAccountSummary and GetFXRates
+// are retried according to policy. Exec is not, as commands need not be
+// idempotent.
+func WithRetry(session Session, policy RetryPolicy) Session {
+	return &retrySession{session, policy}
+}
+
+func (r *retrySession) GetSnapshot(ctx context.Context) (*snapshot.Snapshot, error) {
+	var s *snapshot.Snapshot
+	err := r.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		s, err = r.Session.GetSnapshot(ctx)
+		return err
+	})
+	return s, err
+}
+
+func (r *retrySession) GetAccountSummary(ctx context.Context) (AccountSummary, error) {
+	var summary AccountSummary
+	err := r.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		summary, err = r.Session.GetAccountSummary(ctx)
+		return err
+	})
+	return summary, err
+}
+
+func (r *retrySession) GetFXRates(ctx context.Context, base string, currencies []string) (snapshot.FXRates, error) {
+	var rates snapshot.FXRates
+	err := r.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		rates, err = r.Session.GetFXRates(ctx, base, currencies)
+		return err
+	})
+	return rates, err
+}
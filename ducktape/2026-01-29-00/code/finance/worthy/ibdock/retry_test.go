@@ -0,0 +1,134 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/fsouza/go-dockerclient"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakySession fails GetSnapshot with errs in turn before succeeding.
+type flakySession struct {
+	*MockDock
+	errs  []error
+	calls int
+}
+
+func (f *flakySession) GetSnapshot(ctx context.Context) (*snapshot.Snapshot, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return f.MockDock.GetSnapshot(ctx)
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	pacing := &twsapi.Error{Code: 100, Message: "Max rate of messages per second has been exceeded"}
+	for _, test := range []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"transient", []error{fmt.Errorf("reading: %w", pacing), &docker.Error{Status: 503}}, 3, false},
+		{"transient sdk", []error{fmt.Errorf("inspect: %w", cerrdefs.ErrUnavailable)}, 2, false},
+		{"attempts exhausted", []error{ErrSnapshotTimeout, ErrSnapshotTimeout, ErrSnapshotTimeout}, 3, true},
+		{"fatal", []error{&docker.NoSuchContainer{ID: "gone"}}, 1, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			flaky := &flakySession{MockDock: NewMockDock(&snapshot.Snapshot{Account: "U1111111"}), errs: test.errs}
+			_, err := WithRetry(flaky, policy).GetSnapshot(ctx)
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error %v", err, test.wantErr)
+			}
+			if flaky.calls != test.wantCalls {
+				t.Errorf("%d calls, want %d", flaky.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"no such host", &url.Error{Op: "Get", URL: "http://worthy", Err: &net.DNSError{Err: "no such host", Name: "worthy", IsNotFound: true}}, false},
+		{"cancelled request", &url.Error{Op: "Get", URL: "http://worthy", Err: context.Canceled}, false},
+		{"deadline exceeded", fmt.Errorf("snapshot: %w", context.DeadlineExceeded), false},
+		{"script failed", &ExitError{Code: 1}, false},
+		{"script killed", &ExitError{Code: 137}, true},
+		{"script paced", &PacingError{Err: &ExitError{Code: 1}}, true},
+		{"snapshot timeout", ErrSnapshotTimeout, true},
+		{"invalid snapshot", &snapshot.ValidationError{Violations: []string{"no positions"}}, false},
+		{"unknown", errors.New("unknown"), false},
+	} {
+		if got := Retryable(test.err); got != test.want {
+			t.Errorf("%s: Retryable(%v) = %v, want %v", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: -1}
+	var waits []time.Duration
+	for retry := range 4 {
+		waits = append(waits, policy.backoff(retry))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("waits = %v, want %v", waits, want)
+			break
+		}
+	}
+	policy.Jitter = 0.5
+	for range 100 {
+		if wait := policy.backoff(0); wait < 500*time.Millisecond || wait > 1500*time.Millisecond {
+			t.Fatalf("jittered wait %v out of range", wait)
+		}
+	}
+}
+
+func TestDoClock(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, Jitter: -1, Clock: fake}
+	attempts := make(chan int, 3)
+	done := make(chan error, 1)
+	go func() {
+		done <- policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts <- retries(ctx)
+			return ErrSnapshotTimeout
+		})
+	}()
+	<-attempts
+	for _, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		fake.BlockUntil(1)
+		fake.Advance(wait - time.Second)
+		select {
+		case <-attempts:
+			t.Fatalf("retried %v early", time.Second)
+		default:
+		}
+		fake.Advance(time.Second)
+		<-attempts
+	}
+	if err := <-done; err != ErrSnapshotTimeout {
+		t.Errorf("Do = %v, want the last error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"time"
+)
+
+// volumeWait is how long AssessRisk waits for IB to report average volumes.
+const volumeWait = 10 * time.Second
+
+// AssessRisk checks s against limits, see snapshot.Risk, with current FX rates
+// and the average volumes of its stocks. Stocks IB reports no volume for are
+// not checked for liquidity.
+func (dock *Dock) AssessRisk(ctx context.Context, s *snapshot.Snapshot, limits snapshot.RiskLimits) (_ []snapshot.RiskFlag, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, s.Account)
+	defer call.wrapError(&err)
+	client, err := dock.dialAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	currencies := s.Currencies()
+	if len(currencies) == 0 {
+		return nil, nil
+	}
+	// Weights do not depend on the base currency, any will do.
+	rates, err := fxRates(ctx, client, currencies[0], currencies)
+	if err != nil {
+		return nil, classifyPacing(err)
+	}
+	var stocks []twsapi.Contract
+	for _, p := range s.Positions {
+		if p.SecType == "STK" {
+			stocks = append(stocks, twsapi.Contract{Symbol: p.Symbol, SecType: p.SecType, Exchange: "SMART", Currency: p.Currency})
+		}
+	}
+	volumes := make(map[string]float64)
+	for start := 0; start < len(stocks); start += defaultBatchSize {
+		batch, err := client.AverageVolumes(ctx, stocks[start:min(start+defaultBatchSize, len(stocks))], volumeWait)
+		if err != nil {
+			return nil, classifyPacing(err)
+		}
+		for _, volume := range batch {
+			if volume.Err != nil {
+				dock.logger.Println("No average volume for", volume.Contract.Symbol+":", volume.Err)
+				continue
+			}
+			volumes[volume.Contract.Symbol] = volume.Average
+		}
+	}
+	return s.Risk(limits, rates, volumes)
+}
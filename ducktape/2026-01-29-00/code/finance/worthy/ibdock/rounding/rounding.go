@@ -0,0 +1,142 @@
+// Package rounding is the one place deciding how values are rounded for
+// people and other programs, so exports, reports and the API agree:
+//
+//	policy := rounding.Policy{Places: 2, Mode: rounding.HalfEven}
+//	policy.Format(2.675) // "2.68"
+//	policy.Format(2.665) // "2.66"
+//
+// Values are rounded as the decimals they print as, not as their binary
+// approximation, which is what accountants expect: with %.2f, 2.675 would
+// become 2.67.
+package rounding
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+type Mode int
+
+const (
+	// HalfUp rounds halves away from zero, as usual for display.
+	HalfUp Mode = iota
+	// HalfEven rounds halves to the even neighbour (banker's rounding), so
+	// rounding errors do not add up over many values.
+	HalfEven
+	// Down truncates towards zero.
+	Down
+)
+
+var modeNames = map[Mode]string{
+	HalfUp:   "half-up",
+	HalfEven: "half-even",
+	Down:     "down",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode parses the name String gives a Mode. "bankers" is accepted for
+// HalfEven.
+func ParseMode(name string) (Mode, error) {
+	if name == "bankers" {
+		return HalfEven, nil
+	}
+	for m, n := range modeNames {
+		if n == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("rounding mode %q, want half-up, half-even or down", name)
+}
+
+// Policy rounds to Places decimal places in Mode.
+type Policy struct {
+	Places int
+	Mode   Mode
+}
+
+// Default is what reports used before policies were configurable.
+var Default = Policy{Places: 2, Mode: HalfUp}
+
+// Round rounds x. NaNs and infinities are returned as they are.
+func (p Policy) Round(x float64) float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) || p.Places < 0 {
+		return x
+	}
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(x, 'g', -1, 64))
+	if !ok {
+		return x
+	}
+	rounded, _ := p.round(r).Float64()
+	if rounded == 0 {
+		// Keep -0.001 from printing as "-0.00".
+		return 0
+	}
+	return rounded
+}
+
+// RoundDecimal rounds x exactly, without going through float64. Should the
+// result not fit a Decimal, x is returned as it is.
+func (p Policy) RoundDecimal(x snapshot.Decimal) snapshot.Decimal {
+	if p.Places < 0 {
+		return x
+	}
+	r, ok := new(big.Rat).SetString(x.String())
+	if !ok {
+		return x
+	}
+	rounded, err := snapshot.ParseDecimal(p.round(r).FloatString(p.Places))
+	if err != nil {
+		return x
+	}
+	return rounded
+}
+
+// round rounds r to Places decimals in Mode.
+func (p Policy) round(r *big.Rat) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(p.Places)), nil)
+	r = new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && p.Mode != Down {
+		// Compare the dropped fraction with one half.
+		half := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom())
+		if half > 0 || (half == 0 && (p.Mode == HalfUp || q.Bit(0) == 1)) {
+			q.Add(q, big.NewInt(int64(rem.Sign())))
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// Format rounds x and prints it with exactly Places decimals.
+func (p Policy) Format(x float64) string {
+	return strconv.FormatFloat(p.Round(x), 'f', max(p.Places, 0), 64)
+}
+
+// FormatDecimal is Format for an exact x.
+func (p Policy) FormatDecimal(x snapshot.Decimal) string {
+	r, ok := new(big.Rat).SetString(p.RoundDecimal(x).String())
+	if !ok {
+		return x.String()
+	}
+	return r.FloatString(max(p.Places, 0))
+}
+
+// Percent formats the fraction x, e.g. 0.25, as a percentage to Places
+// decimals, e.g. "25.00%".
+func (p Policy) Percent(x float64) string {
+	return p.Format(100*x) + "%"
+}
+
+// RoundFraction rounds the fraction x so that it is exact as a percentage to
+// Places decimals: fractions get two more places than amounts.
+func (p Policy) RoundFraction(x float64) float64 {
+	return Policy{Places: p.Places + 2, Mode: p.Mode}.Round(x)
+}
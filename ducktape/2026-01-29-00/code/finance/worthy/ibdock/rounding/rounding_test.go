@@ -0,0 +1,82 @@
+package rounding
+
+import (
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"math"
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	for _, test := range []struct {
+		policy Policy
+		x      float64
+		want   string
+	}{
+		{Policy{2, HalfUp}, 2.675, "2.68"},
+		{Policy{2, HalfUp}, -2.675, "-2.68"},
+		{Policy{2, HalfUp}, 2.674999, "2.67"},
+		{Policy{2, HalfEven}, 2.675, "2.68"},
+		{Policy{2, HalfEven}, 2.665, "2.66"},
+		{Policy{2, HalfEven}, -2.665, "-2.66"},
+		{Policy{0, HalfEven}, 0.5, "0"},
+		{Policy{0, HalfEven}, 1.5, "2"},
+		{Policy{2, Down}, 2.679, "2.67"},
+		{Policy{2, Down}, -2.679, "-2.67"},
+		{Policy{2, HalfUp}, -0.001, "0.00"},
+		{Policy{4, HalfUp}, 1e-7, "0.0000"},
+		{Policy{2, HalfUp}, 1234567.895, "1234567.90"},
+	} {
+		if got := test.policy.Format(test.x); got != test.want {
+			t.Errorf("%+v.Format(%v) = %s, want %s", test.policy, test.x, got, test.want)
+		}
+	}
+	if got := Default.Round(math.Inf(-1)); !math.IsInf(got, -1) {
+		t.Errorf("Round(-Inf) = %v", got)
+	}
+	if got := (Policy{1, HalfEven}).RoundFraction(0.12345); got != 0.123 {
+		t.Errorf("RoundFraction = %v, want 0.123", got)
+	}
+}
+
+func TestRoundDecimal(t *testing.T) {
+	for _, test := range []struct {
+		policy Policy
+		x      string
+		want   string
+	}{
+		{Policy{2, HalfUp}, "2.675", "2.68"},
+		{Policy{2, HalfEven}, "-2.665", "-2.66"},
+		{Policy{2, Down}, "-2.679", "-2.67"},
+		{Policy{2, HalfUp}, "-0.001", "0.00"},
+		{Policy{2, HalfUp}, "1.5e3", "1500.00"},
+		// More digits than a float64 holds: through float64 this would
+		// be 90071992547409.92.
+		{Policy{2, HalfUp}, "90071992547409.925", "90071992547409.93"},
+	} {
+		x, err := snapshot.ParseDecimal(test.x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := test.policy.FormatDecimal(x); got != test.want {
+			t.Errorf("%+v.FormatDecimal(%s) = %s, want %s", test.policy, test.x, got, test.want)
+		}
+	}
+	x, _ := snapshot.ParseDecimal("2.675")
+	if got := (Policy{Places: -1}).RoundDecimal(x); got != x {
+		t.Errorf("RoundDecimal with negative places = %v, want %v", got, x)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{HalfUp, HalfEven, Down} {
+		if got, err := ParseMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %v, %v", m, got, err)
+		}
+	}
+	if got, err := ParseMode("bankers"); err != nil || got != HalfEven {
+		t.Errorf("ParseMode(bankers) = %v, %v", got, err)
+	}
+	if _, err := ParseMode("up"); err == nil {
+		t.Errorf("ParseMode(up) succeeded")
+	}
+}
@@ -0,0 +1,170 @@
+// Package rpcserver serves ibdock sessions over gRPC (see ibdockpb), so other
+// services can read IB data without linking Docker client code or holding
+// credentials:
+//
+//	server := rpcserver.New(map[string]rpcserver.Credentials{
+//	  "retirement": {Username: "...", Password: "..."},
+//	}, logger)
+//	s := grpc.NewServer()
+//	ibdockpb.RegisterIbdockServer(s, server)
+//	err := s.Serve(listener)
+package rpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/ibdockpb"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+	"sync"
+)
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+type Server struct {
+	ibdockpb.UnimplementedIbdockServer
+	credentials map[string]Credentials
+	logger      *log.Logger
+	start       func(Credentials) (ibdock.Session, error)
+
+	mu       sync.Mutex
+	sessions map[string]ibdock.Session
+}
+
+// New returns a server that starts sessions for the accounts in credentials,
+// keyed by the names clients use in StartSession.
+func New(credentials map[string]Credentials, logger *log.Logger) *Server {
+	return &Server{
+		credentials: credentials,
+		logger:      logger,
+		start: func(c Credentials) (ibdock.Session, error) {
+			return ibdock.StartNew(c.Username, c.Password, logger)
+		},
+		sessions: make(map[string]ibdock.Session),
+	}
+}
+
+func (s *Server) StartSession(ctx context.Context, request *ibdockpb.StartSessionRequest) (*ibdockpb.StartSessionResponse, error) {
+	credentials, ok := s.credentials[request.Account]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no credentials for account %q", request.Account)
+	}
+	dock, err := s.start(credentials)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "starting session: %v", err)
+	}
+	id := newSessionID()
+	s.mu.Lock()
+	s.sessions[id] = dock
+	s.mu.Unlock()
+	s.logger.Println("Started session", id, "for", request.Account)
+	return &ibdockpb.StartSessionResponse{SessionId: id}, nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) session(id string) (ibdock.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dock, ok := s.sessions[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no session %q", id)
+	}
+	return dock, nil
+}
+
+func (s *Server) GetSnapshot(ctx context.Context, request *ibdockpb.GetSnapshotRequest) (*ibdockpb.Snapshot, error) {
+	dock, err := s.session(request.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := dock.GetSnapshot(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "reading snapshot: %v", err)
+	}
+	return snapshotProto(snap), nil
+}
+
+func (s *Server) GetAccountSummary(ctx context.Context, request *ibdockpb.GetAccountSummaryRequest) (*ibdockpb.AccountSummary, error) {
+	dock, err := s.session(request.SessionId)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := dock.GetAccountSummary(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "reading account summary: %v", err)
+	}
+	return &ibdockpb.AccountSummary{
+		Account:         summary.Account,
+		BaseCurrency:    summary.BaseCurrency,
+		NetLiquidation:  summary.NetLiquidation,
+		TotalCashValue:  summary.TotalCashValue,
+		Cash:            summary.Cash,
+		BuyingPower:     summary.BuyingPower,
+		AvailableFunds:  summary.AvailableFunds,
+		ExcessLiquidity: summary.ExcessLiquidity,
+		InitMarginReq:   summary.InitMarginReq,
+		MaintMarginReq:  summary.MaintMarginReq,
+	}, nil
+}
+
+func (s *Server) StopSession(ctx context.Context, request *ibdockpb.StopSessionRequest) (*ibdockpb.StopSessionResponse, error) {
+	s.mu.Lock()
+	dock, ok := s.sessions[request.SessionId]
+	delete(s.sessions, request.SessionId)
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no session %q", request.SessionId)
+	}
+	dock.Kill()
+	s.logger.Println("Stopped session", request.SessionId)
+	return &ibdockpb.StopSessionResponse{}, nil
+}
+
+// Close stops all sessions.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, dock := range s.sessions {
+		dock.Kill()
+		delete(s.sessions, id)
+	}
+}
+
+func snapshotProto(snap *snapshot.Snapshot) *ibdockpb.Snapshot {
+	pb := &ibdockpb.Snapshot{
+		Account:     snap.Account,
+		AccountName: snap.AccountName,
+		Timestamp:   timestamppb.New(snap.Timestamp),
+	}
+	for _, p := range snap.Positions {
+		pb.Positions = append(pb.Positions, &ibdockpb.Position{
+			Symbol:   p.Symbol,
+			SecType:  p.SecType,
+			Currency: p.Currency,
+			// The doubles are for clients predating the exact fields.
+			Quantity:           p.Quantity.Float64(),
+			AvgCost:            p.AvgCost.Float64(),
+			MarketPrice:        p.MarketPrice.Float64(),
+			MarketValue:        p.MarketValue.Float64(),
+			InTransfer:         p.InTransfer,
+			QuantityDecimal:    p.Quantity.String(),
+			AvgCostDecimal:     p.AvgCost.String(),
+			MarketPriceDecimal: p.MarketPrice.String(),
+			MarketValueDecimal: p.MarketValue.String(),
+		})
+	}
+	return pb
+}
@@ -0,0 +1,67 @@
+package rpcserver
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/ibdockpb"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log"
+	"net"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	// More digits than a double holds, to check they reach the client.
+	quantity, _ := snapshot.ParseDecimal("123456789012.345678")
+	fake := ibdock.NewMockDock(&snapshot.Snapshot{Account: "U1111111", Positions: []snapshot.Position{{Symbol: "VT", Quantity: quantity}}})
+	fake.Summary = ibdock.AccountSummary{Account: "U1111111", Cash: map[string]float64{"USD": 12.5}}
+	server := New(map[string]Credentials{"main": {Username: "user", Password: "secret"}}, log.New(io.Discard, "", 0))
+	server.start = func(c Credentials) (ibdock.Session, error) {
+		if c.Username != "user" {
+			t.Errorf("started with %+v", c)
+		}
+		return fake, nil
+	}
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	ibdockpb.RegisterIbdockServer(s, server)
+	go s.Serve(listener)
+	defer s.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := ibdockpb.NewIbdockClient(conn)
+	ctx := context.Background()
+
+	if _, err := client.StartSession(ctx, &ibdockpb.StartSessionRequest{Account: "other"}); status.Code(err) != codes.NotFound {
+		t.Errorf("StartSession for an unknown account = %v, want NotFound", err)
+	}
+	started, err := client.StartSession(ctx, &ibdockpb.StartSessionRequest{Account: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := client.GetSnapshot(ctx, &ibdockpb.GetSnapshotRequest{SessionId: started.SessionId})
+	if err != nil || snap.Account != "U1111111" || len(snap.Positions) != 1 || snap.Positions[0].QuantityDecimal != "123456789012.345678" {
+		t.Errorf("GetSnapshot = %v, %v", snap, err)
+	}
+	summary, err := client.GetAccountSummary(ctx, &ibdockpb.GetAccountSummaryRequest{SessionId: started.SessionId})
+	if err != nil || summary.Cash["USD"] != 12.5 {
+		t.Errorf("GetAccountSummary = %v, %v", summary, err)
+	}
+	if _, err := client.StopSession(ctx, &ibdockpb.StopSessionRequest{SessionId: started.SessionId}); err != nil || !fake.Stopped() {
+		t.Errorf("StopSession = %v, stopped %v", err, fake.Stopped())
+	}
+	if _, err := client.GetSnapshot(ctx, &ibdockpb.GetSnapshotRequest{SessionId: started.SessionId}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetSnapshot after stop = %v, want NotFound", err)
+	}
+}
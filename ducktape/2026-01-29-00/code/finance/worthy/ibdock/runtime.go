@@ -0,0 +1,147 @@
+package ibdock
+
+import (
+	"context"
+	"io"
+	"slices"
+	"time"
+)
+
+// containerRuntime is the part of the Docker Engine API ibdock uses. It is
+// implemented on the official Docker SDK by mobyRuntime and, until the
+// migration to it is done, on go-dockerclient by legacyRuntime; see
+// WithLegacyDockerClient.
+type containerRuntime interface {
+	create(ctx context.Context, spec containerSpec) (string, error)
+	start(ctx context.Context, id string) error
+	// copyTo extracts the tar archive into the container at dir, creating
+	// the directories the archive names.
+	copyTo(ctx context.Context, id, dir string, archive io.Reader) error
+	inspect(ctx context.Context, idOrName string) (containerInfo, error)
+	// stop gives the container grace to exit before killing it. Stopping a
+	// container that is not running succeeds.
+	stop(ctx context.Context, id string, grace time.Duration) error
+	remove(ctx context.Context, id string, force bool) error
+	// list returns the containers matching filters, e.g. {"label":
+	// {sessionLabel}}, including stopped ones if all is set.
+	list(ctx context.Context, all bool, filters map[string][]string) ([]containerSummary, error)
+	logs(ctx context.Context, id string, w io.Writer, follow bool) error
+	// startExec starts cmd in the container, copying its output to stdout
+	// and stderr. The returned wait blocks until the output is copied.
+	startExec(ctx context.Context, id string, cmd []string, opts ExecOptions, stdout, stderr io.Writer) (string, func() error, error)
+	inspectExec(ctx context.Context, execID string) (running bool, exitCode int, err error)
+	// endpoint is the daemon's address, e.g. "tcp://docker-host:2376".
+	endpoint() string
+	// daemonPlatform is the platform the daemon runs containers as
+	// natively, e.g. "linux/arm64".
+	daemonPlatform(ctx context.Context) (string, error)
+	// imagePlatform is the platform a local image is built for, e.g.
+	// "linux/arm/v7"; found is false if the image is not on the host.
+	imagePlatform(ctx context.Context, ref string) (platform string, found bool, err error)
+	// pull passes the daemon's progress messages to progress as they come.
+	pull(ctx context.Context, ref, platform string, progress func(pullMessage)) error
+	// info describes the daemon, see Diagnose.
+	info(ctx context.Context) (daemonInfo, error)
+	// imageDigest is the repository digest a local image was pulled by,
+	// or its ID if it was built locally; found is false if the image is
+	// not on the host.
+	imageDigest(ctx context.Context, ref string) (digest string, found bool, err error)
+	// closeIdle closes the connections kept open for later requests. The
+	// runtime stays usable.
+	closeIdle()
+}
+
+// daemonInfo is what Diagnose needs of the Docker daemon.
+type daemonInfo struct {
+	Version    string
+	APIVersion string
+	// RootDir is where the daemon keeps images and containers, on its host.
+	RootDir string
+	// Time is the daemon's clock, zero if it did not say.
+	Time time.Time
+	// OperatingSystem is that of the daemon's host, e.g. "Ubuntu 24.04 LTS"
+	// or "Docker Desktop".
+	OperatingSystem string
+}
+
+// containerSpec is an ibcontroller container to create. Its ports are
+// always published.
+type containerSpec struct {
+	Name   string
+	Image  string
+	Env    []string
+	Labels map[string]string
+	// Credentials are added to Env when creating the container.
+	Credentials secretEnv
+	// Limits, zero for none; Memory is in bytes.
+	Memory    int64
+	CPUShares int64
+	PidsLimit int64
+	Restart   restartPolicy
+	// Network is the Docker network to join, empty for the default bridge.
+	Network string
+	// APIBinding, if set, publishes the TWS API port there alone instead of
+	// all ports on random host ports.
+	APIBinding *portBinding
+	// SettingsVolume, if set, is a named volume to mount at settingsDir.
+	SettingsVolume string
+	// Platform, if set, is the platform to run the image as, e.g.
+	// "linux/amd64".
+	Platform string
+	// DebugVNC publishes vncPort too, on the APIBinding host IP if set.
+	DebugVNC bool
+	// HealthCheck, if set, replaces the image's health check.
+	HealthCheck *healthConfig
+}
+
+// env is the container's whole environment.
+func (spec containerSpec) env() []string {
+	return append(slices.Clip(spec.Env), spec.Credentials...)
+}
+
+// secretEnv holds environment entries with credentials. It formats as
+// [REDACTED], also in %+v and %#v of a containerSpec.
+type secretEnv []string
+
+func (secretEnv) String() string   { return "[REDACTED]" }
+func (secretEnv) GoString() string { return "[REDACTED]" }
+
+// containerInfo is what ibdock needs of an inspected container.
+type containerInfo struct {
+	ID     string
+	Name   string
+	Image  string
+	Labels map[string]string
+	// ImageID is the digest of the image the container runs.
+	ImageID   string
+	Created   time.Time
+	StartedAt time.Time
+
+	Running    bool
+	Paused     bool
+	Restarting bool
+	// Status describes the state for humans, e.g. "exited (1)".
+	Status string
+	// Health is the health check status, empty without a health check.
+	// HealthFailures counts the probes in a row that failed, HealthOutput
+	// is what the last one printed.
+	Health         string
+	HealthFailures int
+	HealthOutput   string
+	// Ports maps container ports like "7496/tcp" to their host bindings.
+	Ports map[string][]portBinding
+}
+
+type portBinding struct {
+	HostIP   string
+	HostPort string
+}
+
+type containerSummary struct {
+	ID      string
+	Name    string
+	Labels  map[string]string
+	Running bool
+	// Ports are the host bindings of its published ports.
+	Ports []portBinding
+}
@@ -0,0 +1,62 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// screenshotCmd grabs the gateway's X screen as PNG with ImageMagick.
+var screenshotCmd = []string{"import", "-display", ":1", "-window", "root", "png:-"}
+
+const (
+	screenshotTimeout  = 10 * time.Second
+	maxScreenshotBytes = 32 << 20
+)
+
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// Screenshot returns a PNG of the container's screen, to see what the
+// gateway shows, e.g. a dialog the login is stuck on. Unlike Exec, it does
+// not restart a container that died.
+func (dock *Dock) Screenshot(ctx context.Context) (_ []byte, err error) {
+	defer dock.redactError(&err)
+	result, err := dock.execCurrent(ctx, screenshotCmd, ExecOptions{Timeout: screenshotTimeout, MaxOutputBytes: maxScreenshotBytes})
+	if err != nil {
+		return nil, fmt.Errorf("screenshot: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("screenshot: %w: %s", &ExitError{Code: result.ExitCode}, bytes.TrimSpace(result.Stderr))
+	}
+	if !bytes.HasPrefix(result.Stdout, pngMagic) {
+		return nil, errors.New("screenshot: not a PNG")
+	}
+	return result.Stdout, nil
+}
+
+// ScreenshotError is an error WaitReady returns with the last screen the
+// gateway showed, for finding out after the fact why it never logged in.
+// Get it with errors.As.
+type ScreenshotError struct {
+	Err error
+	// PNG is the screenshot.
+	PNG []byte
+}
+
+func (e *ScreenshotError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScreenshotError) Unwrap() error {
+	return e.Err
+}
+
+// withScreenshot attaches png to err, if there is one.
+func withScreenshot(err error, png []byte) error {
+	if png == nil {
+		return err
+	}
+	return &ScreenshotError{Err: err, PNG: png}
+}
@@ -0,0 +1,47 @@
+package ibdock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestScreenshot(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	png := append(bytes.Clone(pngMagic), "pixels"...)
+	screen := png
+	server.HandleExec(func(e ibdocktest.Exec) ibdocktest.Result {
+		if e.Cmd[0] != "import" {
+			return ibdocktest.Result{ExitCode: 127}
+		}
+		return ibdocktest.Result{Stdout: screen}
+	})
+	logger := log.New(io.Discard, "", 0)
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dock.Screenshot(context.Background()); err != nil || !bytes.Equal(got, png) {
+		t.Errorf("Screenshot = %q, %v", got, err)
+	}
+
+	// Nothing listens on the API port, so the gateway never gets ready.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = dock.WaitReady(ctx)
+	var screenshotErr *ScreenshotError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &screenshotErr) || !bytes.Equal(screenshotErr.PNG, png) {
+		t.Errorf("WaitReady = %v, want a timeout with the screenshot", err)
+	}
+
+	screen = []byte("xwd")
+	if _, err := dock.Screenshot(context.Background()); err == nil {
+		t.Errorf("Screenshot of a non-PNG succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+package ibdock
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SnapshotRequest holds the per-call parameters of the snapshot script, see
+// GetSnapshotWith. Zero fields leave the script's defaults.
+//
+// The script, read_snapshot.py, comes with the image, see
+// imagebuild.Options.Script. Dock runs it as
+//
+//	python3 /root/read_snapshot.py --port=7496 --format=json|csv|proto \
+//	    [--account=ID] [--currency=CODE] [--verbosity=N] [Args...]
+//
+// with IBDOCK_SCRIPT_CONTRACT=1 and Env added to its environment. It prints
+// one snapshot in the asked format on stdout and logs on stderr, exiting
+// non-zero if it cannot read the snapshot, JSON ones with their
+// snapshot.SchemaVersion:
+//
+//   - --account: the managed account to read, by default the login's
+//     default one.
+//   - --currency: read only positions in this currency.
+//   - --verbosity: 0 logs errors only, 1 progress, 2 and up TWS API
+//     messages; default 0.
+//
+// Scripts should fail on flags they do not know rather than ignore them.
+// Flags only ever get added; changes that are not come with a new contract
+// version.
+type SnapshotRequest struct {
+	// Format is "json" (the default), "csv" or "protobuf".
+	Format    string
+	Account   string
+	Currency  string
+	Verbosity int
+	// Args are passed after the flags above, for parameters of a script
+	// newer than this package.
+	Args []string
+	// Env holds extra "KEY=value" entries for the script's environment.
+	Env []string
+}
+
+// scriptContractEnv tells the script which version of the SnapshotRequest
+// contract it is run under.
+const scriptContractEnv = "IBDOCK_SCRIPT_CONTRACT=1"
+
+// scriptFormats maps snapshot formats to the read_snapshot.py --format value
+// that prints them.
+var scriptFormats = map[string]string{
+	"json":     "json",
+	"csv":      "csv",
+	"protobuf": "proto",
+}
+
+func (r SnapshotRequest) format() string {
+	if r.Format == "" {
+		return "json"
+	}
+	return r.Format
+}
+
+// cmdline is the command that runs read_snapshot.py for r.
+func (r SnapshotRequest) cmdline() ([]string, error) {
+	flag, ok := scriptFormats[r.format()]
+	if !ok {
+		return nil, fmt.Errorf("read_snapshot.py cannot print format %q", r.Format)
+	}
+	cmd := []string{"python3", "/root/read_snapshot.py", "--port=7496", "--format=" + flag}
+	if r.Account != "" {
+		cmd = append(cmd, "--account="+r.Account)
+	}
+	if r.Currency != "" {
+		cmd = append(cmd, "--currency="+r.Currency)
+	}
+	if r.Verbosity > 0 {
+		cmd = append(cmd, "--verbosity="+strconv.Itoa(r.Verbosity))
+	}
+	return append(cmd, r.Args...), nil
+}
+
+func (r SnapshotRequest) env() []string {
+	return append([]string{scriptContractEnv}, r.Env...)
+}
@@ -0,0 +1,30 @@
+package ibdock
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSnapshotRequestCmdline(t *testing.T) {
+	for _, test := range []struct {
+		request SnapshotRequest
+		want    []string
+	}{
+		{SnapshotRequest{}, []string{"--format=json"}},
+		{SnapshotRequest{Format: "protobuf", Account: "U2222222"}, []string{"--format=proto", "--account=U2222222"}},
+		{SnapshotRequest{Currency: "EUR", Verbosity: 2, Args: []string{"--skip_options"}}, []string{"--format=json", "--currency=EUR", "--verbosity=2", "--skip_options"}},
+	} {
+		cmd, err := test.request.cmdline()
+		want := append([]string{"python3", "/root/read_snapshot.py", "--port=7496"}, test.want...)
+		if err != nil || !slices.Equal(cmd, want) {
+			t.Errorf("%+v.cmdline() = %q, %v, want %q", test.request, cmd, err, want)
+		}
+	}
+	if _, err := (SnapshotRequest{Format: "xml"}).cmdline(); err == nil {
+		t.Errorf("cmdline of an unknown format succeeded")
+	}
+	env := SnapshotRequest{Env: []string{"IB_LOG_LEVEL=debug"}}.env()
+	if !slices.Equal(env, []string{scriptContractEnv, "IB_LOG_LEVEL=debug"}) {
+		t.Errorf("env() = %q", env)
+	}
+}
@@ -0,0 +1,27 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+)
+
+// Session is the part of Dock most callers need, so they can take a MockDock,
+// a decorator adding retries or metrics, or another backend instead.
+type Session interface {
+	// WaitReady blocks until the session can serve requests.
+	WaitReady(ctx context.Context) error
+	GetSnapshot(ctx context.Context) (*snapshot.Snapshot, error)
+	GetAccountSummary(ctx context.Context) (AccountSummary, error)
+	// GetFXRates values currencies in base, see Dock.GetFXRates.
+	GetFXRates(ctx context.Context, base string, currencies []string) (snapshot.FXRates, error)
+	// Exec runs cmd next to the gateway, see Dock.Exec.
+	Exec(ctx context.Context, cmd []string, opts ExecOptions) (ExecResult, error)
+	// Stop ends the session gracefully, Kill right away.
+	Stop(ctx context.Context) error
+	Kill()
+}
+
+var (
+	_ Session = (*Dock)(nil)
+	_ Session = (*MockDock)(nil)
+)
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// metadataToken is where GCE, GKE and Cloud Run hand out access tokens of the
+// service account a workload runs as.
+const metadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCS is a Sink uploading objects to a Google Cloud Storage bucket.
+type GCS struct {
+	Bucket string
+	// Prefix is prepended to keys, e.g. "snapshots".
+	Prefix string
+	// Token is an OAuth access token with write access to Bucket. It
+	// defaults to $GOOGLE_OAUTH_ACCESS_TOKEN, then to one from the metadata
+	// server, for workloads running on Google Cloud.
+	Token string
+	// Endpoint defaults to https://storage.googleapis.com.
+	Endpoint string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// tokenURL is metadataToken, for tests.
+	tokenURL string
+}
+
+func (g *GCS) Put(ctx context.Context, key, contentType string, data []byte) error {
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	token := firstSet(g.Token, os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"))
+	if token == "" {
+		var err error
+		if token, err = g.metadataToken(ctx, client); err != nil {
+			return err
+		}
+	}
+	endpoint := strings.TrimSuffix(firstSet(g.Endpoint, "https://storage.googleapis.com"), "/")
+	query := url.Values{"uploadType": {"media"}, "name": {path.Join(g.Prefix, key)}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/upload/storage/v1/b/"+url.PathEscape(g.Bucket)+"/o?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return checkResponse(response)
+}
+
+func (g *GCS) metadataToken(ctx context.Context, client *http.Client) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, firstSet(g.tokenURL, metadataToken), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	response, err := client.Do(request)
+	if err != nil {
+		return "", errors.Join(errors.New("no GCS token, see GOOGLE_OAUTH_ACCESS_TOKEN"), err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Join(errors.New("no GCS token, see GOOGLE_OAUTH_ACCESS_TOKEN"), checkResponse(response))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// S3 is a Sink putting objects to an S3 bucket, or one of an S3-compatible
+// store such as MinIO. Requests are signed with AWS Signature Version 4 and
+// address the bucket path-style.
+type S3 struct {
+	Bucket string
+	// Prefix is prepended to keys, e.g. "snapshots".
+	Prefix string
+	// Region defaults to $AWS_REGION, $AWS_DEFAULT_REGION, then us-east-1.
+	Region string
+	// Endpoint defaults to https://s3.REGION.amazonaws.com.
+	Endpoint string
+	// The credentials default to $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY
+	// and $AWS_SESSION_TOKEN.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Clock dates the request signatures, default clock.Real.
+	Clock clock.Clock
+}
+
+func (s *S3) Put(ctx context.Context, key, contentType string, data []byte) error {
+	region := firstSet(s.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	accessKey := firstSet(s.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretKey := firstSet(s.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	token := firstSet(s.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if accessKey == "" || secretKey == "" {
+		return errors.New("no S3 credentials, see AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	endpoint := firstSet(s.Endpoint, "https://s3."+region+".amazonaws.com")
+	objectPath := "/" + uriEncode(s.Bucket) + "/" + uriEncode(path.Join(s.Prefix, key))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(endpoint, "/")+objectPath, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	signV4(request, objectPath, data, region, accessKey, secretKey, token, clock.OrReal(s.Clock).Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return checkResponse(response)
+}
+
+// signV4 adds the x-amz-* and Authorization headers of AWS Signature Version
+// 4 to an S3 request with no query, whose URI-encoded path is objectPath.
+func signV4(request *http.Request, objectPath string, payload []byte, region, accessKey, secretKey, token string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	payloadHash := sha256Hex(payload)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := [][2]string{
+		{"host", request.URL.Host},
+		{"x-amz-content-sha256", payloadHash},
+		{"x-amz-date", amzDate},
+	}
+	if token != "" {
+		request.Header.Set("X-Amz-Security-Token", token)
+		headers = append(headers, [2]string{"x-amz-security-token", token})
+	}
+	var canonicalHeaders strings.Builder
+	var signed []string
+	for _, h := range headers {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", h[0], h[1])
+		signed = append(signed, h[0])
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonicalRequest := strings.Join([]string{request.Method, objectPath, "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := t.Format("20060102") + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{t.Format("20060102"), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode percent-encodes s as Signature Version 4 asks: everything but
+// unreserved characters and slashes.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+// Package sink archives snapshots to a local directory, S3 or Google Cloud
+// Storage, under keys named after their account and time:
+//
+//	archive, err := sink.Open("s3://worthy-archive/snapshots?region=eu-central-1")
+//	if err != nil {
+//	  panic(err)
+//	}
+//	w := &sink.Writer{Sink: archive, Gzip: true}
+//	manager := ibdock.NewManager(dock.GetSnapshot, ibdock.ManagerOptions{
+//	  Interval: time.Hour,
+//	  Hooks:    ibdock.Hooks{OnSnapshot: w.Hook(logger)},
+//	}, logger)
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sink stores encoded snapshots.
+type Sink interface {
+	// Put stores data under key, a slash-separated relative path, replacing
+	// whatever was there.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+}
+
+// Open returns the sink a URL names:
+//
+//   - s3://BUCKET/PREFIX, with optional region and endpoint query
+//     parameters, see S3;
+//   - gs://BUCKET/PREFIX, see GCS;
+//   - file:///DIR or a plain path, see Dir.
+func Open(rawURL string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		query := u.Query()
+		return &S3{Bucket: u.Host, Prefix: prefix, Region: query.Get("region"), Endpoint: query.Get("endpoint")}, nil
+	case "gs":
+		return &GCS{Bucket: u.Host, Prefix: prefix}, nil
+	case "file":
+		return Dir(u.Path), nil
+	case "":
+		return Dir(rawURL), nil
+	}
+	return nil, fmt.Errorf("sink: unknown scheme %q in %s, want s3, gs or file", u.Scheme, rawURL)
+}
+
+// writeTimeout bounds each Put made by Hook, so a hung upload cannot stall
+// the session the hook is called from.
+const writeTimeout = time.Minute
+
+// Writer encodes snapshots and puts them to Sink as
+// ACCOUNT/20060102T150405Z.FORMAT, with ".gz" appended under Gzip.
+type Writer struct {
+	Sink Sink
+	// Format is a snapshot.Lookup format, "json" if empty.
+	Format string
+	Gzip   bool
+	// Dedupe, if set, skips snapshots that hash the same as the last one of
+	// their account written.
+	Dedupe *snapshot.Dedupe
+	// Clock names snapshots without a timestamp, default clock.Real.
+	Clock clock.Clock
+}
+
+// Write stores s and returns the key it was stored under, or "" if Dedupe
+// skipped it.
+func (w *Writer) Write(ctx context.Context, s *snapshot.Snapshot) (string, error) {
+	if w.Dedupe != nil && w.Dedupe.Unchanged(s) {
+		return "", nil
+	}
+	format := w.Format
+	if format == "" {
+		format = "json"
+	}
+	codec, err := snapshot.Lookup(format)
+	if err != nil {
+		return "", err
+	}
+	data, err := codec.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	key, contentType := Key(s, format, clock.OrReal(w.Clock).Now()), codec.ContentType()
+	if w.Gzip {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		gz.Write(data)
+		if err := gz.Close(); err != nil {
+			return "", err
+		}
+		data, key, contentType = b.Bytes(), key+".gz", "application/gzip"
+	}
+	if err := w.Sink.Put(ctx, key, contentType, data); err != nil {
+		return "", fmt.Errorf("sink: %s: %w", key, err)
+	}
+	if w.Dedupe != nil {
+		if err := w.Dedupe.Record(s); err != nil {
+			return key, fmt.Errorf("sink: %s: stored, but cannot record its hash: %w", key, err)
+		}
+	}
+	return key, nil
+}
+
+// Hook returns an ibdock.Hooks.OnSnapshot that writes each snapshot, logging
+// failures to logger.
+func (w *Writer) Hook(logger *log.Logger) func(*snapshot.Snapshot) {
+	return func(s *snapshot.Snapshot) {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		defer cancel()
+		if key, err := w.Write(ctx, s); err != nil {
+			logger.Println("Cannot archive the snapshot:", err)
+		} else if key == "" {
+			logger.Println("Snapshot unchanged, not archiving it")
+		}
+	}
+}
+
+// Key is where Writer stores s in format, before any ".gz": the account,
+// then the snapshot's UTC time, or now if it has none.
+func Key(s *snapshot.Snapshot, format string, now time.Time) string {
+	timestamp := s.Timestamp
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+	name := timestamp.UTC().Format("20060102T150405Z") + "." + format
+	if s.Account == "" {
+		return name
+	}
+	return path.Join(s.Account, name)
+}
+
+// Dir is a Sink writing files under a local directory. Files appear
+// atomically, so readers never see a partial snapshot.
+type Dir string
+
+func (d Dir) Put(ctx context.Context, key, contentType string, data []byte) error {
+	name := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// checkResponse closes response, returning an error with the start of its
+// body unless it succeeded.
+func checkResponse(response *http.Response) error {
+	defer response.Body.Close()
+	if response.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	return fmt.Errorf("%s %s: %s: %s", response.Request.Method, response.Request.URL.Redacted(), response.Status, bytes.TrimSpace(body))
+}
@@ -0,0 +1,147 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSnapshot = &snapshot.Snapshot{
+	Account:   "U1234567",
+	Timestamp: time.Date(2026, 1, 2, 15, 4, 5, 0, time.FixedZone("CET", 3600)),
+	Positions: []snapshot.Position{{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(10, 0)}},
+}
+
+func TestWriterDir(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{Sink: Dir(dir), Gzip: true}
+	key, err := w.Write(context.Background(), testSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "U1234567/20260102T140405Z.json.gz"; key != want {
+		t.Errorf("key %q, want %q", key, want)
+	}
+	f, err := os.Open(filepath.Join(dir, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got snapshot.Snapshot
+	if err := snapshot.Unmarshal("json", data, &got); err != nil || got.Positions[0].Symbol != "VT" {
+		t.Errorf("archived %s: %v", data, err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var request *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	s3 := &S3{
+		Bucket: "archive", Prefix: "snapshots", Region: "eu-central-1", Endpoint: server.URL,
+		AccessKeyID: "AKID", SecretAccessKey: "secret",
+		Clock: clock.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+	if err := s3.Put(context.Background(), "U1234567/x y.json", "application/json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if request.Method != http.MethodPut || request.URL.EscapedPath() != "/archive/snapshots/U1234567/x%20y.json" || string(body) != "{}" {
+		t.Errorf("got %s %s with %q", request.Method, request.URL.EscapedPath(), body)
+	}
+	auth := request.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260102/eu-central-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("Authorization: %s", auth)
+	}
+	if got := request.Header.Get("X-Amz-Date"); got != "20260102T030405Z" {
+		t.Errorf("X-Amz-Date: %s", got)
+	}
+}
+
+func TestGCS(t *testing.T) {
+	var uploads []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "no Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, `{"access_token":"ya29.test","expires_in":3599}`)
+	})
+	mux.HandleFunc("POST /upload/storage/v1/b/archive/o", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		uploads = append(uploads, r.Header.Get("Authorization")+" "+r.URL.Query().Get("name")+" "+string(data))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	gcs := &GCS{Bucket: "archive", Prefix: "snapshots", Endpoint: server.URL, tokenURL: server.URL + "/token"}
+	if err := gcs.Put(context.Background(), "U1234567/a.csv", "text/csv", []byte("a,b")); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0] != "Bearer ya29.test snapshots/U1234567/a.csv a,b" {
+		t.Errorf("uploads %q", uploads)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, test := range []struct {
+		url  string
+		want Sink
+	}{
+		{"s3://archive/snapshots?region=eu-central-1", &S3{Bucket: "archive", Prefix: "snapshots", Region: "eu-central-1"}},
+		{"gs://archive", &GCS{Bucket: "archive"}},
+		{"file:///var/lib/worthy", Dir("/var/lib/worthy")},
+		{"archive", Dir("archive")},
+	} {
+		got, err := Open(test.url)
+		if err != nil {
+			t.Errorf("Open(%q): %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Open(%q) = %+v, want %+v", test.url, got, test.want)
+		}
+	}
+	if _, err := Open("ftp://archive"); err == nil {
+		t.Error("Open(ftp://) succeeded")
+	}
+}
+
+func TestWriterDedupe(t *testing.T) {
+	dir := t.TempDir()
+	w := &Writer{Sink: Dir(dir), Dedupe: &snapshot.Dedupe{Path: filepath.Join(dir, "hashes.json")}}
+	if key, err := w.Write(context.Background(), testSnapshot); err != nil || key == "" {
+		t.Fatalf("Write = %q, %v", key, err)
+	}
+	next := *testSnapshot
+	next.Timestamp = next.Timestamp.Add(24 * time.Hour)
+	if key, err := w.Write(context.Background(), &next); err != nil || key != "" {
+		t.Errorf("Write of an identical snapshot = %q, %v, want it skipped", key, err)
+	}
+	next.Positions = []snapshot.Position{{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(11, 0)}}
+	if key, err := w.Write(context.Background(), &next); err != nil || key != "U1234567/20260103T140405Z.json" {
+		t.Errorf("Write of a changed snapshot = %q, %v", key, err)
+	}
+}
@@ -0,0 +1,81 @@
+package ibdock
+
+import (
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	_ "github.com/agentydragon/worthy/ibdock/snapshot/snapshotpb"
+)
+
+// GetSnapshot reads a snapshot of the session's account, transferred as JSON.
+func (dock *Dock) GetSnapshot(ctx context.Context) (_ *snapshot.Snapshot, err error) {
+	defer dock.redactError(&err)
+	return dock.GetSnapshotAs(ctx, "json")
+}
+
+// GetSnapshotAs is GetSnapshot with the snapshot script printing the given
+// format: "json", "csv" or "protobuf". The script is killed if it runs past
+// the snapshot timeout or ctx is done first.
+func (dock *Dock) GetSnapshotAs(ctx context.Context, format string) (_ *snapshot.Snapshot, err error) {
+	defer dock.redactError(&err)
+	return dock.GetSnapshotWith(ctx, SnapshotRequest{Format: format})
+}
+
+// GetSnapshotFor is GetSnapshot of one of the accounts the login manages, see
+// ListAccounts, as for a Financial Advisor login with several sub-accounts.
+// An empty account is the login's default one.
+func (dock *Dock) GetSnapshotFor(ctx context.Context, account string) (_ *snapshot.Snapshot, err error) {
+	defer dock.redactError(&err)
+	return dock.GetSnapshotWith(ctx, SnapshotRequest{Account: account})
+}
+
+// GetSnapshotWith is GetSnapshot with the snapshot script run with the
+// parameters of request. Errors are *CallErrors, see Call.
+func (dock *Dock) GetSnapshotWith(ctx context.Context, request SnapshotRequest) (_ *snapshot.Snapshot, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, request.Account)
+	s, err := dock.getSnapshot(ctx, request)
+	return s, call.wrap(err)
+}
+
+func (dock *Dock) getSnapshot(ctx context.Context, request SnapshotRequest) (*snapshot.Snapshot, error) {
+	data, err := dock.readSnapshot(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	unmarshal := snapshot.Unmarshal
+	if dock.strict {
+		unmarshal = snapshot.UnmarshalStrict
+	}
+	s := new(snapshot.Snapshot)
+	if err := unmarshal(request.format(), data, s); err != nil {
+		return nil, err
+	}
+	if err := checkRequested(s, request); err != nil {
+		return nil, err
+	}
+	if dock.validation != nil {
+		if err := s.Validate(*dock.validation, clock.OrReal(dock.clock).Now()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// checkRequested checks that s is what request asked for: scripts predating
+// --account and --currency ignore them.
+func checkRequested(s *snapshot.Snapshot, request SnapshotRequest) error {
+	if request.Account != "" && s.Account != request.Account {
+		return fmt.Errorf("asked for a snapshot of %s, got one of %s; does the image's read_snapshot.py take --account?", request.Account, s.Account)
+	}
+	if request.Currency == "" {
+		return nil
+	}
+	for _, p := range s.Positions {
+		if p.Currency != request.Currency {
+			return fmt.Errorf("asked for %s positions, got %s %s; does the image's read_snapshot.py take --currency?", request.Currency, p.Symbol, p.Currency)
+		}
+	}
+	return nil
+}
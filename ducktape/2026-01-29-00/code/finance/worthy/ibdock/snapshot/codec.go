@@ -0,0 +1,116 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Codec serializes snapshots in one wire format.
+type Codec interface {
+	// ContentType is the MIME type of the encoded form, for HTTP
+	// negotiation.
+	ContentType() string
+	Marshal(s *Snapshot) ([]byte, error)
+	Unmarshal(data []byte, s *Snapshot) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// Register makes a codec available under the given format name. Codec
+// packages call it from init, so that importing them is enough to opt in:
+//
+//	import _ "github.com/agentydragon/worthy/ibdock/snapshot/msgpack"
+//
+// It panics if the name is already taken.
+func Register(format string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if codec == nil {
+		panic("snapshot: Register codec is nil")
+	}
+	if _, dup := codecs[format]; dup {
+		panic("snapshot: Register called twice for format " + format)
+	}
+	codecs[format] = codec
+}
+
+// Lookup returns the codec registered for format.
+func Lookup(format string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[format]
+	if !ok {
+		return nil, fmt.Errorf("snapshot: unknown format %q (forgotten import?)", format)
+	}
+	return codec, nil
+}
+
+// Formats returns the sorted names of all registered formats.
+func Formats() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	formats := make([]string, 0, len(codecs))
+	for format := range codecs {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Negotiate returns the first of the preferred formats that is registered, or
+// "json" if preferred is empty.
+func Negotiate(preferred ...string) (string, error) {
+	if len(preferred) == 0 {
+		return "json", nil
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, format := range preferred {
+		if _, ok := codecs[format]; ok {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("snapshot: none of %v is a registered format", preferred)
+}
+
+func Marshal(format string, s *Snapshot) ([]byte, error) {
+	codec, err := Lookup(format)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Marshal(s)
+}
+
+func Unmarshal(format string, data []byte, s *Snapshot) error {
+	codec, err := Lookup(format)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, s)
+}
+
+// StrictCodec is a Codec that can also decode strictly: failing on fields
+// Snapshot does not have and, where the format can tell, on missing ones. This
+// catches drift between the snapshot script in an image and this package.
+type StrictCodec interface {
+	Codec
+	UnmarshalStrict(data []byte, s *Snapshot) error
+}
+
+// UnmarshalStrict is Unmarshal in strict mode; it fails for formats without
+// one.
+func UnmarshalStrict(format string, data []byte, s *Snapshot) error {
+	codec, err := Lookup(format)
+	if err != nil {
+		return err
+	}
+	strict, ok := codec.(StrictCodec)
+	if !ok {
+		return fmt.Errorf("snapshot: format %q has no strict mode", format)
+	}
+	return strict.UnmarshalStrict(data, s)
+}
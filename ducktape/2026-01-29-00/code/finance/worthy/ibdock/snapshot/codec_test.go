@@ -0,0 +1,175 @@
+package snapshot_test
+
+import (
+	"encoding/binary"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	_ "github.com/agentydragon/worthy/ibdock/snapshot/msgpack"
+	_ "github.com/agentydragon/worthy/ibdock/snapshot/snapshotpb"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	original := &snapshot.Snapshot{
+		Account:     "U1234567",
+		AccountName: "Retirement",
+		Timestamp:   time.Date(2026, time.January, 29, 16, 30, 0, 123, time.UTC),
+		Positions: []snapshot.Position{
+			{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.DecimalFromFloat(12), AvgCost: snapshot.DecimalFromFloat(95.5), MarketPrice: snapshot.DecimalFromFloat(101.25), MarketValue: snapshot.DecimalFromFloat(1215)},
+			{Symbol: "EUR", SecType: "CASH", Currency: "EUR", Quantity: snapshot.DecimalFromFloat(-0.5)},
+			{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: snapshot.DecimalFromFloat(3), InTransfer: true},
+		},
+		PendingTransfers: []snapshot.Transfer{
+			{Direction: snapshot.TransferIn, Symbol: "VWCE", Currency: "EUR", Quantity: snapshot.DecimalFromFloat(40), Value: snapshot.DecimalFromFloat(4400), Initiated: time.Date(2026, time.January, 20, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, format := range []string{"json", "msgpack", "protobuf"} {
+		data, err := snapshot.Marshal(format, original)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", format, err)
+		}
+		var decoded snapshot.Snapshot
+		if err := snapshot.Unmarshal(format, data, &decoded); err != nil {
+			t.Fatalf("%v: unmarshal: %v", format, err)
+		}
+		inUTC(&decoded)
+		if !reflect.DeepEqual(&decoded, original) {
+			t.Errorf("%v: round trip gave %+v, want %+v", format, decoded, *original)
+		}
+	}
+}
+
+func TestRoundTripExactDecimals(t *testing.T) {
+	// Neither is a double: 0.1+0.2 is 0.30000000000000004 in float64, and
+	// doubles have 15 to 17 significant digits.
+	sum := snapshot.DecimalFromFloat(0.1).Add(snapshot.DecimalFromFloat(0.2))
+	quantity, _ := snapshot.ParseDecimal("123456789012.345678")
+	original := &snapshot.Snapshot{
+		Account:          "U1234567",
+		Timestamp:        time.Date(2026, time.January, 29, 16, 30, 0, 0, time.UTC),
+		Positions:        []snapshot.Position{{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: quantity, MarketPrice: sum, MarketValue: quantity.Mul(sum)}},
+		PendingTransfers: []snapshot.Transfer{{Direction: snapshot.TransferIn, Symbol: "VT", Currency: "USD", Quantity: quantity, Value: sum}},
+	}
+	for _, format := range []string{"json", "msgpack", "protobuf"} {
+		data, err := snapshot.Marshal(format, original)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", format, err)
+		}
+		var decoded snapshot.Snapshot
+		if err := snapshot.Unmarshal(format, data, &decoded); err != nil {
+			t.Fatalf("%v: unmarshal: %v", format, err)
+		}
+		got := decoded.Positions[0]
+		if got.Quantity != quantity || got.MarketPrice.String() != "0.3" || got.MarketValue != quantity.Mul(sum) {
+			t.Errorf("%v: round trip gave %v × %v = %v, want %v × 0.3", format, got.Quantity, got.MarketPrice, got.MarketValue, quantity)
+		}
+		if transfer := decoded.PendingTransfers[0]; transfer.Quantity != quantity || transfer.Value != sum {
+			t.Errorf("%v: round trip gave transfer of %v worth %v", format, transfer.Quantity, transfer.Value)
+		}
+	}
+}
+
+func TestProtobufReadsLegacyDoubles(t *testing.T) {
+	double := func(b []byte, tag byte, v float64) []byte {
+		return binary.LittleEndian.AppendUint64(append(b, tag), math.Float64bits(v))
+	}
+	// A Position with quantity 12 and market value 1215 as doubles (fields 4
+	// and 7), and market value 1215.5 as a decimal string (field 12), which
+	// wins over the double whatever their order.
+	var position []byte
+	position = double(position, 4<<3|1, 12)
+	position = append(position, 12<<3|2, 6)
+	position = append(position, "1215.5"...)
+	position = double(position, 7<<3|1, 1215)
+	data := append([]byte{3<<3 | 2, byte(len(position))}, position...)
+
+	var s snapshot.Snapshot
+	if err := snapshot.Unmarshal("protobuf", data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Positions[0]; got.Quantity.String() != "12" || got.MarketValue.String() != "1215.5" {
+		t.Errorf("decoded %v worth %v, want 12 worth 1215.5", got.Quantity, got.MarketValue)
+	}
+}
+
+// inUTC moves all times to UTC; codecs need not preserve the location.
+func inUTC(s *snapshot.Snapshot) {
+	s.Timestamp = s.Timestamp.UTC()
+	for i := range s.PendingTransfers {
+		s.PendingTransfers[i].Initiated = s.PendingTransfers[i].Initiated.UTC()
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	format, err := snapshot.Negotiate("cbor", "msgpack", "json")
+	if err != nil || format != "msgpack" {
+		t.Errorf("Negotiate = %q, %v; want msgpack", format, err)
+	}
+	if _, err := snapshot.Negotiate("cbor"); err == nil {
+		t.Errorf("Negotiate(cbor) should fail")
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	for _, original := range []*snapshot.Snapshot{
+		{
+			Account:   "U1234567",
+			Timestamp: time.Date(2026, time.January, 29, 16, 30, 0, 123, time.UTC),
+			Positions: []snapshot.Position{
+				{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.DecimalFromFloat(12), AvgCost: snapshot.DecimalFromFloat(95.5), MarketPrice: snapshot.DecimalFromFloat(101.25), MarketValue: snapshot.DecimalFromFloat(1215)},
+				{Symbol: "VWCE, Acc", SecType: "STK", Currency: "EUR", Quantity: snapshot.DecimalFromFloat(3), InTransfer: true},
+			},
+		},
+		{Account: "U1234567", AccountName: "Empty", Timestamp: time.Date(2026, time.January, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		data, err := snapshot.Marshal("csv", original)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var decoded snapshot.Snapshot
+		if err := snapshot.Unmarshal("csv", data, &decoded); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		inUTC(&decoded)
+		if !reflect.DeepEqual(&decoded, original) {
+			t.Errorf("round trip gave %+v, want %+v", decoded, *original)
+		}
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	original := &snapshot.Snapshot{
+		Account:   "U1234567",
+		Timestamp: time.Date(2026, time.January, 29, 16, 30, 0, 0, time.UTC),
+		Positions: []snapshot.Position{{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.DecimalFromFloat(12)}},
+	}
+	for _, format := range []string{"json", "csv", "msgpack", "protobuf"} {
+		data, err := snapshot.Marshal(format, original)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", format, err)
+		}
+		var decoded snapshot.Snapshot
+		if err := snapshot.UnmarshalStrict(format, data, &decoded); err != nil {
+			t.Errorf("%v: strict decoding of our own output: %v", format, err)
+		}
+	}
+	var s snapshot.Snapshot
+	for _, bad := range []string{
+		`{"Account": "U1", "Timestamp": "2026-01-29T00:00:00Z", "Positions": [], "Extra": 1}`,
+		`{"Account": "U1", "Positions": []}`,
+		`{"Account": "U1", "Timestamp": "2026-01-29T00:00:00Z", "Positions": [{"Symbol": "VT"}]}`,
+	} {
+		if err := snapshot.Unmarshal("json", []byte(bad), &s); err != nil {
+			t.Errorf("tolerant decoding of %s: %v", bad, err)
+		}
+		if err := snapshot.UnmarshalStrict("json", []byte(bad), &s); err == nil {
+			t.Errorf("strict decoding of %s should fail", bad)
+		}
+	}
+	// Field 9 of Snapshot is not defined.
+	if err := snapshot.UnmarshalStrict("protobuf", []byte{0x48, 0x01}, &s); err == nil {
+		t.Errorf("strict protobuf decoding should fail on unknown fields")
+	}
+}
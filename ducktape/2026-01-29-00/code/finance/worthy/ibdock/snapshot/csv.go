@@ -0,0 +1,91 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// csvHeader is the header row of the "csv" format: one row per position, with
+// the account and timestamp repeated on each. A snapshot without positions is
+// a single row with the position columns empty. PendingTransfers are not
+// represented.
+var csvHeader = []string{
+	"Account", "AccountName", "Timestamp",
+	"Symbol", "SecType", "Currency", "Quantity", "AvgCost", "MarketPrice", "MarketValue", "InTransfer",
+}
+
+type csvCodec struct{}
+
+func (csvCodec) ContentType() string {
+	return "text/csv"
+}
+
+func (csvCodec) Marshal(s *Snapshot) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(csvHeader)
+	head := []string{s.Account, s.AccountName, s.Timestamp.Format(time.RFC3339Nano)}
+	if len(s.Positions) == 0 {
+		w.Write(append(head, make([]string, len(csvHeader)-len(head))...))
+	}
+	for _, p := range s.Positions {
+		w.Write(append(head[:len(head):len(head)],
+			p.Symbol, p.SecType, p.Currency,
+			p.Quantity.String(), p.AvgCost.String(), p.MarketPrice.String(), p.MarketValue.String(),
+			strconv.FormatBool(p.InTransfer)))
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+func (csvCodec) Unmarshal(data []byte, s *Snapshot) error {
+	return unmarshalCSV(data, s, false)
+}
+
+// UnmarshalStrict also requires the header row to be exactly csvHeader.
+func (csvCodec) UnmarshalStrict(data []byte, s *Snapshot) error {
+	return unmarshalCSV(data, s, true)
+}
+
+func unmarshalCSV(data []byte, s *Snapshot, strict bool) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = len(csvHeader)
+	rows, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) < 2 {
+		return fmt.Errorf("snapshot: csv has %d rows, want a header and at least one more", len(rows))
+	}
+	if strict && !slices.Equal(rows[0], csvHeader) {
+		return fmt.Errorf("snapshot: csv header %v, want %v", rows[0], csvHeader)
+	}
+	*s = Snapshot{Account: rows[1][0], AccountName: rows[1][1]}
+	if s.Timestamp, err = time.Parse(time.RFC3339Nano, rows[1][2]); err != nil {
+		return err
+	}
+	for _, row := range rows[1:] {
+		if row[3] == "" {
+			continue
+		}
+		p := Position{Symbol: row[3], SecType: row[4], Currency: row[5]}
+		for i, d := range []*Decimal{&p.Quantity, &p.AvgCost, &p.MarketPrice, &p.MarketValue} {
+			if *d, err = ParseDecimal(row[6+i]); err != nil {
+				return fmt.Errorf("snapshot: csv %s: %w", csvHeader[6+i], err)
+			}
+		}
+		if p.InTransfer, err = strconv.ParseBool(row[10]); err != nil {
+			return fmt.Errorf("snapshot: csv InTransfer: %w", err)
+		}
+		s.Positions = append(s.Positions, p)
+	}
+	return nil
+}
+
+func init() {
+	Register("csv", csvCodec{})
+}
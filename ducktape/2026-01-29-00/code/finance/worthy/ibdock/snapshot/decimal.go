@@ -0,0 +1,291 @@
+package snapshot
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, for the quantities, prices and values
+// of snapshots: float64 cannot hold most decimal fractions, e.g. 0.1, so sums
+// of them drift by cents that accounting downstream notices. It keeps up to
+// 18 significant digits; results that need more are rounded half to even.
+// The zero value is 0.
+//
+// Decimals marshal to JSON as numbers and to text as String, so they read
+// back exactly. Analytics that weigh values by FX rates or volatilities work
+// on Float64.
+type Decimal struct {
+	// The value is coef × 10^exp, coef having no trailing zeros, so equal
+	// values are equal structs.
+	coef int64
+	exp  int32
+}
+
+// maxDigits is how many significant digits a Decimal keeps: every 18-digit
+// number fits an int64.
+const maxDigits = 18
+
+// maxParsedExp bounds the exponent of the Decimals ParseDecimal reads, which
+// covers the float64 range, 5e-324 to 1.8e308, so that String of what a
+// snapshot says does not expand to gigabytes of zeros.
+const maxParsedExp = 400
+
+// NewDecimal returns coef × 10^exp, e.g. NewDecimal(12345, -2) is 123.45.
+// It panics if dropping the trailing zeros of coef takes exp past an int32.
+func NewDecimal(coef int64, exp int32) Decimal {
+	return mustFromBig(big.NewInt(coef), int64(exp))
+}
+
+// DecimalFromFloat returns the shortest decimal that reads back as x, e.g.
+// 0.1 for 0.1, which is what it was written as in a program or a double-typed
+// format. NaNs and infinities have no decimal and become 0.
+func DecimalFromFloat(x float64) Decimal {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return Decimal{}
+	}
+	d, _ := ParseDecimal(strconv.FormatFloat(x, 'g', -1, 64))
+	return d
+}
+
+// ParseDecimal parses a decimal number like "-123.45" or "1.5e3".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	exp := int64(0)
+	if hasExponent {
+		e, err := strconv.ParseInt(exponent, 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("snapshot: decimal %q: bad exponent", s)
+		}
+		exp = e
+	}
+	sign := ""
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	digits := whole + fraction
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("snapshot: decimal %q: not a number", s)
+	}
+	coef, _ := new(big.Int).SetString(sign+digits, 10)
+	d, err := fromBig(coef, exp-int64(len(fraction)))
+	if err != nil || d.exp < -maxParsedExp || d.exp > maxParsedExp {
+		return Decimal{}, fmt.Errorf("snapshot: decimal %q: out of range", s)
+	}
+	return d, nil
+}
+
+// errExponentRange is returned by fromBig for exponents that do not fit.
+var errExponentRange = errors.New("snapshot: decimal exponent out of range")
+
+// fromBig normalizes coef × 10^exp, rounding coef to maxDigits.
+func fromBig(coef *big.Int, exp int64) (Decimal, error) {
+	if coef.Sign() == 0 {
+		return Decimal{}, nil
+	}
+	ten := big.NewInt(10)
+	if n := len(new(big.Int).Abs(coef).String()) - maxDigits; n > 0 {
+		divisor := new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+		q, r := new(big.Int).QuoRem(coef, divisor, new(big.Int))
+		half := new(big.Int).Mul(r.Abs(r), big.NewInt(2)).Cmp(divisor)
+		if half > 0 || (half == 0 && q.Bit(0) == 1) {
+			q.Add(q, big.NewInt(int64(coef.Sign())))
+		}
+		coef, exp = q, exp+int64(n)
+	}
+	q, r := new(big.Int), new(big.Int)
+	for {
+		q.QuoRem(coef, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		coef, exp = new(big.Int).Set(q), exp+1
+	}
+	if exp < math.MinInt32 || exp > math.MaxInt32 {
+		return Decimal{}, errExponentRange
+	}
+	return Decimal{coef: coef.Int64(), exp: int32(exp)}, nil
+}
+
+// mustFromBig is fromBig for arithmetic, which panics rather than wrap the
+// exponent around. Starting from what ParseDecimal reads, that takes
+// millions of multiplications.
+func mustFromBig(coef *big.Int, exp int64) Decimal {
+	d, err := fromBig(coef, exp)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// maxGap is how many digits aligned lets one operand lie below the other. One
+// further below is under a 10^maxDigits-th of the other, so it can change
+// neither their sum rounded to maxDigits nor their order.
+const maxGap = 2*maxDigits + 1
+
+// aligned returns the coefficients of d and e scaled to their common
+// exponent. An operand more than maxGap digits below the other stands in as
+// ±1 maxGap digits below it, which sums and compares the same and keeps the
+// scale factor small: 1 + 1e-2000000000 would take a billion-digit one.
+func aligned(d, e Decimal) (*big.Int, *big.Int, int64) {
+	// Zero is zero at any exponent.
+	if d.coef == 0 {
+		d.exp = e.exp
+	}
+	if e.coef == 0 {
+		e.exp = d.exp
+	}
+	switch gap := int64(d.exp) - int64(e.exp); {
+	case gap > maxGap:
+		e = Decimal{coef: int64(e.Sign()), exp: d.exp - maxGap}
+	case gap < -maxGap:
+		d = Decimal{coef: int64(d.Sign()), exp: e.exp - maxGap}
+	}
+	exp := min(d.exp, e.exp)
+	scale := func(x Decimal) *big.Int {
+		factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(x.exp-exp)), nil)
+		return factor.Mul(factor, big.NewInt(x.coef))
+	}
+	return scale(d), scale(e), int64(exp)
+}
+
+// Add returns d + e. It panics if the exponent of the sum overflows an int32,
+// as 1e2147483647 + 9e2147483647 does.
+func (d Decimal) Add(e Decimal) Decimal {
+	x, y, exp := aligned(d, e)
+	return mustFromBig(x.Add(x, y), exp)
+}
+
+// Sub returns d - e. It panics where Add does.
+func (d Decimal) Sub(e Decimal) Decimal {
+	return d.Add(e.Neg())
+}
+
+// Mul returns d × e. It panics if the exponent of the product overflows an
+// int32.
+func (d Decimal) Mul(e Decimal) Decimal {
+	coef := new(big.Int).Mul(big.NewInt(d.coef), big.NewInt(e.coef))
+	return mustFromBig(coef, int64(d.exp)+int64(e.exp))
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: -d.coef, exp: d.exp}
+}
+
+// Abs returns |d|.
+func (d Decimal) Abs() Decimal {
+	if d.coef < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than e.
+func (d Decimal) Cmp(e Decimal) int {
+	x, y, _ := aligned(d, e)
+	return x.Cmp(y)
+}
+
+// Sign returns -1, 0 or 1 as d is negative, zero or positive.
+func (d Decimal) Sign() int {
+	switch {
+	case d.coef < 0:
+		return -1
+	case d.coef > 0:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.coef == 0
+}
+
+// Float64 returns the float64 nearest to d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String formats d without an exponent, e.g. "-0.005" or "2400".
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.coef, 10)
+	sign := ""
+	if d.coef < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	switch {
+	case d.exp >= 0:
+		return sign + digits + strings.Repeat("0", int(d.exp))
+	case -int(d.exp) < len(digits):
+		point := len(digits) + int(d.exp)
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	return sign + "0." + strings.Repeat("0", -int(d.exp)-len(digits)) + digits
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a number or a string holding one.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value stores d as a float64, for database/sql: columns indexing positions
+// are DOUBLE PRECISION, for querying. Exact values are in the snapshot kept
+// whole.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.Float64(), nil
+}
+
+// Scan reads a number or text column.
+func (d *Decimal) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*d = Decimal{}
+	case int64:
+		*d = NewDecimal(src, 0)
+	case float64:
+		*d = DecimalFromFloat(src)
+	case []byte:
+		return d.UnmarshalText(src)
+	case string:
+		return d.UnmarshalText([]byte(src))
+	default:
+		return fmt.Errorf("snapshot: cannot scan %T into a Decimal", src)
+	}
+	return nil
+}
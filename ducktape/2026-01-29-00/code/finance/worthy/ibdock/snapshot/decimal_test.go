@@ -0,0 +1,114 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// dec writes Decimals in test literals.
+func dec(x float64) Decimal {
+	return DecimalFromFloat(x)
+}
+
+func TestDecimal(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"2400.50", "2400.5"},
+		{"-0.005", "-0.005"},
+		{"1.5e3", "1500"},
+		{"+12e-4", "0.0012"},
+		{"0.000", "0"},
+		{"123456789012345678901", "123456789012345679000"},
+	} {
+		d, err := ParseDecimal(test.in)
+		if err != nil || d.String() != test.want {
+			t.Errorf("ParseDecimal(%q) = %v, %v, want %s", test.in, d, err, test.want)
+		}
+	}
+	for _, in := range []string{"", "-", "1.2.3", "1e", "0x10", "one", "10e2147483647", "1e401", "1e-500", "1e2000000000"} {
+		if d, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %v", in, d)
+		}
+	}
+
+	if d, err := ParseDecimal("1.7976931348623157e308"); err != nil || d.Float64() != math.MaxFloat64 {
+		t.Errorf("ParseDecimal of the largest float64 = %v, %v", d, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Quantity": "1e2000000000"}`), new(Position)); err == nil {
+		t.Errorf("decoded a quantity with a huge exponent")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("1e2000000000 × 1e2000000000 did not panic")
+			}
+		}()
+		t.Errorf("1e2000000000 × 1e2000000000 = %v", NewDecimal(1, 2000000000).Mul(NewDecimal(1, 2000000000)).exp)
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("1e2147483647 + 9e2147483647 did not panic")
+			}
+		}()
+		t.Errorf("1e2147483647 + 9e2147483647 = %v", NewDecimal(1, math.MaxInt32).Add(NewDecimal(9, math.MaxInt32)).exp)
+	}()
+
+	// Operands far apart sum and compare without scaling one by 10^gap.
+	huge, tiny := NewDecimal(1, 2000000000), NewDecimal(1, -2000000000)
+	for _, test := range []struct {
+		name      string
+		got, want Decimal
+	}{
+		{"huge + tiny", huge.Add(tiny), huge},
+		{"huge - tiny", huge.Sub(tiny), huge},
+		{"tiny - huge", tiny.Sub(huge), huge.Neg()},
+		{"0 + tiny", Decimal{}.Add(tiny), tiny},
+		{"tiny - 0", tiny.Sub(Decimal{}), tiny},
+		// Just within the gap, the smaller operand still rounds the sum.
+		{"1 + 5e-18", dec(1).Add(NewDecimal(5, -18)), dec(1)},
+		{"1 + 6e-18", dec(1).Add(NewDecimal(6, -18)), NewDecimal(100000000000000001, -17)},
+		{"99…9 + 5e-1", NewDecimal(999999999999999999, 0).Add(NewDecimal(5, -1)), NewDecimal(1, 18)},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+	if huge.Cmp(tiny) != 1 || tiny.Cmp(huge) != -1 || tiny.Neg().Cmp(huge.Neg()) != 1 || tiny.Cmp(Decimal{}) != 1 || tiny.Neg().Cmp(huge) != -1 {
+		t.Errorf("Cmp of operands far apart")
+	}
+
+	// Ten cents a hundred times is ten, which float64 sums miss.
+	var sum Decimal
+	for range 100 {
+		sum = sum.Add(dec(0.1))
+	}
+	if sum != NewDecimal(10, 0) {
+		t.Errorf("100 × 0.1 = %v", sum)
+	}
+	if got := dec(19.99).Mul(dec(3)).Sub(dec(59.97)); !got.IsZero() {
+		t.Errorf("19.99 × 3 - 59.97 = %v", got)
+	}
+	if dec(2.5).Cmp(dec(2.50001)) != -1 || dec(-1).Abs() != dec(1) || dec(-3).Sign() != -1 {
+		t.Errorf("Cmp, Abs or Sign")
+	}
+
+	var position Position
+	if err := json.Unmarshal([]byte(`{"Quantity": 0.30000000000000004, "AvgCost": "12.345", "MarketPrice": null}`), &position); err != nil {
+		t.Fatal(err)
+	}
+	if position.Quantity.String() != "0.30000000000000004" || position.AvgCost != NewDecimal(12345, -3) || !position.MarketPrice.IsZero() {
+		t.Errorf("decoded %+v", position)
+	}
+	data, err := json.Marshal(Position{MarketValue: NewDecimal(-100005, -2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || string(fields["MarketValue"]) != "-1000.05" {
+		t.Errorf("encoded %s", data)
+	}
+}
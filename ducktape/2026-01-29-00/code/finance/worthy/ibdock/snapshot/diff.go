@@ -0,0 +1,81 @@
+package snapshot
+
+// Changes is what changed between two snapshots of an account.
+type Changes struct {
+	// Opened are positions only in the newer snapshot.
+	Opened []Position
+	// Closed are positions only in the older snapshot.
+	Closed []Position
+	// Changed are positions in both whose quantity or market value differ.
+	Changed []PositionChange
+}
+
+// PositionChange is a position held in both snapshots.
+type PositionChange struct {
+	Before, After Position
+	QuantityDelta Decimal
+	ValueDelta    Decimal
+}
+
+// PositionsChanged reports whether positions were opened, closed or changed in
+// quantity; moves in market value alone do not count.
+func (c Changes) PositionsChanged() bool {
+	if len(c.Opened) > 0 || len(c.Closed) > 0 {
+		return true
+	}
+	for _, change := range c.Changed {
+		if !change.QuantityDelta.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// positionKey identifies a position across snapshots.
+type positionKey struct {
+	Symbol, SecType, Currency string
+}
+
+func keyOf(p Position) positionKey {
+	return positionKey{p.Symbol, p.SecType, p.Currency}
+}
+
+// Diff compares snapshot a with the newer snapshot b. Either may be nil, which
+// counts as having no positions. Results follow b's position order, with
+// Closed in a's.
+func Diff(a, b *Snapshot) Changes {
+	var changes Changes
+	before := make(map[positionKey]Position)
+	if a != nil {
+		for _, p := range a.Positions {
+			before[keyOf(p)] = p
+		}
+	}
+	seen := make(map[positionKey]bool)
+	if b != nil {
+		for _, p := range b.Positions {
+			key := keyOf(p)
+			seen[key] = true
+			old, ok := before[key]
+			switch {
+			case !ok:
+				changes.Opened = append(changes.Opened, p)
+			case old.Quantity != p.Quantity || old.MarketValue != p.MarketValue:
+				changes.Changed = append(changes.Changed, PositionChange{
+					Before:        old,
+					After:         p,
+					QuantityDelta: p.Quantity.Sub(old.Quantity),
+					ValueDelta:    p.MarketValue.Sub(old.MarketValue),
+				})
+			}
+		}
+	}
+	if a != nil {
+		for _, p := range a.Positions {
+			if !seen[keyOf(p)] {
+				changes.Closed = append(changes.Closed, p)
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,38 @@
+package snapshot
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a := &Snapshot{Positions: []Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(10), MarketValue: dec(1000)},
+		{Symbol: "BND", SecType: "STK", Currency: "USD", Quantity: dec(5), MarketValue: dec(350)},
+		{Symbol: "USD", SecType: "CASH", Currency: "USD", Quantity: dec(200), MarketValue: dec(200)},
+	}}
+	b := &Snapshot{Positions: []Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(10), MarketValue: dec(1010)},
+		{Symbol: "USD", SecType: "CASH", Currency: "USD", Quantity: dec(150), MarketValue: dec(150)},
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: dec(3), MarketValue: dec(330)},
+	}}
+	changes := Diff(a, b)
+	if len(changes.Opened) != 1 || changes.Opened[0].Symbol != "VWCE" {
+		t.Errorf("Opened = %+v", changes.Opened)
+	}
+	if len(changes.Closed) != 1 || changes.Closed[0].Symbol != "BND" {
+		t.Errorf("Closed = %+v", changes.Closed)
+	}
+	if len(changes.Changed) != 2 || changes.Changed[0].ValueDelta != dec(10) || changes.Changed[1].QuantityDelta != dec(-50) {
+		t.Errorf("Changed = %+v", changes.Changed)
+	}
+	if !changes.PositionsChanged() {
+		t.Errorf("PositionsChanged = false")
+	}
+
+	b.Positions = b.Positions[:1]
+	a.Positions = a.Positions[:1]
+	if changes := Diff(a, b); changes.PositionsChanged() || len(changes.Changed) != 1 {
+		t.Errorf("a mark-to-market move alone gave %+v", changes)
+	}
+	if changes := Diff(nil, b); len(changes.Opened) != 1 {
+		t.Errorf("Diff from nil = %+v", changes)
+	}
+}
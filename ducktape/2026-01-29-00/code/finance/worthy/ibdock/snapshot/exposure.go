@@ -0,0 +1,148 @@
+package snapshot
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// fxFutures maps the symbols of currency futures to the currency they buy,
+// priced in the contract's Currency.
+var fxFutures = map[string]string{
+	"AUD": "AUD", "M6A": "AUD",
+	"CAD": "CAD", "MCD": "CAD",
+	"CHF": "CHF", "MSF": "CHF",
+	"EUR": "EUR", "M6E": "EUR",
+	"GBP": "GBP", "M6B": "GBP",
+	"JPY": "JPY", "MJY": "JPY",
+	"MXP": "MXN",
+	"NZD": "NZD",
+}
+
+// Exposure is how much of a snapshot's value moves with one currency.
+type Exposure struct {
+	Currency string
+	// Value is in the base currency of the FXRates the exposure was computed
+	// with. It is negative for net short exposure.
+	Value         float64
+	Contributions []Contribution
+}
+
+// Contribution is one position's part of an Exposure.
+type Contribution struct {
+	Symbol  string
+	SecType string
+	// Value is in the base currency, like Exposure.Value.
+	Value float64
+}
+
+// value returns the market value of p and the currency it is in: Currency,
+// but for plain cash balances (Symbol equal to or without Currency), whose
+// value IB may leave out as it is just their Quantity.
+func (p Position) value() (string, float64) {
+	unit, value := p.decimalValue()
+	return unit, value.Float64()
+}
+
+// decimalValue is value, exactly.
+func (p Position) decimalValue() (string, Decimal) {
+	if p.SecType == "CASH" && (p.Currency == "" || p.Currency == p.Symbol) {
+		if p.MarketValue.IsZero() {
+			return p.Symbol, p.Quantity
+		}
+		return p.Symbol, p.MarketValue
+	}
+	return p.Currency, p.MarketValue
+}
+
+// legs returns the currencies p is exposed to and by how much, in units of
+// the currency value returns. Raw position currency gets derivatives wrong in
+// both directions, so this looks through them:
+//
+//   - Currency futures are long their currency and short Currency by their
+//     notional, which IB reports as the market value.
+//   - Other futures are unfunded, so their notional is no exposure at all;
+//     their gains and losses settle into cash daily.
+//   - CASH positions in a pair (Symbol EUR, Currency USD) are spot or forward
+//     FX, long Symbol and short Currency.
+//   - Everything else, plain cash balances included, is held in the currency
+//     of its value.
+func (p Position) legs() (string, map[string]float64) {
+	unit, value := p.value()
+	switch {
+	case p.SecType == "FUT":
+		if base, ok := fxFutures[p.Symbol]; ok && base != p.Currency {
+			return unit, map[string]float64{base: value, unit: -value}
+		}
+		return unit, nil
+	case p.SecType == "CASH" && unit != p.Symbol:
+		return unit, map[string]float64{p.Symbol: value, unit: -value}
+	}
+	return unit, map[string]float64{unit: value}
+}
+
+// Currencies returns the sorted currencies CurrencyExposure needs rates for.
+func (s *Snapshot) Currencies() []string {
+	var currencies []string
+	for _, p := range s.Positions {
+		currencies = append(currencies, p.Currency)
+		if p.SecType == "CASH" {
+			currencies = append(currencies, p.Symbol)
+		}
+		if base, ok := fxFutures[p.Symbol]; ok && p.SecType == "FUT" {
+			currencies = append(currencies, base)
+		}
+	}
+	sort.Strings(currencies)
+	currencies = slices.Compact(currencies)
+	if len(currencies) > 0 && currencies[0] == "" {
+		currencies = currencies[1:]
+	}
+	return currencies
+}
+
+// CurrencyExposure returns the effective exposure of the snapshot to each
+// currency, valued with rates, largest first. Currencies a position touches
+// only through offsetting legs are left out.
+func (s *Snapshot) CurrencyExposure(rates FXRates) ([]Exposure, error) {
+	exposures := make(map[string]*Exposure)
+	var missing []string
+	for _, p := range s.Positions {
+		unit, legs := p.legs()
+		for currency, value := range legs {
+			if value == 0 {
+				continue
+			}
+			rate, ok := rates[unit]
+			if !ok {
+				missing = append(missing, unit)
+				continue
+			}
+			e, ok := exposures[currency]
+			if !ok {
+				e = &Exposure{Currency: currency}
+				exposures[currency] = e
+			}
+			e.Value += value * rate
+			e.Contributions = append(e.Contributions, Contribution{Symbol: p.Symbol, SecType: p.SecType, Value: value * rate})
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, fmt.Errorf("snapshot: no FX rates for %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	var report []Exposure
+	for _, e := range exposures {
+		if e.Value != 0 {
+			report = append(report, *e)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Value != report[j].Value {
+			return report[i].Value > report[j].Value
+		}
+		return report[i].Currency < report[j].Currency
+	})
+	return report, nil
+}
@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCurrencyExposure(t *testing.T) {
+	s := &Snapshot{Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", MarketValue: dec(1000)},
+		{Symbol: "VT", SecType: "STK", Currency: "USD", MarketValue: dec(500)},
+		// Hedges 600 USD worth of the euros back into dollars.
+		{Symbol: "EUR", SecType: "CASH", Currency: "USD", Quantity: dec(-500), MarketValue: dec(-600)},
+		// An FX future: 1250 USD of yen, funded by a dollar short.
+		{Symbol: "JPY", SecType: "FUT", Currency: "USD", MarketValue: dec(1250)},
+		// An index future has no currency exposure past its P&L, which is
+		// settled into cash.
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(25000)},
+		{Symbol: "CHF", SecType: "CASH", Quantity: dec(100)},
+	}}
+	if got, want := s.Currencies(), []string{"CHF", "EUR", "JPY", "USD"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Currencies = %v, want %v", got, want)
+	}
+	if _, err := s.CurrencyExposure(FXRates{"USD": 1}); err == nil {
+		t.Errorf("CurrencyExposure without EUR and CHF rates succeeded")
+	}
+	report, err := s.CurrencyExposure(FXRates{"USD": 1, "EUR": 1.2, "CHF": 1.25, "JPY": 0.0065})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, e := range report {
+		got[e.Currency] = e.Value
+	}
+	// USD: 500 + 600 - 1250 = -150.
+	want := map[string]float64{"EUR": 600, "JPY": 1250, "CHF": 125, "USD": -150}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exposure = %v, want %v", got, want)
+	}
+	if report[0].Currency != "JPY" || len(report[0].Contributions) != 1 {
+		t.Errorf("report not sorted largest first: %+v", report)
+	}
+}
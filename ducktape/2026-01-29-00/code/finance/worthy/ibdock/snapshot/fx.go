@@ -0,0 +1,83 @@
+package snapshot
+
+import (
+	"fmt"
+	"time"
+)
+
+// FXRates maps currency codes to how many units of some base currency one
+// unit of them is worth. The base currency itself maps to 1.
+type FXRates map[string]float64
+
+// Value is the net value of the snapshot in the base currency of rates.
+// Futures count for nothing, as their notional is not value held.
+func (s *Snapshot) Value(rates FXRates) (Decimal, error) {
+	var total Decimal
+	for _, p := range s.Positions {
+		value, err := p.ValueIn(rates)
+		if err != nil {
+			return Decimal{}, err
+		}
+		total = total.Add(value)
+	}
+	return total, nil
+}
+
+// ValueIn is the position's value in the base currency of rates, zero for
+// futures like in Snapshot.Value.
+func (p Position) ValueIn(rates FXRates) (Decimal, error) {
+	if p.SecType == "FUT" {
+		return Decimal{}, nil
+	}
+	unit, value := p.decimalValue()
+	rate, ok := rates[unit]
+	if !ok {
+		return Decimal{}, fmt.Errorf("snapshot: no FX rate for %s", unit)
+	}
+	return value.Mul(DecimalFromFloat(rate)), nil
+}
+
+// Converted is a snapshot valued in one currency, see ConvertTo.
+type Converted struct {
+	Base      string
+	Timestamp time.Time
+	// Total is the net value, futures counting for nothing as in Value.
+	Total     Decimal
+	Positions []ConvertedPosition
+}
+
+// ConvertedPosition is a position with its value in the Converted's Base.
+type ConvertedPosition struct {
+	Position
+	// Rate is what one unit of the currency the position is valued in is
+	// worth in Base; zero for futures.
+	Rate  float64
+	Value Decimal
+}
+
+// ConvertTo values the snapshot's positions and total in base, at rates
+// whose base currency it must be, e.g. from ibdock's Dock.GetFXRates.
+func (s *Snapshot) ConvertTo(base string, rates FXRates) (*Converted, error) {
+	if rate, ok := rates[base]; ok && rate != 1 {
+		return nil, fmt.Errorf("snapshot: FX rates are not in %s", base)
+	}
+	converted := &Converted{Base: base, Timestamp: s.Timestamp}
+	for _, p := range s.Positions {
+		position := ConvertedPosition{Position: p}
+		if p.SecType != "FUT" {
+			unit, value := p.decimalValue()
+			rate, ok := rates[unit]
+			if unit == base {
+				rate, ok = 1, true
+			}
+			if !ok {
+				return nil, fmt.Errorf("snapshot: no FX rate for %s", unit)
+			}
+			position.Rate = rate
+			position.Value = value.Mul(DecimalFromFloat(rate))
+			converted.Total = converted.Total.Add(position.Value)
+		}
+		converted.Positions = append(converted.Positions, position)
+	}
+	return converted, nil
+}
@@ -0,0 +1,59 @@
+package snapshot
+
+import "testing"
+
+func TestConvertTo(t *testing.T) {
+	s := &Snapshot{Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: dec(50), MarketValue: dec(5000.1)},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(250000)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(0.1)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(0.2)},
+	}}
+	converted, err := s.ConvertTo("USD", FXRates{"USD": 1, "EUR": 1.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.1 + 0.2 is exactly 0.3 here, unlike in float64.
+	if converted.Base != "USD" || converted.Total != dec(5500.41) {
+		t.Errorf("converted to %s with total %v, want USD 5500.41", converted.Base, converted.Total)
+	}
+	want := []struct {
+		rate  float64
+		value Decimal
+	}{{1.1, dec(5500.11)}, {0, Decimal{}}, {1, dec(0.1)}, {1, dec(0.2)}}
+	for i, p := range converted.Positions {
+		if p.Symbol != s.Positions[i].Symbol || p.Rate != want[i].rate || p.Value != want[i].value {
+			t.Errorf("position %d = %s at %v: %v, want %v at %v", i, p.Symbol, p.Rate, p.Value, want[i].value, want[i].rate)
+		}
+	}
+	if _, err := s.ConvertTo("USD", FXRates{"USD": 1}); err == nil {
+		t.Errorf("ConvertTo without a EUR rate succeeded")
+	}
+	if _, err := s.ConvertTo("EUR", FXRates{"USD": 1, "EUR": 1.1}); err == nil {
+		t.Errorf("ConvertTo with USD rates to EUR succeeded")
+	}
+}
+
+func TestValue(t *testing.T) {
+	s := &Snapshot{Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: dec(50), MarketValue: dec(5000.1)},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(250000)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(0.1)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(0.2)},
+	}}
+	rates := FXRates{"USD": 1, "EUR": 1.1}
+	value, err := s.Value(rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := s.ConvertTo("USD", rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != dec(5500.41) || value != converted.Total {
+		t.Errorf("Value = %v, want 5500.41 like ConvertTo's %v", value, converted.Total)
+	}
+	if _, err := s.Value(FXRates{"USD": 1}); err == nil {
+		t.Errorf("Value without a EUR rate succeeded")
+	}
+}
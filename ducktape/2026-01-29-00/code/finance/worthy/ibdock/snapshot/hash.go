@@ -0,0 +1,113 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hash is a hex SHA-256 of what s holds: its account, positions and pending
+// transfers, in no particular order. Timestamp and AccountName are left out,
+// so snapshots of an account that nothing happened to in between hash the
+// same, whichever format they were read back from.
+func (s *Snapshot) Hash() string {
+	var lines []string
+	for _, p := range s.Positions {
+		lines = append(lines, strings.Join([]string{
+			"P", p.Symbol, p.SecType, p.Currency, p.Quantity.String(), p.AvgCost.String(),
+			p.MarketPrice.String(), p.MarketValue.String(), fmt.Sprint(p.InTransfer),
+		}, "\x00"))
+	}
+	for _, t := range s.PendingTransfers {
+		lines = append(lines, strings.Join([]string{
+			"T", t.Direction, t.Symbol, t.Currency, t.Quantity.String(), t.Value.String(),
+			t.Initiated.UTC().Format(time.RFC3339Nano),
+		}, "\x00"))
+	}
+	slices.Sort(lines)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", s.Account)
+	for _, line := range lines {
+		fmt.Fprintf(h, "%s\n", line)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Dedupe remembers the Hash of the last snapshot of each account passed on,
+// to skip passing on identical ones again, as sinks do nightly otherwise. It
+// is safe for concurrent use.
+type Dedupe struct {
+	// Path, if set, is a JSON file keeping the hashes across runs, for
+	// one-shot commands run on a schedule.
+	Path string
+
+	mu     sync.Mutex
+	loaded bool
+	last   map[string]string
+}
+
+// Unchanged reports whether s hashes the same as the last snapshot of its
+// account recorded. A missing or unreadable Path counts as none recorded.
+func (d *Dedupe) Unchanged(s *Snapshot) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	last, ok := d.last[s.Account]
+	return ok && last == s.Hash()
+}
+
+// Record remembers s as the last snapshot of its account, saving Path.
+func (d *Dedupe) Record(s *Snapshot) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	d.last[s.Account] = s.Hash()
+	if d.Path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(d.last, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(d.Path), "."+filepath.Base(d.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), d.Path)
+}
+
+func (d *Dedupe) load() {
+	if d.loaded {
+		return
+	}
+	d.loaded = true
+	d.last = make(map[string]string)
+	if d.Path == "" {
+		return
+	}
+	data, err := os.ReadFile(d.Path)
+	if err == nil {
+		err = json.Unmarshal(data, &d.last)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// A corrupt file costs one duplicate per account, not the snapshots.
+		d.last = make(map[string]string)
+	}
+}
@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHash(t *testing.T) {
+	a := &Snapshot{
+		Account:   "U1234567",
+		Timestamp: time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC),
+		Positions: []Position{
+			{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(10), MarketValue: dec(1000)},
+			{Symbol: "USD", SecType: "CASH", Currency: "USD", Quantity: dec(200), MarketValue: dec(200)},
+		},
+	}
+	b := &Snapshot{
+		Account:     "U1234567",
+		AccountName: "Retirement",
+		Timestamp:   a.Timestamp.Add(24 * time.Hour),
+		Positions:   []Position{a.Positions[1], a.Positions[0]},
+	}
+	if a.Hash() != b.Hash() {
+		t.Errorf("reordered positions at another time hash differently")
+	}
+	data, err := Marshal("csv", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Snapshot
+	if err := Unmarshal("csv", data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != a.Hash() {
+		t.Errorf("snapshot read back from CSV hashes differently")
+	}
+
+	b.Positions[0].MarketValue = dec(201)
+	if a.Hash() == b.Hash() {
+		t.Errorf("changed market value hashes the same")
+	}
+	c := &Snapshot{Account: "U7654321", Positions: a.Positions}
+	if a.Hash() == c.Hash() {
+		t.Errorf("another account hashes the same")
+	}
+	c = &Snapshot{Account: a.Account, Positions: a.Positions, PendingTransfers: []Transfer{{Direction: TransferIn, Currency: "USD", Quantity: dec(100)}}}
+	if a.Hash() == c.Hash() {
+		t.Errorf("pending transfer hashes the same")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes.json")
+	a := &Snapshot{Account: "U1234567", Positions: []Position{{Symbol: "VT", Quantity: dec(10)}}}
+	b := &Snapshot{Account: "U1234567", Positions: []Position{{Symbol: "VT", Quantity: dec(11)}}}
+	d := &Dedupe{Path: path}
+	if d.Unchanged(a) {
+		t.Errorf("first snapshot unchanged")
+	}
+	if err := d.Record(a); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Unchanged(a) || d.Unchanged(b) {
+		t.Errorf("Unchanged(a) = %v, Unchanged(b) = %v after recording a", d.Unchanged(a), d.Unchanged(b))
+	}
+	if other := (&Snapshot{Account: "U7654321", Positions: a.Positions}); d.Unchanged(other) {
+		t.Errorf("another account's snapshot unchanged")
+	}
+	if reloaded := (&Dedupe{Path: path}); !reloaded.Unchanged(a) {
+		t.Errorf("hash not kept in %s", path)
+	}
+}
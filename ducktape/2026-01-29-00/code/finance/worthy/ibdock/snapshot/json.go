@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonCodec reads and writes snapshots as JSON objects carrying their
+// SchemaVersion, migrating older ones.
+type jsonCodec struct{}
+
+type versionedSnapshot struct {
+	SchemaVersion int
+	*Snapshot
+}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(s *Snapshot) ([]byte, error) {
+	return json.Marshal(versionedSnapshot{SchemaVersion, s})
+}
+
+func (jsonCodec) Unmarshal(data []byte, s *Snapshot) error {
+	data, err := migrate(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &versionedSnapshot{Snapshot: s})
+}
+
+// Fields that strict decoding requires; the omitempty ones may be missing.
+var (
+	requiredFields         = []string{"Account", "Timestamp", "Positions"}
+	requiredPositionFields = []string{"Symbol", "SecType", "Currency", "Quantity", "AvgCost", "MarketPrice", "MarketValue"}
+)
+
+func (jsonCodec) UnmarshalStrict(data []byte, s *Snapshot) error {
+	data, err := migrate(data)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&versionedSnapshot{Snapshot: s}); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if err := checkFields("snapshot", fields, requiredFields); err != nil {
+		return err
+	}
+	var positions struct {
+		Positions []map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(data, &positions); err != nil {
+		return err
+	}
+	for i, position := range positions.Positions {
+		if err := checkFields(fmt.Sprintf("position %d", i), position, requiredPositionFields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkFields(what string, fields map[string]json.RawMessage, required []string) error {
+	for _, field := range required {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("snapshot: %s is missing field %s", what, field)
+		}
+	}
+	return nil
+}
+
+func init() {
+	Register("json", jsonCodec{})
+}
@@ -0,0 +1,37 @@
+// Package msgpack registers the "msgpack" snapshot format. Import it for its
+// side effect:
+//
+//	import _ "github.com/agentydragon/worthy/ibdock/snapshot/msgpack"
+package msgpack
+
+import (
+	"bytes"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (codec) Marshal(s *snapshot.Snapshot) ([]byte, error) {
+	return msgpack.Marshal(s)
+}
+
+func (codec) Unmarshal(data []byte, s *snapshot.Snapshot) error {
+	return msgpack.Unmarshal(data, s)
+}
+
+// UnmarshalStrict fails on fields Snapshot does not have. msgpack cannot tell
+// missing fields from zero ones.
+func (codec) UnmarshalStrict(data []byte, s *snapshot.Snapshot) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields(true)
+	return decoder.Decode(s)
+}
+
+func init() {
+	snapshot.Register("msgpack", codec{})
+}
@@ -0,0 +1,18 @@
+package snapshot
+
+// Nicknames maps raw IB account IDs to the names they should be shown under.
+type Nicknames map[string]string
+
+// Name returns the nickname of account, or the account ID itself if it has
+// none.
+func (n Nicknames) Name(account string) string {
+	if name, ok := n[account]; ok {
+		return name
+	}
+	return account
+}
+
+// Apply fills in s.AccountName. s.Account keeps the raw ID.
+func (n Nicknames) Apply(s *Snapshot) {
+	s.AccountName = n.Name(s.Account)
+}
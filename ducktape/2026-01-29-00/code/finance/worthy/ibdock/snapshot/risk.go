@@ -0,0 +1,84 @@
+package snapshot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	RiskConcentration = "concentration"
+	RiskLiquidity     = "liquidity"
+)
+
+// RiskLimits configures Risk. Zero limits are not checked.
+type RiskLimits struct {
+	// MaxWeight is the largest share of the portfolio one position may be,
+	// e.g. 0.2 for 20%.
+	MaxWeight float64
+	// MaxDaysToLiquidate is the most trading days selling a position may
+	// take, trading Participation of the average daily volume each day.
+	MaxDaysToLiquidate float64
+	// Participation defaults to 0.1.
+	Participation float64
+}
+
+// RiskFlag is a position over one of the RiskLimits.
+type RiskFlag struct {
+	// Kind is RiskConcentration or RiskLiquidity.
+	Kind   string
+	Symbol string
+	// Value is the position's weight or days to liquidate, Limit the limit
+	// it exceeds.
+	Value float64
+	Limit float64
+}
+
+func (f RiskFlag) String() string {
+	if f.Kind == RiskConcentration {
+		return fmt.Sprintf("%s is %.1f%% of the portfolio (limit %.1f%%)", f.Symbol, 100*f.Value, 100*f.Limit)
+	}
+	return fmt.Sprintf("%s takes %.1f days to liquidate (limit %.1f)", f.Symbol, f.Value, f.Limit)
+}
+
+// Risk flags positions that are too large a part of the portfolio, valued
+// with rates, or too large for their average daily volume, as given by
+// volumes keyed by symbol. Positions without a volume are not checked for
+// liquidity. Cash is never flagged, but counts towards the portfolio's value.
+// Futures do neither: their notional is not value held, see legs.
+func (s *Snapshot) Risk(limits RiskLimits, rates FXRates, volumes map[string]float64) ([]RiskFlag, error) {
+	participation := limits.Participation
+	if participation <= 0 {
+		participation = 0.1
+	}
+	values := make([]float64, len(s.Positions))
+	total := 0.0
+	for i, p := range s.Positions {
+		if p.SecType == "FUT" {
+			continue
+		}
+		unit, value := p.value()
+		rate, ok := rates[unit]
+		if !ok {
+			return nil, fmt.Errorf("snapshot: no FX rate for %s", unit)
+		}
+		values[i] = math.Abs(value * rate)
+		total += values[i]
+	}
+	var flags []RiskFlag
+	for i, p := range s.Positions {
+		if p.SecType == "CASH" || p.SecType == "FUT" {
+			continue
+		}
+		if limits.MaxWeight > 0 && total > 0 && values[i]/total > limits.MaxWeight {
+			flags = append(flags, RiskFlag{Kind: RiskConcentration, Symbol: p.Symbol, Value: values[i] / total, Limit: limits.MaxWeight})
+		}
+		if volume := volumes[p.Symbol]; limits.MaxDaysToLiquidate > 0 && volume > 0 {
+			if days := math.Abs(p.Quantity.Float64()) / (participation * volume); days > limits.MaxDaysToLiquidate {
+				flags = append(flags, RiskFlag{Kind: RiskLiquidity, Symbol: p.Symbol, Value: days, Limit: limits.MaxDaysToLiquidate})
+			}
+		}
+	}
+	sort.SliceStable(flags, func(i, j int) bool { return flags[i].Value/flags[i].Limit > flags[j].Value/flags[j].Limit })
+	return flags, nil
+}
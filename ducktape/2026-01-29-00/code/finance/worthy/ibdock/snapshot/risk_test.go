@@ -0,0 +1,32 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRisk(t *testing.T) {
+	s := &Snapshot{Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: dec(50), MarketValue: dec(5000)},
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(20), MarketValue: dec(2400)},
+		{Symbol: "TINY", SecType: "STK", Currency: "USD", Quantity: dec(1000), MarketValue: dec(1000)},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(250000)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(1600)},
+	}}
+	rates := FXRates{"USD": 1, "EUR": 1.2}
+	// VWCE is 6000 of 11000.
+	flags, err := s.Risk(RiskLimits{MaxWeight: 0.5, MaxDaysToLiquidate: 5}, rates, map[string]float64{"VT": 3000000, "TINY": 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RiskFlag{
+		{Kind: RiskLiquidity, Symbol: "TINY", Value: 20, Limit: 5},
+		{Kind: RiskConcentration, Symbol: "VWCE", Value: 6000.0 / 11000, Limit: 0.5},
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("Risk = %+v, want %+v", flags, want)
+	}
+	if _, err := s.Risk(RiskLimits{MaxWeight: 0.5}, FXRates{"USD": 1}, nil); err == nil {
+		t.Errorf("Risk without a EUR rate succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the JSON snapshot schema, which the json
+// codec writes in a top-level SchemaVersion field. It goes up with every
+// change old decoders would misread, along with a migration from the
+// previous version.
+//
+// Version 1 is JSON without the field, as snapshot scripts and stores wrote
+// it before the versioning.
+const SchemaVersion = 2
+
+// MinSchemaVersion is the oldest version the json codec still reads.
+const MinSchemaVersion = 1
+
+// ErrUnsupportedSchema is matched by SchemaErrors.
+var ErrUnsupportedSchema = errors.New("unsupported snapshot schema")
+
+// SchemaError reports a JSON snapshot in a schema version the json codec
+// cannot read, outside MinSchemaVersion to SchemaVersion.
+type SchemaError struct {
+	Version int
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("snapshot: schema version %d is not between %d and %d", e.Version, MinSchemaVersion, SchemaVersion)
+}
+
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrUnsupportedSchema
+}
+
+// migrations[v] upgrades the top-level fields of a version v snapshot to
+// version v+1, for each v from MinSchemaVersion up.
+var migrations = map[int]func(fields map[string]json.RawMessage) error{
+	// Version 2 only added SchemaVersion. Version 1 covers every unversioned
+	// output: fields added since, like AccountName, PendingTransfers and
+	// InTransfer, are optional, and Decimal reads the float values of the
+	// oldest.
+	1: func(map[string]json.RawMessage) error { return nil },
+}
+
+// migrate returns data, a JSON snapshot of any supported version, in the
+// current SchemaVersion.
+func migrate(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	version := 1
+	if raw, ok := fields["SchemaVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("snapshot: SchemaVersion: %w", err)
+		}
+	}
+	if version < MinSchemaVersion || version > SchemaVersion {
+		return nil, &SchemaError{Version: version}
+	}
+	if version == SchemaVersion {
+		return data, nil
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage)
+	}
+	for ; version < SchemaVersion; version++ {
+		if err := migrations[version](fields); err != nil {
+			return nil, fmt.Errorf("snapshot: migrating schema version %d: %w", version, err)
+		}
+	}
+	fields["SchemaVersion"] = json.RawMessage(fmt.Sprint(SchemaVersion))
+	return json.Marshal(fields)
+}
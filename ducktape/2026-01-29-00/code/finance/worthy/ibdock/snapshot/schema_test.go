@@ -0,0 +1,48 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"testing"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	data, err := snapshot.Marshal("json", &snapshot.Snapshot{Account: "U1"})
+	if err != nil || !bytes.HasPrefix(data, []byte(`{"SchemaVersion":2,"Account":"U1"`)) {
+		t.Errorf("Marshal = %s, %v; want SchemaVersion 2 first", data, err)
+	}
+
+	// An unversioned snapshot from before decimal values was version 1.
+	v1 := `{"Account": "U1", "Timestamp": "2026-01-29T00:00:00Z", "Positions": [{"Symbol": "VT", "SecType": "STK", "Currency": "USD", "Quantity": 10, "AvgCost": 95.5, "MarketPrice": 101.25, "MarketValue": 1012.5}]}`
+	for _, unmarshal := range []func(string, []byte, *snapshot.Snapshot) error{snapshot.Unmarshal, snapshot.UnmarshalStrict} {
+		var s snapshot.Snapshot
+		if err := unmarshal("json", []byte(v1), &s); err != nil {
+			t.Errorf("decoding version 1: %v", err)
+		} else if s.Account != "U1" || len(s.Positions) != 1 || s.Positions[0].MarketValue.String() != "1012.5" {
+			t.Errorf("version 1 decoded as %+v", s)
+		}
+	}
+
+	for _, bad := range []struct {
+		data    string
+		version int
+	}{
+		{`{"SchemaVersion": 3, "Account": "U1"}`, 3},
+		{`{"SchemaVersion": 0, "Account": "U1"}`, 0},
+	} {
+		var s snapshot.Snapshot
+		err := snapshot.Unmarshal("json", []byte(bad.data), &s)
+		var schema *snapshot.SchemaError
+		if !errors.Is(err, snapshot.ErrUnsupportedSchema) || !errors.As(err, &schema) || schema.Version != bad.version {
+			t.Errorf("decoding %s: %v, want ErrUnsupportedSchema", bad.data, err)
+		}
+		if err := snapshot.UnmarshalStrict("json", []byte(bad.data), &s); !errors.Is(err, snapshot.ErrUnsupportedSchema) {
+			t.Errorf("strict decoding %s: %v, want ErrUnsupportedSchema", bad.data, err)
+		}
+	}
+	var s snapshot.Snapshot
+	if err := snapshot.Unmarshal("json", []byte(`{"SchemaVersion": "2"}`), &s); err == nil {
+		t.Errorf("decoding a string SchemaVersion should fail")
+	}
+}
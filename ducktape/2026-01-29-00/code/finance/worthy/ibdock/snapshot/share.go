@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Share is a snapshot redacted for showing to others, e.g. an advisor: what
+// it holds in what proportions and how that has done, without any amounts,
+// quantities or the account.
+type Share struct {
+	Timestamp time.Time
+	// Allocations are the positions' weights, largest first.
+	Allocations []Allocation
+	// Return is the unrealized gain of the positions with a cost basis,
+	// relative to that basis, e.g. 0.1 for 10%.
+	Return float64
+}
+
+// Allocation is one position's part of a Share.
+type Allocation struct {
+	Symbol  string
+	SecType string
+	// Weight is the part of the snapshot's net value, negative for shorts.
+	Weight float64
+	// Return is the position's unrealized gain relative to its cost, zero
+	// for cash and positions without a cost basis.
+	Return float64 `json:",omitempty"`
+}
+
+// Share redacts the snapshot, weighing positions by their value at rates.
+// Futures are left out, as their notional is not value held.
+func (s *Snapshot) Share(rates FXRates) (*Share, error) {
+	total, err := s.Value(rates)
+	if err != nil {
+		return nil, err
+	}
+	if total.Sign() <= 0 {
+		return nil, errors.New("snapshot: cannot share weights of a snapshot without positive value")
+	}
+	share := &Share{Timestamp: s.Timestamp}
+	var cost, gain float64
+	for _, p := range s.Positions {
+		if p.SecType == "FUT" {
+			continue
+		}
+		value, err := p.ValueIn(rates)
+		if err != nil {
+			return nil, err
+		}
+		// Weights and returns are ratios, which Decimal cannot divide.
+		a := Allocation{Symbol: p.Symbol, SecType: p.SecType, Weight: value.Float64() / total.Float64()}
+		if basis := p.Quantity.Mul(p.AvgCost); p.SecType != "CASH" && !p.InTransfer && !basis.IsZero() {
+			// Shorts have a negative basis and gain as their value falls
+			// towards zero.
+			rate := rates[p.Currency]
+			unrealized := p.MarketValue.Sub(basis).Float64()
+			a.Return = unrealized / basis.Abs().Float64()
+			cost += basis.Abs().Float64() * rate
+			gain += unrealized * rate
+		}
+		share.Allocations = append(share.Allocations, a)
+	}
+	if cost > 0 {
+		share.Return = gain / cost
+	}
+	sort.SliceStable(share.Allocations, func(i, j int) bool {
+		return math.Abs(share.Allocations[i].Weight) > math.Abs(share.Allocations[j].Weight)
+	})
+	return share, nil
+}
@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShare(t *testing.T) {
+	s := &Snapshot{Account: "U1111111", Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", Quantity: dec(50), AvgCost: dec(80), MarketValue: dec(5000)},
+		{Symbol: "TSLA", SecType: "STK", Currency: "USD", Quantity: dec(-10), AvgCost: dec(250), MarketValue: dec(-2000)},
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(20), MarketValue: dec(2400), InTransfer: true},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(250000)},
+		{Symbol: "USD", SecType: "CASH", Quantity: dec(1600)},
+	}}
+	// 6000 - 2000 + 2400 + 1600 = 8000 USD.
+	share, err := s.Share(FXRates{"USD": 1, "EUR": 1.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Allocation{
+		{Symbol: "VWCE", SecType: "STK", Weight: 0.75, Return: 0.25},
+		{Symbol: "VT", SecType: "STK", Weight: 0.3},
+		{Symbol: "TSLA", SecType: "STK", Weight: -0.25, Return: 0.2},
+		{Symbol: "USD", SecType: "CASH", Weight: 0.2},
+	}
+	if len(share.Allocations) != len(want) {
+		t.Fatalf("Allocations = %+v, want %+v", share.Allocations, want)
+	}
+	for i, a := range share.Allocations {
+		w := want[i]
+		if a.Symbol != w.Symbol || math.Abs(a.Weight-w.Weight) > 1e-9 || math.Abs(a.Return-w.Return) > 1e-9 {
+			t.Errorf("Allocations[%d] = %+v, want %+v", i, a, w)
+		}
+	}
+	// Gains of 1200 and 500 USD on a cost of 4800 + 2500.
+	if want := 1700.0 / 7300; math.Abs(share.Return-want) > 1e-9 {
+		t.Errorf("Return = %v, want %v", share.Return, want)
+	}
+	if _, err := (&Snapshot{}).Share(FXRates{"USD": 1}); err == nil {
+		t.Errorf("Share of an empty snapshot succeeded")
+	}
+}
@@ -0,0 +1,41 @@
+// Package snapshot holds the typed portfolio snapshot read out of an IB
+// session, and the registry of formats it can be (de)serialized in.
+//
+//	data, err := snapshot.Marshal("json", s)
+//	if err != nil {
+//	  panic(err)
+//	}
+//	var decoded snapshot.Snapshot
+//	err = snapshot.Unmarshal("json", data, &decoded)
+package snapshot
+
+import "time"
+
+// Snapshot is the state of one IB account at one point in time.
+type Snapshot struct {
+	// Account is the raw IB account ID, e.g. U1234567.
+	Account string
+	// AccountName is the human-readable name of Account, see Nicknames.
+	AccountName string `json:",omitempty"`
+	Timestamp   time.Time
+	Positions   []Position
+	// PendingTransfers lists transfers that were initiated but have not
+	// settled yet.
+	PendingTransfers []Transfer `json:",omitempty"`
+}
+
+// Position is a single holding. Values are in the position's Currency.
+type Position struct {
+	Symbol string
+	// IB security type: STK, OPT, FUT, CASH, BOND, ...
+	SecType     string
+	Currency    string
+	Quantity    Decimal
+	AvgCost     Decimal
+	MarketPrice Decimal
+	MarketValue Decimal
+	// InTransfer marks a position that arrived by transfer and has no cost
+	// basis yet: IB reports it with a zero AvgCost, so returns computed
+	// from it would be bogus.
+	InTransfer bool `json:",omitempty"`
+}
@@ -0,0 +1,301 @@
+// Package snapshotpb registers the "protobuf" snapshot format, laid out as in
+// snapshot.proto. It encodes directly with protowire instead of generated
+// code, so there is no protoc step in the build. Import it for its side effect:
+//
+//	import _ "github.com/agentydragon/worthy/ibdock/snapshot/snapshotpb"
+package snapshotpb
+
+import (
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"time"
+)
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (codec) Marshal(s *snapshot.Snapshot) ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, s.Account)
+	if !s.Timestamp.IsZero() {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimestamp(s.Timestamp))
+	}
+	b = appendString(b, 4, s.AccountName)
+	for _, transfer := range s.PendingTransfers {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTransfer(transfer))
+	}
+	for _, position := range s.Positions {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalPosition(position))
+	}
+	return b, nil
+}
+
+func (codec) Unmarshal(data []byte, s *snapshot.Snapshot) error {
+	return decoder{}.snapshot(data, s)
+}
+
+// UnmarshalStrict fails on unknown field numbers. Like any proto3 decoder, it
+// cannot tell missing fields from zero ones.
+func (codec) UnmarshalStrict(data []byte, s *snapshot.Snapshot) error {
+	return decoder{strict: true}.snapshot(data, s)
+}
+
+type decoder struct {
+	strict bool
+}
+
+// skip consumes a field the message does not define.
+func (d decoder) skip(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+	if d.strict {
+		return 0, fmt.Errorf("snapshotpb: unknown field %d", num)
+	}
+	return protowire.ConsumeFieldValue(num, typ, value), nil
+}
+
+func (d decoder) snapshot(data []byte, s *snapshot.Snapshot) error {
+	*s = snapshot.Snapshot{}
+	return walk(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(value)
+			s.Account = v
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := d.unmarshalTimestamp(v)
+			s.Timestamp = t
+			return n, err
+		case num == 3 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			position, err := d.unmarshalPosition(v)
+			s.Positions = append(s.Positions, position)
+			return n, err
+		case num == 4 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(value)
+			s.AccountName = v
+			return n, nil
+		case num == 5 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			transfer, err := d.unmarshalTransfer(v)
+			s.PendingTransfers = append(s.PendingTransfers, transfer)
+			return n, err
+		}
+		return d.skip(num, typ, value)
+	})
+}
+
+func marshalPosition(p snapshot.Position) []byte {
+	var b []byte
+	b = appendString(b, 1, p.Symbol)
+	b = appendString(b, 2, p.SecType)
+	b = appendString(b, 3, p.Currency)
+	b = appendDecimal(b, 9, p.Quantity)
+	b = appendDecimal(b, 10, p.AvgCost)
+	b = appendDecimal(b, 11, p.MarketPrice)
+	b = appendDecimal(b, 12, p.MarketValue)
+	if p.InTransfer {
+		b = protowire.AppendTag(b, 8, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+func (d decoder) unmarshalPosition(data []byte) (snapshot.Position, error) {
+	var p snapshot.Position
+	stringFields := map[protowire.Number]*string{1: &p.Symbol, 2: &p.SecType, 3: &p.Currency}
+	amounts := decimalFields{
+		exact:  map[protowire.Number]*snapshot.Decimal{9: &p.Quantity, 10: &p.AvgCost, 11: &p.MarketPrice, 12: &p.MarketValue},
+		legacy: map[protowire.Number]protowire.Number{4: 9, 5: 10, 6: 11, 7: 12},
+	}
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		if field, ok := stringFields[num]; ok && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(value)
+			*field = v
+			return n, nil
+		}
+		if n, ok, err := amounts.consume(num, typ, value); ok {
+			return n, err
+		}
+		if num == 8 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(value)
+			p.InTransfer = v != 0
+			return n, nil
+		}
+		return d.skip(num, typ, value)
+	})
+	return p, err
+}
+
+func marshalTransfer(t snapshot.Transfer) []byte {
+	var b []byte
+	b = appendString(b, 1, t.Direction)
+	b = appendString(b, 2, t.Symbol)
+	b = appendString(b, 3, t.Currency)
+	b = appendDecimal(b, 7, t.Quantity)
+	b = appendDecimal(b, 8, t.Value)
+	if !t.Initiated.IsZero() {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimestamp(t.Initiated))
+	}
+	return b
+}
+
+func (d decoder) unmarshalTransfer(data []byte) (snapshot.Transfer, error) {
+	var t snapshot.Transfer
+	stringFields := map[protowire.Number]*string{1: &t.Direction, 2: &t.Symbol, 3: &t.Currency}
+	amounts := decimalFields{
+		exact:  map[protowire.Number]*snapshot.Decimal{7: &t.Quantity, 8: &t.Value},
+		legacy: map[protowire.Number]protowire.Number{4: 7, 5: 8},
+	}
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		if field, ok := stringFields[num]; ok && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(value)
+			*field = v
+			return n, nil
+		}
+		if n, ok, err := amounts.consume(num, typ, value); ok {
+			return n, err
+		}
+		if num == 6 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			initiated, err := d.unmarshalTimestamp(v)
+			t.Initiated = initiated
+			return n, err
+		}
+		return d.skip(num, typ, value)
+	})
+	return t, err
+}
+
+// decimalFields decodes the amounts of a message: exact ones are decimal
+// strings, legacy ones the doubles snapshots had before, each standing in for
+// an exact field. A legacy value is only used if its exact field is absent, in
+// whatever order the two come.
+type decimalFields struct {
+	exact  map[protowire.Number]*snapshot.Decimal
+	legacy map[protowire.Number]protowire.Number
+	seen   map[protowire.Number]bool
+}
+
+// consume decodes the field if it is an amount, reporting whether it was.
+func (f *decimalFields) consume(num protowire.Number, typ protowire.Type, value []byte) (int, bool, error) {
+	if field, ok := f.exact[num]; ok && typ == protowire.BytesType {
+		v, n := protowire.ConsumeString(value)
+		if n < 0 {
+			return n, true, nil
+		}
+		parsed, err := snapshot.ParseDecimal(v)
+		*field = parsed
+		f.markSeen(num)
+		return n, true, err
+	}
+	if exact, ok := f.legacy[num]; ok && typ == protowire.Fixed64Type {
+		v, n := protowire.ConsumeFixed64(value)
+		if !f.seen[exact] {
+			*f.exact[exact] = snapshot.DecimalFromFloat(math.Float64frombits(v))
+		}
+		return n, true, nil
+	}
+	return 0, false, nil
+}
+
+func (f *decimalFields) markSeen(num protowire.Number) {
+	if f.seen == nil {
+		f.seen = make(map[protowire.Number]bool)
+	}
+	f.seen[num] = true
+}
+
+// google.protobuf.Timestamp: seconds = 1, nanos = 2.
+func marshalTimestamp(t time.Time) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(t.Unix()))
+	if t.Nanosecond() != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(t.Nanosecond()))
+	}
+	return b
+}
+
+func (d decoder) unmarshalTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos int64
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte) (int, error) {
+		if (num == 1 || num == 2) && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(value)
+			if num == 1 {
+				seconds = int64(v)
+			} else {
+				nanos = int64(int32(v))
+			}
+			return n, nil
+		}
+		return d.skip(num, typ, value)
+	})
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+// walk calls field for every field in a message. field consumes the value and
+// returns its length, or a negative protowire error code.
+func walk(data []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		n, err := field(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if n > len(data) {
+			return errors.New("snapshotpb: truncated field")
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendDecimal writes d as a decimal string, which reads back exactly.
+func appendDecimal(b []byte, num protowire.Number, d snapshot.Decimal) []byte {
+	if d.IsZero() {
+		return b
+	}
+	return appendString(b, num, d.String())
+}
+
+func init() {
+	snapshot.Register("protobuf", codec{})
+}
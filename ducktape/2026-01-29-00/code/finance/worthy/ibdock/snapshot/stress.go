@@ -0,0 +1,100 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Shock is one hypothetical market move in a Scenario.
+type Shock struct {
+	// SecType and Symbol select the positions whose prices move by Change,
+	// e.g. SecType "STK" for all stocks. At least one is required unless
+	// Currency is set.
+	SecType string `yaml:"sec_type" toml:"sec_type"`
+	Symbol  string `yaml:"symbol" toml:"symbol"`
+	// Currency instead makes this an FX shock: the currency's value in the
+	// base currency moves by Change.
+	Currency string `yaml:"currency" toml:"currency"`
+	// Change is relative, e.g. -0.2 for a 20% fall.
+	Change float64 `yaml:"change" toml:"change"`
+}
+
+// Scenario is a named set of shocks applied together.
+type Scenario struct {
+	Name   string  `yaml:"name" toml:"name"`
+	Shocks []Shock `yaml:"shocks" toml:"shocks"`
+}
+
+// StressResult is a snapshot revalued under one Scenario. Values are in the
+// base currency of the FXRates passed to Stress.
+type StressResult struct {
+	Scenario string
+	Value    float64
+	// Change is the gain or loss the scenario would cause.
+	Change float64
+	// Contributions are the positions' parts of Change, largest loss first.
+	Contributions []Contribution
+}
+
+// Stress revalues the snapshot under each scenario at rates. Price shocks of
+// a position compound; FX shocks apply to its currency exposure as
+// CurrencyExposure sees it, so a hedged position does not move with the
+// currency it is hedged out of. Futures gain or lose the price move on their
+// notional.
+func (s *Snapshot) Stress(scenarios []Scenario, rates FXRates) ([]StressResult, error) {
+	value, err := s.Value(rates)
+	if err != nil {
+		return nil, err
+	}
+	var results []StressResult
+	for _, scenario := range scenarios {
+		var prices []Shock
+		fx := make(map[string]float64)
+		for _, shock := range scenario.Shocks {
+			switch {
+			case shock.Currency != "":
+				if _, ok := rates[shock.Currency]; !ok {
+					return nil, fmt.Errorf("snapshot: scenario %q: no FX rate for %s", scenario.Name, shock.Currency)
+				}
+				fx[shock.Currency] = (1+fx[shock.Currency])*(1+shock.Change) - 1
+			case shock.SecType != "" || shock.Symbol != "":
+				prices = append(prices, shock)
+			default:
+				return nil, fmt.Errorf("snapshot: scenario %q: shock selects nothing", scenario.Name)
+			}
+		}
+		// Shocked values are estimates, so unlike Value they are floats.
+		result := StressResult{Scenario: scenario.Name, Value: value.Float64()}
+		for _, p := range s.Positions {
+			unit, legs := p.legs()
+			rate, ok := rates[unit]
+			if !ok {
+				return nil, fmt.Errorf("snapshot: no FX rate for %s", unit)
+			}
+			factor := 1.0
+			for _, shock := range prices {
+				if (shock.SecType == "" || shock.SecType == p.SecType) && (shock.Symbol == "" || shock.Symbol == p.Symbol) {
+					factor *= 1 + shock.Change
+				}
+			}
+			change := 0.0
+			for currency, leg := range legs {
+				change += leg * rate * (factor*(1+fx[currency]) - 1)
+			}
+			if legs == nil {
+				// Unfunded: only the price move on the notional settles,
+				// in unit.
+				_, notional := p.value()
+				change += notional * (factor - 1) * rate * (1 + fx[unit])
+			}
+			if change != 0 {
+				result.Change += change
+				result.Contributions = append(result.Contributions, Contribution{Symbol: p.Symbol, SecType: p.SecType, Value: change})
+			}
+		}
+		result.Value += result.Change
+		sort.SliceStable(result.Contributions, func(i, j int) bool { return result.Contributions[i].Value < result.Contributions[j].Value })
+		results = append(results, result)
+	}
+	return results, nil
+}
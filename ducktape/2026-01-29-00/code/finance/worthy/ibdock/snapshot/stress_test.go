@@ -0,0 +1,57 @@
+package snapshot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStress(t *testing.T) {
+	s := &Snapshot{Positions: []Position{
+		{Symbol: "VWCE", SecType: "STK", Currency: "EUR", MarketValue: dec(1000)},
+		{Symbol: "VT", SecType: "STK", Currency: "USD", MarketValue: dec(500)},
+		// Hedges VT's dollars back into euros.
+		{Symbol: "EUR", SecType: "CASH", Currency: "USD", Quantity: dec(400), MarketValue: dec(500)},
+		{Symbol: "ES", SecType: "FUT", Currency: "USD", MarketValue: dec(2000)},
+		{Symbol: "EUR", SecType: "CASH", Currency: "EUR", Quantity: dec(100)},
+	}}
+	rates := FXRates{"EUR": 1, "USD": 0.8}
+	scenarios := []Scenario{
+		{Name: "equities -20%", Shocks: []Shock{{SecType: "STK", Change: -0.2}, {SecType: "FUT", Change: -0.2}}},
+		{Name: "USD/EUR -10%", Shocks: []Shock{{Currency: "USD", Change: -0.1}}},
+		{Name: "VT -50%", Shocks: []Shock{{Symbol: "VT", Change: -0.5}}},
+	}
+	results, err := s.Stress(scenarios, rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Value: 1000 + 400 + 400 + 100, the future counting for nothing.
+	want := []struct {
+		change float64
+		worst  string
+	}{
+		// -200 VWCE, -80 VT, -320 on the future's 1600 EUR notional.
+		{-600, "ES"},
+		// USD exposure is VT's 400 less the 400 hedge: none.
+		{0, "VT"},
+		{-200, "VT"},
+	}
+	for i, result := range results {
+		if math.Abs(result.Change-want[i].change) > 1e-9 || math.Abs(result.Value-1900-want[i].change) > 1e-9 {
+			t.Errorf("%s: value %v, change %v; want change %v", result.Scenario, result.Value, result.Change, want[i].change)
+		}
+		worst := ""
+		if len(result.Contributions) > 0 {
+			worst = result.Contributions[0].Symbol
+		}
+		if worst != want[i].worst {
+			t.Errorf("%s: worst contribution %q, want %q: %+v", result.Scenario, worst, want[i].worst, result.Contributions)
+		}
+	}
+
+	if _, err := s.Stress([]Scenario{{Name: "bad", Shocks: []Shock{{Change: -1}}}}, rates); err == nil {
+		t.Error("shock selecting nothing accepted")
+	}
+	if _, err := s.Stress([]Scenario{{Name: "yen", Shocks: []Shock{{Currency: "JPY", Change: -0.1}}}}, rates); err == nil {
+		t.Error("FX shock without rate accepted")
+	}
+}
@@ -0,0 +1,56 @@
+package snapshot
+
+import "time"
+
+const (
+	TransferIn  = "IN"
+	TransferOut = "OUT"
+)
+
+// Transfer is a movement of cash or positions between IB and another
+// institution, e.g. an ACATS transfer or a deposit. Until it settles, its value
+// is either missing from Positions or shows up there without a cost basis.
+type Transfer struct {
+	// Direction is TransferIn or TransferOut.
+	Direction string
+	// Symbol is empty for cash transfers.
+	Symbol   string
+	Currency string
+	// Quantity is the number of units, or the amount for cash transfers.
+	Quantity Decimal
+	// Value is the market value in Currency, if known.
+	Value     Decimal
+	Initiated time.Time
+}
+
+// PendingValue sums the value of pending transfers in currency, incoming
+// transfers counting as positive.
+func (s *Snapshot) PendingValue(currency string) Decimal {
+	var total Decimal
+	for _, transfer := range s.PendingTransfers {
+		if transfer.Currency != currency {
+			continue
+		}
+		value := transfer.Value
+		if transfer.Symbol == "" {
+			value = transfer.Quantity
+		}
+		if transfer.Direction == TransferOut {
+			value = value.Neg()
+		}
+		total = total.Add(value)
+	}
+	return total
+}
+
+// FlagTransferredPositions sets InTransfer on non-cash positions that have no
+// cost basis, which is how IB reports positions received by transfer until
+// the delivering broker sends the cost basis over.
+func (s *Snapshot) FlagTransferredPositions() {
+	for i := range s.Positions {
+		position := &s.Positions[i]
+		if position.SecType != "CASH" && !position.Quantity.IsZero() && position.AvgCost.IsZero() {
+			position.InTransfer = true
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package snapshot
+
+import "testing"
+
+func TestPendingTransfers(t *testing.T) {
+	s := &Snapshot{
+		Positions: []Position{
+			{Symbol: "VT", SecType: "STK", Quantity: dec(10), AvgCost: dec(95)},
+			{Symbol: "VWCE", SecType: "STK", Quantity: dec(40)},
+			{Symbol: "USD", SecType: "CASH", Quantity: dec(100)},
+		},
+		PendingTransfers: []Transfer{
+			{Direction: TransferIn, Currency: "EUR", Quantity: dec(1000)},
+			{Direction: TransferOut, Symbol: "VT", Currency: "EUR", Quantity: dec(2), Value: dec(190)},
+			{Direction: TransferIn, Currency: "USD", Quantity: dec(50)},
+		},
+	}
+	s.FlagTransferredPositions()
+	if s.Positions[0].InTransfer || !s.Positions[1].InTransfer || s.Positions[2].InTransfer {
+		t.Errorf("InTransfer flags wrong: %+v", s.Positions)
+	}
+	if got := s.PendingValue("EUR"); got != dec(810) {
+		t.Errorf("PendingValue(EUR) = %v, want 810", got)
+	}
+}
@@ -0,0 +1,81 @@
+package snapshot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ValidationRules configures Validate. The zero value runs every check but
+// freshness.
+type ValidationRules struct {
+	// MaxAge, if positive, is how old Timestamp may be.
+	MaxAge time.Duration
+	// ValueTolerance is how far a stock's MarketValue may be from Quantity
+	// times MarketPrice, as a share of the latter; it defaults to 0.01.
+	// Negative skips the check.
+	ValueTolerance float64
+	// AllowEmpty accepts snapshots without positions. IB reports even an
+	// empty account's cash, so none usually means the read was cut short.
+	AllowEmpty bool
+	// AllowZeroPrices accepts positions without a market price, e.g. for
+	// accounts holding delisted securities.
+	AllowZeroPrices bool
+}
+
+// ValidationError lists everything wrong with a snapshot, see Validate.
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return "snapshot: invalid: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate checks that s looks like a complete read of an account at now: IB
+// sometimes returns partial data that parses fine, e.g. positions without
+// prices. It returns a *ValidationError listing every violation, or nil.
+//
+// Only stocks are checked for consistent values: other security types have
+// multipliers or are priced per 100 of face value.
+func (s *Snapshot) Validate(rules ValidationRules, now time.Time) error {
+	tolerance := rules.ValueTolerance
+	if tolerance == 0 {
+		tolerance = 0.01
+	}
+	var violations []string
+	if s.Account == "" {
+		violations = append(violations, "no account ID")
+	}
+	switch {
+	case s.Timestamp.IsZero():
+		violations = append(violations, "no timestamp")
+	case rules.MaxAge > 0 && now.Sub(s.Timestamp) > rules.MaxAge:
+		violations = append(violations, fmt.Sprintf("taken at %s, more than %v before %s", s.Timestamp.Format(time.RFC3339), rules.MaxAge, now.Format(time.RFC3339)))
+	}
+	if len(s.Positions) == 0 && !rules.AllowEmpty {
+		violations = append(violations, "no positions")
+	}
+	for i, p := range s.Positions {
+		name := p.Symbol
+		if name == "" {
+			name = fmt.Sprintf("position %d", i)
+			violations = append(violations, name+": no symbol")
+		}
+		if p.SecType == "CASH" || p.Quantity.IsZero() {
+			continue
+		}
+		if p.MarketPrice.Sign() <= 0 && !rules.AllowZeroPrices {
+			violations = append(violations, fmt.Sprintf("%s: market price %v", name, p.MarketPrice))
+			continue
+		}
+		if want := p.Quantity.Mul(p.MarketPrice); p.SecType == "STK" && tolerance > 0 && math.Abs(p.MarketValue.Sub(want).Float64()) > tolerance*math.Abs(want.Float64()) {
+			violations = append(violations, fmt.Sprintf("%s: market value %v, but %v × %v is %v", name, p.MarketValue, p.Quantity, p.MarketPrice, want))
+		}
+	}
+	if violations != nil {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package snapshot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	good := Snapshot{
+		Account:   "U1234567",
+		Timestamp: now.Add(-time.Minute),
+		Positions: []Position{
+			{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: dec(20), MarketPrice: dec(120), MarketValue: dec(2400.5)},
+			{Symbol: "ES", SecType: "FUT", Currency: "USD", Quantity: dec(1), MarketPrice: dec(5000), MarketValue: dec(250000)},
+			{Symbol: "USD", SecType: "CASH", Quantity: dec(1600)},
+		},
+	}
+	if err := good.Validate(ValidationRules{MaxAge: time.Hour}, now); err != nil {
+		t.Errorf("Validate(good) = %v", err)
+	}
+
+	bad := Snapshot{
+		Timestamp: now.Add(-2 * time.Hour),
+		Positions: []Position{
+			{Symbol: "VT", SecType: "STK", Quantity: dec(20), MarketPrice: dec(120), MarketValue: dec(1200)},
+			{Symbol: "DEAD", SecType: "STK", Quantity: dec(5)},
+			{SecType: "STK", Quantity: dec(1), MarketPrice: dec(-1)},
+		},
+	}
+	err := bad.Validate(ValidationRules{MaxAge: time.Hour}, now)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate(bad) = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		"no account ID",
+		"taken at 2026-03-02T13:00:00Z, more than 1h0m0s before 2026-03-02T15:00:00Z",
+		"VT: market value 1200, but 20 × 120 is 2400",
+		"DEAD: market price 0",
+		"position 2: no symbol",
+		"position 2: market price -1",
+	}
+	if !reflect.DeepEqual(validationErr.Violations, want) {
+		t.Errorf("violations = %q, want %q", validationErr.Violations, want)
+	}
+
+	empty := Snapshot{Account: "U1234567", Timestamp: now}
+	if err := empty.Validate(ValidationRules{}, now); err == nil {
+		t.Errorf("Validate(empty) succeeded")
+	}
+	if err := empty.Validate(ValidationRules{AllowEmpty: true}, now); err != nil {
+		t.Errorf("Validate(empty) with AllowEmpty = %v", err)
+	}
+}
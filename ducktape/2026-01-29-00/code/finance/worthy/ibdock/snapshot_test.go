@@ -0,0 +1,134 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidation(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	// A read cut short: the account header but no positions.
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111", Timestamp: time.Now()})
+	logger := log.New(io.Discard, "", 0)
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("lax"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dock.GetSnapshot(context.Background()); err != nil {
+		t.Errorf("GetSnapshot without validation = %v", err)
+	}
+	dock, err = StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithSessionName("strict"), WithValidation(snapshot.ValidationRules{MaxAge: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dock.GetSnapshot(context.Background())
+	var validationErr *snapshot.ValidationError
+	if !errors.As(err, &validationErr) || Retryable(err) {
+		t.Errorf("GetSnapshot of no positions = %v, want a fatal *ValidationError", err)
+	}
+}
+
+func TestGetSnapshotFor(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111"}, &snapshot.Snapshot{Account: "U2222222"})
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, account := range []string{"", "U2222222"} {
+		s, err := dock.GetSnapshotFor(ctx, account)
+		want := account
+		if want == "" {
+			want = "U1111111"
+		}
+		if err != nil || s.Account != want {
+			t.Errorf("GetSnapshotFor(%q) = %+v, %v, want a snapshot of %s", account, s, err, want)
+		}
+	}
+	if _, err := dock.GetSnapshotFor(ctx, "U3333333"); err == nil {
+		t.Errorf("GetSnapshotFor of an unknown account succeeded")
+	}
+
+	// An image whose script ignores --account.
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		data, _ := snapshot.Marshal("json", &snapshot.Snapshot{Account: "U1111111"})
+		return ibdocktest.Result{Stdout: data}
+	})
+	if _, err := dock.GetSnapshotFor(ctx, "U2222222"); err == nil {
+		t.Errorf("GetSnapshotFor accepted a snapshot of the default account")
+	}
+}
+
+func TestGetSnapshotWith(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111", Positions: []snapshot.Position{
+		{Symbol: "VWCE", Currency: "EUR"},
+		{Symbol: "AAPL", Currency: "USD"},
+	}})
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s, err := dock.GetSnapshotWith(ctx, SnapshotRequest{Currency: "EUR", Verbosity: 1})
+	if err != nil || len(s.Positions) != 1 || s.Positions[0].Symbol != "VWCE" {
+		t.Errorf("GetSnapshotWith(EUR) = %+v, %v", s, err)
+	}
+
+	// An image whose script ignores --currency.
+	var env []string
+	server.HandleExec(func(e ibdocktest.Exec) ibdocktest.Result {
+		env = e.Env
+		data, _ := snapshot.Marshal("json", &snapshot.Snapshot{Account: "U1111111", Positions: []snapshot.Position{{Symbol: "AAPL", Currency: "USD"}}})
+		return ibdocktest.Result{Stdout: data}
+	})
+	if _, err := dock.GetSnapshotWith(ctx, SnapshotRequest{Currency: "EUR", Env: []string{"IB_LOG_LEVEL=debug"}}); err == nil {
+		t.Errorf("GetSnapshotWith accepted USD positions for EUR")
+	}
+	if !slices.Contains(env, scriptContractEnv) || !slices.Contains(env, "IB_LOG_LEVEL=debug") {
+		t.Errorf("script environment %q", env)
+	}
+}
+
+func TestSnapshotTimeout(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+		if exec.Cmd[0] == "python3" {
+			return ibdocktest.Result{Delay: time.Minute}
+		}
+		return ibdocktest.Result{}
+	})
+	fake := clock.NewFake(time.Now())
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithClock(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := dock.GetSnapshot(context.Background())
+		done <- err
+	}()
+	// The exec's poll and its timeout.
+	fake.BlockUntil(2)
+	start := time.Now()
+	fake.Advance(defaultSnapshotTimeout)
+	if err := <-done; !errors.Is(err, ErrSnapshotTimeout) {
+		t.Errorf("GetSnapshot past the timeout = %v, want ErrSnapshotTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > killTimeout {
+		t.Errorf("timing out took %v", elapsed)
+	}
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"time"
+)
+
+func (s *SQLStore) Contract(ctx context.Context, conID int) (twsapi.ContractDetails, error) {
+	var details twsapi.ContractDetails
+	var data string
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT data FROM contracts WHERE con_id = ?`), conID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return details, ErrNotFound
+	}
+	if err != nil {
+		return details, err
+	}
+	err = json.Unmarshal([]byte(data), &details)
+	return details, err
+}
+
+func (s *SQLStore) SaveContract(ctx context.Context, details twsapi.ContractDetails) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO contracts (con_id, updated_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (con_id) DO UPDATE SET updated_at = excluded.updated_at, data = excluded.data`),
+		details.Contract.ConID, time.Now().UnixNano(), string(data))
+	return err
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const uniqueIndex = `CREATE UNIQUE INDEX IF NOT EXISTS snapshots_unique ON snapshots (account, taken_at, session)`
+
+// migrate brings databases created by older versions up to the current
+// schema: it adds the session column and, unless there are duplicates left
+// for Dedupe, the unique index.
+func (s *SQLStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `SELECT session FROM snapshots LIMIT 0`); err != nil {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE snapshots ADD COLUMN session TEXT NOT NULL DEFAULT ''`); err != nil {
+			return err
+		}
+	}
+	var one int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT 1 FROM snapshots GROUP BY account, taken_at, session HAVING COUNT(*) > 1 LIMIT 1`,
+	).Scan(&one)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, uniqueIndex)
+	return err
+}
+
+// Dedupe repairs databases written before saves were idempotent: of each set
+// of snapshots with the same account, timestamp and session it keeps the
+// first saved, and then adds the unique index that keeps it that way. It
+// returns how many snapshots it removed.
+func (s *SQLStore) Dedupe(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE id NOT IN (
+		SELECT MIN(id) FROM snapshots GROUP BY account, taken_at, session
+	)`)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, uniqueIndex); err != nil {
+		return 0, err
+	}
+	return removed, tx.Commit()
+}
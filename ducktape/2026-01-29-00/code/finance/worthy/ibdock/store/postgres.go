@@ -0,0 +1,23 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/lib/pq"
+)
+
+// OpenPostgres connects to the Postgres database described by dsn, e.g.
+// "postgres://worthy@localhost/worthy?sslmode=disable", and creates the
+// tables if they do not exist.
+func OpenPostgres(dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSQLStore(context.Background(), db, postgres)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"strings"
+	"time"
+)
+
+// dialect holds what differs between the supported databases.
+type dialect struct {
+	// idColumn declares the auto-incrementing snapshot ID.
+	idColumn string
+	// numbered placeholders ($1, $2, ...) instead of ?.
+	numbered bool
+}
+
+var (
+	sqlite   = dialect{idColumn: "id INTEGER PRIMARY KEY AUTOINCREMENT"}
+	postgres = dialect{idColumn: "id BIGSERIAL PRIMARY KEY", numbered: true}
+)
+
+// Snapshots are kept whole as JSON; positions are split out as well so they
+// can be queried by symbol. Contract details are JSON too. Timestamps are Unix
+// nanoseconds. The unique index on snapshots is created by migrate, since
+// databases from before it may hold duplicates.
+func (d dialect) schema() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS snapshots (
+			` + d.idColumn + `,
+			account TEXT NOT NULL,
+			taken_at BIGINT NOT NULL,
+			session TEXT NOT NULL DEFAULT '',
+			data TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS snapshots_account_taken_at ON snapshots (account, taken_at)`,
+		`CREATE TABLE IF NOT EXISTS positions (
+			snapshot_id BIGINT NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+			symbol TEXT NOT NULL,
+			sec_type TEXT NOT NULL,
+			currency TEXT NOT NULL,
+			quantity DOUBLE PRECISION NOT NULL,
+			avg_cost DOUBLE PRECISION NOT NULL,
+			market_price DOUBLE PRECISION NOT NULL,
+			market_value DOUBLE PRECISION NOT NULL,
+			in_transfer BOOLEAN NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS positions_symbol ON positions (symbol)`,
+		`CREATE TABLE IF NOT EXISTS contracts (
+			con_id BIGINT PRIMARY KEY,
+			updated_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		)`,
+	}
+}
+
+// rebind rewrites the ? placeholders in query for d.
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// SQLStore is a SnapshotStore and ContractStore backed by SQLite or Postgres.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func newSQLStore(ctx context.Context, db *sql.DB, d dialect) (*SQLStore, error) {
+	for _, statement := range d.schema() {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("store: creating schema: %w", err)
+		}
+	}
+	s := &SQLStore{db: db, dialect: d}
+	if err := s.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("store: migrating schema: %w", err)
+	}
+	return s, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Save(ctx context.Context, snap *snapshot.Snapshot) error {
+	return s.SaveSession(ctx, "", snap)
+}
+
+func (s *SQLStore) SaveSession(ctx context.Context, session string, snap *snapshot.Snapshot) error {
+	data, err := snapshot.Marshal("json", snap)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// The lookup covers databases still waiting for Dedupe, which lack the
+	// unique index ON CONFLICT relies on; the index covers concurrent writers.
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		s.dialect.rebind(`SELECT id FROM snapshots WHERE account = ? AND taken_at = ? AND session = ?`),
+		snap.Account, snap.Timestamp.UnixNano(), session,
+	).Scan(&id)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	err = tx.QueryRowContext(ctx,
+		s.dialect.rebind(`INSERT INTO snapshots (account, taken_at, session, data) VALUES (?, ?, ?, ?)
+			ON CONFLICT DO NOTHING RETURNING id`),
+		snap.Account, snap.Timestamp.UnixNano(), session, string(data),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	insert := s.dialect.rebind(`INSERT INTO positions
+		(snapshot_id, symbol, sec_type, currency, quantity, avg_cost, market_price, market_value, in_transfer)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, p := range snap.Positions {
+		if _, err := tx.ExecContext(ctx, insert,
+			id, p.Symbol, p.SecType, p.Currency, p.Quantity, p.AvgCost, p.MarketPrice, p.MarketValue, p.InTransfer,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Latest(ctx context.Context, account string) (*snapshot.Snapshot, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx,
+		s.dialect.rebind(`SELECT data FROM snapshots WHERE account = ? ORDER BY taken_at DESC, id DESC LIMIT 1`),
+		account,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	snap := new(snapshot.Snapshot)
+	if err := snapshot.Unmarshal("json", []byte(data), snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (s *SQLStore) Range(ctx context.Context, account string, from, to time.Time) ([]*snapshot.Snapshot, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.dialect.rebind(`SELECT data FROM snapshots WHERE account = ? AND taken_at >= ? AND taken_at < ? ORDER BY taken_at, id`),
+		account, from.UnixNano(), to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snaps []*snapshot.Snapshot
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		snap := new(snapshot.Snapshot)
+		if err := snapshot.Unmarshal("json", []byte(data), snap); err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+func (s *SQLStore) BySymbol(ctx context.Context, symbol string, from, to time.Time) ([]Holding, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT
+			s.account, s.taken_at,
+			p.symbol, p.sec_type, p.currency, p.quantity, p.avg_cost, p.market_price, p.market_value, p.in_transfer
+		FROM positions p JOIN snapshots s ON s.id = p.snapshot_id
+		WHERE p.symbol = ? AND s.taken_at >= ? AND s.taken_at < ?
+		ORDER BY s.taken_at, s.id`),
+		symbol, from.UnixNano(), to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var holdings []Holding
+	for rows.Next() {
+		var h Holding
+		var takenAt int64
+		p := &h.Position
+		if err := rows.Scan(&h.Account, &takenAt,
+			&p.Symbol, &p.SecType, &p.Currency, &p.Quantity, &p.AvgCost, &p.MarketPrice, &p.MarketValue, &p.InTransfer,
+		); err != nil {
+			return nil, err
+		}
+		h.Timestamp = time.Unix(0, takenAt).UTC()
+		holdings = append(holdings, h)
+	}
+	return holdings, rows.Err()
+}
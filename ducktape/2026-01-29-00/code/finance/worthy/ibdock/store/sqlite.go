@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens (creating if needed) the SQLite database at path.
+func OpenSQLite(path string) (*SQLStore, error) {
+	// Foreign keys are off by default in SQLite; positions rely on them to go
+	// away with their snapshot.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serializes writers anyway; one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	s, err := newSQLStore(context.Background(), db, sqlite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
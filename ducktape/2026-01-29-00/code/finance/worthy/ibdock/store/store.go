@@ -0,0 +1,55 @@
+// Package store persists snapshots in SQL databases, so periodic snapshots
+// have somewhere durable to go. SQLite and Postgres are supported:
+//
+//	s, err := store.OpenSQLite("snapshots.db")
+//	if err != nil {
+//	  panic(err)
+//	}
+//	defer s.Close()
+//	err = s.Save(ctx, snap)
+//	latest, err := s.Latest(ctx, "U1234567")
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"time"
+)
+
+// ErrNotFound is returned by Latest when the account has no snapshots, and by
+// Contract for contracts not in the store.
+var ErrNotFound = errors.New("store: no snapshot found")
+
+type SnapshotStore interface {
+	// Save is SaveSession with no session.
+	Save(ctx context.Context, s *snapshot.Snapshot) error
+	// SaveSession persists s under its Account and Timestamp and the session
+	// that took it. Saving a snapshot again under the same three is a no-op,
+	// so retried and replayed jobs can save freely.
+	SaveSession(ctx context.Context, session string, s *snapshot.Snapshot) error
+	// Latest returns the most recent snapshot of account.
+	Latest(ctx context.Context, account string) (*snapshot.Snapshot, error)
+	// Range returns the snapshots of account taken in [from, to), oldest
+	// first.
+	Range(ctx context.Context, account string, from, to time.Time) ([]*snapshot.Snapshot, error)
+	// BySymbol returns the positions in symbol across all accounts in
+	// snapshots taken in [from, to), oldest first.
+	BySymbol(ctx context.Context, symbol string, from, to time.Time) ([]Holding, error)
+	Close() error
+}
+
+// ContractStore caches contract details, which rarely change, across
+// sessions.
+type ContractStore interface {
+	Contract(ctx context.Context, conID int) (twsapi.ContractDetails, error)
+	SaveContract(ctx context.Context, details twsapi.ContractDetails) error
+}
+
+// Holding is a position as recorded in one snapshot.
+type Holding struct {
+	Account   string
+	Timestamp time.Time
+	Position  snapshot.Position
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "snapshots.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Latest(ctx, "U1111111"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest on an empty store = %v, want ErrNotFound", err)
+	}
+	day := func(d int) time.Time { return time.Date(2026, time.January, d, 0, 0, 0, 0, time.UTC) }
+	for _, snap := range []*snapshot.Snapshot{
+		{Account: "U1111111", Timestamp: day(1), Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(10)}}},
+		{Account: "U1111111", Timestamp: day(2), Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(12)}, {Symbol: "BND", Quantity: snapshot.DecimalFromFloat(5)}}},
+		{Account: "U2222222", Timestamp: day(2), Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(1), InTransfer: true}}},
+		{Account: "U1111111", Timestamp: day(3)},
+	} {
+		if err := s.Save(ctx, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := s.Latest(ctx, "U1111111")
+	if err != nil || !latest.Timestamp.Equal(day(3)) {
+		t.Errorf("Latest = %+v, %v", latest, err)
+	}
+	snaps, err := s.Range(ctx, "U1111111", day(1), day(3))
+	if err != nil || len(snaps) != 2 || len(snaps[1].Positions) != 2 {
+		t.Errorf("Range = %+v, %v", snaps, err)
+	}
+	holdings, err := s.BySymbol(ctx, "VT", day(1), day(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holdings) != 3 || holdings[0].Position.Quantity != snapshot.DecimalFromFloat(10) || holdings[2].Account != "U2222222" || !holdings[2].Position.InTransfer {
+		t.Errorf("BySymbol = %+v", holdings)
+	}
+}
+
+func TestSaveIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "snapshots.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	snap := &snapshot.Snapshot{Account: "U1111111", Timestamp: time.Unix(1767225600, 0).UTC(),
+		Positions: []snapshot.Position{{Symbol: "VT", Quantity: snapshot.DecimalFromFloat(10)}}}
+	for _, session := range []string{"main", "main", "backfill"} {
+		if err := s.SaveSession(ctx, session, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snaps, err := s.Range(ctx, "U1111111", snap.Timestamp, snap.Timestamp.Add(time.Second))
+	if err != nil || len(snaps) != 2 {
+		t.Errorf("Range = %d snapshots, %v; want one per session", len(snaps), err)
+	}
+	holdings, err := s.BySymbol(ctx, "VT", snap.Timestamp, snap.Timestamp.Add(time.Second))
+	if err != nil || len(holdings) != 2 {
+		t.Errorf("BySymbol = %d holdings, %v; want 2", len(holdings), err)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshots.db")
+	// The schema before saves were idempotent, with a snapshot saved twice.
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE snapshots (id INTEGER PRIMARY KEY AUTOINCREMENT, account TEXT NOT NULL, taken_at BIGINT NOT NULL, data TEXT NOT NULL)`,
+		`INSERT INTO snapshots (account, taken_at, data) VALUES ('U1111111', 1, '{"Account":"U1111111"}'), ('U1111111', 1, '{"Account":"U1111111"}'), ('U1111111', 2, '{"Account":"U1111111"}')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Save(ctx, &snapshot.Snapshot{Account: "U1111111", Timestamp: time.Unix(0, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.Dedupe(ctx)
+	if err != nil || removed != 1 {
+		t.Errorf("Dedupe = %d, %v; want 1 removed", removed, err)
+	}
+	snaps, err := s.Range(ctx, "U1111111", time.Unix(0, 0), time.Unix(0, 3))
+	if err != nil || len(snaps) != 2 {
+		t.Errorf("Range after Dedupe = %d snapshots, %v; want 2", len(snaps), err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	if got := postgres.rebind("a = ? AND b < ?"); got != "a = $1 AND b < $2" {
+		t.Errorf("rebind = %q", got)
+	}
+}
+
+func TestContracts(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "snapshots.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Contract(ctx, 756733); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Contract on an empty store = %v, want ErrNotFound", err)
+	}
+	spy := twsapi.ContractDetails{Contract: twsapi.Contract{ConID: 756733, Symbol: "SPY"}, PrimaryExchange: "ARCA"}
+	for _, exchange := range []string{"NYSE", "ARCA"} {
+		spy.PrimaryExchange = exchange
+		if err := s.SaveContract(ctx, spy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := s.Contract(ctx, 756733); err != nil || got != spy {
+		t.Errorf("Contract = %+v, %v; want %+v", got, err, spy)
+	}
+}
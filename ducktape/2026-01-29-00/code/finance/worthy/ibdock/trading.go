@@ -0,0 +1,47 @@
+package ibdock
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+)
+
+// ErrTradingNotAllowed is returned for orders through a Dock not created with
+// WithAllowTrading, or a live one not created with WithLiveTrading.
+var ErrTradingNotAllowed = errors.New("trading is not allowed on this session, see ibdock.WithAllowTrading")
+
+// tradingClientID is the TWS API client ID of all connections DialTrading
+// makes. The gateway lets only the client ID that placed an order cancel it,
+// so it has to stay the same across connections.
+const tradingClientID = 0
+
+// CheckTrading returns ErrTradingNotAllowed unless the Dock may trade.
+func (dock *Dock) CheckTrading() (err error) {
+	defer dock.redactError(&err)
+	if !dock.allowTrading || dock.TradingMode() != "paper" && !dock.liveTrading {
+		return ErrTradingNotAllowed
+	}
+	return nil
+}
+
+// DialTrading connects to the gateway for placing and cancelling orders, see
+// the trading package. Only one such connection can be open at a time.
+func (dock *Dock) DialTrading(ctx context.Context) (_ *twsapi.Client, err error) {
+	defer dock.redactError(&err)
+	ctx, call := dock.beginCall(ctx, "")
+	defer call.wrapError(&err)
+	if err := dock.CheckTrading(); err != nil {
+		return nil, err
+	}
+	if err := dock.pace(ctx); err != nil {
+		return nil, err
+	}
+	if err := dock.ensureRunning(ctx); err != nil {
+		return nil, err
+	}
+	endpoint, err := dock.APIEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return twsapi.DialJournal(ctx, endpoint, tradingClientID, dock.journal)
+}
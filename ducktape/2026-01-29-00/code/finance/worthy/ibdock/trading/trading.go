@@ -0,0 +1,176 @@
+// Package trading places and cancels orders through an ibdock session, for
+// simple rebalancing. It is opt-in: the Dock has to be created with
+// ibdock.WithAllowTrading, which trades in paper sessions only, or
+// ibdock.WithLiveTrading:
+//
+//	dock, err := ibdock.StartNew(login, password, logger, ibdock.WithTradingMode("paper"), ibdock.WithAllowTrading())
+//	...
+//	trader, err := trading.New(dock, trading.Options{DryRun: true})
+//	if err != nil {
+//	  panic(err)
+//	}
+//	_, err = trader.PlaceOrder(ctx, trading.Order{
+//	  Contract: twsapi.Contract{Symbol: "VT", SecType: "STK", Exchange: "SMART", Currency: "USD"},
+//	  Action:   "BUY",
+//	  Quantity: 10,
+//	  Type:     "MKT",
+//	})
+package trading
+
+import (
+	"context"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"io"
+	"log"
+	"sync"
+)
+
+// Order is an order for one contract.
+type Order struct {
+	Contract twsapi.Contract
+	// Action is "BUY" or "SELL".
+	Action   string
+	Quantity float64
+	// Type is "MKT" or "LMT", with LimitPrice.
+	Type       string
+	LimitPrice float64
+	// TIF is the time in force, e.g. "GTC"; "DAY" if empty.
+	TIF string
+	// Account is the account to trade in, for logins managing several.
+	Account string
+	// Ref is a note shown with the order in TWS.
+	Ref string
+}
+
+func (o Order) validate() error {
+	if o.Action != "BUY" && o.Action != "SELL" {
+		return fmt.Errorf("trading: action %q, want BUY or SELL", o.Action)
+	}
+	if o.Quantity <= 0 {
+		return fmt.Errorf("trading: quantity %v is not positive", o.Quantity)
+	}
+	switch o.Type {
+	case "MKT":
+	case "LMT":
+		if o.LimitPrice <= 0 {
+			return fmt.Errorf("trading: limit order without a limit price")
+		}
+	default:
+		return fmt.Errorf("trading: order type %q, want MKT or LMT", o.Type)
+	}
+	if o.Contract.ConID == 0 && (o.Contract.Symbol == "" || o.Contract.SecType == "" || o.Contract.Currency == "") {
+		return fmt.Errorf("trading: contract %+v needs a ConID, or a symbol, security type and currency", o.Contract)
+	}
+	return nil
+}
+
+func (o Order) String() string {
+	s := fmt.Sprintf("%s %v %s %s %s", o.Action, o.Quantity, o.Contract.Symbol, o.Contract.SecType, o.Type)
+	if o.Type == "LMT" {
+		s += fmt.Sprintf(" @ %v %s", o.LimitPrice, o.Contract.Currency)
+	}
+	return s
+}
+
+type Options struct {
+	// DryRun has the gateway check orders without placing them, and skips
+	// cancellations.
+	DryRun bool
+	// Logger is told about every order; none if nil.
+	Logger *log.Logger
+}
+
+// Trader trades through one Dock. Its calls are serialized, since the gateway
+// takes only one connection with the Dock's trading client ID.
+type Trader struct {
+	dock    *ibdock.Dock
+	options Options
+	mu      sync.Mutex
+}
+
+// New returns a Trader for dock, or ibdock.ErrTradingNotAllowed.
+func New(dock *ibdock.Dock, options Options) (*Trader, error) {
+	if err := dock.CheckTrading(); err != nil {
+		return nil, err
+	}
+	if options.Logger == nil {
+		options.Logger = log.New(io.Discard, "", 0)
+	}
+	return &Trader{dock: dock, options: options}, nil
+}
+
+// do runs f on a trading connection.
+func (t *Trader) do(ctx context.Context, f func(*twsapi.Client) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	client, err := t.dock.DialTrading(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return f(client)
+}
+
+// ListOpenOrders returns the orders the gateway is working, including ones
+// entered in TWS or by other API clients.
+func (t *Trader) ListOpenOrders(ctx context.Context) ([]twsapi.OpenOrder, error) {
+	var orders []twsapi.OpenOrder
+	err := t.do(ctx, func(client *twsapi.Client) error {
+		var err error
+		orders, err = client.OpenOrders(ctx)
+		return err
+	})
+	return orders, err
+}
+
+// PlaceOrder places order and returns its first status, whose OrderID is what
+// CancelOrder takes. In a dry run the order is only checked, and the status
+// has no OrderID.
+func (t *Trader) PlaceOrder(ctx context.Context, order Order) (twsapi.OrderStatus, error) {
+	if err := order.validate(); err != nil {
+		return twsapi.OrderStatus{}, err
+	}
+	var status twsapi.OrderStatus
+	err := t.do(ctx, func(client *twsapi.Client) error {
+		var err error
+		status, err = client.PlaceOrder(ctx, order.Contract, twsapi.Order{
+			Action:     order.Action,
+			Quantity:   order.Quantity,
+			Type:       order.Type,
+			LimitPrice: order.LimitPrice,
+			TIF:        order.TIF,
+			Account:    order.Account,
+			OrderRef:   order.Ref,
+			WhatIf:     t.options.DryRun,
+		})
+		return err
+	})
+	switch {
+	case err != nil:
+		t.options.Logger.Printf("Order %s failed: %v", order, err)
+	case t.options.DryRun:
+		t.options.Logger.Printf("Dry run: the gateway accepts %s", order)
+		status = twsapi.OrderStatus{Status: "DryRun"}
+	default:
+		t.options.Logger.Printf("Placed %s as order %d: %s", order, status.OrderID, status.Status)
+	}
+	return status, err
+}
+
+// CancelOrder cancels the order with id, which must have been placed by a
+// Trader. In a dry run it only logs.
+func (t *Trader) CancelOrder(ctx context.Context, id int) error {
+	if t.options.DryRun {
+		t.options.Logger.Printf("Dry run: not cancelling order %d", id)
+		return nil
+	}
+	err := t.do(ctx, func(client *twsapi.Client) error {
+		return client.CancelOrder(ctx, id)
+	})
+	if err == nil {
+		t.options.Logger.Printf("Cancelled order %d", id)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package trading
+
+import (
+	"errors"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/twsapi"
+	"testing"
+)
+
+func TestNewNeedsAllowTrading(t *testing.T) {
+	if _, err := New(new(ibdock.Dock), Options{}); !errors.Is(err, ibdock.ErrTradingNotAllowed) {
+		t.Errorf("New = %v, want ErrTradingNotAllowed", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	vt := twsapi.Contract{Symbol: "VT", SecType: "STK", Exchange: "SMART", Currency: "USD"}
+	for _, test := range []struct {
+		order Order
+		valid bool
+	}{
+		{Order{Contract: vt, Action: "BUY", Quantity: 10, Type: "MKT"}, true},
+		{Order{Contract: vt, Action: "SELL", Quantity: 1, Type: "LMT", LimitPrice: 110}, true},
+		{Order{Contract: twsapi.Contract{ConID: 52197301}, Action: "BUY", Quantity: 1, Type: "MKT"}, true},
+		{Order{Contract: vt, Action: "SHORT", Quantity: 1, Type: "MKT"}, false},
+		{Order{Contract: vt, Action: "BUY", Quantity: 0, Type: "MKT"}, false},
+		{Order{Contract: vt, Action: "BUY", Quantity: 1, Type: "LMT"}, false},
+		{Order{Contract: vt, Action: "BUY", Quantity: 1, Type: "STP"}, false},
+		{Order{Contract: twsapi.Contract{Symbol: "VT"}, Action: "BUY", Quantity: 1, Type: "MKT"}, false},
+	} {
+		if err := test.order.validate(); (err == nil) != test.valid {
+			t.Errorf("%s: validate = %v, want valid %v", test.order, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package ibdock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckTrading(t *testing.T) {
+	for _, test := range []struct {
+		options []Option
+		allowed bool
+	}{
+		{[]Option{WithTradingMode("paper")}, false},
+		{[]Option{WithTradingMode("paper"), WithAllowTrading()}, true},
+		{[]Option{WithAllowTrading()}, false},
+		{[]Option{WithLiveTrading()}, true},
+	} {
+		dock := new(Dock)
+		for _, option := range test.options {
+			option(dock)
+		}
+		err := dock.CheckTrading()
+		if allowed := err == nil; allowed != test.allowed || err != nil && !errors.Is(err, ErrTradingNotAllowed) {
+			t.Errorf("%s mode, allow %v, live %v: CheckTrading = %v", dock.TradingMode(), dock.allowTrading, dock.liveTrading, err)
+		}
+	}
+}
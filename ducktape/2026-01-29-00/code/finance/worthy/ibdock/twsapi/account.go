@@ -0,0 +1,211 @@
+package twsapi
+
+import (
+	"context"
+	"strings"
+)
+
+// Contract identifies an instrument.
+type Contract struct {
+	ConID                        int
+	Symbol                       string
+	SecType                      string
+	LastTradeDateOrContractMonth string
+	Strike                       float64
+	Right                        string
+	Multiplier                   string
+	Exchange                     string
+	Currency                     string
+	LocalSymbol                  string
+	TradingClass                 string
+}
+
+// Position is a holding as reported by reqPositions.
+type Position struct {
+	Account  string
+	Contract Contract
+	Position float64
+	AvgCost  float64
+}
+
+// PortfolioPosition is a holding together with IB's current marks, as
+// reported by account updates.
+type PortfolioPosition struct {
+	Contract      Contract
+	Position      float64
+	MarketPrice   float64
+	MarketValue   float64
+	AverageCost   float64
+	UnrealizedPNL float64
+	RealizedPNL   float64
+}
+
+// AccountValue is one key of the account's state, e.g. NetLiquidation or
+// CashBalance. Currency is empty for keys that are not amounts.
+type AccountValue struct {
+	Key      string
+	Value    string
+	Currency string
+}
+
+// Portfolio is one account's positions and values.
+type Portfolio struct {
+	Account   string
+	Positions []PortfolioPosition
+	Values    []AccountValue
+	// UpdateTime is the gateway's "HH:MM" time of the last update.
+	UpdateTime string
+}
+
+// Positions returns the positions of all accessible accounts.
+func (c *Client) Positions(ctx context.Context) ([]Position, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	if err := c.send(msgReqPositions, 1); err != nil {
+		return nil, err
+	}
+	var positions []Position
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inPosition:
+			// version, account, contract..., position, avgCost
+			positions = append(positions, Position{
+				Account: msg.string(2),
+				Contract: Contract{
+					ConID:                        msg.int(3),
+					Symbol:                       msg.string(4),
+					SecType:                      msg.string(5),
+					LastTradeDateOrContractMonth: msg.string(6),
+					Strike:                       msg.float(7),
+					Right:                        msg.string(8),
+					Multiplier:                   msg.string(9),
+					Exchange:                     msg.string(10),
+					Currency:                     msg.string(11),
+					LocalSymbol:                  msg.string(12),
+					TradingClass:                 msg.string(13),
+				},
+				Position: msg.float(14),
+				AvgCost:  msg.float(15),
+			})
+		case inPositionEnd:
+			return positions, c.send(msgCancelPositions, 1)
+		case inError:
+			if err := msg.error(); !err.warning() {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Portfolio subscribes to updates of account (which may be empty for
+// single-account logins), collects the first full download and unsubscribes.
+func (c *Client) Portfolio(ctx context.Context, account string) (*Portfolio, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	if err := c.send(msgReqAccountUpdates, 2, true, account); err != nil {
+		return nil, err
+	}
+	portfolio := &Portfolio{Account: account}
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inAccountValue:
+			// version, key, value, currency, account
+			portfolio.Values = append(portfolio.Values, AccountValue{
+				Key:      msg.string(2),
+				Value:    msg.string(3),
+				Currency: msg.string(4),
+			})
+			portfolio.Account = msg.string(5)
+		case inPortfolioValue:
+			// version, contract..., position, marketPrice, marketValue,
+			// averageCost, unrealizedPNL, realizedPNL, account
+			portfolio.Positions = append(portfolio.Positions, PortfolioPosition{
+				Contract: Contract{
+					ConID:                        msg.int(2),
+					Symbol:                       msg.string(3),
+					SecType:                      msg.string(4),
+					LastTradeDateOrContractMonth: msg.string(5),
+					Strike:                       msg.float(6),
+					Right:                        msg.string(7),
+					Multiplier:                   msg.string(8),
+					Exchange:                     msg.string(9),
+					Currency:                     msg.string(10),
+					LocalSymbol:                  msg.string(11),
+					TradingClass:                 msg.string(12),
+				},
+				Position:      msg.float(13),
+				MarketPrice:   msg.float(14),
+				MarketValue:   msg.float(15),
+				AverageCost:   msg.float(16),
+				UnrealizedPNL: msg.float(17),
+				RealizedPNL:   msg.float(18),
+			})
+		case inAccountUpdateTime:
+			portfolio.UpdateTime = msg.string(2)
+		case inAccountDownloadEnd:
+			return portfolio, c.send(msgReqAccountUpdates, 2, false, account)
+		case inError:
+			if err := msg.error(); !err.warning() {
+				return nil, err
+			}
+		}
+	}
+}
+
+// SummaryValue is one row of an account summary.
+type SummaryValue struct {
+	Account  string
+	Tag      string
+	Value    string
+	Currency string
+}
+
+// AccountSummary requests the given summary tags (e.g. NetLiquidation,
+// BuyingPower, $LEDGER:ALL) for all accounts in group, which is usually "All".
+func (c *Client) AccountSummary(ctx context.Context, group string, tags ...string) ([]SummaryValue, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	id := c.reqID()
+	if err := c.send(msgReqAccountSummary, 1, id, group, strings.Join(tags, ",")); err != nil {
+		return nil, err
+	}
+	var values []SummaryValue
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inAccountSummary:
+			// version, reqId, account, tag, value, currency
+			if msg.int(2) != id {
+				continue
+			}
+			values = append(values, SummaryValue{
+				Account:  msg.string(3),
+				Tag:      msg.string(4),
+				Value:    msg.string(5),
+				Currency: msg.string(6),
+			})
+		case inAccountSummaryEnd:
+			if msg.int(2) == id {
+				return values, c.send(msgCancelSummary, 1, id)
+			}
+		case inError:
+			if err := msg.error(); !err.warning() && (err.ReqID == id || err.ReqID == -1) {
+				return nil, err
+			}
+		}
+	}
+}
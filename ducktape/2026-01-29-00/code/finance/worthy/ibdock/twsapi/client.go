@@ -0,0 +1,282 @@
+// Package twsapi is a minimal client for the TWS / IB Gateway socket API. It
+// speaks just enough of the protocol to read a portfolio out of a logged-in
+// gateway, e.g. the one running in an ibdock container, and to place simple
+// orders for the trading package:
+//
+//	client, err := twsapi.Dial(ctx, "127.0.0.1:7496", 1)
+//	if err != nil {
+//	  panic(err)
+//	}
+//	defer client.Close()
+//	positions, err := client.Positions(ctx)
+package twsapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Range of API versions we can speak. Message layouts in this package follow
+// maxClientVersion; newer servers negotiate down to it.
+const (
+	minClientVersion = 100
+	maxClientVersion = 151
+)
+
+// maxFrameSize bounds the messages the client accepts, as IB's own client
+// does, so that a corrupt length or something else listening on the port
+// cannot make it allocate gigabytes.
+const maxFrameSize = 16 << 20
+
+// Client is a connection to a TWS or IB Gateway API port. Requests are
+// serialized: each call writes its request and reads until the matching end
+// marker, so a Client is safe for concurrent use but does not pipeline.
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	mu      sync.Mutex
+	nextID  int
+	orderID int
+	journal *Journal
+
+	// ServerVersion is the API version negotiated with the gateway.
+	ServerVersion int
+	// Accounts lists the accounts the logged-in user can access.
+	Accounts []string
+	// Clock times waits like AverageVolumes', default clock.Real.
+	Clock clock.Clock
+}
+
+// Error is an error message sent by the gateway.
+type Error struct {
+	// ReqID is the request the error belongs to, or -1 for errors about the
+	// connection as a whole.
+	ReqID   int
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("tws error %d (request %d): %s", e.Code, e.ReqID, e.Message)
+}
+
+// warning reports whether the message is informational; TWS reports farm
+// connection status and similar notices through the error channel.
+func (e *Error) warning() bool {
+	switch e.Code {
+	case 10090, // part of the requested market data is not subscribed
+		10167: // displaying delayed market data
+		return true
+	}
+	return e.Code >= 2100 && e.Code < 2200
+}
+
+// Dial connects to the gateway at addr and performs the API handshake.
+// clientID must be unique among the clients connected to the same gateway.
+func Dial(ctx context.Context, addr string, clientID int) (*Client, error) {
+	return DialJournal(ctx, addr, clientID, nil)
+}
+
+// DialJournal is Dial recording all messages of the connection, including the
+// handshake, in journal.
+func DialJournal(ctx context.Context, addr string, clientID int, journal *Journal) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, reader: bufio.NewReader(conn), nextID: 1, journal: journal}
+	if err := c.handshake(ctx, clientID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) handshake(ctx context.Context, clientID int) error {
+	defer c.watch(ctx)()
+	hello := fmt.Sprintf("v%d..%d", minClientVersion, maxClientVersion)
+	var b []byte
+	b = append(b, "API\x00"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(hello)))
+	b = append(b, hello...)
+	c.journal.record(true, []string{"API", hello})
+	if _, err := c.conn.Write(b); err != nil {
+		return err
+	}
+	fields, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	if len(fields) < 1 {
+		return errors.New("twsapi: empty handshake reply")
+	}
+	c.ServerVersion, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return fmt.Errorf("twsapi: bad server version %q", fields[0])
+	}
+	if err := c.send(msgStartAPI, 2, clientID, ""); err != nil {
+		return err
+	}
+	// The gateway volunteers the next order ID and the managed accounts
+	// once the API is started; wait for both so Accounts is filled in.
+	var gotID, gotAccounts bool
+	for !gotID || !gotAccounts {
+		msg, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msg.id {
+		case inNextValidID:
+			c.orderID = msg.int(2)
+			gotID = true
+		case inManagedAccounts:
+			c.Accounts = splitAccounts(msg.string(2))
+			gotAccounts = true
+		case inError:
+			if err := msg.error(); !err.warning() {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func splitAccounts(list string) []string {
+	var accounts []string
+	for _, account := range strings.Split(list, ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+// watch applies ctx's deadline and cancellation to the connection until the
+// returned function is called.
+func (c *Client) watch(ctx context.Context) func() {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		stop()
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+func (c *Client) reqID() int {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// send writes one message made of the given fields.
+func (c *Client) send(fields ...any) error {
+	var payload []byte
+	for _, field := range fields {
+		switch v := field.(type) {
+		case string:
+			payload = append(payload, v...)
+		case int:
+			payload = strconv.AppendInt(payload, int64(v), 10)
+		case bool:
+			if v {
+				payload = append(payload, '1')
+			} else {
+				payload = append(payload, '0')
+			}
+		case float64:
+			payload = strconv.AppendFloat(payload, v, 'f', -1, 64)
+		default:
+			panic(fmt.Sprintf("twsapi: cannot encode %T", field))
+		}
+		payload = append(payload, 0)
+	}
+	if c.journal != nil {
+		c.journal.record(true, strings.Split(strings.TrimSuffix(string(payload), "\x00"), "\x00"))
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	_, err := c.conn.Write(append(b, payload...))
+	return err
+}
+
+func (c *Client) readFrame() ([]string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.reader, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("twsapi: message of %d bytes exceeds the %d byte limit", n, maxFrameSize)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+	fields := strings.Split(string(payload), "\x00")
+	// Every field is NUL-terminated, leaving an empty string at the end.
+	if n := len(fields); n > 0 && fields[n-1] == "" {
+		fields = fields[:n-1]
+	}
+	c.journal.record(false, fields)
+	return fields, nil
+}
+
+func (c *Client) readMessage() (message, error) {
+	fields, err := c.readFrame()
+	if err != nil {
+		return message{}, err
+	}
+	if len(fields) == 0 {
+		return message{}, errors.New("twsapi: empty message")
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return message{}, fmt.Errorf("twsapi: bad message id %q", fields[0])
+	}
+	return message{id: id, fields: fields}, nil
+}
+
+// message is one incoming message. Field 0 is the message id, and for the
+// message types this package handles, field 1 is the message version.
+type message struct {
+	id     int
+	fields []string
+}
+
+func (m message) string(i int) string {
+	if i < len(m.fields) {
+		return m.fields[i]
+	}
+	return ""
+}
+
+func (m message) int(i int) int {
+	v, _ := strconv.Atoi(m.string(i))
+	return v
+}
+
+func (m message) float(i int) float64 {
+	v, _ := strconv.ParseFloat(m.string(i), 64)
+	return v
+}
+
+func (m message) error() *Error {
+	return &Error{ReqID: m.int(2), Code: m.int(3), Message: m.string(4)}
+}
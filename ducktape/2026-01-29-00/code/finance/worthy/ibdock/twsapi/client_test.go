@@ -0,0 +1,261 @@
+package twsapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGateway accepts one connection, completes the handshake and answers
+// each request with the messages scripted for its message id.
+func fakeGateway(t *testing.T, replies map[string][][]string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		prefix := make([]byte, 4)
+		if _, err := io.ReadFull(reader, prefix); err != nil || string(prefix) != "API\x00" {
+			return
+		}
+		if _, err := readTestFrame(reader); err != nil {
+			return
+		}
+		writeTestFrame(conn, "151", "20260129 12:00:00 CET")
+		if _, err := readTestFrame(reader); err != nil {
+			return
+		}
+		writeTestFrame(conn, "15", "1", "U1111111,U2222222")
+		writeTestFrame(conn, "4", "2", "-1", "2104", "Market data farm connection is OK:usfarm")
+		writeTestFrame(conn, "9", "1", "1")
+		for {
+			request, err := readTestFrame(reader)
+			if err != nil {
+				return
+			}
+			for _, reply := range replies[request[0]] {
+				writeTestFrame(conn, reply...)
+			}
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readTestFrame(r io.Reader) ([]string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(payload), "\x00"), "\x00"), nil
+}
+
+func writeTestFrame(w io.Writer, fields ...string) {
+	payload := strings.Join(fields, "\x00") + "\x00"
+	w.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...))
+}
+
+func TestPositions(t *testing.T) {
+	addr := fakeGateway(t, map[string][][]string{
+		"61": {
+			{"61", "3", "U1111111", "756733", "SPY", "STK", "", "0", "", "", "ARCA", "USD", "SPY", "SPY", "10", "401.5"},
+			{"61", "3", "U2222222", "12087792", "EUR", "CASH", "", "0", "", "", "IDEALPRO", "USD", "EUR.USD", "EUR.USD", "-2500", "1.08"},
+			{"62", "1"},
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if client.ServerVersion != 151 {
+		t.Errorf("ServerVersion = %v, want 151", client.ServerVersion)
+	}
+	if len(client.Accounts) != 2 || client.Accounts[1] != "U2222222" {
+		t.Errorf("Accounts = %v", client.Accounts)
+	}
+	positions, err := client.Positions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("got %d positions, want 2", len(positions))
+	}
+	spy := positions[0]
+	if spy.Account != "U1111111" || spy.Contract.ConID != 756733 || spy.Contract.Symbol != "SPY" || spy.Position != 10 || spy.AvgCost != 401.5 {
+		t.Errorf("unexpected position %+v", spy)
+	}
+	if eur := positions[1]; eur.Contract.LocalSymbol != "EUR.USD" || eur.Position != -2500 {
+		t.Errorf("unexpected position %+v", eur)
+	}
+}
+
+func TestOversizedFrame(t *testing.T) {
+	// A length prefix of 4 GiB - 1, as from a non-TWS listener, followed by
+	// nothing: the client must fail without allocating or waiting for it.
+	c := &Client{reader: bufio.NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))}
+	if _, err := c.readFrame(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("readFrame = %v, want a size limit error", err)
+	}
+}
+
+func TestPortfolioError(t *testing.T) {
+	addr := fakeGateway(t, map[string][][]string{
+		"6": {{"4", "2", "-1", "321", "Error validating request:-'bK' : cause - Account code required"}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, err = client.Portfolio(ctx, "")
+	if apiErr, ok := err.(*Error); !ok || apiErr.Code != 321 {
+		t.Errorf("Portfolio error = %v, want code 321", err)
+	}
+}
+
+func TestContractDetails(t *testing.T) {
+	addr := fakeGateway(t, map[string][][]string{
+		"9": {
+			{"10", "8", "1", "VWCE", "STK", "", "0", "", "SMART", "EUR", "VWCE", "IBIS2", "VWCE", "290651477", "0.002", "1", "1",
+				"ACTIVETIM,AD", "SMART,IBIS2,GETTEX", "1", "0", "VANG FTSE AW USDA", "IBIS2", "", "Funds", "Equity Fund", "International"},
+			{"52", "1", "1"},
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	details, err := client.ContractDetails(ctx, Contract{ConID: 290651477})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details) != 1 {
+		t.Fatalf("got %d contracts, want 1", len(details))
+	}
+	if d := details[0]; d.Contract.ConID != 290651477 || d.Contract.Currency != "EUR" || d.PrimaryExchange != "IBIS2" || d.LongName != "VANG FTSE AW USDA" || d.MinTick != 0.002 || d.Category != "Equity Fund" {
+		t.Errorf("unexpected details %+v", d)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	addr := fakeGateway(t, map[string][][]string{"61": {{"62", "1"}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	journal := NewJournal(1 << 20)
+	journal.Sanitize = RedactAccounts
+	client, err := DialJournal(ctx, addr, 1, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Positions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	journal.WriteTo(&b)
+	for _, want := range []string{"> API|v100..151", "< 15|1|UXXXXXXX,UXXXXXXX", "> 61|1", "< 62|1", "> 64|1"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("journal does not contain %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "U1111111") {
+		t.Errorf("journal leaks account IDs:\n%s", b.String())
+	}
+
+	small := NewJournal(10)
+	small.record(true, []string{"61", "1"})
+	small.record(false, []string{"62", "1", "tail"})
+	if entries := small.Entries(); len(entries) != 1 || entries[0].Fields[0] != "62" {
+		t.Errorf("capped journal kept %v", entries)
+	}
+}
+
+func TestAverageVolumes(t *testing.T) {
+	addr := fakeGateway(t, map[string][][]string{
+		// Every subscription gets request 1's volume, so request 2 only
+		// finishes when the wait is over.
+		"1":  {{"2", "6", "1", "21", "254000"}},
+		"49": {{"49", "1", "1769684400"}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	volumes, err := client.AverageVolumes(ctx, []Contract{{Symbol: "SPY"}, {Symbol: "ILLIQ"}}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volumes[0].Average != 254000 || volumes[0].Err != nil {
+		t.Errorf("SPY volume = %+v", volumes[0])
+	}
+	if volumes[1].Err != ErrNoVolume {
+		t.Errorf("ILLIQ volume = %+v, want ErrNoVolume", volumes[1])
+	}
+}
+
+func TestOrders(t *testing.T) {
+	openOrder := []string{"5", "1", "756733", "SPY", "STK", "", "0", "", "", "SMART", "USD", "SPY", "SPY",
+		"BUY", "10", "LMT", "400.5", "", "GTC", "", "U1111111", "", "0", "rebalance", "0", "98765", "0", "0"}
+	orderStatus := []string{"3", "1", "Submitted", "0", "10", "0", "98765", "0", "0", "0", "", "0"}
+	addr := fakeGateway(t, map[string][][]string{
+		"3":  {openOrder, orderStatus},
+		"16": {openOrder, orderStatus, {"53", "1"}},
+		"4":  {{"4", "2", "1", "202", "Order Canceled - reason:"}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	spy := Contract{Symbol: "SPY", SecType: "STK", Exchange: "SMART", Currency: "USD"}
+	status, err := client.PlaceOrder(ctx, spy, Order{Action: "BUY", Quantity: 10, Type: "LMT", LimitPrice: 400.5, TIF: "GTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.OrderID != 1 || status.Status != "Submitted" || status.Remaining != 10 {
+		t.Errorf("PlaceOrder status %+v", status)
+	}
+	orders, err := client.OpenOrders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("got %d open orders, want 1", len(orders))
+	}
+	if o := orders[0]; o.PermID != 98765 || o.Contract.ConID != 756733 || o.Order.Quantity != 10 || o.Order.LimitPrice != 400.5 ||
+		o.Order.Account != "U1111111" || o.Order.OrderRef != "rebalance" || o.Status.Status != "Submitted" {
+		t.Errorf("open order %+v", o)
+	}
+	if err := client.CancelOrder(ctx, 1); err != nil {
+		t.Errorf("CancelOrder = %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package twsapi
+
+import "context"
+
+// ContractDetails is what the gateway knows about a contract beyond what
+// identifies it.
+type ContractDetails struct {
+	Contract        Contract
+	PrimaryExchange string
+	MarketName      string
+	LongName        string
+	MinTick         float64
+	Industry        string
+	Category        string
+	Subcategory     string
+}
+
+// ContractDetails looks up the contracts matching contract, which may be just
+// a ConID or a partial description such as a symbol and security type.
+func (c *Client) ContractDetails(ctx context.Context, contract Contract) ([]ContractDetails, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	id := c.reqID()
+	if err := c.send(msgReqContractData, 8, id,
+		contract.ConID, contract.Symbol, contract.SecType, contract.LastTradeDateOrContractMonth,
+		contract.Strike, contract.Right, contract.Multiplier, contract.Exchange, "",
+		contract.Currency, contract.LocalSymbol, contract.TradingClass,
+		false, // include expired
+		"",    // security ID type
+		"",    // security ID
+	); err != nil {
+		return nil, err
+	}
+	var details []ContractDetails
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inContractData:
+			// version, reqId, symbol, secType, lastTradeDate, strike, right,
+			// exchange, currency, localSymbol, marketName, tradingClass,
+			// conId, minTick, mdSizeMultiplier, multiplier, orderTypes,
+			// validExchanges, priceMagnifier, underConId, longName,
+			// primaryExchange, contractMonth, industry, category,
+			// subcategory, ...
+			if msg.int(2) != id {
+				continue
+			}
+			details = append(details, ContractDetails{
+				Contract: Contract{
+					ConID:                        msg.int(13),
+					Symbol:                       msg.string(3),
+					SecType:                      msg.string(4),
+					LastTradeDateOrContractMonth: msg.string(5),
+					Strike:                       msg.float(6),
+					Right:                        msg.string(7),
+					Multiplier:                   msg.string(16),
+					Exchange:                     msg.string(8),
+					Currency:                     msg.string(9),
+					LocalSymbol:                  msg.string(10),
+					TradingClass:                 msg.string(12),
+				},
+				MarketName:      msg.string(11),
+				MinTick:         msg.float(14),
+				LongName:        msg.string(21),
+				PrimaryExchange: msg.string(22),
+				Industry:        msg.string(24),
+				Category:        msg.string(25),
+				Subcategory:     msg.string(26),
+			})
+		case inContractDataEnd:
+			if msg.int(2) == id {
+				return details, nil
+			}
+		case inError:
+			if err := msg.error(); !err.warning() && (err.ReqID == id || err.ReqID == -1) {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package twsapi
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal records the messages exchanged with the gateway, for debugging the
+// protocol when snapshots misbehave. It keeps the most recent messages up to
+// a size cap and is safe for concurrent use, so one Journal can span all of a
+// session's connections.
+type Journal struct {
+	// Sanitize, if set, rewrites the fields of each message before it is
+	// recorded, e.g. RedactAccounts.
+	Sanitize func(fields []string) []string
+	// Clock stamps the entries, default clock.Real.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	entries  []JournalEntry
+	dropped  int
+}
+
+type JournalEntry struct {
+	Time time.Time
+	// Sent is true for requests, false for messages from the gateway.
+	Sent   bool
+	Fields []string
+}
+
+func (e JournalEntry) size() int {
+	n := 0
+	for _, field := range e.Fields {
+		n += len(field) + 1
+	}
+	return n
+}
+
+// NewJournal returns a journal that keeps at most maxBytes of message fields.
+func NewJournal(maxBytes int) *Journal {
+	return &Journal{maxBytes: maxBytes}
+}
+
+func (j *Journal) record(sent bool, fields []string) {
+	if j == nil {
+		return
+	}
+	fields = append([]string(nil), fields...)
+	if j.Sanitize != nil {
+		fields = j.Sanitize(fields)
+	}
+	entry := JournalEntry{Time: clock.OrReal(j.Clock).Now(), Sent: sent, Fields: fields}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+	j.size += entry.size()
+	for j.size > j.maxBytes && len(j.entries) > 0 {
+		j.size -= j.entries[0].size()
+		j.entries = j.entries[1:]
+		j.dropped++
+	}
+}
+
+// Entries returns the recorded messages, oldest first.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// WriteTo writes the journal as text, one message per line with ">" marking
+// requests and "<" messages from the gateway.
+func (j *Journal) WriteTo(w io.Writer) (int64, error) {
+	j.mu.Lock()
+	dropped := j.dropped
+	j.mu.Unlock()
+	var b strings.Builder
+	if dropped > 0 {
+		fmt.Fprintf(&b, "(%d older messages dropped)\n", dropped)
+	}
+	for _, entry := range j.Entries() {
+		direction := "<"
+		if entry.Sent {
+			direction = ">"
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", entry.Time.Format("15:04:05.000"), direction, strings.Join(entry.Fields, "|"))
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+var accountID = regexp.MustCompile(`\b(DU|DF|U|F)\d{5,}\b`)
+
+// RedactAccounts is a Journal.Sanitize function that masks IB account IDs,
+// so journals can be shared.
+func RedactAccounts(fields []string) []string {
+	for i, field := range fields {
+		fields[i] = accountID.ReplaceAllString(field, "${1}XXXXXXX")
+	}
+	return fields
+}
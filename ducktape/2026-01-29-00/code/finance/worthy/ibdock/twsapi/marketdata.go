@@ -0,0 +1,112 @@
+package twsapi
+
+import (
+	"context"
+	"math"
+)
+
+// Tick types of the prices in a market data snapshot, live and delayed.
+const (
+	tickBid          = 1
+	tickAsk          = 2
+	tickLast         = 4
+	tickClose        = 9
+	tickDelayedBid   = 66
+	tickDelayedAsk   = 67
+	tickDelayedLast  = 68
+	tickDelayedClose = 75
+)
+
+// Quote is a market data snapshot of one contract. Prices the gateway did not
+// report are zero.
+type Quote struct {
+	Contract Contract
+	Bid      float64
+	Ask      float64
+	Last     float64
+	Close    float64
+	// Err is set if the gateway refused the request for this contract.
+	Err error
+}
+
+// Mid returns the bid/ask midpoint, falling back to the last and then the
+// closing price when there is no two-sided market.
+func (q Quote) Mid() float64 {
+	if q.Bid > 0 && q.Ask > 0 {
+		return (q.Bid + q.Ask) / 2
+	}
+	if q.Last > 0 {
+		return q.Last
+	}
+	return q.Close
+}
+
+// MarketSnapshots requests a one-off market data snapshot of each contract and
+// waits for all of them. Per-contract failures are reported in Quote.Err;
+// the returned error is for failures of the connection as a whole.
+func (c *Client) MarketSnapshots(ctx context.Context, contracts []Contract) ([]Quote, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	quotes := make([]Quote, len(contracts))
+	pending := make(map[int]int)
+	for i, contract := range contracts {
+		quotes[i].Contract = contract
+		id := c.reqID()
+		pending[id] = i
+		if err := c.send(msgReqMktData, 11, id,
+			contract.ConID, contract.Symbol, contract.SecType, contract.LastTradeDateOrContractMonth,
+			contract.Strike, contract.Right, contract.Multiplier, contract.Exchange, "",
+			contract.Currency, contract.LocalSymbol, contract.TradingClass,
+			false, // no delta-neutral contract
+			"",    // generic ticks
+			true,  // snapshot
+			false, // regulatory snapshot
+			"",    // options
+		); err != nil {
+			return nil, err
+		}
+	}
+	for len(pending) > 0 {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inTickPrice:
+			// version, reqId, tickType, price, size, attributes
+			i, ok := pending[msg.int(2)]
+			if !ok {
+				continue
+			}
+			price := msg.float(4)
+			if price <= 0 || price == math.MaxFloat64 {
+				continue
+			}
+			switch msg.int(3) {
+			case tickBid, tickDelayedBid:
+				quotes[i].Bid = price
+			case tickAsk, tickDelayedAsk:
+				quotes[i].Ask = price
+			case tickLast, tickDelayedLast:
+				quotes[i].Last = price
+			case tickClose, tickDelayedClose:
+				quotes[i].Close = price
+			}
+		case inTickSnapshotEnd:
+			delete(pending, msg.int(2))
+		case inError:
+			err := msg.error()
+			if err.warning() {
+				continue
+			}
+			if i, ok := pending[err.ReqID]; ok {
+				quotes[i].Err = err
+				delete(pending, err.ReqID)
+			} else if err.ReqID == -1 {
+				return nil, err
+			}
+		}
+	}
+	return quotes, nil
+}
@@ -0,0 +1,42 @@
+package twsapi
+
+// Outgoing message ids.
+const (
+	msgReqMktData        = 1
+	msgCancelMktData     = 2
+	msgPlaceOrder        = 3
+	msgCancelOrder       = 4
+	msgReqAccountUpdates = 6
+	msgReqContractData   = 9
+	msgReqAllOpenOrders  = 16
+	msgReqCurrentTime    = 49
+	msgReqPositions      = 61
+	msgReqAccountSummary = 62
+	msgCancelSummary     = 63
+	msgCancelPositions   = 64
+	msgStartAPI          = 71
+)
+
+// Incoming message ids.
+const (
+	inTickPrice          = 1
+	inTickSize           = 2
+	inOrderStatus        = 3
+	inError              = 4
+	inOpenOrder          = 5
+	inAccountValue       = 6
+	inPortfolioValue     = 7
+	inAccountUpdateTime  = 8
+	inNextValidID        = 9
+	inContractData       = 10
+	inManagedAccounts    = 15
+	inCurrentTime        = 49
+	inContractDataEnd    = 52
+	inOpenOrderEnd       = 53
+	inAccountDownloadEnd = 54
+	inTickSnapshotEnd    = 57
+	inPosition           = 61
+	inPositionEnd        = 62
+	inAccountSummary     = 63
+	inAccountSummaryEnd  = 64
+)
@@ -0,0 +1,270 @@
+package twsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Order is what to trade: the subset of IB's order fields simple rebalancing
+// needs. The rest are sent as IB's defaults.
+type Order struct {
+	// Action is "BUY" or "SELL".
+	Action   string
+	Quantity float64
+	// Type is e.g. "MKT" or "LMT".
+	Type       string
+	LimitPrice float64
+	// TIF is the time in force, e.g. "DAY" (the gateway's default if empty)
+	// or "GTC".
+	TIF string
+	// Account is the account to trade in, for logins managing several.
+	Account string
+	// OrderRef is a free-form note shown with the order in TWS.
+	OrderRef string
+	// WhatIf asks the gateway to check the order and report its margin
+	// impact without placing it.
+	WhatIf bool
+}
+
+// OrderStatus is the gateway's report on an order.
+type OrderStatus struct {
+	OrderID int
+	// Status is e.g. "PreSubmitted", "Submitted", "Filled" or "Cancelled".
+	Status       string
+	Filled       float64
+	Remaining    float64
+	AvgFillPrice float64
+	PermID       int
+}
+
+// OpenOrder is an order the gateway is working.
+type OpenOrder struct {
+	OrderID  int
+	ClientID int
+	PermID   int
+	Contract Contract
+	Order    Order
+	Status   OrderStatus
+}
+
+// errOrderCancelled is the error code with which the gateway confirms a
+// cancellation.
+const errOrderCancelled = 202
+
+// nextOrderID returns an order ID this client ID has not used yet, counting
+// from the one the gateway reported at the handshake.
+func (c *Client) nextOrderID() int {
+	id := c.orderID
+	c.orderID++
+	return id
+}
+
+// PlaceOrder sends order for contract and waits for the gateway to accept it,
+// returning its first status. WhatIf orders are not placed and have no
+// status but their ID; the gateway's objections come as an *Error.
+func (c *Client) PlaceOrder(ctx context.Context, contract Contract, order Order) (OrderStatus, error) {
+	if contract.SecType == "BAG" {
+		return OrderStatus{}, errors.New("twsapi: combo orders are not supported")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	id := c.nextOrderID()
+	limit := ""
+	if order.Type != "MKT" {
+		limit = strconv.FormatFloat(order.LimitPrice, 'f', -1, 64)
+	}
+	// The layout of server version 151, which has no message version, with
+	// what this package does not set at the defaults of IB's own clients.
+	if err := c.send(msgPlaceOrder, id,
+		contract.ConID, contract.Symbol, contract.SecType, contract.LastTradeDateOrContractMonth,
+		contract.Strike, contract.Right, contract.Multiplier, contract.Exchange, "",
+		contract.Currency, contract.LocalSymbol, contract.TradingClass,
+		// Security ID type and ID.
+		"", "",
+		// Action, quantity, type, limit and aux price.
+		order.Action, order.Quantity, order.Type, limit, "",
+		// TIF, OCA group, account, open/close, origin, order ref, transmit,
+		// parent, block, sweep to fill, display size, trigger method,
+		// outside RTH, hidden, shares allocation.
+		order.TIF, "", order.Account, "", 0, order.OrderRef, true,
+		0, false, false, 0, 0,
+		false, false, "",
+		// Discretionary amount, good after, good till; FA group, method,
+		// percentage, profile; model code; short sale slot, designated
+		// location, exempt code.
+		0, "", "",
+		"", "", "", "",
+		"",
+		0, "", -1,
+		// OCA type, rule 80A, settling firm, all or none, min quantity,
+		// percent offset, e-trade only, firm quote only, NBBO price cap.
+		0, "", "", false, "", "", false, false, "",
+		// Auction strategy, starting price, stock reference price, delta,
+		// stock range lower and upper; override percentage constraints,
+		// volatility and its type, delta neutral order type and aux price.
+		0, "", "", "", "", "",
+		false, "", "", "", "",
+		// Continuous update, reference price type, trail stop price, trailing
+		// percent.
+		false, "", "", "",
+		// Scale init and subsequent level size, price increment, table, active
+		// start and stop time.
+		"", "", "", "", "", "",
+		// Hedge type, opt out of SMART routing, clearing account and intent,
+		// not held, delta neutral contract, algo strategy and ID.
+		"", false, "", "", false, false, "", "",
+		// What-if, misc options, solicited, randomize size and price,
+		// conditions.
+		order.WhatIf, "", false, false, false, 0,
+		// Adjusted order type, trigger price, limit price offset, adjusted
+		// stop, stop limit and trailing amount and unit.
+		"", "", "", "", "", "", 0,
+		// Ext operator, soft dollar tier name and value, cash quantity,
+		// MiFID II decision maker and algo, execution trader and algo.
+		"", "", "", "",
+		"", "", "", "",
+		// Don't use auto price for hedge, OMS container, discretionary up to
+		// limit price, use price management algo.
+		false, false, false, "",
+	); err != nil {
+		return OrderStatus{}, err
+	}
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return OrderStatus{}, err
+		}
+		switch msg.id {
+		case inOpenOrder:
+			if order.WhatIf && msg.int(1) == id {
+				return OrderStatus{OrderID: id}, nil
+			}
+		case inOrderStatus:
+			if status := parseOrderStatus(msg); status.OrderID == id {
+				return status, nil
+			}
+		case inError:
+			if err := msg.error(); !err.warning() && (err.ReqID == id || err.ReqID == -1) {
+				return OrderStatus{}, err
+			}
+		}
+	}
+}
+
+// OpenOrders lists the orders the gateway is working, from all API clients
+// and TWS.
+func (c *Client) OpenOrders(ctx context.Context) ([]OpenOrder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	if err := c.send(msgReqAllOpenOrders, 1); err != nil {
+		return nil, err
+	}
+	var orders []OpenOrder
+	byID := make(map[int]int)
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inOpenOrder:
+			// orderId, contract..., action, totalQuantity, orderType,
+			// lmtPrice, auxPrice, tif, ocaGroup, account, openClose, origin,
+			// orderRef, clientId, permId, ...; no message version since
+			// server version 145.
+			byID[msg.int(1)] = len(orders)
+			orders = append(orders, OpenOrder{
+				OrderID:  msg.int(1),
+				ClientID: msg.int(24),
+				PermID:   msg.int(25),
+				Contract: Contract{
+					ConID:                        msg.int(2),
+					Symbol:                       msg.string(3),
+					SecType:                      msg.string(4),
+					LastTradeDateOrContractMonth: msg.string(5),
+					Strike:                       msg.float(6),
+					Right:                        msg.string(7),
+					Multiplier:                   msg.string(8),
+					Exchange:                     msg.string(9),
+					Currency:                     msg.string(10),
+					LocalSymbol:                  msg.string(11),
+					TradingClass:                 msg.string(12),
+				},
+				Order: Order{
+					Action:     msg.string(13),
+					Quantity:   msg.float(14),
+					Type:       msg.string(15),
+					LimitPrice: msg.float(16),
+					TIF:        msg.string(18),
+					Account:    msg.string(20),
+					OrderRef:   msg.string(23),
+				},
+			})
+		case inOrderStatus:
+			status := parseOrderStatus(msg)
+			if i, ok := byID[status.OrderID]; ok {
+				orders[i].Status = status
+			}
+		case inOpenOrderEnd:
+			return orders, nil
+		case inError:
+			if err := msg.error(); !err.warning() {
+				return nil, err
+			}
+		}
+	}
+}
+
+// CancelOrder cancels the order with id, which must have been placed by this
+// client ID, and waits for the gateway to confirm.
+func (c *Client) CancelOrder(ctx context.Context, id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	if err := c.send(msgCancelOrder, 1, id); err != nil {
+		return err
+	}
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msg.id {
+		case inOrderStatus:
+			if status := parseOrderStatus(msg); status.OrderID == id {
+				switch status.Status {
+				case "Cancelled", "ApiCancelled":
+					return nil
+				case "Filled":
+					return fmt.Errorf("twsapi: order %d filled before it could be cancelled", id)
+				}
+			}
+		case inError:
+			err := msg.error()
+			if err.Code == errOrderCancelled && err.ReqID == id {
+				return nil
+			}
+			if !err.warning() && (err.ReqID == id || err.ReqID == -1) {
+				return err
+			}
+		}
+	}
+}
+
+// parseOrderStatus reads an orderStatus message: orderId, status, filled,
+// remaining, avgFillPrice, permId, ...; no message version since server
+// version 131.
+func parseOrderStatus(msg message) OrderStatus {
+	return OrderStatus{
+		OrderID:      msg.int(1),
+		Status:       msg.string(2),
+		Filled:       msg.float(3),
+		Remaining:    msg.float(4),
+		AvgFillPrice: msg.float(5),
+		PermID:       msg.int(6),
+	}
+}
@@ -0,0 +1,109 @@
+package twsapi
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"time"
+)
+
+// Generic tick list and tick type of IB's 90-day average daily volume.
+const (
+	genericMiscStats = "165"
+	tickAvgVolume    = 21
+)
+
+// Volume is the average daily trading volume of one contract.
+type Volume struct {
+	Contract Contract
+	// Average is as the gateway reports it; for US stocks, older gateways
+	// report it in lots of 100 shares.
+	Average float64
+	// Err is set if the gateway refused the request for this contract, or
+	// did not report its volume within the wait.
+	Err error
+}
+
+// ErrNoVolume is a Volume's Err when the gateway reported no average volume.
+var ErrNoVolume = errors.New("twsapi: no average volume reported")
+
+// AverageVolumes reads the average daily volume of each contract. Market
+// data snapshots cannot carry it, so this subscribes to each contract,
+// waits up to wait for the volumes to come in, and unsubscribes again.
+func (c *Client) AverageVolumes(ctx context.Context, contracts []Contract, wait time.Duration) ([]Volume, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.watch(ctx)()
+	volumes := make([]Volume, len(contracts))
+	pending := make(map[int]int)
+	for i, contract := range contracts {
+		volumes[i].Contract = contract
+		id := c.reqID()
+		pending[id] = i
+		if err := c.send(msgReqMktData, 11, id,
+			contract.ConID, contract.Symbol, contract.SecType, contract.LastTradeDateOrContractMonth,
+			contract.Strike, contract.Right, contract.Multiplier, contract.Exchange, "",
+			contract.Currency, contract.LocalSymbol, contract.TradingClass,
+			false, // no delta-neutral contract
+			genericMiscStats,
+			false, // streaming: snapshots cannot carry generic ticks
+			false, // regulatory snapshot
+			"",    // options
+		); err != nil {
+			return nil, err
+		}
+	}
+	cancel := func(id int) error {
+		delete(pending, id)
+		return c.send(msgCancelMktData, 2, id)
+	}
+	// Contracts IB has no volume for never answer. Once the wait is over,
+	// ask for the time: the reply comes after everything sent before it, so
+	// it marks where to stop reading.
+	waited, stop := clock.OrReal(c.Clock).After(wait), make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-waited:
+			c.send(msgReqCurrentTime, 1)
+		case <-stop:
+		}
+	}()
+	for len(pending) > 0 {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msg.id {
+		case inTickSize:
+			// version, reqId, tickType, size
+			i, ok := pending[msg.int(2)]
+			if !ok || msg.int(3) != tickAvgVolume {
+				continue
+			}
+			volumes[i].Average = msg.float(4)
+			if err := cancel(msg.int(2)); err != nil {
+				return nil, err
+			}
+		case inCurrentTime:
+			for id, i := range pending {
+				volumes[i].Err = ErrNoVolume
+				if err := cancel(id); err != nil {
+					return nil, err
+				}
+			}
+		case inError:
+			err := msg.error()
+			if err.warning() {
+				continue
+			}
+			if i, ok := pending[err.ReqID]; ok {
+				volumes[i].Err = err
+				delete(pending, err.ReqID)
+			} else if err.ReqID == -1 {
+				return nil, err
+			}
+		}
+	}
+	return volumes, nil
+}
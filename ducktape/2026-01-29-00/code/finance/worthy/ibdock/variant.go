@@ -0,0 +1,48 @@
+package ibdock
+
+import (
+	"fmt"
+	"time"
+)
+
+// Variant is the IB application a session's container runs.
+type Variant string
+
+const (
+	// Gateway is IB Gateway, the default: it has no trading UI, so it
+	// needs less memory and logs in faster than TWS, and it is all the API
+	// needs.
+	Gateway Variant = "gateway"
+	// TWS is the full Trader Workstation.
+	TWS Variant = "tws"
+)
+
+// twsImage is the image TWS sessions run, built by imagebuild alongside the
+// Gateway one. Both serve the API on 7496, TWS's default, which the Gateway
+// image sets in IBC's config in place of IB Gateway's 4001.
+const twsImage = "agentydragon/ibcontroller-tws"
+
+func (v Variant) check() error {
+	switch v {
+	case "", Gateway, TWS:
+		return nil
+	}
+	return fmt.Errorf("unknown variant %q, want %s or %s", v, Gateway, TWS)
+}
+
+func (v Variant) image() string {
+	if v == TWS {
+		return twsImage
+	}
+	return image
+}
+
+// readyPollInterval is how often WaitReady tries the API. TWS brings up its
+// whole UI before it logs in, which takes minutes, so trying it as often as
+// the gateway only fills the log.
+func (v Variant) readyPollInterval() time.Duration {
+	if v == TWS {
+		return 15 * time.Second
+	}
+	return 5 * time.Second
+}
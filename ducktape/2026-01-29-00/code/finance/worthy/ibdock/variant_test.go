@@ -0,0 +1,30 @@
+package ibdock
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestVariant(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	logger := log.New(io.Discard, "", 0)
+	dock, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithVariant(TWS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := server.Containers()[0].Config.Image; image != twsImage {
+		t.Errorf("TWS session runs %s", image)
+	}
+	if _, err := Attach(dock.ContainerID(), logger, WithDockerEndpoint(server.URL())); err == nil {
+		t.Errorf("Attach to a TWS container as a gateway one succeeded")
+	}
+	if _, err := Attach(dock.ContainerID(), logger, WithDockerEndpoint(server.URL()), WithVariant(TWS)); err != nil {
+		t.Errorf("Attach with WithVariant(TWS): %v", err)
+	}
+	if _, err := StartNew("jdoe", "secret", logger, WithDockerEndpoint(server.URL()), WithVariant("portal")); err == nil {
+		t.Errorf("StartNew with an unknown variant succeeded")
+	}
+}
@@ -0,0 +1,78 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"time"
+)
+
+const defaultWatchInterval = time.Minute
+
+// SnapshotDelta is what changed in the account since the last delta Watch
+// sent.
+type SnapshotDelta struct {
+	// Snapshot is the snapshot the delta led up to; nil if Err is set.
+	Snapshot *snapshot.Snapshot
+	// Changes are relative to the previous delta's Snapshot: for the first
+	// delta, every position is Opened.
+	Changes snapshot.Changes
+	// Err is why a poll failed. Watch goes on polling after failures,
+	// which come with the changes of no snapshot.
+	Err error
+}
+
+// Watch polls the account with a snapshot every interval, see
+// WithWatchInterval, sending a delta each time positions or their market
+// values moved, for showing the portfolio as it changes. The first delta,
+// from the snapshot taken before Watch returns, has every position. The
+// channel is closed once ctx is done.
+//
+// Watch fails if the first snapshot does; later failures are sent as
+// deltas with Err set.
+func (dock *Dock) Watch(ctx context.Context) (_ <-chan SnapshotDelta, err error) {
+	defer dock.redactError(&err)
+	interval := dock.watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	first, err := dock.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deltas := make(chan SnapshotDelta, 1)
+	deltas <- SnapshotDelta{Snapshot: first, Changes: snapshot.Diff(nil, first)}
+	go func() {
+		defer close(deltas)
+		ticker := clock.OrReal(dock.clock).NewTicker(interval)
+		defer ticker.Stop()
+		last, lastHash := first, first.Hash()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+			var delta SnapshotDelta
+			s, err := dock.GetSnapshot(ctx)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				dock.logger.Println("Watch snapshot failed:", err)
+				delta.Err = dock.redactor.Error(err)
+			case s.Hash() == lastHash:
+				continue
+			default:
+				delta = SnapshotDelta{Snapshot: s, Changes: snapshot.Diff(last, s)}
+				last, lastHash = s, s.Hash()
+			}
+			select {
+			case deltas <- delta:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return deltas, nil
+}
@@ -0,0 +1,61 @@
+package ibdock
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	var mu sync.Mutex
+	// Polls see 10, 10, then a failure, then 12 shares for good.
+	quantities := []int64{10, 10, 0, 12}
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		mu.Lock()
+		defer mu.Unlock()
+		q := quantities[0]
+		if len(quantities) > 1 {
+			quantities = quantities[1:]
+		}
+		if q == 0 {
+			return ibdocktest.Result{Stderr: []byte("error 502"), ExitCode: 1}
+		}
+		data, _ := snapshot.Marshal("json", &snapshot.Snapshot{Account: "U1111111", Timestamp: time.Now(), Positions: []snapshot.Position{
+			{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(q, 0)},
+		}})
+		return ibdocktest.Result{Stdout: data}
+	})
+	dock, err := StartNew("jdoe", "secret", log.New(io.Discard, "", 0), WithDockerEndpoint(server.URL()), WithWatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	deltas, err := dock.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := <-deltas
+	if len(first.Changes.Opened) != 1 || first.Snapshot == nil || first.Err != nil {
+		t.Errorf("first delta %+v, want VT opened", first)
+	}
+	// The second poll saw no change, so the failed third comes next.
+	if failed := <-deltas; failed.Err == nil || failed.Snapshot != nil {
+		t.Errorf("second delta %+v, want the failure", failed)
+	}
+	changed := <-deltas
+	if len(changed.Changes.Changed) != 1 || changed.Changes.Changed[0].QuantityDelta != snapshot.NewDecimal(2, 0) {
+		t.Errorf("third delta %+v, want VT up 2", changed)
+	}
+	cancel()
+	for range deltas {
+		// Deltas of polls still in flight at the cancellation.
+	}
+}
@@ -0,0 +1,137 @@
+// Package worthyclient pushes snapshots to the ingestion endpoint of the
+// worthy server, which values and charts them:
+//
+//	client := &worthyclient.Client{URL: "https://worthy.example.com/api/snapshots"}
+//	manager := ibdock.NewManager(dock.GetSnapshot, ibdock.ManagerOptions{
+//	  Interval: time.Hour,
+//	  Hooks:    ibdock.Hooks{OnSnapshot: client.Hook(logger)},
+//	}, logger)
+package worthyclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// pushTimeout bounds each Push made by Hook, retries included, so a hung
+// server cannot stall the session the hook is called from.
+const pushTimeout = 5 * time.Minute
+
+// Client POSTs snapshots as JSON to URL, authenticated with
+// "Authorization: Bearer TOKEN" and carrying an Idempotency-Key header that
+// is the same for every push of a snapshot, so the server can ignore
+// retried or repeated ones.
+type Client struct {
+	URL string
+	// Token defaults to $WORTHY_TOKEN.
+	Token string
+	// Retry is how pushes failing with network errors, 429 or 5xx are
+	// retried; its Retryable is ignored.
+	Retry ibdock.RetryPolicy
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Dedupe, if set, skips snapshots that hash the same as the last one of
+	// their account pushed.
+	Dedupe *snapshot.Dedupe
+}
+
+// StatusError is a response other than 2xx.
+type StatusError struct {
+	Status int
+	// Body is the start of the response body.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("worthy server returned %d %s: %s", e.Status, http.StatusText(e.Status), e.Body)
+}
+
+// IdempotencyKey identifies s by its account and time.
+func IdempotencyKey(s *snapshot.Snapshot) string {
+	return s.Account + "@" + s.Timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// ErrUnchanged is returned by Push for snapshots Dedupe skipped.
+var ErrUnchanged = errors.New("worthyclient: snapshot unchanged, not pushed")
+
+// Push sends s to the server, retrying transient failures.
+func (c *Client) Push(ctx context.Context, s *snapshot.Snapshot) error {
+	if c.Dedupe != nil && c.Dedupe.Unchanged(s) {
+		return ErrUnchanged
+	}
+	data, err := snapshot.Marshal("json", s)
+	if err != nil {
+		return err
+	}
+	token := c.Token
+	if token == "" {
+		token = os.Getenv("WORTHY_TOKEN")
+	}
+	if token == "" {
+		return errors.New("worthyclient: no token, see WORTHY_TOKEN")
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	retry := c.Retry
+	retry.Retryable = retryable
+	err = retry.Do(ctx, func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+token)
+		request.Header.Set("Idempotency-Key", IdempotencyKey(s))
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode/100 == 2 {
+			return nil
+		}
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return &StatusError{Status: response.StatusCode, Body: string(bytes.TrimSpace(body))}
+	})
+	if err == nil && c.Dedupe != nil {
+		if err := c.Dedupe.Record(s); err != nil {
+			return fmt.Errorf("worthyclient: pushed, but cannot record the snapshot's hash: %w", err)
+		}
+	}
+	return err
+}
+
+// Hook returns an ibdock.Hooks.OnSnapshot that pushes each snapshot, logging
+// failures to logger.
+func (c *Client) Hook(logger *log.Logger) func(*snapshot.Snapshot) {
+	return func(s *snapshot.Snapshot) {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		if err := c.Push(ctx, s); errors.Is(err, ErrUnchanged) {
+			logger.Println("Snapshot unchanged, not pushing it to worthy")
+		} else if err != nil {
+			logger.Println("Cannot push the snapshot to worthy:", err)
+		}
+	}
+}
+
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status >= 500 || statusErr.Status == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
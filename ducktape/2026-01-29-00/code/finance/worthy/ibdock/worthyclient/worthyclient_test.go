@@ -0,0 +1,67 @@
+package worthyclient
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPush(t *testing.T) {
+	var keys []string
+	statuses := []int{http.StatusServiceUnavailable, http.StatusCreated, http.StatusBadRequest}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var s snapshot.Snapshot
+		if err := snapshot.UnmarshalStrict("json", body, &s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		status := statuses[0]
+		statuses = statuses[1:]
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	client := &Client{URL: server.URL, Token: "secret", Retry: ibdock.RetryPolicy{InitialBackoff: time.Millisecond}}
+	s := &snapshot.Snapshot{Account: "U1234567", Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := client.Push(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if want := "U1234567@2026-01-02T03:04:05Z"; len(keys) != 2 || keys[0] != want || keys[1] != want {
+		t.Errorf("Idempotency-Keys %q, want %q twice", keys, want)
+	}
+	// Client errors are not retried.
+	err := client.Push(context.Background(), s)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusBadRequest || len(keys) != 3 {
+		t.Errorf("Push = %v after %d requests, want a 400 after 3", err, len(keys))
+	}
+}
+
+func TestPushDedupe(t *testing.T) {
+	pushes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushes++
+	}))
+	defer server.Close()
+	client := &Client{URL: server.URL, Token: "secret", Dedupe: new(snapshot.Dedupe)}
+	s := &snapshot.Snapshot{Account: "U1234567", Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := client.Push(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	again := *s
+	again.Timestamp = again.Timestamp.Add(24 * time.Hour)
+	if err := client.Push(context.Background(), &again); !errors.Is(err, ErrUnchanged) || pushes != 1 {
+		t.Errorf("Push of an identical snapshot = %v after %d pushes, want ErrUnchanged after 1", err, pushes)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/imagebuild"
+	"github.com/fsouza/go-dockerclient"
+	"os"
+)
+
+var variant = flag.String("variant", "gateway", "Application to install: gateway for IB Gateway, or tws")
+var twsVersion = flag.String("tws_version", imagebuild.DefaultTWSVersion, "Major IB Gateway or TWS version, e.g. 1030")
+var installerURL = flag.String("installer_url", "", "Installer to download (default: current stable of --variant)")
+var ibcVersion = flag.String("ibc_version", imagebuild.DefaultIBCVersion, "IBC release to install")
+var script = flag.String("script", "read_snapshot.py", "Path to the snapshot script to bake in")
+var scriptRevision = flag.String("script_revision", "local", "Revision of the snapshot script, used in the image tag")
+
+func main() {
+	flag.Parse()
+	content, err := os.ReadFile(*script)
+	if err != nil {
+		panic(err)
+	}
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	options := imagebuild.Options{
+		Variant:        *variant,
+		TWSVersion:     *twsVersion,
+		InstallerURL:   *installerURL,
+		IBCVersion:     *ibcVersion,
+		Script:         content,
+		ScriptRevision: *scriptRevision,
+	}
+	name, err := imagebuild.Build(context.Background(), client, options, os.Stdout)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Built", name, "and tagged it", imagebuild.ImageRepository(options)+":latest")
+}
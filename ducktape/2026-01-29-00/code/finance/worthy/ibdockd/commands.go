@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/locale"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/sink"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	_ "github.com/agentydragon/worthy/ibdock/snapshot/msgpack"
+	"github.com/agentydragon/worthy/ibdock/store"
+	"github.com/agentydragon/worthy/ibdock/worthyclient"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// containerFlags registers the flags selecting the container of a session
+// started with "ibdockd start", returning its name or ID after parsing.
+func containerFlags(flags *flag.FlagSet) func() string {
+	name := flags.String("name", "default", "Session name given to start")
+	container := flags.String("container", "", "Container name or ID, overriding --name")
+	return func() string {
+		if *container != "" {
+			return *container
+		}
+		return "ibcontroller_" + *name
+	}
+}
+
+// start starts a session container and leaves it running.
+func start(args []string) error {
+	flags := flag.NewFlagSet("start", flag.ExitOnError)
+	name := flags.String("name", "default", "Session name, to refer to the session in later commands (default the --account with --config)")
+	creds := credentialFlags(flags)
+	wait := flags.Duration("wait", 0, "How long to wait for the gateway to log in; 0 returns right away")
+	debugVNC := flags.Bool("debug_vnc", false, "Publish a VNC server on the gateway's screen, to click through dialogs the login is stuck on")
+	vncPassword := flags.String("vnc_password", "", "Password for --debug_vnc (default $IBDOCK_VNC_PASSWORD; none if empty)")
+	takeOver := flags.Bool("take_over_session", false, "Log out another session of the same IB user instead of failing")
+	accountLock := flags.Bool("account_lock", false, "Fail if another session of the same IB user started with --account_lock runs on the Docker host")
+	dryRun := flags.Bool("dry_run", false, "Only check Docker, the image, the credentials, the ports and the options, and print what was found")
+	flags.Parse(args)
+	c, err := creds()
+	if err != nil {
+		return err
+	}
+	options := c.options
+	if options == nil || isSet(flags, "name") {
+		options = append(options, ibdock.WithSessionName(*name))
+	}
+	if *takeOver {
+		options = append(options, ibdock.WithSessionConflict(ibdock.ConflictTakeOver))
+	}
+	if *accountLock {
+		options = append(options, ibdock.WithAccountLock())
+	}
+	if *debugVNC {
+		if *vncPassword == "" {
+			*vncPassword = os.Getenv("IBDOCK_VNC_PASSWORD")
+		}
+		options = append(options, ibdock.WithDebugVNC(*vncPassword))
+	}
+	if *dryRun {
+		var report ibdock.PreflightReport
+		_, err := ibdock.StartNew(c.Login, c.Password, logger, append(options, ibdock.WithDryRun(&report))...)
+		fmt.Print(&report)
+		if errors.Is(err, ibdock.ErrDryRun) {
+			return nil
+		}
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	dock, err := ibdock.StartNewContext(ctx, c.Login, c.Password, logger, options...)
+	if err != nil {
+		return err
+	}
+	// A session interrupted before it is up is removed, not left behind
+	// half logged in.
+	if ctx.Err() != nil {
+		stopSession(dock)
+		return ctx.Err()
+	}
+	fmt.Println(dock.ContainerID())
+	if *debugVNC {
+		endpoint, err := dock.DebugEndpoint()
+		if err != nil {
+			return err
+		}
+		logger.Println("VNC server at", endpoint)
+	}
+	if *wait > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, *wait)
+		defer cancel()
+		err := dock.WaitReady(waitCtx)
+		if ctx.Err() != nil {
+			stopSession(dock)
+			return ctx.Err()
+		}
+		var screenshot *ibdock.ScreenshotError
+		if errors.As(err, &screenshot) {
+			saveScreenshot(screenshot.PNG)
+		}
+		return err
+	}
+	return nil
+}
+
+// saveScreenshot keeps the screen of a gateway that did not get ready, to
+// see why.
+func saveScreenshot(png []byte) {
+	f, err := os.CreateTemp("", "ibdock-*.png")
+	if err == nil {
+		_, err = f.Write(png)
+		err = errors.Join(err, f.Close())
+	}
+	if err != nil {
+		logger.Println("Cannot save the screenshot:", err)
+		return
+	}
+	logger.Println("Screen when giving up saved to", f.Name())
+}
+
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// takeSnapshot reads a snapshot out of a running session.
+func takeSnapshot(args []string) error {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	container := containerFlags(flags)
+	output := flags.String("output", "json", fmt.Sprintf("Output format, one of %v", snapshot.Formats()))
+	outFile := flags.String("out_file", "", "File to write the snapshot to (default stdout)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the snapshot")
+	strict := flags.Bool("strict", false, "Fail on unknown or missing fields in the snapshot")
+	account := flags.String("ib_account", "", "IB account ID to snapshot, for logins managing several, see the accounts command (default the login's default account)")
+	currency := flags.String("currency", "", "Only read positions in this currency")
+	verbosity := flags.Int("verbosity", 0, "How much the snapshot script logs: 0 errors, 1 progress, 2 TWS API messages")
+	scriptArgs := flags.String("script_args", "", "Comma-separated extra arguments to the snapshot script")
+	mark := flags.Bool("mark_to_market", false, "Value positions at current quotes instead of IB's marks, which can be stale")
+	execReport := flags.String("exec_report", "", "File to write the snapshot script's ibdock.ExecReport to as JSON, also if it fails")
+	archive := sinkFlags(flags)
+	worthy := worthyFlags(flags)
+	flags.Parse(args)
+	w, err := archive()
+	if err != nil {
+		return err
+	}
+	var options []ibdock.Option
+	if *strict {
+		options = append(options, ibdock.WithStrictDecoding())
+	}
+	events := make(chan ibdock.Event, 16)
+	if *execReport != "" {
+		options = append(options, ibdock.WithEvents(events))
+	}
+	dock, err := ibdock.Attach(container(), logger, options...)
+	if err != nil {
+		return err
+	}
+	// A signal kills the snapshot script rather than leave it running in the
+	// session.
+	interrupted, stop := interruptContext()
+	defer stop()
+	ctx, cancel := context.WithTimeout(interrupted, *timeout)
+	defer cancel()
+	request := ibdock.SnapshotRequest{Account: *account, Currency: *currency, Verbosity: *verbosity}
+	if *scriptArgs != "" {
+		request.Args = strings.Split(*scriptArgs, ",")
+	}
+	s, err := dock.GetSnapshotWith(ctx, request)
+	if *execReport != "" {
+		if err := writeExecReport(*execReport, events); err != nil {
+			logger.Println("Cannot write the exec report:", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *mark {
+		if s, err = dock.MarkToMarket(ctx, s); err != nil {
+			return err
+		}
+	}
+	flushCtx, cancelFlush := flushContext(ctx)
+	defer cancelFlush()
+	if w != nil {
+		key, err := w.Write(flushCtx, s)
+		switch {
+		case err != nil:
+			return err
+		case key == "":
+			logger.Println("Snapshot unchanged since the last one archived, not archiving it")
+		default:
+			logger.Println("Archived as", key)
+		}
+	}
+	if client := worthy(); client != nil {
+		if err := client.Push(flushCtx, s); errors.Is(err, worthyclient.ErrUnchanged) {
+			logger.Println("Snapshot unchanged since the last one pushed, not pushing it")
+		} else if err != nil {
+			return err
+		}
+	}
+	data, err := snapshot.Marshal(*output, s)
+	if err != nil {
+		return err
+	}
+	if *outFile == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeFileAtomically(*outFile, data)
+}
+
+// writeExecReport writes the report of the last exec in events to path.
+func writeExecReport(path string, events <-chan ibdock.Event) error {
+	var report *ibdock.ExecReport
+	for len(events) > 0 {
+		if event := <-events; event.Kind == ibdock.EventExecFinished {
+			report = event.Report
+		}
+	}
+	if report == nil {
+		return errors.New("no exec finished")
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, append(data, '\n'))
+}
+
+// accounts prints the IB accounts the session's login manages, one per line,
+// the default one first.
+func accounts(args []string) error {
+	flags := flag.NewFlagSet("accounts", flag.ExitOnError)
+	container := containerFlags(flags)
+	timeout := flags.Duration("timeout", time.Minute, "How long to wait for the gateway")
+	flags.Parse(args)
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ids, err := dock.ListAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+	return nil
+}
+
+// writeFileAtomically writes data to a temporary file next to path and renames
+// it over path, so readers never see a partial snapshot.
+func writeFileAtomically(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func stop(args []string) error {
+	flags := flag.NewFlagSet("stop", flag.ExitOnError)
+	container := containerFlags(flags)
+	flags.Parse(args)
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	dock.Kill()
+	return nil
+}
+
+// gc removes ibcontroller containers that are no longer running.
+func gc(args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	flags.Parse(args)
+	removed, err := ibdock.RemoveStopped(context.Background(), logger)
+	fmt.Printf("Removed %d containers\n", len(removed))
+	return err
+}
+
+func logs(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	container := containerFlags(flags)
+	follow := flags.Bool("follow", false, "Keep printing new output")
+	flags.Parse(args)
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	err = dock.Logs(ctx, os.Stdout, *follow)
+	if ctx.Err() != nil {
+		// Interrupting --follow is how it ends.
+		return nil
+	}
+	return err
+}
+
+// storeFlags registers the flags selecting a snapshot store, returning a
+// function that opens it after parsing.
+func storeFlags(flags *flag.FlagSet) func() (*store.SQLStore, error) {
+	sqlitePath := flags.String("sqlite", "", "SQLite snapshot database")
+	dsn := flags.String("postgres", "", "Postgres snapshot database, e.g. postgres://worthy@localhost/worthy")
+	return func() (*store.SQLStore, error) {
+		switch {
+		case *sqlitePath != "" && *dsn == "":
+			return store.OpenSQLite(*sqlitePath)
+		case *dsn != "" && *sqlitePath == "":
+			return store.OpenPostgres(*dsn)
+		}
+		return nil, errors.New("exactly one of --sqlite and --postgres is required")
+	}
+}
+
+// sinkFlags registers the flags archiving snapshots to a sink, returning
+// the writer after parsing, nil without --sink.
+func sinkFlags(flags *flag.FlagSet) func() (*sink.Writer, error) {
+	url := flags.String("sink", "", "Also archive snapshots to this directory, file://, s3:// or gs:// URL, see sink.Open")
+	format := flags.String("sink_format", "json", fmt.Sprintf("Format of archived snapshots, one of %v", snapshot.Formats()))
+	gzip := flags.Bool("sink_gzip", false, "Gzip archived snapshots")
+	dedupe := flags.String("sink_dedupe_state", "", "File remembering the hash of each account's last archived snapshot, so identical ones are not archived again")
+	return func() (*sink.Writer, error) {
+		if *url == "" {
+			return nil, nil
+		}
+		if _, err := snapshot.Lookup(*format); err != nil {
+			return nil, err
+		}
+		s, err := sink.Open(*url)
+		if err != nil {
+			return nil, err
+		}
+		w := &sink.Writer{Sink: s, Format: *format, Gzip: *gzip}
+		if *dedupe != "" {
+			w.Dedupe = &snapshot.Dedupe{Path: *dedupe}
+		}
+		return w, nil
+	}
+}
+
+// worthyFlags registers the flags pushing snapshots to the worthy server,
+// returning the client after parsing, nil without --worthy_url.
+func worthyFlags(flags *flag.FlagSet) func() *worthyclient.Client {
+	url := flags.String("worthy_url", "", "Also push snapshots to this worthy ingestion endpoint, e.g. https://worthy.example.com/api/snapshots")
+	token := flags.String("worthy_token", "", "Token for --worthy_url (default $WORTHY_TOKEN)")
+	attempts := flags.Int("worthy_attempts", 5, "Tries per push when it fails transiently")
+	dedupe := flags.String("worthy_dedupe_state", "", "File remembering the hash of each account's last pushed snapshot, so identical ones are not pushed again")
+	return func() *worthyclient.Client {
+		if *url == "" {
+			return nil
+		}
+		client := &worthyclient.Client{URL: *url, Token: *token, Retry: ibdock.RetryPolicy{MaxAttempts: *attempts}}
+		if *dedupe != "" {
+			client.Dedupe = &snapshot.Dedupe{Path: *dedupe}
+		}
+		return client
+	}
+}
+
+// roundingFlags registers the flags choosing how reported values are rounded,
+// returning the policy after parsing.
+func roundingFlags(flags *flag.FlagSet) func() (rounding.Policy, error) {
+	places := flags.Int("precision", rounding.Default.Places, "Decimal places of amounts and percentages")
+	mode := flags.String("rounding", rounding.Default.Mode.String(), "Rounding mode: half-up, half-even (banker's) or down")
+	return func() (rounding.Policy, error) {
+		m, err := rounding.ParseMode(*mode)
+		if err != nil {
+			return rounding.Policy{}, err
+		}
+		if *places < 0 {
+			return rounding.Policy{}, errors.New("--precision must not be negative")
+		}
+		return rounding.Policy{Places: *places, Mode: m}, nil
+	}
+}
+
+// printerFlags registers roundingFlags and the language of reports,
+// returning the printer after parsing.
+func printerFlags(flags *flag.FlagSet) func() (locale.Printer, error) {
+	round := roundingFlags(flags)
+	lang := flags.String("locale", "", "Language of reports: en or cs (default $IBDOCK_LOCALE, or en)")
+	return func() (locale.Printer, error) {
+		policy, err := round()
+		if err != nil {
+			return locale.Printer{}, err
+		}
+		if *lang == "" {
+			*lang = os.Getenv("IBDOCK_LOCALE")
+		}
+		l := locale.English
+		if *lang != "" {
+			if l, err = locale.Parse(*lang); err != nil {
+				return locale.Printer{}, err
+			}
+		}
+		return locale.Printer{Policy: policy, Locale: l}, nil
+	}
+}
+
+// dedupe removes duplicate snapshots from a store written before saves were
+// idempotent.
+func dedupe(args []string) error {
+	flags := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	open := storeFlags(flags)
+	flags.Parse(args)
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	removed, err := s.Dedupe(context.Background())
+	if err != nil {
+		return err
+	}
+	logger.Println("Removed", removed, "duplicate snapshots")
+	return nil
+}
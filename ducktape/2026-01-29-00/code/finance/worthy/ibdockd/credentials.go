@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/agentydragon/worthy/ibdock"
+	"os"
+)
+
+type credentials struct {
+	Login    string
+	Password string
+	// options are the session options from --config.
+	options []ibdock.Option
+}
+
+// credentialFlags registers the flags for IB credentials on flags. The
+// returned function resolves them after parsing: flags win over the
+// IB_LOGIN_ID and IB_PASSWORD environment variables, which win over the JSON
+// file given by --credentials_file ({"Login": ..., "Password": ...}), which
+// wins over the --account of the ibdock.LoadConfig file given by --config, so
+// cron jobs need not put passwords on the command line.
+func credentialFlags(flags *flag.FlagSet) func() (credentials, error) {
+	login := flags.String("login", "", "IB login (default $IB_LOGIN_ID)")
+	password := flags.String("password", "", "IB password (default $IB_PASSWORD)")
+	file := flags.String("credentials_file", "", "JSON file with Login and Password")
+	configFile := flags.String("config", "", "YAML or TOML config file, see ibdock.LoadConfig")
+	account := flags.String("account", "default", "Account in --config to use")
+	return func() (credentials, error) {
+		var c credentials
+		if *configFile != "" {
+			config, err := ibdock.LoadConfig(*configFile)
+			if err != nil {
+				return c, err
+			}
+			a, err := config.Account(*account)
+			if err != nil {
+				return c, err
+			}
+			c.Login, c.Password, c.options = a.Username, a.Password, config.Options(a)
+		}
+		if *file != "" {
+			data, err := os.ReadFile(*file)
+			if err != nil {
+				return c, err
+			}
+			if err := json.Unmarshal(data, &c); err != nil {
+				return c, err
+			}
+		}
+		for _, override := range []struct {
+			value string
+			field *string
+		}{
+			{os.Getenv("IB_LOGIN_ID"), &c.Login},
+			{os.Getenv("IB_PASSWORD"), &c.Password},
+			{*login, &c.Login},
+			{*password, &c.Password},
+		} {
+			if override.value != "" {
+				*override.field = override.value
+			}
+		}
+		if c.Login == "" || c.Password == "" {
+			return c, errors.New("IB login and password are required, see --login, --password, --credentials_file and --config")
+		}
+		redactor.Add(c.Login, c.Password)
+		return c, nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialFlags(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "ibdock.yaml")
+	if err := os.WriteFile(config, []byte("accounts:\n  - name: main\n    username: config-login\n    password: config-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "credentials.json")
+	if err := os.WriteFile(file, []byte(`{"Login": "file-login", "Password": "file-password"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromConfig := []string{"--config", config, "--account", "main"}
+	fromFile := []string{"--credentials_file", file}
+	fromFlags := []string{"--login", "flag-login", "--password", "flag-password"}
+
+	for _, test := range []struct {
+		name                    string
+		args                    []string
+		envLogin, envPassword   string
+		wantLogin, wantPassword string
+	}{
+		// Each source alone first, so each registers its secrets with
+		// the redactor before others could.
+		{"config", fromConfig, "", "", "config-login", "config-password"},
+		{"file", fromFile, "", "", "file-login", "file-password"},
+		{"env", nil, "env-login", "env-password", "env-login", "env-password"},
+		{"flags", fromFlags, "", "", "flag-login", "flag-password"},
+		{"file over config", append(fromConfig, fromFile...), "", "", "file-login", "file-password"},
+		{"env over file", append(fromConfig, fromFile...), "env-login", "env-password", "env-login", "env-password"},
+		{"flags over env", append(append(fromConfig, fromFile...), fromFlags...), "env-login", "env-password", "flag-login", "flag-password"},
+		// Each field falls back on its own.
+		{"login flag, password env", []string{"--login", "flag-login"}, "", "env-password", "flag-login", "env-password"},
+		{"login env, password config", fromConfig, "env-login", "", "env-login", "config-password"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("IB_LOGIN_ID", test.envLogin)
+			t.Setenv("IB_PASSWORD", test.envPassword)
+			c, err := credentialFlagsFor(t, test.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Login != test.wantLogin || c.Password != test.wantPassword {
+				t.Errorf("got %s/%s, want %s/%s", c.Login, c.Password, test.wantLogin, test.wantPassword)
+			}
+			if usesConfig := strings.Contains(strings.Join(test.args, " "), config); usesConfig != (c.options != nil) {
+				t.Errorf("got options %v from a config used: %v", c.options, usesConfig)
+			}
+			if got := redactor.Redact(c.Login + " " + c.Password); got != "[REDACTED] [REDACTED]" {
+				t.Errorf("credentials logged as %q", got)
+			}
+		})
+	}
+}
+
+func TestCredentialFlagsMissing(t *testing.T) {
+	t.Setenv("IB_LOGIN_ID", "env-login")
+	t.Setenv("IB_PASSWORD", "")
+	if _, err := credentialFlagsFor(t); err == nil {
+		t.Error("no error without a password")
+	}
+	if _, err := credentialFlagsFor(t, "--credentials_file", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("no error for a missing --credentials_file")
+	}
+	if _, err := credentialFlagsFor(t, "--config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("no error for a missing --config")
+	}
+}
+
+// credentialFlagsFor resolves the credentials of args.
+func credentialFlagsFor(t *testing.T, args ...string) (credentials, error) {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	resolve := credentialFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return resolve()
+}
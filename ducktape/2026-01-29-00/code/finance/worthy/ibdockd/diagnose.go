@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"time"
+)
+
+// diagnose prints what is wrong with the Docker environment sessions would
+// run in, failing if something would keep them from starting.
+func diagnose(args []string) error {
+	flags := flag.NewFlagSet("diagnose", flag.ExitOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file to take the Docker options from, see ibdock.LoadConfig")
+	account := flags.String("account", "default", "Account in --config to use")
+	apiPort := flags.Int("api_port", 0, "Host port sessions will publish the TWS API on, see ibdock.WithAPIPort")
+	timeout := flags.Duration("timeout", time.Minute, "How long to wait for the Docker daemon")
+	flags.Parse(args)
+	var options []ibdock.Option
+	if *configFile != "" {
+		config, err := ibdock.LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		a, err := config.Account(*account)
+		if err != nil {
+			return err
+		}
+		options = config.Options(a)
+	}
+	if *apiPort != 0 {
+		options = append(options, ibdock.WithAPIPort(*apiPort))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report := ibdock.Diagnose(ctx, options...)
+	fmt.Print(report)
+	if report.Worst() == ibdock.SeverityError {
+		return errors.New("the Docker environment has problems, see above")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/export"
+	"github.com/agentydragon/worthy/ibdock/flex"
+	"github.com/agentydragon/worthy/ibdock/performance"
+	"os"
+	"time"
+)
+
+// exportBundle writes a zip of an account's monthly statements, performance
+// and allocation from the store, for an accountant. Deposits and withdrawals
+// come from a Flex query if one is given; without it, they count as growth.
+// Values are at the current FX rates of a running session.
+func exportBundle(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	open := storeFlags(flags)
+	container := containerFlags(flags)
+	account := flags.String("ib_account", "", "IB account ID to export, e.g. U1234567")
+	months := flags.Int("months", 12, "How many months back to export, this one included")
+	token := flags.String("flex_token", "", "Flex Web Service token (default $IB_FLEX_TOKEN)")
+	queryID := flags.String("flex_query", "", "ID of an Activity Flex Query with the Cash Transactions section, to tell contributions from growth")
+	base := flags.String("base", "USD", "Currency to report values in")
+	outFile := flags.String("out_file", "", "Zip file to write (default <ib_account>-<month>.zip)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the Flex query and rates")
+	printer := printerFlags(flags)
+	flags.Parse(args)
+	p, err := printer()
+	if err != nil {
+		return err
+	}
+	if *account == "" {
+		return errors.New("--ib_account is required")
+	}
+	if *token == "" {
+		*token = os.Getenv("IB_FLEX_TOKEN")
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month()-time.Month(*months-1), 1, 0, 0, 0, 0, time.UTC)
+	snapshots, err := s.Range(ctx, *account, from, now)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots of %s since %s", *account, from.Format("2006-01-02"))
+	}
+	var transactions []flex.CashTransaction
+	if *queryID != "" {
+		client := &flex.Client{Token: *token, QueryID: *queryID}
+		fetched, err := client.GetTransactions(ctx, from, now)
+		if err != nil {
+			return err
+		}
+		transactions = fetched.CashTransactions
+	}
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	var currencies []string
+	for _, snapshot := range snapshots {
+		currencies = append(currencies, snapshot.Currencies()...)
+	}
+	for _, t := range transactions {
+		if t.Type == flex.DepositsWithdrawals {
+			currencies = append(currencies, t.Currency)
+		}
+	}
+	rates, err := dock.GetFXRates(ctx, *base, currencies)
+	if err != nil {
+		return err
+	}
+	report, err := performance.Monthly(snapshots, transactions, rates)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	bundle := export.Bundle{Base: *base, Rates: rates, Snapshots: snapshots, Performance: report, Rounding: &p.Policy, Locale: p.Locale}
+	if err := bundle.WriteZip(&out); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		*outFile = fmt.Sprintf("%s-%s.zip", *account, now.Format("2006-01"))
+	}
+	if err := writeFileAtomically(*outFile, out.Bytes()); err != nil {
+		return err
+	}
+	fmt.Println(*outFile)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// exposure prints a running session's effective currency exposure, looking
+// through FX derivatives, valued at IB's current FX rates.
+func exposure(args []string) error {
+	flags := flag.NewFlagSet("exposure", flag.ExitOnError)
+	container := containerFlags(flags)
+	base := flags.String("base", "USD", "Currency to value exposure in")
+	detail := flags.Bool("detail", false, "List the positions contributing to each currency")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the snapshot and rates")
+	printer := printerFlags(flags)
+	flags.Parse(args)
+	p, err := printer()
+	if err != nil {
+		return err
+	}
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	s, err := dock.GetSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	rates, err := dock.GetFXRates(ctx, *base, s.Currencies())
+	if err != nil {
+		return err
+	}
+	report, err := s.CurrencyExposure(rates)
+	if err != nil {
+		return err
+	}
+	total := 0.0
+	for _, e := range report {
+		total += e.Value
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\n", p.T("Currency"), p.Sprintf("Value (%s)", *base), p.T("Share"))
+	for _, e := range report {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", e.Currency, p.Money(e.Value), p.Percent(e.Value/total))
+		if *detail {
+			for _, c := range e.Contributions {
+				fmt.Fprintf(w, "%s %s\t%s\t\t\n", c.SecType, c.Symbol, p.Money(c.Value))
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,68 @@
+// ibdockd runs IB sessions in ibdock containers, from cron or as a daemon:
+//
+//	ibdockd start --name=main --credentials_file=ib.json
+//	ibdockd snapshot --name=main --output=csv --out_file=positions.csv
+//	ibdockd stop --name=main
+//	ibdockd snapshot-all --config=accounts.yaml --output=csv --out_file=all.csv
+//	ibdockd serve --credentials_file=ib.json --addr=:8080
+package main
+
+import (
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"log"
+	"os"
+	"sort"
+)
+
+// redactor masks account IDs, and the credentials credentialFlags reads, in
+// what ibdockd logs; see ibdock.NewRedactor to turn it off.
+var redactor = ibdock.NewRedactor()
+
+var logger = redactor.Logger(log.New(os.Stderr, "ibdockd: ", log.LstdFlags))
+
+var commands = map[string]func(args []string) error{
+	"accounts":     accounts,
+	"start":        start,
+	"snapshot":     takeSnapshot,
+	"snapshot-all": snapshotAll,
+	"stop":         stop,
+	"gc":           gc,
+	"logs":         logs,
+	"dedupe":       dedupe,
+	"diagnose":     diagnose,
+	"exposure":     exposure,
+	"export":       exportBundle,
+	"performance":  performanceReport,
+	"report":       renderReport,
+	"risk":         risk,
+	"serve":        serve,
+	"soak":         soak,
+	"stress":       stress,
+	"watch":        watch,
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: ibdockd <command> [flags]\ncommands: %v\nrun ibdockd <command> -h for the command's flags\n", names)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	err := command(os.Args[2:])
+	if err != nil {
+		logger.Print(err)
+	}
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/flex"
+	"github.com/agentydragon/worthy/ibdock/performance"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// performanceReport prints how much of an account's growth over the last
+// months was saved into it and how much the market added, from the store's
+// snapshots and the deposits and withdrawals of a Flex query. Values are at
+// the current FX rates of a running session.
+func performanceReport(args []string) error {
+	flags := flag.NewFlagSet("performance", flag.ExitOnError)
+	open := storeFlags(flags)
+	container := containerFlags(flags)
+	account := flags.String("ib_account", "", "IB account ID to report on, e.g. U1234567")
+	months := flags.Int("months", 12, "How many months back to report on, this one included")
+	token := flags.String("flex_token", "", "Flex Web Service token (default $IB_FLEX_TOKEN)")
+	queryID := flags.String("flex_query", "", "ID of an Activity Flex Query with the Cash Transactions section")
+	base := flags.String("base", "USD", "Currency to report values in")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the Flex query and rates")
+	printer := printerFlags(flags)
+	flags.Parse(args)
+	p, err := printer()
+	if err != nil {
+		return err
+	}
+	if *account == "" || *queryID == "" {
+		return errors.New("--ib_account and --flex_query are required")
+	}
+	if *token == "" {
+		*token = os.Getenv("IB_FLEX_TOKEN")
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month()-time.Month(*months-1), 1, 0, 0, 0, 0, time.UTC)
+	snapshots, err := s.Range(ctx, *account, from, now)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots of %s since %s", *account, from.Format("2006-01-02"))
+	}
+	client := &flex.Client{Token: *token, QueryID: *queryID}
+	transactions, err := client.GetTransactions(ctx, from, now)
+	if err != nil {
+		return err
+	}
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	var currencies []string
+	for _, snapshot := range snapshots {
+		currencies = append(currencies, snapshot.Currencies()...)
+	}
+	for _, t := range transactions.OfType(flex.DepositsWithdrawals) {
+		currencies = append(currencies, t.Currency)
+	}
+	rates, err := dock.GetFXRates(ctx, *base, currencies)
+	if err != nil {
+		return err
+	}
+	report, err := performance.Monthly(snapshots, transactions.CashTransactions, rates)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", p.T("Month"), p.Sprintf("Start (%s)", *base), p.T("End"), p.T("Contributions"), p.T("Growth"))
+	for _, m := range report.Months {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", p.Month(m.Start), p.MoneyDecimal(m.StartValue), p.MoneyDecimal(m.EndValue), p.MoneyDecimal(m.Contributions), p.MoneyDecimal(m.Growth))
+	}
+	fmt.Fprintf(w, "%s\t\t\t%s\t%s\t\n", p.T("Total"), p.MoneyDecimal(report.Contributions), p.MoneyDecimal(report.Growth))
+	fmt.Fprintf(w, "%s\t\t\t%s\t\t\n", p.T("Savings rate"), p.Sprintf("%s/month", p.Money(report.SavingsRate)))
+	return w.Flush()
+}
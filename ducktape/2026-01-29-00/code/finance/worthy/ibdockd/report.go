@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/report"
+	"os"
+	"time"
+)
+
+// renderReport renders an account's latest stored snapshot through a template
+// from the config's reports section or a file, valued at the current FX
+// rates of a running session:
+//
+//	ibdockd report render --config=ibdock.yaml --template=summary --ib_account=U1234567 --sqlite=ib.db
+func renderReport(args []string) error {
+	if len(args) == 0 || args[0] != "render" {
+		return errors.New("usage: ibdockd report render [flags]")
+	}
+	flags := flag.NewFlagSet("report render", flag.ExitOnError)
+	open := storeFlags(flags)
+	container := containerFlags(flags)
+	configFile := flags.String("config", "", "YAML or TOML config file with a reports section, see ibdock.Config")
+	name := flags.String("template", "", "Report in the config's reports section, or a template file")
+	account := flags.String("ib_account", "", "IB account ID to report on, e.g. U1234567")
+	base := flags.String("base", "USD", "Currency to value positions in")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the rates")
+	printer := printerFlags(flags)
+	flags.Parse(args[1:])
+	p, err := printer()
+	if err != nil {
+		return err
+	}
+	if *name == "" || *account == "" {
+		return errors.New("--template and --ib_account are required")
+	}
+	text, ok := "", false
+	if *configFile != "" {
+		config, err := ibdock.LoadConfig(*configFile)
+		if err != nil {
+			return err
+		}
+		text, ok = config.Reports[*name]
+	}
+	if !ok {
+		data, err := os.ReadFile(*name)
+		if err != nil {
+			return fmt.Errorf("no report %q in the config and no such file: %w", *name, err)
+		}
+		text = string(data)
+	}
+	tmpl, err := report.Parse(*name, text, p)
+	if err != nil {
+		return err
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	latest, err := s.Latest(ctx, *account)
+	if err != nil {
+		return err
+	}
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	rates, err := dock.GetFXRates(ctx, *base, latest.Currencies())
+	if err != nil {
+		return err
+	}
+	data, err := report.NewData(latest, *base, rates)
+	if err != nil {
+		return err
+	}
+	return tmpl.Render(os.Stdout, data)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"time"
+)
+
+// risk prints the positions of a running session that break concentration or
+// liquidity limits.
+func risk(args []string) error {
+	flags := flag.NewFlagSet("risk", flag.ExitOnError)
+	container := containerFlags(flags)
+	maxWeight := flags.Float64("max_weight", 0.2, "Largest part of the portfolio one position may be; 0 disables the check")
+	maxDays := flags.Float64("max_days", 1, "Most trading days a position may take to liquidate; 0 disables the check")
+	participation := flags.Float64("participation", 0.1, "Part of a stock's average daily volume one could trade without moving it")
+	timeout := flags.Duration("timeout", 5*time.Minute, "How long to wait for the snapshot and market data")
+	flags.Parse(args)
+	dock, err := ibdock.Attach(container(), logger)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	s, err := dock.GetSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	flagged, err := dock.AssessRisk(ctx, s, snapshot.RiskLimits{
+		MaxWeight:          *maxWeight,
+		MaxDaysToLiquidate: *maxDays,
+		Participation:      *participation,
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range flagged {
+		fmt.Println(f)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// daemon owns the long-lived session and the Manager snapshotting it.
+type daemon struct {
+	// start starts a session, normally a Dock.
+	start   func() (ibdock.Session, error)
+	logger  *log.Logger
+	manager *ibdock.Manager
+	// rounding applies to /share and to /snapshot?rounded.
+	rounding rounding.Policy
+	// clock dates successes for /health, default clock.Real.
+	clock clock.Clock
+
+	mu   sync.Mutex
+	dock ibdock.Session
+	// latest is the last successful snapshot.
+	latest atomic.Pointer[snapshot.Snapshot]
+
+	snapshots   atomic.Int64
+	failures    atomic.Int64
+	restarts    atomic.Int64
+	lastSuccess atomic.Int64 // Unix seconds
+	lastError   atomic.Value // string
+	lastFailed  atomic.Bool
+}
+
+func (d *daemon) current() ibdock.Session {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dock
+}
+
+func (d *daemon) takeSnapshot(ctx context.Context) (*snapshot.Snapshot, error) {
+	return d.current().GetSnapshot(ctx)
+}
+
+// restart replaces the session with a freshly started one.
+func (d *daemon) restart(ctx context.Context) error {
+	// The old session goes first: its container holds the name the new one
+	// takes, and IB logs one of two sessions of a login out anyway.
+	d.current().Kill()
+	dock, err := d.start()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.dock = dock
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *daemon) hooks() ibdock.Hooks {
+	return ibdock.Hooks{
+		OnSnapshot: func(s *snapshot.Snapshot) {
+			d.latest.Store(s)
+			d.snapshots.Add(1)
+			d.lastSuccess.Store(clock.OrReal(d.clock).Now().Unix())
+			d.lastFailed.Store(false)
+		},
+		OnError: func(err error) {
+			d.failures.Add(1)
+			d.lastError.Store(err.Error())
+			d.lastFailed.Store(true)
+		},
+		OnRestart: func(error) {
+			d.restarts.Add(1)
+		},
+	}
+}
+
+// chainSnapshot returns an OnSnapshot hook calling first, then then.
+func chainSnapshot(first, then func(*snapshot.Snapshot)) func(*snapshot.Snapshot) {
+	return func(s *snapshot.Snapshot) {
+		first(s)
+		then(s)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type errorResponse struct {
+	Error string
+}
+
+// handleSnapshot returns a snapshot at most max_age old (a Go duration,
+// default 0: always take a new one). With rounded set, market values are
+// rounded for display; by default they are as IB reported them.
+func (d *daemon) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	var options ibdock.SnapshotOptions
+	if maxAge := r.URL.Query().Get("max_age"); maxAge != "" {
+		var err error
+		if options.MaxAge, err = time.ParseDuration(maxAge); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+			return
+		}
+	}
+	handle, err := d.manager.TriggerSnapshot(r.Context(), options)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{err.Error()})
+		return
+	}
+	s, err := handle.Wait(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorResponse{err.Error()})
+		return
+	}
+	if r.URL.Query().Has("rounded") {
+		s = roundSnapshot(s, d.rounding)
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// roundSnapshot returns a copy of s with market values rounded. Quantities
+// and per-unit prices are kept, as rounding them would change the holding.
+func roundSnapshot(s *snapshot.Snapshot, policy rounding.Policy) *snapshot.Snapshot {
+	rounded := *s
+	rounded.Positions = make([]snapshot.Position, len(s.Positions))
+	for i, p := range s.Positions {
+		p.MarketValue = policy.RoundDecimal(p.MarketValue)
+		rounded.Positions[i] = p
+	}
+	return &rounded
+}
+
+type health struct {
+	Healthy     bool
+	LastSuccess time.Time `json:",omitempty"`
+	LastError   string    `json:",omitempty"`
+}
+
+// handleHealth reports healthy if a snapshot succeeded within maxAge. Without
+// a maxAge, when snapshots are only taken on request and so may be rare, it
+// reports healthy unless the last snapshot failed.
+func (d *daemon) handleHealth(maxAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var h health
+		if last := d.lastSuccess.Load(); last != 0 {
+			h.LastSuccess = time.Unix(last, 0)
+		}
+		switch {
+		case maxAge <= 0:
+			h.Healthy = !d.lastFailed.Load()
+		case !h.LastSuccess.IsZero():
+			h.Healthy = clock.OrReal(d.clock).Now().Sub(h.LastSuccess) <= maxAge
+		}
+		h.LastError, _ = d.lastError.Load().(string)
+		status := http.StatusOK
+		if !h.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, h)
+	}
+}
+
+type metrics struct {
+	Snapshots int64
+	Failures  int64
+	Restarts  int64
+	// LastSuccess is in Unix seconds, 0 if there was none yet.
+	LastSuccess int64
+}
+
+func (d *daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, metrics{
+		Snapshots:   d.snapshots.Load(),
+		Failures:    d.failures.Load(),
+		Restarts:    d.restarts.Load(),
+		LastSuccess: d.lastSuccess.Load(),
+	})
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	creds := credentialFlags(flags)
+	addr := flags.String("addr", ":8080", "Address to serve HTTP on")
+	interval := flags.Duration("interval", time.Hour, "Interval between scheduled snapshots")
+	startupDelay := flags.Duration("startup_delay", time.Minute, "Time to let the gateway log in before the first snapshot")
+	restartAfter := flags.Int("restart_after", 3, "Failed snapshots in a row after which the container is replaced")
+	strict := flags.Bool("strict", false, "Fail on unknown or missing fields in snapshots, to catch image drift")
+	drainTimeout := flags.Duration("drain_timeout", 5*time.Minute, "On shutdown, how long to let an in-flight snapshot finish before cancelling it")
+	stopTimeout := flags.Duration("stop_timeout", 30*time.Second, "On shutdown, how long to let the gateway exit before killing its container")
+	mock := flags.String("mock", "", "Comma-separated snapshot files to serve in turn instead of starting a gateway, for demos")
+	mockFormat := flags.String("mock_format", "json", "Format of the --mock files")
+	attempts := flags.Int("attempts", 1, "Tries per container start and snapshot when they fail transiently, see ibdock.Retryable")
+	shareToken := flags.String("share_token", "", "Serve a redacted view of the latest snapshot, allocation percentages and returns only, at /share/<token> (default $IBDOCK_SHARE_TOKEN; empty disables)")
+	shareBase := flags.String("share_base", "USD", "Currency to weigh positions in for /share")
+	round := roundingFlags(flags)
+	archive := sinkFlags(flags)
+	worthy := worthyFlags(flags)
+	flags.Parse(args)
+	policy, err := round()
+	if err != nil {
+		return err
+	}
+	w, err := archive()
+	if err != nil {
+		return err
+	}
+	if *shareToken == "" {
+		*shareToken = os.Getenv("IBDOCK_SHARE_TOKEN")
+	}
+	if *shareToken != "" && len(*shareToken) < minShareToken {
+		return fmt.Errorf("--share_token must be at least %d characters", minShareToken)
+	}
+
+	// Signals during the start are handled too, so the container it
+	// creates is stopped again.
+	ctx, stop := interruptContext()
+	defer stop()
+	d := &daemon{logger: logger, rounding: policy}
+	if *mock != "" {
+		d.start = func() (ibdock.Session, error) {
+			return ibdock.LoadMockDock(*mockFormat, strings.Split(*mock, ",")...)
+		}
+	} else {
+		c, err := creds()
+		if err != nil {
+			return err
+		}
+		options := c.options
+		if *strict {
+			options = append(options, ibdock.WithStrictDecoding())
+		}
+		retry := ibdock.RetryPolicy{MaxAttempts: *attempts}
+		d.start = func() (ibdock.Session, error) {
+			var dock *ibdock.Dock
+			err := retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				dock, err = ibdock.StartNewContext(ctx, c.Login, c.Password, logger, options...)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			return ibdock.WithRetry(dock, retry), nil
+		}
+	}
+	d.lastError.Store("")
+	if d.dock, err = d.start(); err != nil {
+		return err
+	}
+	hooks := d.hooks()
+	var exports inflight
+	if w != nil {
+		hooks.OnSnapshot = chainSnapshot(hooks.OnSnapshot, exports.track(w.Hook(logger)))
+	}
+	if client := worthy(); client != nil {
+		hooks.OnSnapshot = chainSnapshot(hooks.OnSnapshot, exports.track(client.Hook(logger)))
+	}
+	d.manager = ibdock.NewManager(d.takeSnapshot, ibdock.ManagerOptions{
+		Interval:     *interval,
+		Restart:      d.restart,
+		RestartAfter: *restartAfter,
+		Hooks:        hooks,
+	}, logger)
+
+	// Runs get their own context so a signal stops the schedule without
+	// cutting off a snapshot halfway; see the "snapshots" stage below.
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /snapshot", d.handleSnapshot)
+	// Without a schedule, --interval=0, snapshots are as fresh as clients
+	// ask for and /health only checks the last one succeeded.
+	mux.HandleFunc("GET /health", d.handleHealth(max(2**interval, 0)))
+	mux.HandleFunc("GET /metrics", d.handleMetrics)
+	if *shareToken != "" {
+		sh := &sharer{d: d, token: *shareToken, base: *shareBase}
+		mux.HandleFunc("GET /share/{token}", sh.handleShare)
+	}
+	server := &http.Server{Addr: *addr, Handler: mux}
+	served := make(chan error, 1)
+	go func() {
+		logger.Println("Serving on", *addr)
+		served <- server.ListenAndServe()
+	}()
+	go func() {
+		select {
+		case <-time.After(*startupDelay):
+			d.manager.Run(runCtx)
+		case <-ctx.Done():
+		}
+	}()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-served:
+	}
+	// The order matters: new runs stop first so the in-flight one is the
+	// last, its handler and hooks finish before HTTP requests waiting on it
+	// are cut off, and the container goes only once nothing uses it. The
+	// archive and worthy exports run in the hooks and write through, so
+	// there is no buffer to flush nor store to close; the "exports" stage
+	// only waits for one still running should the drain give up on it.
+	err = shutdown(logger,
+		stage{"snapshots", *drainTimeout, func(ctx context.Context) error {
+			err := d.manager.Shutdown(ctx)
+			if err != nil {
+				cancelRuns()
+			}
+			return err
+		}},
+		stage{"exports", flushTimeout, exports.wait},
+		stage{"http", 10 * time.Second, server.Shutdown},
+		stage{"session", *stopTimeout, func(ctx context.Context) error {
+			dock := d.current()
+			err := dock.Stop(ctx)
+			if err != nil {
+				dock.Kill()
+			}
+			return err
+		}},
+	)
+	if serveErr != nil && serveErr != http.ErrServerClosed {
+		return serveErr
+	}
+	return err
+}
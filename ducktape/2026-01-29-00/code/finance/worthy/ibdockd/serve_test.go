@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/clock"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2026, 3, 7, 9, 30, 0, 0, time.UTC)
+
+// get serves a GET of target with handler and decodes the JSON response
+// into v, returning the status.
+func get(t *testing.T, handler http.Handler, target string, v any) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	if v != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: %v: %s", target, err, recorder.Body)
+		}
+	}
+	return recorder.Code
+}
+
+func TestHandleHealth(t *testing.T) {
+	clk := clock.NewFake(testTime)
+	d := &daemon{clock: clk}
+	hooks := d.hooks()
+	scheduled, unscheduled := d.handleHealth(2*time.Hour), d.handleHealth(0)
+	check := func(when string, handler http.HandlerFunc, want int) {
+		t.Helper()
+		var h health
+		if got := get(t, handler, "/health", &h); got != want || h.Healthy != (want == http.StatusOK) {
+			t.Errorf("%s: status %d, %+v, want %d", when, got, h, want)
+		}
+	}
+
+	check("scheduled, before any snapshot", scheduled, http.StatusServiceUnavailable)
+	check("unscheduled, before any snapshot", unscheduled, http.StatusOK)
+	hooks.OnSnapshot(&snapshot.Snapshot{})
+	check("scheduled, fresh", scheduled, http.StatusOK)
+	clk.Advance(3 * time.Hour)
+	check("scheduled, stale", scheduled, http.StatusServiceUnavailable)
+	check("unscheduled, old", unscheduled, http.StatusOK)
+	hooks.OnError(errors.New("gateway gone"))
+	check("unscheduled, failed", unscheduled, http.StatusServiceUnavailable)
+	var h health
+	get(t, unscheduled, "/health", &h)
+	if h.LastError != "gateway gone" || !h.LastSuccess.Equal(testTime) {
+		t.Errorf("health %+v, want the error and the success at %v", h, testTime)
+	}
+	hooks.OnSnapshot(&snapshot.Snapshot{})
+	check("unscheduled, recovered", unscheduled, http.StatusOK)
+}
+
+// testDaemon runs a daemon over a MockDock until the test ends.
+func testDaemon(t *testing.T, s *snapshot.Snapshot) *daemon {
+	mock := ibdock.NewMockDock(s)
+	mock.FXRates = snapshot.FXRates{"EUR": 1.25}
+	d := &daemon{logger: log.New(io.Discard, "", 0), rounding: rounding.Default, clock: clock.NewFake(testTime), dock: mock}
+	d.manager = ibdock.NewManager(d.takeSnapshot, ibdock.ManagerOptions{Hooks: d.hooks()}, d.logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	go d.manager.Run(ctx)
+	t.Cleanup(cancel)
+	return d
+}
+
+func TestHandleSnapshot(t *testing.T) {
+	stored := &snapshot.Snapshot{Account: "U1111111", Timestamp: testTime, Positions: []snapshot.Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(10, 0), MarketValue: snapshot.NewDecimal(1234565, -3)},
+	}}
+	d := testDaemon(t, stored)
+	handler := http.HandlerFunc(d.handleSnapshot)
+
+	var s snapshot.Snapshot
+	if got := get(t, handler, "/snapshot", &s); got != http.StatusOK || !reflect.DeepEqual(&s, stored) {
+		t.Errorf("GET /snapshot: %d, %+v, want %+v", got, s, stored)
+	}
+	if latest := d.latest.Load(); !reflect.DeepEqual(latest, stored) {
+		t.Errorf("latest snapshot %+v, want %+v", latest, stored)
+	}
+	if got := get(t, handler, "/snapshot?rounded&max_age=1h", &s); got != http.StatusOK || s.Positions[0].MarketValue.String() != "1234.57" {
+		t.Errorf("GET /snapshot?rounded: %d, %+v, want a market value of 1234.57", got, s)
+	}
+	if n := d.snapshots.Load(); n != 1 {
+		t.Errorf("took %d snapshots, want 1 as the second was within max_age", n)
+	}
+	var e errorResponse
+	if got := get(t, handler, "/snapshot?max_age=soon", &e); got != http.StatusBadRequest || e.Error == "" {
+		t.Errorf("GET /snapshot?max_age=soon: %d, %+v", got, e)
+	}
+	d.manager.Shutdown(context.Background())
+	if got := get(t, handler, "/snapshot", &e); got != http.StatusServiceUnavailable {
+		t.Errorf("GET /snapshot after shutdown: %d, %+v", got, e)
+	}
+}
+
+func TestHandleShare(t *testing.T) {
+	d := testDaemon(t, &snapshot.Snapshot{Account: "U1111111", Timestamp: testTime, Positions: []snapshot.Position{
+		{Symbol: "VT", SecType: "STK", Currency: "USD", Quantity: snapshot.NewDecimal(10, 0), AvgCost: snapshot.NewDecimal(100, 0), MarketValue: snapshot.NewDecimal(1500, 0)},
+		{Symbol: "EUR", SecType: "CASH", Currency: "EUR", Quantity: snapshot.NewDecimal(400, 0)},
+	}})
+	mux := http.NewServeMux()
+	sh := &sharer{d: d, token: "0123456789abcdef", base: "USD"}
+	mux.HandleFunc("GET /share/{token}", sh.handleShare)
+
+	if got := get(t, mux, "/share/0123456789abcdeX", nil); got != http.StatusNotFound {
+		t.Errorf("GET with a wrong token: %d, want 404", got)
+	}
+	var e errorResponse
+	if got := get(t, mux, "/share/0123456789abcdef", &e); got != http.StatusServiceUnavailable {
+		t.Errorf("GET before any snapshot: %d, %+v, want 503", got, e)
+	}
+	if got := get(t, http.HandlerFunc(d.handleSnapshot), "/snapshot", nil); got != http.StatusOK {
+		t.Fatalf("GET /snapshot: %d", got)
+	}
+	var share snapshot.Share
+	if got := get(t, mux, "/share/0123456789abcdef", &share); got != http.StatusOK {
+		t.Fatalf("GET of the latest snapshot: %d", got)
+	}
+	want := []snapshot.Allocation{{Symbol: "VT", SecType: "STK", Weight: 0.75, Return: 0.5}, {Symbol: "EUR", SecType: "CASH", Weight: 0.25}}
+	if !reflect.DeepEqual(share.Allocations, want) || share.Return != 0.5 {
+		t.Errorf("shared %+v, want allocations %+v returning 0.5", share, want)
+	}
+}
+
+func TestChainSnapshot(t *testing.T) {
+	var calls []string
+	s := &snapshot.Snapshot{Account: "U1111111"}
+	hook := func(name string) func(*snapshot.Snapshot) {
+		return func(got *snapshot.Snapshot) {
+			if got != s {
+				t.Errorf("%s got %p, want %p", name, got, s)
+			}
+			calls = append(calls, name)
+		}
+	}
+	chainSnapshot(chainSnapshot(hook("daemon"), hook("archive")), hook("worthy"))(s)
+	if want := []string{"daemon", "archive", "worthy"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("called %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/rounding"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"net/http"
+	"sync"
+)
+
+// minShareToken is the shortest --share_token accepted, so share URLs
+// cannot be guessed.
+const minShareToken = 16
+
+// sharer serves the redacted view of the latest snapshot, see
+// snapshot.Share. Views are computed once per snapshot, so visitors of a
+// shared link cannot make the gateway do more work.
+type sharer struct {
+	d     *daemon
+	token string
+	base  string
+
+	mu       sync.Mutex
+	snapshot *snapshot.Snapshot
+	share    *snapshot.Share
+}
+
+func (sh *sharer) view(r *http.Request) (*snapshot.Share, error) {
+	s := sh.d.latest.Load()
+	if s == nil {
+		return nil, errors.New("no snapshot yet")
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.snapshot == s {
+		return sh.share, nil
+	}
+	rates, err := sh.d.current().GetFXRates(r.Context(), sh.base, s.Currencies())
+	if err != nil {
+		return nil, err
+	}
+	share, err := s.Share(rates)
+	if err != nil {
+		return nil, err
+	}
+	roundShare(share, sh.d.rounding)
+	sh.snapshot, sh.share = s, share
+	return share, nil
+}
+
+// roundShare rounds the weights and returns of share so they print as
+// percentages to the policy's places.
+func roundShare(share *snapshot.Share, policy rounding.Policy) {
+	share.Return = policy.RoundFraction(share.Return)
+	for i := range share.Allocations {
+		a := &share.Allocations[i]
+		a.Weight = policy.RoundFraction(a.Weight)
+		a.Return = policy.RoundFraction(a.Return)
+	}
+}
+
+// handleShare serves GET /share/{token}. Wrong tokens get a plain 404.
+func (sh *sharer) handleShare(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.PathValue("token")), []byte(sh.token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+	// The token is in the URL: keep it out of caches and Referer headers.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	share, err := sh.view(r)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, share)
+}
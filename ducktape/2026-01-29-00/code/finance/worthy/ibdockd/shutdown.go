@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"log"
+	"sync"
+	"time"
+)
+
+// stage is one step of shutting the daemon down.
+type stage struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// shutdown runs stages in order, each bounded by its own timeout. A stage
+// that fails or times out does not stop later ones: the session container
+// should go away even if draining snapshots did not finish.
+func shutdown(logger *log.Logger, stages ...stage) error {
+	var errs []error
+	for _, s := range stages {
+		logger.Println("Shutdown:", s.name)
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		err := s.run(ctx)
+		cancel()
+		if err != nil {
+			logger.Println("Shutdown:", s.name, "failed:", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// inflight counts the OnSnapshot hooks it tracks that are still running, so
+// shutdown can wait for archiving and pushing to finish even when draining
+// snapshots gave up on the run calling them.
+type inflight struct {
+	mu sync.Mutex
+	n  int
+	// idle, if set, is closed once n drops to zero.
+	idle chan struct{}
+}
+
+func (f *inflight) track(hook func(*snapshot.Snapshot)) func(*snapshot.Snapshot) {
+	return func(s *snapshot.Snapshot) {
+		f.mu.Lock()
+		f.n++
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.n--; f.n == 0 && f.idle != nil {
+				close(f.idle)
+				f.idle = nil
+			}
+		}()
+		hook(s)
+	}
+}
+
+// wait blocks until no tracked hook runs or ctx is done.
+func (f *inflight) wait(ctx context.Context) error {
+	f.mu.Lock()
+	if f.n == 0 {
+		f.mu.Unlock()
+		return nil
+	}
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+	idle := f.idle
+	f.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
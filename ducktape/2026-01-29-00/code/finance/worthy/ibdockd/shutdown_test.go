@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	var ran []string
+	run := func(name string, err error) stage {
+		return stage{name, time.Second, func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	err := shutdown(log.New(io.Discard, "", 0),
+		stage{"snapshots", time.Millisecond, func(ctx context.Context) error {
+			ran = append(ran, "snapshots")
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		run("exports", nil),
+		run("http", errors.New("refused")),
+		run("session", nil),
+	)
+	if want := []string{"snapshots", "exports", "http", "session"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "http: refused") {
+		t.Errorf("shutdown error %v, want the snapshots timeout and the http failure", err)
+	}
+}
+
+func TestInflight(t *testing.T) {
+	var exports inflight
+	if err := exports.wait(context.Background()); err != nil {
+		t.Fatalf("wait without exports: %v", err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	hook := exports.track(func(*snapshot.Snapshot) {
+		close(started)
+		<-release
+	})
+	go hook(&snapshot.Snapshot{})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := exports.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait for a running export = %v, want a timeout", err)
+	}
+	waited := make(chan error)
+	go func() { waited <- exports.wait(context.Background()) }()
+	close(release)
+	if err := <-waited; err != nil {
+		t.Errorf("wait for a finished export = %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"github.com/agentydragon/worthy/ibdock"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// flushTimeout bounds archiving and pushing a snapshot that was taken before
+// a signal, so it is not lost to the signal but cannot hold up the exit.
+const flushTimeout = time.Minute
+
+// caught is the first SIGINT or SIGTERM an interruptContext got, for the
+// exit code.
+var caught atomic.Pointer[syscall.Signal]
+
+// interruptContext returns a context cancelled by SIGINT or SIGTERM, so
+// commands can kill their execs and remove their containers before exiting.
+// After the first signal the default handling is back, so a second one
+// exits right away.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			if sig, ok := sig.(syscall.Signal); ok {
+				caught.CompareAndSwap(nil, &sig)
+			}
+			signal.Stop(signals)
+			logger.Println("Got", sig.String()+", cleaning up; send it again to exit right away")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// stopSessionTimeout bounds how long stopSession lets the gateway exit.
+const stopSessionTimeout = 30 * time.Second
+
+// stopSession stops session, killing it if it does not stop in time.
+func stopSession(session ibdock.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopSessionTimeout)
+	defer cancel()
+	if err := session.Stop(ctx); err != nil {
+		session.Kill()
+	}
+}
+
+// flushContext returns a context for finishing the work of ctx after ctx
+// was cancelled by a signal, within flushTimeout.
+func flushContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+}
+
+// exitCode is 0 for commands that succeeded, even after a signal, as serve
+// does when it shut down cleanly; 128 plus the signal's number, as shells
+// report, for commands a signal cut short; and 1 for other failures.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if sig := caught.Load(); sig != nil {
+		return 128 + int(*sig)
+	}
+	return 1
+}
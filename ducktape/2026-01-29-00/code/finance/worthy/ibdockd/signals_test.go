@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInterruptExitCode(t *testing.T) {
+	caught.Store(nil)
+	t.Cleanup(func() { caught.Store(nil) })
+	failed := errors.New("failed")
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("exitCode(nil) = %d, want 0", got)
+	}
+	if got := exitCode(failed); got != 1 {
+		t.Errorf("exitCode without a signal = %d, want 1", got)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("SIGTERM did not cancel the context")
+	}
+	if got := exitCode(failed); got != 128+int(syscall.SIGTERM) {
+		t.Errorf("exitCode after SIGTERM = %d, want %d", got, 128+int(syscall.SIGTERM))
+	}
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("exitCode of a success after SIGTERM = %d, want 0", got)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"github.com/agentydragon/worthy/ibdock/worthyclient"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// snapshotAll starts a session for each account of a config file, a few at a
+// time, snapshots it and stops it again, and writes the snapshots as one
+// report. Accounts that fail are listed at the end and fail the command, but
+// do not keep the others out of the report.
+func snapshotAll(args []string) error {
+	flags := flag.NewFlagSet("snapshot-all", flag.ExitOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file listing the accounts, see ibdock.LoadConfig")
+	parallel := flags.Int("parallel", 2, "How many sessions to run at once")
+	output := flags.String("output", "json", "Report format: json (an array of snapshots) or csv (their rows under one header)")
+	outFile := flags.String("out_file", "", "File to write the report to (default stdout)")
+	timeout := flags.Duration("timeout", 10*time.Minute, "How long to wait for each account's login and snapshot")
+	archive := sinkFlags(flags)
+	worthy := worthyFlags(flags)
+	flags.Parse(args)
+	w, err := archive()
+	if err != nil {
+		return err
+	}
+	if *configFile == "" {
+		return errors.New("--config is required")
+	}
+	if *output != "json" && *output != "csv" {
+		return fmt.Errorf("unknown --output %q, want json or csv", *output)
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("--parallel must be positive, got %d", *parallel)
+	}
+	client := worthy()
+	config, err := ibdock.LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if len(config.Accounts) == 0 {
+		return fmt.Errorf("%s lists no accounts", *configFile)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	snaps := make([]*snapshot.Snapshot, len(config.Accounts))
+	errs := make([]error, len(config.Accounts))
+	slots := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i, account := range config.Accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if errs[i] = ctx.Err(); errs[i] != nil {
+				return
+			}
+			snaps[i], errs[i] = snapshotAccount(ctx, config, account, *timeout)
+			// Snapshots taken before a signal are still archived.
+			flushCtx, cancel := flushContext(ctx)
+			defer cancel()
+			if errs[i] == nil && w != nil {
+				_, errs[i] = w.Write(flushCtx, snaps[i])
+			}
+			if errs[i] == nil && client != nil {
+				if errs[i] = client.Push(flushCtx, snaps[i]); errors.Is(errs[i], worthyclient.ErrUnchanged) {
+					errs[i] = nil
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var taken []*snapshot.Snapshot
+	var failed []string
+	for i, account := range config.Accounts {
+		// Snapshots that could not be archived still make the report.
+		if snaps[i] != nil {
+			taken = append(taken, snaps[i])
+		}
+		if errs[i] != nil {
+			failed = append(failed, account.Name)
+		}
+	}
+	data, err := mergeSnapshots(*output, taken)
+	if err != nil {
+		return err
+	}
+	if *outFile == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = writeFileAtomically(*outFile, data)
+	}
+	if err != nil {
+		return err
+	}
+	for i, account := range config.Accounts {
+		if errs[i] != nil {
+			logger.Printf("%s failed: %v", account.Name, errs[i])
+		}
+	}
+	logger.Printf("Snapshotted %d of %d accounts", len(taken), len(config.Accounts))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d accounts failed: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// snapshotAccount starts account's session, waits for it to log in, takes
+// its snapshot and stops it, all within timeout.
+func snapshotAccount(ctx context.Context, config *ibdock.Config, account ibdock.AccountConfig, timeout time.Duration) (*snapshot.Snapshot, error) {
+	if account.Username == "" || account.Password == "" {
+		return nil, errors.New("no username or password, see ibdock.LoadConfig")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	redactor.Add(account.Username, account.Password)
+	accountLogger := redactor.Logger(log.New(os.Stderr, "ibdockd: "+account.Name+": ", log.LstdFlags))
+	dock, err := ibdock.StartNewContext(ctx, account.Username, account.Password, accountLogger, config.Options(account)...)
+	if err != nil {
+		return nil, err
+	}
+	defer stopSession(dock)
+	if err := dock.WaitReady(ctx); err != nil {
+		var screenshot *ibdock.ScreenshotError
+		if errors.As(err, &screenshot) {
+			saveScreenshot(screenshot.PNG)
+		}
+		return nil, err
+	}
+	return dock.GetSnapshot(ctx)
+}
+
+// mergeSnapshots renders snaps as one report: a JSON array, or the rows of
+// their CSVs under a single header.
+func mergeSnapshots(format string, snaps []*snapshot.Snapshot) ([]byte, error) {
+	if format == "json" {
+		if snaps == nil {
+			snaps = []*snapshot.Snapshot{}
+		}
+		return json.Marshal(snaps)
+	}
+	var b bytes.Buffer
+	for i, s := range snaps {
+		data, err := snapshot.Marshal("csv", s)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			data = data[bytes.IndexByte(data, '\n')+1:]
+		}
+		b.Write(data)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAll(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	if err := server.ServeGateway("U1111111"); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	running := 0
+	server.HandleExec(func(exec ibdocktest.Exec) ibdocktest.Result {
+		if !strings.HasSuffix(exec.Cmd[1], "read_snapshot.py") {
+			return ibdocktest.Result{}
+		}
+		var name string
+		alive := 0
+		for _, c := range server.Containers() {
+			if c.ID == exec.Container {
+				name = strings.TrimPrefix(c.Name, "/ibcontroller_")
+			}
+			if c.State.Running {
+				alive++
+			}
+		}
+		mu.Lock()
+		running = max(running, alive)
+		mu.Unlock()
+		if name == "broken" {
+			return ibdocktest.Result{Stderr: []byte("not logged in"), ExitCode: 1}
+		}
+		data, err := snapshot.Marshal("json", &snapshot.Snapshot{Account: name, Timestamp: testTime})
+		if err != nil {
+			return ibdocktest.Result{Stderr: []byte(err.Error()), ExitCode: 2}
+		}
+		// Long enough for the sessions of the parallel accounts to overlap.
+		return ibdocktest.Result{Stdout: data, Delay: 20 * time.Millisecond}
+	})
+	dir := t.TempDir()
+	config := filepath.Join(dir, "ibdock.yaml")
+	var accounts strings.Builder
+	for _, name := range []string{"one", "broken", "two", "three"} {
+		accounts.WriteString("  - {name: " + name + ", username: " + name + "-login, password: " + name + "-password}\n")
+	}
+	accounts.WriteString("  - {name: nopass, username: nopass-login}\n")
+	if err := os.WriteFile(config, []byte("docker: {endpoint: "+server.URL()+"}\naccounts:\n"+accounts.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "snapshots.json")
+
+	err := snapshotAll([]string{"--config", config, "--parallel", "2", "--out_file", out, "--timeout", "10s"})
+	if err == nil || !strings.Contains(err.Error(), "[broken nopass]") {
+		t.Errorf("snapshot-all = %v, want broken and nopass to fail", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accounts that failed do not keep the others out of the report,
+	// which lists them in config order.
+	var snaps []snapshot.Snapshot
+	if err := json.Unmarshal(data, &snaps); err != nil {
+		t.Fatalf("report %s: %v", data, err)
+	}
+	var got []string
+	for _, s := range snaps {
+		got = append(got, s.Account)
+	}
+	if strings.Join(got, " ") != "one two three" {
+		t.Errorf("report of accounts %q, want one two three", got)
+	}
+	if running > 2 {
+		t.Errorf("%d sessions at once, want at most --parallel=2", running)
+	}
+	if containers := server.Containers(); len(containers) != 0 {
+		t.Errorf("containers left after snapshot-all: %+v", containers)
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	if data, err := mergeSnapshots("json", nil); string(data) != "[]" || err != nil {
+		t.Errorf("JSON report of no snapshots = %s, %v", data, err)
+	}
+	var snaps []*snapshot.Snapshot
+	for _, account := range []string{"U1111111", "U2222222"} {
+		snaps = append(snaps, &snapshot.Snapshot{Account: account, Timestamp: testTime, Positions: []snapshot.Position{
+			{Symbol: "VT", Quantity: snapshot.NewDecimal(10, 0), Currency: "USD"},
+		}})
+	}
+	data, err := mergeSnapshots("csv", snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := snapshot.Marshal("csv", snaps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(single), "\n")
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if strings.Count(string(data), header) != 1 || lines[0] != header {
+		t.Errorf("CSV report %q, want the header %q once, first", data, header)
+	}
+	if len(lines) != 3 || !strings.Contains(lines[1], "U1111111") || !strings.Contains(lines[2], "U2222222") {
+		t.Errorf("CSV report rows %q, want one for each account in order", lines[1:])
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// leakSample counts what a long-running daemon should not accumulate.
+// Negative counts could not be taken. Containers leaves out the session's
+// own, which is missing between a kill and the restart replacing it.
+type leakSample struct {
+	Goroutines int
+	FDs        int
+	Containers int
+}
+
+func (s leakSample) String() string {
+	return fmt.Sprintf("%d goroutines, %d fds, %d containers", s.Goroutines, s.FDs, s.Containers)
+}
+
+// takeLeakSample counts this process's goroutines and open files, and unless
+// session is nil, the ibcontroller containers on the Docker host besides the
+// one whose ID it returns.
+func takeLeakSample(ctx context.Context, session func() string) leakSample {
+	s := leakSample{Goroutines: runtime.NumGoroutine(), FDs: -1, Containers: -1}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		s.FDs = len(fds)
+	}
+	if session != nil {
+		if ids, err := ibdock.Containers(ctx); err == nil {
+			s.Containers = len(slices.DeleteFunc(ids, func(id string) bool { return id == session() }))
+		} else {
+			logger.Println("Cannot count containers:", err)
+		}
+	}
+	return s
+}
+
+// leaks lists how s grew past baseline by more than the allowed slack.
+// Containers get no slack: restarts must replace them, not add to them.
+func (s leakSample) leaks(baseline leakSample, goroutineSlack, fdSlack int) []string {
+	var leaks []string
+	for _, c := range []struct {
+		what          string
+		before, after int
+		slack         int
+	}{
+		{"goroutines", baseline.Goroutines, s.Goroutines, goroutineSlack},
+		{"fds", baseline.FDs, s.FDs, fdSlack},
+		{"containers", baseline.Containers, s.Containers, 0},
+	} {
+		if c.before >= 0 && c.after >= 0 && c.after > c.before+c.slack {
+			leaks = append(leaks, fmt.Sprintf("%s grew from %d to %d", c.what, c.before, c.after))
+		}
+	}
+	return leaks
+}
+
+// soak runs the snapshot Manager as serve does for hours, killing the session
+// at random to exercise its restarts, and fails if the process or Docker host
+// accumulates goroutines, files or containers, or snapshots stop succeeding.
+// It is for trying a release before leaving it unattended, so injected
+// restarts need a paper account or --mock.
+func soak(args []string) error {
+	flags := flag.NewFlagSet("soak", flag.ExitOnError)
+	creds := credentialFlags(flags)
+	hours := flags.Float64("hours", 24, "How long to run")
+	interval := flags.Duration("interval", 10*time.Minute, "Interval between scheduled snapshots")
+	startupDelay := flags.Duration("startup_delay", time.Minute, "Time to let the gateway log in before the first snapshot")
+	restartAfter := flags.Int("restart_after", 1, "Failed snapshots in a row after which the container is replaced")
+	restartEvery := flags.Duration("restart_every", time.Hour, "Mean time between injected session kills, at random; 0 injects none")
+	sampleEvery := flags.Duration("sample_every", 10*time.Minute, "How often to count goroutines, fds and containers")
+	warmup := flags.Duration("warmup", 30*time.Minute, "How long to run before taking the baseline counts")
+	goroutineSlack := flags.Int("goroutine_slack", 20, "How many goroutines over the baseline count as a leak")
+	fdSlack := flags.Int("fd_slack", 20, "How many open files over the baseline count as a leak")
+	mock := flags.String("mock", "", "Comma-separated snapshot files to serve in turn instead of starting a gateway")
+	mockFormat := flags.String("mock_format", "json", "Format of the --mock files")
+	attempts := flags.Int("attempts", 1, "Tries per container start and snapshot when they fail transiently, see ibdock.Retryable")
+	flags.Parse(args)
+	if *hours <= 0 {
+		return errors.New("--hours must be positive")
+	}
+	if *warmup >= time.Duration(*hours*float64(time.Hour)) {
+		return errors.New("--warmup must be shorter than --hours")
+	}
+
+	// Signals during the start are handled too, so the container it
+	// creates is stopped again.
+	ctx, stop := interruptContext()
+	defer stop()
+	d := &daemon{logger: logger}
+	// session stays nil with --mock, which has no containers to count.
+	var session func() string
+	if *mock != "" {
+		d.start = func() (ibdock.Session, error) {
+			return ibdock.LoadMockDock(*mockFormat, strings.Split(*mock, ",")...)
+		}
+	} else {
+		c, err := creds()
+		if err != nil {
+			return err
+		}
+		retry := ibdock.RetryPolicy{MaxAttempts: *attempts}
+		var sessionID atomic.Value // string
+		sessionID.Store("")
+		session = func() string { return sessionID.Load().(string) }
+		d.start = func() (ibdock.Session, error) {
+			var dock *ibdock.Dock
+			err := retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				dock, err = ibdock.StartNewContext(ctx, c.Login, c.Password, logger, c.options...)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			if *restartEvery > 0 && dock.TradingMode() != "paper" {
+				dock.Kill()
+				return nil, errors.New("injected restarts would kill a live session; use a paper account, --mock or --restart_every=0")
+			}
+			sessionID.Store(dock.ContainerID())
+			return ibdock.WithRetry(dock, retry), nil
+		}
+	}
+	d.lastError.Store("")
+	var err error
+	if d.dock, err = d.start(); err != nil {
+		return err
+	}
+	d.manager = ibdock.NewManager(d.takeSnapshot, ibdock.ManagerOptions{
+		Interval:     *interval,
+		Restart:      d.restart,
+		RestartAfter: *restartAfter,
+		Hooks:        d.hooks(),
+	}, logger)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*hours*float64(time.Hour)))
+	defer cancel()
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+	go func() {
+		select {
+		case <-time.After(*startupDelay):
+			d.manager.Run(runCtx)
+		case <-ctx.Done():
+		}
+	}()
+
+	// nextKill is nil when no restarts are injected.
+	var nextKill <-chan time.Time
+	scheduleKill := func() {
+		if *restartEvery > 0 {
+			nextKill = time.After(time.Duration(rand.ExpFloat64() * float64(*restartEvery)))
+		}
+	}
+	scheduleKill()
+	sampler := time.NewTicker(*sampleEvery)
+	defer sampler.Stop()
+	warmedUp := time.After(*warmup)
+	var baseline, peak leakSample
+	haveBaseline := false
+	injected := 0
+	var regressions []string
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-nextKill:
+			logger.Println("Soak: injecting a session kill")
+			d.current().Kill()
+			injected++
+			scheduleKill()
+		case <-warmedUp:
+			baseline = takeLeakSample(ctx, session)
+			peak = baseline
+			haveBaseline = true
+			logger.Println("Soak: baseline", baseline)
+		case <-sampler.C:
+			s := takeLeakSample(ctx, session)
+			if haveBaseline {
+				peak = leakSample{max(peak.Goroutines, s.Goroutines), max(peak.FDs, s.FDs), max(peak.Containers, s.Containers)}
+			}
+			logger.Printf("Soak: %s; %d snapshots, %d failures, %d restarts", s, d.snapshots.Load(), d.failures.Load(), d.restarts.Load())
+		}
+	}
+	interrupted := ctx.Err() == context.Canceled
+
+	// Counted before shutting down, which would release whatever leaked.
+	final := takeLeakSample(context.Background(), session)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancelDrain()
+	if err := d.manager.Shutdown(drainCtx); err != nil {
+		cancelRuns()
+	}
+	if haveBaseline {
+		regressions = append(regressions, final.leaks(baseline, *goroutineSlack, *fdSlack)...)
+	}
+	if d.snapshots.Load() == 0 {
+		regressions = append(regressions, "no snapshot succeeded")
+	} else if since := time.Since(time.Unix(d.lastSuccess.Load(), 0)); since > 2**interval+*startupDelay+time.Second {
+		// lastSuccess is in whole seconds.
+		msg, _ := d.lastError.Load().(string)
+		regressions = append(regressions, fmt.Sprintf("no snapshot succeeded in the last %v: %s", since.Round(time.Second), msg))
+	}
+	dock := d.current()
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelStop()
+	if err := dock.Stop(stopCtx); err != nil {
+		dock.Kill()
+	}
+
+	fmt.Printf("snapshots %d, failures %d, restarts %d, injected kills %d\n", d.snapshots.Load(), d.failures.Load(), d.restarts.Load(), injected)
+	if haveBaseline {
+		fmt.Printf("baseline: %s\npeak:     %s\nfinal:    %s\n", baseline, peak, final)
+	}
+	if len(regressions) > 0 {
+		return fmt.Errorf("soak failed: %s", strings.Join(regressions, "; "))
+	}
+	if interrupted {
+		return errors.New("soak interrupted")
+	}
+	fmt.Println("soak passed")
+	return nil
+}
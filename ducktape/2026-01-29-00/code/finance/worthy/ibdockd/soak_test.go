@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/agentydragon/worthy/ibdock/ibdocktest"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLeaks(t *testing.T) {
+	baseline := leakSample{Goroutines: 50, FDs: 10, Containers: 1}
+	for _, test := range []struct {
+		name   string
+		sample leakSample
+		want   []string
+	}{
+		{"within slack", leakSample{Goroutines: 55, FDs: 15, Containers: 1}, nil},
+		{"shrunk", leakSample{Goroutines: 10, FDs: 5, Containers: 0}, nil},
+		{"goroutines", leakSample{Goroutines: 61, FDs: 10, Containers: 1}, []string{"goroutines grew from 50 to 61"}},
+		{"everything", leakSample{Goroutines: 61, FDs: 21, Containers: 2}, []string{"goroutines grew from 50 to 61", "fds grew from 10 to 21", "containers grew from 1 to 2"}},
+		// Counts that could not be taken are not compared.
+		{"uncounted", leakSample{Goroutines: 50, FDs: -1, Containers: -1}, nil},
+	} {
+		if got := test.sample.leaks(baseline, 10, 10); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: leaks = %q, want %q", test.name, got, test.want)
+		}
+	}
+	if got := (leakSample{Goroutines: 100, FDs: 100, Containers: 5}).leaks(leakSample{-1, -1, -1}, 0, 0); got != nil {
+		t.Errorf("leaks over an uncounted baseline = %q", got)
+	}
+}
+
+// soakConfig writes an ibdock config for a session in mode, paced for a
+// snapshot every few milliseconds, and points Docker at server.
+func soakConfig(t *testing.T, server *ibdocktest.Server, mode string) string {
+	t.Helper()
+	t.Setenv("DOCKER_HOST", server.URL())
+	t.Setenv("IB_LOGIN_ID", "")
+	t.Setenv("IB_PASSWORD", "")
+	config := filepath.Join(t.TempDir(), "ibdock.yaml")
+	if err := os.WriteFile(config, []byte("mode: "+mode+"\nrate_limit: {rate: 1000, burst: 100}\naccounts:\n  - name: main\n    username: jdoe\n    password: secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// soakFor runs soak for about a second against config with a snapshot
+// every 20ms, so that it samples, kills and restarts the session a few
+// times. The slack is tight enough to catch a connection leaked per
+// restart.
+func soakFor(config string, restartEvery string) error {
+	return soak([]string{
+		"--config", config, "--account", "main",
+		"--hours", "0.0003",
+		"--interval", "20ms",
+		"--startup_delay", "0",
+		"--restart_every", restartEvery,
+		"--sample_every", "50ms",
+		"--warmup", "200ms",
+		"--goroutine_slack", "5",
+		"--fd_slack", "5",
+	})
+}
+
+func TestSoak(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.Snapshot(&snapshot.Snapshot{Account: "U1111111"})
+	if err := soakFor(soakConfig(t, server, "paper"), "150ms"); err != nil {
+		t.Errorf("soak of a healthy session: %v", err)
+	}
+	if containers := server.Containers(); len(containers) != 0 {
+		t.Errorf("containers left after soak: %+v", containers)
+	}
+}
+
+func TestSoakFailures(t *testing.T) {
+	server := ibdocktest.NewServer()
+	defer server.Close()
+	server.HandleExec(func(ibdocktest.Exec) ibdocktest.Result {
+		return ibdocktest.Result{Stderr: []byte("not logged in"), ExitCode: 1}
+	})
+	if err := soakFor(soakConfig(t, server, "paper"), "0"); err == nil || !strings.Contains(err.Error(), "no snapshot succeeded") {
+		t.Errorf("soak without a successful snapshot = %v", err)
+	}
+
+	// Injected kills would log a live account out.
+	if err := soakFor(soakConfig(t, server, "live"), "150ms"); err == nil || !strings.Contains(err.Error(), "live session") {
+		t.Errorf("soak of a live session = %v", err)
+	}
+	if containers := server.Containers(); len(containers) != 0 {
+		t.Errorf("containers left after refusing a live session: %+v", containers)
+	}
+}
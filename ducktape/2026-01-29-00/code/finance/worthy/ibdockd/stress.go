@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/agentydragon/worthy/ibdock"
+	"os"
+	"text/tabwriter"
+)
+
+// stress revalues an account's latest stored snapshot under the scenarios of
+// an ibdock config file, without touching a gateway.
+func stress(args []string) error {
+	flags := flag.NewFlagSet("stress", flag.ExitOnError)
+	open := storeFlags(flags)
+	configFile := flags.String("config", "", "YAML or TOML config file with a stress section, see ibdock.Config")
+	account := flags.String("ib_account", "", "IB account ID to stress test, e.g. U1234567")
+	detail := flags.Int("detail", 0, "How many of the largest losses to list per scenario")
+	printer := printerFlags(flags)
+	flags.Parse(args)
+	p, err := printer()
+	if err != nil {
+		return err
+	}
+	if *configFile == "" || *account == "" {
+		return errors.New("--config and --ib_account are required")
+	}
+	config, err := ibdock.LoadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if config.Stress.Base == "" || len(config.Stress.Scenarios) == 0 {
+		return fmt.Errorf("%s: stress needs a base currency and scenarios", *configFile)
+	}
+	s, err := open()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	latest, err := s.Latest(context.Background(), *account)
+	if err != nil {
+		return err
+	}
+	results, err := latest.Stress(config.Stress.Scenarios, config.Stress.Rates())
+	if err != nil {
+		return err
+	}
+	fmt.Println(p.Sprintf("%s as of %s", *account, p.DateTime(latest.Timestamp)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\t\n", p.T("Scenario"), p.Sprintf("Value (%s)", config.Stress.Base), p.T("Change"))
+	for _, result := range results {
+		before := result.Value - result.Change
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", result.Scenario, p.Money(result.Value), p.Money(result.Change), p.Percent(result.Change/before))
+		for i, c := range result.Contributions {
+			if i >= *detail || c.Value >= 0 {
+				break
+			}
+			fmt.Fprintf(w, "%s %s\t\t%s\t\t\n", c.SecType, c.Symbol, p.Money(c.Value))
+		}
+	}
+	return w.Flush()
+}
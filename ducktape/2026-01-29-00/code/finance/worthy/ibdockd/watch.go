@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/agentydragon/worthy/ibdock"
+	"github.com/agentydragon/worthy/ibdock/snapshot"
+	"os"
+	"time"
+)
+
+// watchLine is one line of watch's output.
+type watchLine struct {
+	Time     time.Time
+	Snapshot *snapshot.Snapshot `json:",omitempty"`
+	Changes  snapshot.Changes
+	Err      string `json:",omitempty"`
+}
+
+// watch prints a running session's position changes as they happen, one
+// JSON object a line, until interrupted. The first line has every position.
+func watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	container := containerFlags(flags)
+	interval := flags.Duration("interval", time.Minute, "How often to poll the account")
+	flags.Parse(args)
+	dock, err := ibdock.Attach(container(), logger, ibdock.WithWatchInterval(*interval))
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	deltas, err := dock.Watch(ctx)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for delta := range deltas {
+		line := watchLine{Time: time.Now(), Snapshot: delta.Snapshot, Changes: delta.Changes}
+		if delta.Err != nil {
+			line.Err = delta.Err.Error()
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	// Interrupting is how watching ends.
+	return nil
+}